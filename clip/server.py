@@ -28,7 +28,7 @@ logger.debug("CLIP model loaded successfully.")
 
 class CLIPServiceServicer(clip_pb2_grpc.CLIPServiceServicer):
     """
-    Implements the gRPC CLIP service for image embedding.
+    Implements the gRPC CLIP service for image and text embedding.
     """
     def GetImageEmbedding(self, request, context):
         logger.info("Received image embedding request")
@@ -61,6 +61,27 @@ class CLIPServiceServicer(clip_pb2_grpc.CLIPServiceServicer):
         logger.info("Returning embedding vector")
         return clip_pb2.EmbeddingResponse(embedding=embedding_vector)
 
+    def GetTextEmbedding(self, request, context):
+        logger.info("Received text embedding request")
+        if not request.text.strip():
+            context.set_details("Text must not be empty")
+            context.set_code(grpc.StatusCode.INVALID_ARGUMENT)
+            return clip_pb2.EmbeddingResponse()
+
+        # Tokenize the text, truncating it to the model's context length.
+        logger.debug("Tokenizing text")
+        tokens = clip.tokenize([request.text], truncate=True).to(device)
+
+        # Encode the text to obtain its embedding.
+        logger.debug("Encoding text with CLIP model")
+        with torch.no_grad():
+            embedding = model.encode_text(tokens)
+
+        # Remove the batch dimension and convert the tensor to a list.
+        embedding_vector = embedding.squeeze(0).cpu().tolist()
+        logger.info("Returning embedding vector")
+        return clip_pb2.EmbeddingResponse(embedding=embedding_vector)
+
 class HealthCheckHandler(BaseHTTPRequestHandler):
     """
     HTTP handler for health check endpoint.
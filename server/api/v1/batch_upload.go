@@ -0,0 +1,239 @@
+package v1
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Outcomes of a file in a batch upload
+const (
+	BatchUploadCreated   = "created"
+	BatchUploadDuplicate = "duplicate"
+	BatchUploadError     = "error"
+)
+
+// zipSignature opens the local file header that every zip archive begins with
+var zipSignature = []byte("PK\x03\x04")
+
+// BatchUploadResult reports the outcome of one file of a batch upload
+type BatchUploadResult struct {
+	Filename   string `json:"filename"`              // Name of the uploaded file or archive entry
+	Status     string `json:"status"`                // One of created, duplicate or error
+	ID         string `json:"id,omitempty"`          // UUID of the created image
+	ConflictID string `json:"conflict_id,omitempty"` // UUID of the existing image a near-duplicate matched
+	Error      string `json:"error,omitempty"`       // Reason the file was not stored
+}
+
+// batchFileReader reads the contents of one file of a batch, deferred until the file is
+// known to be within the batch limit
+type batchFileReader func() ([]byte, error)
+
+// CreateImageBatch stores several images from one multipart request, given either as
+// repeated "image" parts or as zip archives in them. Each file passes through the same
+// pipeline as CreateImage and is reported on separately, so that partial failures are
+// visible. An optional "metadata" part applies to every image and must precede them.
+// Parts are read as they arrive, and archives are spooled to a temporary file, so only
+// one image is held in memory at a time.
+func (h *ImageHandler) CreateImageBatch(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	reader, err := c.Request().MultipartReader()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Expected multipart form data")
+	}
+
+	maxFiles := h.container.Config.BatchUploadMaxFiles
+	maxBytes := h.container.Config.BatchUploadMaxFileBytes
+	maxArchiveBytes := h.container.Config.BatchUploadMaxArchiveBytes
+
+	var metadata ImageMetadataRequest
+	results := []BatchUploadResult{}
+
+	// Only files count towards the limit, not rows reporting misplaced metadata or
+	// unreadable archives
+	files := 0
+	add := func(filename string, read batchFileReader) {
+		result := BatchUploadResult{Filename: filename, Status: BatchUploadError}
+		if files >= maxFiles {
+			result.Error = fmt.Sprintf("Batch exceeds the limit of %d files", maxFiles)
+			results = append(results, result)
+			return
+		}
+
+		files++
+		if data, err := read(); err != nil {
+			result.Error = err.Error()
+		} else {
+			result = h.storeBatchFile(ctx, filename, data, metadata)
+		}
+		results = append(results, result)
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Error parsing form: "+err.Error())
+		}
+
+		switch part.FormName() {
+		case "metadata":
+			// Files already stored cannot take metadata sent after them
+			if files > 0 {
+				results = append(results, BatchUploadResult{
+					Filename: "metadata",
+					Status:   BatchUploadError,
+					Error:    "Metadata must precede the images it applies to",
+				})
+				break
+			}
+
+			metadata = ImageMetadataRequest{}
+			if err := json.NewDecoder(part).Decode(&metadata); err != nil {
+				part.Close()
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid metadata JSON: "+err.Error())
+			}
+		case "image":
+			buffered := bufio.NewReader(part)
+			if signature, _ := buffered.Peek(len(zipSignature)); bytes.Equal(signature, zipSignature) {
+				if err := addZipEntries(buffered, maxArchiveBytes, maxBytes, add); err != nil {
+					results = append(results, BatchUploadResult{
+						Filename: part.FileName(),
+						Status:   BatchUploadError,
+						Error:    err.Error(),
+					})
+				}
+			} else {
+				add(part.FileName(), func() ([]byte, error) {
+					return readBatchFile(buffered, maxBytes)
+				})
+			}
+		}
+
+		part.Close()
+	}
+
+	if len(results) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "No images were uploaded")
+	}
+
+	return c.JSON(http.StatusOK, results)
+}
+
+// storeBatchFile runs one file of a batch through the upload pipeline, translating a
+// failure into its result
+func (h *ImageHandler) storeBatchFile(ctx context.Context, filename string, data []byte, metadata ImageMetadataRequest) BatchUploadResult {
+	result := BatchUploadResult{Filename: filename}
+
+	h.deriveTitle(&metadata, filename)
+
+	err := h.checkIngestionPolicy(metadata)
+	if err == nil {
+		imageModel, storeErr := h.storeImage(ctx, data, filename, metadata)
+		if storeErr == nil {
+			result.Status = BatchUploadCreated
+			result.ID = imageModel.UUID
+			return result
+		}
+		err = storeErr
+	}
+
+	result.Status = BatchUploadError
+
+	var httpErr *echo.HTTPError
+	if !errors.As(err, &httpErr) {
+		result.Error = err.Error()
+		return result
+	}
+
+	if httpErr.Code == http.StatusConflict {
+		result.Status = BatchUploadDuplicate
+	}
+
+	switch message := httpErr.Message.(type) {
+	case string:
+		result.Error = message
+	case map[string]any:
+		result.Error, _ = message["error"].(string)
+		result.ConflictID, _ = message["conflict_id"].(string)
+	default:
+		result.Error = fmt.Sprint(message)
+	}
+
+	return result
+}
+
+// addZipEntries adds each file of a zip archive to a batch. The directory of an archive
+// is stored at its end, so the archive is first spooled to a temporary file, refusing
+// archives larger than maxArchiveBytes. Directories and the hidden files and resource
+// forks that archivers add are skipped.
+func addZipEntries(r io.Reader, maxArchiveBytes int64, maxBytes int64, add func(string, batchFileReader)) error {
+	spool, err := os.CreateTemp("", "curator-batch-*.zip")
+	if err != nil {
+		return fmt.Errorf("unable to buffer archive: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err := io.Copy(spool, io.LimitReader(r, maxArchiveBytes+1))
+	if err != nil {
+		return fmt.Errorf("unable to buffer archive: %w", err)
+	}
+	if size > maxArchiveBytes {
+		return fmt.Errorf("archive exceeds the limit of %d bytes", maxArchiveBytes)
+	}
+
+	archive, err := zip.NewReader(spool, size)
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	for _, entry := range archive.File {
+		name := path.Base(entry.Name)
+		if entry.FileInfo().IsDir() || strings.HasPrefix(name, ".") || strings.HasPrefix(entry.Name, "__MACOSX/") {
+			continue
+		}
+
+		add(name, func() ([]byte, error) {
+			if entry.UncompressedSize64 > uint64(maxBytes) {
+				return nil, fmt.Errorf("file exceeds the limit of %d bytes", maxBytes)
+			}
+
+			file, err := entry.Open()
+			if err != nil {
+				return nil, fmt.Errorf("unable to read archive entry: %w", err)
+			}
+			defer file.Close()
+
+			return readBatchFile(file, maxBytes)
+		})
+	}
+
+	return nil
+}
+
+// readBatchFile reads a file of a batch, refusing files larger than maxBytes
+func readBatchFile(r io.Reader, maxBytes int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("file exceeds the limit of %d bytes", maxBytes)
+	}
+	return data, nil
+}
@@ -0,0 +1,28 @@
+package dtos
+
+import "github.com/foresturquhart/curator/server/models"
+
+// CollectionOrderRequest reorders a collection, either by listing every image in its new
+// order or by applying a sequence of moves
+type CollectionOrderRequest struct {
+	ImageIDs []string                `json:"image_ids,omitempty" validate:"omitempty,unique,dive,uuid"`
+	Moves    []CollectionMoveRequest `json:"moves,omitempty" validate:"omitempty,dive"`
+}
+
+// CollectionMoveRequest moves an image to directly after another, or to the front when
+// no preceding image is given
+type CollectionMoveRequest struct {
+	ImageID string  `json:"image_id" validate:"required,uuid"`
+	AfterID *string `json:"after_id,omitempty" validate:"omitempty,uuid"`
+}
+
+func (r *CollectionOrderRequest) ToMoves() []models.CollectionMove {
+	moves := make([]models.CollectionMove, len(r.Moves))
+	for i, move := range r.Moves {
+		moves[i] = models.CollectionMove{
+			ImageUUID: move.ImageID,
+			AfterUUID: move.AfterID,
+		}
+	}
+	return moves
+}
@@ -0,0 +1,145 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/foresturquhart/curator/server/models"
+)
+
+type ImageResponse struct {
+	ID          string                `json:"id"`
+	Filename    string                `json:"filename"`
+	MD5         string                `json:"md5"`
+	SHA1        string                `json:"sha1"`
+	Width       int                   `json:"width"`
+	Height      int                   `json:"height"`
+	Format      models.ImageFormat    `json:"format"`
+	Size        int64                 `json:"size"`
+	Title       *string               `json:"title"`
+	Description *string               `json:"description"`
+	Rating      *int                  `json:"rating"`
+	CameraMake  *string               `json:"camera_make"`
+	CameraModel *string               `json:"camera_model"`
+	PHash       *string               `json:"phash"`
+	Language    *string               `json:"language"`
+	ViewCount   int64                 `json:"view_count"`
+	CreatedAt   time.Time             `json:"created_at"`
+	UpdatedAt   time.Time             `json:"updated_at"`
+	Tags        []ImageTagResponse    `json:"tags"`
+	People      []ImagePersonResponse `json:"people"`
+	Sources     []ImageSourceResponse `json:"sources"`
+	URL         *string               `json:"url,omitempty"`
+
+	// HasThumbnail reports whether a thumbnail has been stored
+	HasThumbnail bool `json:"has_thumbnail"`
+
+	// SkippedAssociations lists the tags and people a lenient write left out
+	SkippedAssociations []*models.SkippedAssociation `json:"skipped_associations,omitempty"`
+}
+
+type ImageTagResponse struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+type ImagePersonResponse struct {
+	ID      string            `json:"id"`
+	Name    string            `json:"name"`
+	Role    models.PersonRole `json:"role"`
+	AddedAt time.Time         `json:"added_at"`
+}
+
+type ImageSourceResponse struct {
+	URL         string  `json:"url"`
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	IsPrimary   bool    `json:"is_primary"`
+}
+
+// ImageTagsResponse separates the tags assigned to an image from those it inherits as
+// ancestors of its assigned tags
+type ImageTagsResponse struct {
+	Direct    []ImageTagResponse `json:"direct"`
+	Inherited []ImageTagResponse `json:"inherited"`
+}
+
+func ImageTagsFromModels(direct []*models.ImageTag, inherited []*models.ImageTag) *ImageTagsResponse {
+	return &ImageTagsResponse{
+		Direct:    imageTagsFromModels(direct),
+		Inherited: imageTagsFromModels(inherited),
+	}
+}
+
+func imageTagsFromModels(imageTags []*models.ImageTag) []ImageTagResponse {
+	tags := make([]ImageTagResponse, len(imageTags))
+	for i, tag := range imageTags {
+		tags[i] = ImageTagResponse{
+			ID:      tag.UUID,
+			Name:    tag.Name,
+			AddedAt: tag.AddedAt,
+		}
+	}
+	return tags
+}
+
+func ImageFromModel(image *models.Image) *ImageResponse {
+	tags := imageTagsFromModels(image.Tags)
+
+	people := make([]ImagePersonResponse, len(image.People))
+	for i, person := range image.People {
+		people[i] = ImagePersonResponse{
+			ID:      person.UUID,
+			Name:    person.Name,
+			Role:    person.Role,
+			AddedAt: person.AddedAt,
+		}
+	}
+
+	sources := make([]ImageSourceResponse, len(image.Sources))
+	for i, src := range image.Sources {
+		sources[i] = ImageSourceResponse{
+			URL:         src.URL,
+			Title:       src.Title,
+			Description: src.Description,
+			IsPrimary:   src.IsPrimary,
+		}
+	}
+
+	return &ImageResponse{
+		ID:          image.UUID,
+		Filename:    image.Filename,
+		MD5:         image.MD5,
+		SHA1:        image.SHA1,
+		Width:       image.Width,
+		Height:      image.Height,
+		Format:      image.Format,
+		Size:        image.Size,
+		Title:       image.Title,
+		Description: image.Description,
+		Rating:      image.Rating,
+		CameraMake:  image.CameraMake,
+		CameraModel: image.CameraModel,
+		PHash:       image.PHashHex(),
+		Language:    image.Language,
+		ViewCount:   image.ViewCount,
+		CreatedAt:   image.CreatedAt,
+		UpdatedAt:   image.UpdatedAt,
+		Tags:        tags,
+		People:      people,
+		Sources:     sources,
+		URL:         image.URL,
+
+		HasThumbnail:        image.HasThumbnail,
+		SkippedAssociations: image.SkippedAssociations,
+	}
+}
+
+// ImagesFromModels converts a list of images into responses
+func ImagesFromModels(images []*models.Image) []*ImageResponse {
+	responses := make([]*ImageResponse, len(images))
+	for i, image := range images {
+		responses[i] = ImageFromModel(image)
+	}
+	return responses
+}
@@ -1,10 +1,12 @@
 package dtos
 
 import (
+	"errors"
 	"time"
 
 	"github.com/foresturquhart/curator/server/models"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 )
 
 var Validate = validator.New()
@@ -12,6 +14,9 @@ var Validate = validator.New()
 type PersonCreateRequest struct {
 	Name        string                `json:"name" validate:"required,min=1"`
 	Description *string               `json:"description,omitempty"`
+	Aliases     []string              `json:"aliases,omitempty" validate:"dive,required"`
+	ActiveSince *string               `json:"active_since,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	ActiveUntil *string               `json:"active_until,omitempty" validate:"omitempty,datetime=2006-01-02"`
 	Sources     []PersonSourceRequest `json:"sources,omitempty" validate:"dive"`
 }
 
@@ -27,6 +32,9 @@ func (r *PersonCreateRequest) ToModel() *models.Person {
 	return &models.Person{
 		Name:        r.Name,
 		Description: r.Description,
+		Aliases:     r.Aliases,
+		ActiveSince: parseDate(r.ActiveSince),
+		ActiveUntil: parseDate(r.ActiveUntil),
 		Sources:     sources,
 	}
 }
@@ -34,7 +42,28 @@ func (r *PersonCreateRequest) ToModel() *models.Person {
 type PersonUpdateRequest struct {
 	Name        *string               `json:"name,omitempty" validate:"omitempty,min=1"`
 	Description *string               `json:"description,omitempty"`
+	Aliases     []string              `json:"aliases,omitempty" validate:"dive,required"`
+	ActiveSince *string               `json:"active_since,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	ActiveUntil *string               `json:"active_until,omitempty" validate:"omitempty,datetime=2006-01-02"`
 	Sources     []PersonSourceRequest `json:"sources,omitempty" validate:"dive"`
+
+	// PrimaryImageID chooses the image representing the person, an empty string clears it.
+	// It is checked by ValidatePrimaryImageID, as the validator would reject the empty
+	// string a non-nil pointer refers to.
+	PrimaryImageID *string `json:"primary_image_id,omitempty"`
+}
+
+// ValidatePrimaryImageID checks that a primary image ID, unless empty, is a UUID
+func (r *PersonUpdateRequest) ValidatePrimaryImageID() error {
+	if r.PrimaryImageID == nil || *r.PrimaryImageID == "" {
+		return nil
+	}
+
+	if _, err := uuid.Parse(*r.PrimaryImageID); err != nil {
+		return errors.New("primary_image_id must be a UUID or empty")
+	}
+
+	return nil
 }
 
 func (r *PersonUpdateRequest) UpdateModel(person *models.Person) {
@@ -44,6 +73,16 @@ func (r *PersonUpdateRequest) UpdateModel(person *models.Person) {
 	if r.Description != nil {
 		person.Description = r.Description
 	}
+	if r.Aliases != nil {
+		person.Aliases = r.Aliases
+	}
+	// An empty string clears an active period bound
+	if r.ActiveSince != nil {
+		person.ActiveSince = parseDate(r.ActiveSince)
+	}
+	if r.ActiveUntil != nil {
+		person.ActiveUntil = parseDate(r.ActiveUntil)
+	}
 	if r.Sources != nil {
 		sources := make([]*models.PersonSource, len(r.Sources))
 		for i, src := range r.Sources {
@@ -55,11 +94,19 @@ func (r *PersonUpdateRequest) UpdateModel(person *models.Person) {
 		}
 		person.Sources = sources
 	}
+	if r.PrimaryImageID != nil {
+		if *r.PrimaryImageID == "" {
+			person.PrimaryImageUUID = nil
+		} else {
+			person.PrimaryImageUUID = r.PrimaryImageID
+		}
+	}
 }
 
 type PersonListRequest struct {
 	Limit         *int    `query:"limit"`
 	StartingAfter *string `query:"starting_after"`
+	EndingBefore  *string `query:"ending_before"`
 	SortBy        *string `query:"sort_by"`
 	SortDirection *string `query:"sort_direction"`
 }
@@ -68,10 +115,13 @@ type PersonSearchRequest struct {
 	Name          *string `json:"name" validate:"omitempty,min=1"`
 	Description   *string `json:"description" validate:"omitempty"`
 	Source        *string `json:"source" validate:"omitempty"`
-	SinceDate     *string `json:"since_date" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
-	BeforeDate    *string `json:"before_date" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
-	Limit         *int    `json:"limit" validate:"omitempty,min=1"`
+	SinceDate     *string `json:"since_date"`
+	BeforeDate    *string `json:"before_date"`
+	ActiveFrom    *string `json:"active_from" validate:"omitempty,datetime=2006-01-02"`
+	ActiveTo      *string `json:"active_to" validate:"omitempty,datetime=2006-01-02"`
+	Limit         *int    `json:"limit" validate:"omitempty,min=1,max=100"`
 	StartingAfter *string `json:"starting_after" validate:"omitempty"`
+	EndingBefore  *string `json:"ending_before" validate:"omitempty"`
 	SortBy        *string `json:"sort_by" validate:"omitempty,oneof=relevance created_at name creator_count subject_count"`
 	SortDirection *string `json:"sort_direction" validate:"omitempty,oneof=asc desc"`
 }
@@ -80,9 +130,25 @@ type PersonResponse struct {
 	ID          string                 `json:"id"`
 	Name        string                 `json:"name"`
 	Description *string                `json:"description,omitempty"`
+	Aliases     []string               `json:"aliases,omitempty"`
+	ActiveSince *string                `json:"active_since,omitempty"`
+	ActiveUntil *string                `json:"active_until,omitempty"`
 	CreatedAt   time.Time              `json:"created_at"`
 	UpdatedAt   time.Time              `json:"updated_at"`
 	Sources     []PersonSourceResponse `json:"sources,omitempty"`
+
+	// PrimaryImageID is the explicitly chosen image, while PrimaryImage is the image
+	// shown for the person, falling back to the most recently associated image
+	PrimaryImageID *string                     `json:"primary_image_id,omitempty"`
+	PrimaryImage   *PersonPrimaryImageResponse `json:"primary_image,omitempty"`
+
+	// PreviewImages are the most recently associated images, included on request
+	PreviewImages []PersonPrimaryImageResponse `json:"preview_images,omitempty"`
+}
+
+type PersonPrimaryImageResponse struct {
+	ID  string  `json:"id"`
+	URL *string `json:"url,omitempty"`
 }
 
 func FromModel(person *models.Person) *PersonResponse {
@@ -94,14 +160,56 @@ func FromModel(person *models.Person) *PersonResponse {
 			Description: src.Description,
 		}
 	}
-	return &PersonResponse{
-		ID:          person.UUID,
-		Name:        person.Name,
-		Description: person.Description,
-		CreatedAt:   person.CreatedAt,
-		UpdatedAt:   person.UpdatedAt,
-		Sources:     sources,
+	response := &PersonResponse{
+		ID:             person.UUID,
+		Name:           person.Name,
+		Description:    person.Description,
+		Aliases:        person.Aliases,
+		ActiveSince:    formatDate(person.ActiveSince),
+		ActiveUntil:    formatDate(person.ActiveUntil),
+		CreatedAt:      person.CreatedAt,
+		UpdatedAt:      person.UpdatedAt,
+		Sources:        sources,
+		PrimaryImageID: person.PrimaryImageUUID,
+	}
+	if person.PrimaryImage != nil {
+		response.PrimaryImage = &PersonPrimaryImageResponse{
+			ID:  person.PrimaryImage.UUID,
+			URL: person.PrimaryImage.URL,
+		}
+	}
+	for _, preview := range person.PreviewImages {
+		response.PreviewImages = append(response.PreviewImages, PersonPrimaryImageResponse{
+			ID:  preview.UUID,
+			URL: preview.URL,
+		})
+	}
+	return response
+}
+
+// parseDate converts an already validated date-only string into a time, treating
+// missing or empty values as unset
+func parseDate(value *string) *time.Time {
+	if value == nil || *value == "" {
+		return nil
 	}
+
+	parsed, err := time.Parse(time.DateOnly, *value)
+	if err != nil {
+		return nil
+	}
+
+	return &parsed
+}
+
+// formatDate renders an optional time as a date-only string
+func formatDate(value *time.Time) *string {
+	if value == nil {
+		return nil
+	}
+
+	formatted := value.Format(time.DateOnly)
+	return &formatted
 }
 
 type PersonSourceRequest struct {
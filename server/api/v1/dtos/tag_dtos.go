@@ -0,0 +1,59 @@
+package dtos
+
+// TagEnsurePathRequest names a chain of tags from the root of the hierarchy down to the
+// tag that should exist
+type TagEnsurePathRequest struct {
+	Path []string `json:"path" validate:"required,min=1,dive,required"`
+}
+
+// TagBulkMoveRequest moves several tags inside a new parent, or to the root when no
+// parent is given
+type TagBulkMoveRequest struct {
+	TagIDs   []string `json:"tag_ids" validate:"required,min=1,dive,uuid"`
+	ParentID *string  `json:"parent_id" validate:"omitempty,uuid"`
+}
+
+// TagCreateRequest creates a tag at the root of the hierarchy, or placed relative to a
+// target tag: inside it as its last child, or before or after it among its siblings
+type TagCreateRequest struct {
+	Name        string  `json:"name" validate:"required,min=1"`
+	Description *string `json:"description,omitempty"`
+	Action      *string `json:"action,omitempty" validate:"omitempty,oneof=root inside before after"`
+	TargetID    *string `json:"target_id,omitempty" validate:"omitempty,uuid"`
+}
+
+// TagUpdateRequest changes the name or description of a tag, and optionally moves it
+// relative to a target tag in the same way a tag is placed when created
+type TagUpdateRequest struct {
+	Name        *string `json:"name,omitempty" validate:"omitempty,min=1"`
+	Description *string `json:"description,omitempty"`
+	Action      *string `json:"action,omitempty" validate:"omitempty,oneof=root inside before after"`
+	TargetID    *string `json:"target_id,omitempty" validate:"omitempty,uuid"`
+}
+
+type TagListRequest struct {
+	Limit         *int    `query:"limit"`
+	StartingAfter *string `query:"starting_after"`
+	SortBy        *string `query:"sort_by"`
+	SortDirection *string `query:"sort_direction"`
+}
+
+type TagSearchRequest struct {
+	Name          *string `json:"name" validate:"omitempty,min=1"`
+	Description   *string `json:"description" validate:"omitempty"`
+	SinceDate     *string `json:"since_date"`
+	BeforeDate    *string `json:"before_date"`
+	Limit         *int    `json:"limit" validate:"omitempty,min=1,max=100"`
+	StartingAfter *string `json:"starting_after" validate:"omitempty"`
+	SortBy        *string `json:"sort_by" validate:"omitempty,oneof=relevance created_at name"`
+	SortDirection *string `json:"sort_direction" validate:"omitempty,oneof=asc desc"`
+}
+
+// TagTreeRequest selects a page of the tag tree. Depth limits the levels returned below
+// the top one, Limit the children returned for each tag, and AfterPosition continues the
+// listing of the top level.
+type TagTreeRequest struct {
+	Depth         *int   `query:"depth" validate:"omitempty,min=0"`
+	Limit         *int   `query:"limit" validate:"omitempty,min=1"`
+	AfterPosition *int32 `query:"after_position"`
+}
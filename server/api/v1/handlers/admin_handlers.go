@@ -0,0 +1,395 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/foresturquhart/curator/server/cache"
+	"github.com/foresturquhart/curator/server/container"
+	"github.com/foresturquhart/curator/server/models"
+	"github.com/foresturquhart/curator/server/repositories"
+	"github.com/foresturquhart/curator/server/storage/indexes"
+	"github.com/foresturquhart/curator/server/tasks"
+	"github.com/foresturquhart/curator/server/utils"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultAdminListLimit = 50
+	maxAdminListLimit     = 1000
+
+	// reindexStreamKeepAlive is the interval between comments sent to keep an idle
+	// progress stream open through proxies
+	reindexStreamKeepAlive = 15 * time.Second
+)
+
+type AdminHandler struct {
+	container       *container.Container
+	imageRepository *repositories.ImageRepository
+	tagRepository   *repositories.TagRepository
+	reindexJobs     *cache.ReindexJobTracker
+}
+
+func NewAdminHandler(c *container.Container, imageRepo *repositories.ImageRepository) *AdminHandler {
+	return &AdminHandler{
+		container:       c,
+		imageRepository: imageRepo,
+		tagRepository:   repositories.NewTagRepository(c),
+		reindexJobs:     cache.NewReindexJobTracker(c),
+	}
+}
+
+// RequireAdminToken guards a route group behind a bearer token. When no token is
+// configured the admin endpoints are disabled entirely rather than left open.
+func RequireAdminToken(token string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if token == "" {
+				return echo.NewHTTPError(http.StatusForbidden, "Admin endpoints are disabled")
+			}
+
+			provided, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid admin token")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// ListImagesWithoutEmbedding reports the images that have no stored embedding, to
+// identify the work set for an embedding backfill. Pages are keyed on the internal
+// image ID, passed back through after_id.
+func (h *AdminHandler) ListImagesWithoutEmbedding(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	limit := defaultAdminListLimit
+	if raw := c.QueryParam("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxAdminListLimit {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxAdminListLimit))
+		}
+		limit = parsed
+	}
+
+	var afterID int64
+	if raw := c.QueryParam("after_id"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "after_id must be a non-negative integer")
+		}
+		afterID = parsed
+	}
+
+	count, err := h.imageRepository.CountWithoutEmbedding(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Error counting images without embedding")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count images without embedding")
+	}
+
+	images, err := h.imageRepository.FindWithoutEmbedding(ctx, limit, afterID)
+	if err != nil {
+		log.Error().Err(err).Msg("Error listing images without embedding")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list images without embedding")
+	}
+
+	data := make([]map[string]any, 0, len(images))
+	for _, image := range images {
+		data = append(data, map[string]any{
+			"internal_id": image.ID,
+			"id":          image.UUID,
+			"filename":    image.Filename,
+			"created_at":  image.CreatedAt,
+		})
+	}
+
+	response := map[string]any{
+		"data":        data,
+		"total_count": count,
+	}
+
+	if len(images) == limit {
+		response["next_after_id"] = images[len(images)-1].ID
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// GenerateThumbnails queues a job that in turn queues thumbnail generation for every
+// image without a thumbnail, or for every image when all=true is given, such as after the
+// thumbnail size changes. Images whose thumbnail generation is still queued, or was kept
+// after failing, are skipped and reported in the worker log.
+func (h *AdminHandler) GenerateThumbnails(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	all := false
+	if raw := c.QueryParam("all"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "all must be true or false")
+		}
+		all = parsed
+	}
+
+	if err := h.container.Worker.EnqueueGenerateThumbnails(ctx, all); err != nil {
+		if errors.Is(err, tasks.ErrAlreadyQueued) {
+			return echo.NewHTTPError(http.StatusConflict, "Thumbnail generation is already queued or running")
+		}
+		log.Error().Err(err).Msg("Error queueing thumbnail generation")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to queue thumbnail generation")
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]any{
+		"status": "queued",
+	})
+}
+
+// GetImageDocument returns the Elasticsearch source indexed for an image, so that
+// differences between the database and the search index can be inspected
+func (h *AdminHandler) GetImageDocument(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	document, err := h.imageRepository.GetDocument(ctx, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, utils.ErrImageNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Image document not found")
+		}
+		log.Error().Err(err).Msg("Error retrieving image document")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve image document")
+	}
+
+	return c.JSONBlob(http.StatusOK, document)
+}
+
+// ReconcileVectors queues a background job that removes orphaned vectors and restores
+// missing ones
+func (h *AdminHandler) ReconcileVectors(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := h.container.Worker.EnqueueReconcileVectors(ctx); err != nil {
+		log.Error().Err(err).Msg("Error queueing vector reconciliation")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to queue vector reconciliation")
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// RebalanceTags queues a background job that renumbers the positions of sibling tags,
+// responding with a conflict while one is already queued or running
+func (h *AdminHandler) RebalanceTags(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := h.container.Worker.EnqueueRebalanceTags(ctx); err != nil {
+		if errors.Is(err, tasks.ErrAlreadyQueued) {
+			return echo.NewHTTPError(http.StatusConflict, "Tag rebalancing is already queued or running")
+		}
+		log.Error().Err(err).Msg("Error queueing tag rebalancing")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to queue tag rebalancing")
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// VerifyTagClosure reports how the tag closure table differs from the tag hierarchy,
+// without correcting it
+func (h *AdminHandler) VerifyTagClosure(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	report, err := h.tagRepository.VerifyClosure(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Error verifying tag closure")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify tag closure")
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// RebuildTagClosure queues a background job that recomputes the tag closure table from
+// the tag hierarchy, responding with a conflict while one is already queued or running
+func (h *AdminHandler) RebuildTagClosure(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := h.container.Worker.EnqueueRebuildTagClosure(ctx); err != nil {
+		if errors.Is(err, tasks.ErrAlreadyQueued) {
+			return echo.NewHTTPError(http.StatusConflict, "Tag closure rebuild is already queued or running")
+		}
+		log.Error().Err(err).Msg("Error queueing tag closure rebuild")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to queue tag closure rebuild")
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// GetTaskSummary counts the pending, active and failed background tasks by task type
+func (h *AdminHandler) GetTaskSummary(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	summary, err := h.container.Worker.Summarize(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Error summarising background tasks")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to summarise background tasks")
+	}
+
+	return c.JSON(http.StatusOK, summary)
+}
+
+// RebuildIndex queues a background job that builds a new version of a search index from
+// its current mapping, indexes every document of that type into it and swaps it in for
+// the live index. The returned job ID can be polled for progress, or used to cancel the
+// job, which leaves the live index untouched. An items_per_second query
+// parameter throttles the job, overriding the configured rate, where zero leaves it
+// unthrottled.
+func (h *AdminHandler) RebuildIndex(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	name := c.Param("name")
+	if _, ok := indexes.Indexes[name]; !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Index not found")
+	}
+
+	itemsPerSecond := h.container.Config.ReindexItemsPerSecond
+	if raw := c.QueryParam("items_per_second"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed < 0 || math.IsInf(parsed, 0) || math.IsNaN(parsed) {
+			return echo.NewHTTPError(http.StatusBadRequest, "items_per_second must be a non-negative number")
+		}
+		itemsPerSecond = parsed
+	}
+
+	jobID := uuid.NewString()
+
+	if err := h.reindexJobs.Create(ctx, jobID, name, itemsPerSecond); err != nil {
+		log.Error().Err(err).Str("index", name).Msg("Error recording index rebuild job")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to queue index rebuild")
+	}
+
+	if err := h.container.Worker.EnqueueRebuildIndex(ctx, jobID, name); err != nil {
+		log.Error().Err(err).Str("index", name).Msg("Error queueing index rebuild")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to queue index rebuild")
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]any{
+		"job_id":           jobID,
+		"index":            name,
+		"items_per_second": itemsPerSecond,
+	})
+}
+
+// CancelReindexJob asks a queued or running reindex job to stop. The new version of the
+// index being rebuilt is discarded, so the live index keeps serving as it was.
+func (h *AdminHandler) CancelReindexJob(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	job, err := h.reindexJobs.Cancel(ctx, c.Param("jobId"))
+	if err != nil {
+		switch {
+		case errors.Is(err, cache.ErrReindexJobNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Reindex job not found")
+		case errors.Is(err, cache.ErrReindexJobDone):
+			return echo.NewHTTPError(http.StatusConflict, "Reindex job has already finished")
+		}
+		log.Error().Err(err).Msg("Error cancelling reindex job")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to cancel reindex job")
+	}
+
+	return c.JSON(http.StatusAccepted, job)
+}
+
+// GetReindexJob reports the progress of a reindex job
+func (h *AdminHandler) GetReindexJob(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	job, err := h.reindexJobs.Get(ctx, c.Param("jobId"))
+	if err != nil {
+		if errors.Is(err, cache.ErrReindexJobNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Reindex job not found")
+		}
+		log.Error().Err(err).Msg("Error retrieving reindex job")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve reindex job")
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+// StreamReindexJob streams the progress of a reindex job as Server-Sent Events. The
+// current state is sent straight away, followed by every change until the job finishes
+// or the client disconnects.
+func (h *AdminHandler) StreamReindexJob(c echo.Context) error {
+	ctx := c.Request().Context()
+	jobID := c.Param("jobId")
+
+	// Subscribe before reading the current state, so that no change falls in between
+	events, unsubscribe, err := h.reindexJobs.Subscribe(ctx, jobID)
+	if err != nil {
+		log.Error().Err(err).Msg("Error subscribing to reindex job")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to follow reindex job")
+	}
+	defer unsubscribe()
+
+	job, err := h.reindexJobs.Get(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, cache.ErrReindexJobNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Reindex job not found")
+		}
+		log.Error().Err(err).Msg("Error retrieving reindex job")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve reindex job")
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	keepAlive := time.NewTicker(reindexStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	if err := writeReindexEvent(res, job); err != nil || job.Status.Done() {
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case job, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := writeReindexEvent(res, job); err != nil || job.Status.Done() {
+				return nil
+			}
+		}
+	}
+}
+
+// writeReindexEvent sends the state of a job as a single progress event
+func writeReindexEvent(res *echo.Response, job *models.ReindexJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(res, "event: progress\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	res.Flush()
+
+	return nil
+}
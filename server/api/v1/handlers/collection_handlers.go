@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/foresturquhart/curator/server/api/v1/dtos"
+	"github.com/foresturquhart/curator/server/container"
+	"github.com/foresturquhart/curator/server/repositories"
+	"github.com/foresturquhart/curator/server/utils"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+type CollectionHandler struct {
+	container  *container.Container
+	repository *repositories.CollectionRepository
+}
+
+func NewCollectionHandler(c *container.Container, repo *repositories.CollectionRepository) *CollectionHandler {
+	return &CollectionHandler{
+		container:  c,
+		repository: repo,
+	}
+}
+
+// OrderCollection rewrites the order of the images in a collection
+func (h *CollectionHandler) OrderCollection(c echo.Context) error {
+	ctx := c.Request().Context()
+	uuid := c.Param("uuid")
+
+	var req dtos.CollectionOrderRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid request data: %v", err))
+	}
+	if err := dtos.Validate.Struct(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	if (req.ImageIDs != nil) == (req.Moves != nil) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Exactly one of image_ids or moves must be provided")
+	}
+
+	var err error
+	if req.ImageIDs != nil {
+		err = h.repository.Reorder(ctx, uuid, req.ImageIDs)
+	} else {
+		err = h.repository.Move(ctx, uuid, req.ToMoves())
+	}
+
+	if err != nil {
+		if errors.Is(err, utils.ErrCollectionNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Collection not found")
+		}
+		if errors.Is(err, utils.ErrInvalidInput) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		log.Error().Err(err).Msgf("Error reordering collection %s", uuid)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to reorder collection")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ndjsonStream writes newline-delimited JSON records to an HTTP response
+type ndjsonStream struct {
+	response *echo.Response
+	encoder  *json.Encoder
+}
+
+// newNDJSONStream prepares the response for streaming an NDJSON export as a download
+func newNDJSONStream(c echo.Context, filename string) *ndjsonStream {
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	response.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	response.WriteHeader(http.StatusOK)
+
+	return &ndjsonStream{
+		response: response,
+		encoder:  json.NewEncoder(response),
+	}
+}
+
+// Write encodes a single record and flushes it to the client
+func (s *ndjsonStream) Write(record any) error {
+	if err := s.encoder.Encode(record); err != nil {
+		return err
+	}
+	s.response.Flush()
+	return nil
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/foresturquhart/curator/server/container"
+	"github.com/foresturquhart/curator/server/storage"
+	"github.com/labstack/echo/v4"
+)
+
+type HealthHandler struct {
+	container *container.Container
+}
+
+func NewHealthHandler(c *container.Container) *HealthHandler {
+	return &HealthHandler{
+		container: c,
+	}
+}
+
+// Health reports the state of backends guarded by a circuit breaker. It responds with
+// 503 while any breaker is open, so that load balancers can route around the instance.
+func (h *HealthHandler) Health(c echo.Context) error {
+	status := http.StatusOK
+
+	elasticState := storage.CircuitClosed
+	if h.container.Elastic.Breaker != nil {
+		elasticState = h.container.Elastic.Breaker.State()
+	}
+	if elasticState == storage.CircuitOpen {
+		status = http.StatusServiceUnavailable
+	}
+
+	return c.JSON(status, map[string]any{
+		"elasticsearch": elasticState,
+	})
+}
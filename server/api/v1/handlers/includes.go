@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/foresturquhart/curator/server/container"
+	"github.com/foresturquhart/curator/server/models"
+	"github.com/labstack/echo/v4"
+)
+
+// includePreviewImages requests the most recently associated images of a person or tag
+const includePreviewImages = "preview_images"
+
+// parseIncludes reads the comma separated include query parameter, rejecting any option
+// the endpoint does not support
+func parseIncludes(c echo.Context, allowed ...string) (map[string]bool, error) {
+	includes := make(map[string]bool)
+	for _, include := range strings.Split(c.QueryParam("include"), ",") {
+		include = strings.TrimSpace(include)
+		if include == "" {
+			continue
+		}
+		if !slices.Contains(allowed, include) {
+			return nil, fmt.Errorf("Invalid include option: %s", include)
+		}
+		includes[include] = true
+	}
+	return includes, nil
+}
+
+// populatePreviewImageURLs fills in the URLs of preview images
+func populatePreviewImageURLs(c *container.Container, previews []*models.ImagePreview) error {
+	for _, preview := range previews {
+		url, err := c.S3.GetPublicURL(preview.GetStoredName())
+		if err != nil {
+			return err
+		}
+		preview.URL = &url
+	}
+	return nil
+}
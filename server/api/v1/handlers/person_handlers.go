@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -47,9 +48,16 @@ func (h *PersonHandler) CreatePerson(c echo.Context) error {
 				"conflict_id": conflictErr.ConflictUUID,
 			})
 		}
+		if errors.Is(err, utils.ErrInvalidInput) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Error storing person: %v", err))
 	}
 
+	if err := h.populatePrimaryImageURL(person); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to resolve primary image URL: %v", err))
+	}
+
 	return c.JSON(http.StatusCreated, dtos.FromModel(person))
 }
 
@@ -64,8 +72,13 @@ func (h *PersonHandler) ListPeople(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
 	}
 
+	includes, err := parseIncludes(c, includePreviewImages)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
 	options := &search.PersonSearchOptions{}
-	if err := applyPeoplePaginationAndSorting(options, req.Limit, req.StartingAfter, req.SortBy, req.SortDirection, h.container.Config.EncryptionKey); err != nil {
+	if err := applyPeoplePaginationAndSorting(options, req.Limit, req.StartingAfter, req.EndingBefore, req.SortBy, req.SortDirection, h.container.Config.EncryptionKey, h.container.Config.ParamMode); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
@@ -74,7 +87,14 @@ func (h *PersonHandler) ListPeople(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list people")
 	}
 
-	response, err := formatPaginatedPersonResponse(people, h.container.Config.EncryptionKey)
+	if includes[includePreviewImages] {
+		if err := h.populatePreviewImages(ctx, people.Data...); err != nil {
+			log.Error().Err(err).Msg("Error fetching preview images for people")
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch preview images")
+		}
+	}
+
+	response, err := formatPaginatedPersonResponse(people, personCursorSignature(options), h.container.Config.EncryptionKey)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
@@ -86,6 +106,11 @@ func (h *PersonHandler) GetPerson(c echo.Context) error {
 	ctx := c.Request().Context()
 	uuid := c.Param("uuid")
 
+	includes, err := parseIncludes(c, includePreviewImages)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
 	person, err := h.service.Get(ctx, uuid)
 	if err != nil {
 		if errors.Is(err, utils.ErrPersonNotFound) {
@@ -94,6 +119,17 @@ func (h *PersonHandler) GetPerson(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve person")
 	}
 
+	if err := h.populatePrimaryImageURL(person); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to resolve primary image URL: %v", err))
+	}
+
+	if includes[includePreviewImages] {
+		if err := h.populatePreviewImages(ctx, person); err != nil {
+			log.Error().Err(err).Msgf("Error fetching preview images for person %s", uuid)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch preview images")
+		}
+	}
+
 	return c.JSON(http.StatusOK, dtos.FromModel(person))
 }
 
@@ -116,6 +152,9 @@ func (h *PersonHandler) UpdatePerson(c echo.Context) error {
 	if err := dtos.Validate.Struct(&req); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
 	}
+	if err := req.ValidatePrimaryImageID(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
 
 	req.UpdateModel(existingPerson)
 	if err := h.service.Update(ctx, existingPerson); err != nil {
@@ -125,9 +164,16 @@ func (h *PersonHandler) UpdatePerson(c echo.Context) error {
 				"conflict_id": conflictErr.ConflictUUID,
 			})
 		}
+		if errors.Is(err, utils.ErrInvalidInput) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to update person: %v", err))
 	}
 
+	if err := h.populatePrimaryImageURL(existingPerson); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to resolve primary image URL: %v", err))
+	}
+
 	return c.JSON(http.StatusOK, dtos.FromModel(existingPerson))
 }
 
@@ -156,16 +202,13 @@ func (h *PersonHandler) SearchPeople(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
 	}
 
-	options := &search.PersonSearchOptions{}
-	if err := applyPeoplePaginationAndSorting(options, req.Limit, req.StartingAfter, req.SortBy, req.SortDirection, h.container.Config.EncryptionKey); err != nil {
+	includes, err := parseIncludes(c, includePreviewImages)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	// Apply pagination and sorting
-	err := applyPeoplePaginationAndSorting(options, req.Limit, req.StartingAfter,
-		req.SortBy, req.SortDirection, h.container.Config.EncryptionKey)
-
-	if err != nil {
+	options := &search.PersonSearchOptions{}
+	if err := applyPeoplePaginationAndSorting(options, req.Limit, req.StartingAfter, req.EndingBefore, req.SortBy, req.SortDirection, h.container.Config.EncryptionKey, h.container.Config.ParamMode); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
@@ -179,19 +222,33 @@ func (h *PersonHandler) SearchPeople(c echo.Context) error {
 		options.Source = *req.Source
 	}
 	if req.SinceDate != nil {
-		sinceTime, err := time.Parse(time.RFC3339, *req.SinceDate)
+		sinceTime, err := utils.ParseSearchDate(*req.SinceDate, h.container.Config.SearchLocation)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, "Invalid since_date format, expected RFC3339")
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid since_date format, expected RFC3339 or YYYY-MM-DD")
 		}
 		options.SinceDate = &sinceTime
 	}
 	if req.BeforeDate != nil {
-		beforeTime, err := time.Parse(time.RFC3339, *req.BeforeDate)
+		beforeTime, err := utils.ParseSearchDate(*req.BeforeDate, h.container.Config.SearchLocation)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, "Invalid before_date format, expected RFC3339")
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid before_date format, expected RFC3339 or YYYY-MM-DD")
 		}
 		options.BeforeDate = &beforeTime
 	}
+	if req.ActiveFrom != nil {
+		activeFrom, err := time.Parse(time.DateOnly, *req.ActiveFrom)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid active_from format, expected YYYY-MM-DD")
+		}
+		options.ActiveFrom = &activeFrom
+	}
+	if req.ActiveTo != nil {
+		activeTo, err := time.Parse(time.DateOnly, *req.ActiveTo)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid active_to format, expected YYYY-MM-DD")
+		}
+		options.ActiveTo = &activeTo
+	}
 
 	people, err := h.service.Search(ctx, options)
 	if err != nil {
@@ -199,7 +256,14 @@ func (h *PersonHandler) SearchPeople(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to search people")
 	}
 
-	response, err := formatPaginatedPersonResponse(people, h.container.Config.EncryptionKey)
+	if includes[includePreviewImages] {
+		if err := h.populatePreviewImages(ctx, people.Data...); err != nil {
+			log.Error().Err(err).Msg("Error fetching preview images for people")
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch preview images")
+		}
+	}
+
+	response, err := formatPaginatedPersonResponse(people, personCursorSignature(options), h.container.Config.EncryptionKey)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
@@ -207,17 +271,62 @@ func (h *PersonHandler) SearchPeople(c echo.Context) error {
 	return c.JSON(http.StatusOK, response)
 }
 
-func applyPeoplePaginationAndSorting(options *search.PersonSearchOptions, limit *int, startingAfter *string, sortBy *string, sortDirection *string, encryptionKey string) error {
-	if limit != nil {
-		options.Limit = *limit
+// ExportPeople streams every person, including their sources, as NDJSON
+func (h *PersonHandler) ExportPeople(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	stream := newNDJSONStream(c, "people.ndjson")
+
+	err := h.service.ExportAll(ctx, func(person *models.Person) error {
+		return stream.Write(dtos.FromModel(person))
+	})
+
+	// The response has already started, so failures can only be logged
+	if err != nil {
+		log.Error().Err(err).Msg("Error exporting people")
 	}
 
-	if startingAfter != nil {
-		cursor, err := utils.DecryptCursor(*startingAfter, encryptionKey)
-		if err != nil {
-			return fmt.Errorf("invalid cursor: %w", err)
+	return nil
+}
+
+// populatePrimaryImageURL fills in the URL of the image shown for a person
+func (h *PersonHandler) populatePrimaryImageURL(person *models.Person) error {
+	if person.PrimaryImage == nil {
+		return nil
+	}
+
+	url, err := h.container.S3.GetPublicURL(person.PrimaryImage.GetStoredName())
+	if err != nil {
+		return err
+	}
+	person.PrimaryImage.URL = &url
+
+	return nil
+}
+
+// populatePreviewImages attaches the most recently associated images to each person,
+// along with their URLs
+func (h *PersonHandler) populatePreviewImages(ctx context.Context, people ...*models.Person) error {
+	if err := h.service.PopulatePreviewImages(ctx, people...); err != nil {
+		return err
+	}
+
+	for _, person := range people {
+		if err := populatePreviewImageURLs(h.container, person.PreviewImages); err != nil {
+			return err
 		}
-		options.StartingAfter = cursor
+	}
+
+	return nil
+}
+
+func applyPeoplePaginationAndSorting(options *search.PersonSearchOptions, limit *int, startingAfter *string, endingBefore *string, sortBy *string, sortDirection *string, encryptionKey string, paramMode string) error {
+	if startingAfter != nil && endingBefore != nil {
+		return fmt.Errorf("starting_after and ending_before cannot be combined")
+	}
+
+	if limit != nil {
+		options.Limit = *limit
 	}
 
 	if sortBy != nil {
@@ -229,7 +338,10 @@ func applyPeoplePaginationAndSorting(options *search.PersonSearchOptions, limit
 		case "name":
 			options.SortBy = search.PersonSortByName
 		default:
-			return fmt.Errorf("invalid sort_by option: %s", *sortBy)
+			if paramMode != utils.ParamModeLenient {
+				return fmt.Errorf("invalid sort_by option: %s", *sortBy)
+			}
+			log.Debug().Str("sort_by", *sortBy).Msg("Ignoring unrecognised sort option")
 		}
 	}
 
@@ -240,27 +352,63 @@ func applyPeoplePaginationAndSorting(options *search.PersonSearchOptions, limit
 		case "desc":
 			options.SortDirection = utils.SortDirectionDesc
 		default:
-			return fmt.Errorf("invalid sort_direction option: %s", *sortDirection)
+			if paramMode != utils.ParamModeLenient {
+				return fmt.Errorf("invalid sort_direction option: %s", *sortDirection)
+			}
+			log.Debug().Str("sort_direction", *sortDirection).Msg("Ignoring unrecognised sort direction")
+		}
+	}
+
+	// Apply cursor once the sort is known, so it can be checked against the cursor's signature
+	if startingAfter != nil {
+		cursor, err := utils.DecryptCursor(*startingAfter, personCursorSignature(options), encryptionKey)
+		if err != nil {
+			return fmt.Errorf("invalid cursor: %w", err)
+		}
+		options.StartingAfter = cursor
+	}
+	if endingBefore != nil {
+		cursor, err := utils.DecryptCursor(*endingBefore, personCursorSignature(options), encryptionKey)
+		if err != nil {
+			return fmt.Errorf("invalid cursor: %w", err)
 		}
+		options.EndingBefore = cursor
 	}
 
 	return nil
 }
 
-func formatPaginatedPersonResponse(result *utils.PaginatedResult[*models.Person], encryptionKey string) (map[string]interface{}, error) {
+// personCursorSignature derives the cursor signature for the sort of a person search
+func personCursorSignature(options *search.PersonSearchOptions) string {
+	return utils.SortSignature(string(options.SortBy), options.SortDirection)
+}
+
+func formatPaginatedPersonResponse(result *utils.PaginatedResult[*models.Person], signature string, encryptionKey string) (map[string]interface{}, error) {
 	response := map[string]any{
 		"data":        result.Data,
 		"has_more":    result.HasMore,
 		"total_count": result.TotalCount,
 	}
 
+	if result.Partial {
+		response["partial"] = true
+	}
+
 	if result.NextCursor != nil {
-		cursor, err := utils.EncryptCursor(result.NextCursor, encryptionKey)
+		cursor, err := utils.EncryptCursor(result.NextCursor, signature, encryptionKey)
 		if err != nil {
 			return nil, fmt.Errorf("failed to encrypt cursor: %w", err)
 		}
 		response["next_cursor"] = cursor
 	}
 
+	if result.PrevCursor != nil {
+		cursor, err := utils.EncryptCursor(result.PrevCursor, signature, encryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt cursor: %w", err)
+		}
+		response["prev_cursor"] = cursor
+	}
+
 	return response, nil
 }
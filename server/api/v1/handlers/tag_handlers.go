@@ -0,0 +1,574 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/foresturquhart/curator/server/api/v1/dtos"
+	"github.com/foresturquhart/curator/server/container"
+	"github.com/foresturquhart/curator/server/models"
+	"github.com/foresturquhart/curator/server/repositories"
+	"github.com/foresturquhart/curator/server/search"
+	"github.com/foresturquhart/curator/server/services"
+	"github.com/foresturquhart/curator/server/utils"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+type TagHandler struct {
+	container *container.Container
+	service   *services.TagService
+}
+
+func NewTagHandler(c *container.Container, svc *services.TagService) *TagHandler {
+	return &TagHandler{
+		container: c,
+		service:   svc,
+	}
+}
+
+func (h *TagHandler) CreateTag(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req dtos.TagCreateRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid request data: %v", err))
+	}
+	if err := dtos.Validate.Struct(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	// Tags are created at the root unless placed relative to another tag
+	action := repositories.TagHierarchyRoot
+	if req.Action != nil {
+		action = parseTagHierarchyAction(*req.Action)
+	}
+
+	targetID, err := h.resolveHierarchyTarget(ctx, action, req.TargetID)
+	if err != nil {
+		return err
+	}
+
+	tag := &models.Tag{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+
+	err = h.service.Create(ctx, tag, repositories.TagCreateOptions{
+		Action:   action,
+		TargetID: targetID,
+	})
+	if err != nil {
+		var conflictErr *utils.ConflictError
+		if errors.As(err, &conflictErr) {
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"error":       "A tag with this name already exists",
+				"conflict_id": conflictErr.ConflictUUID,
+			})
+		}
+		if errors.Is(err, utils.ErrInvalidInput) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		log.Error().Err(err).Msg("Error creating tag")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create tag")
+	}
+
+	return c.JSON(http.StatusCreated, tag)
+}
+
+func (h *TagHandler) ListTags(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req dtos.TagListRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request parameters")
+	}
+	if err := dtos.Validate.Struct(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	includes, err := parseIncludes(c, includePreviewImages)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	options := &search.TagSearchOptions{}
+	if err := applyTagsPaginationAndSorting(options, req.Limit, req.StartingAfter, req.SortBy, req.SortDirection, h.container.Config.EncryptionKey, h.container.Config.ParamMode); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	tags, err := h.service.Search(ctx, options)
+	if err != nil {
+		log.Error().Err(err).Msg("Error listing tags")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list tags")
+	}
+
+	if includes[includePreviewImages] {
+		if err := h.populatePreviewImages(ctx, tags.Data...); err != nil {
+			log.Error().Err(err).Msg("Error fetching preview images for tags")
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch preview images")
+		}
+	}
+
+	response, err := formatPaginatedTagResponse(tags, tagCursorSignature(options), h.container.Config.EncryptionKey)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *TagHandler) GetTag(c echo.Context) error {
+	ctx := c.Request().Context()
+	uuid := c.Param("uuid")
+
+	includes, err := parseIncludes(c, includePreviewImages)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	tag, err := h.service.Get(ctx, uuid)
+	if err != nil {
+		if errors.Is(err, utils.ErrTagNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Tag not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve tag")
+	}
+
+	if includes[includePreviewImages] {
+		if err := h.populatePreviewImages(ctx, tag); err != nil {
+			log.Error().Err(err).Msgf("Error fetching preview images for tag %s", uuid)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch preview images")
+		}
+	}
+
+	return c.JSON(http.StatusOK, tag)
+}
+
+func (h *TagHandler) UpdateTag(c echo.Context) error {
+	ctx := c.Request().Context()
+	uuid := c.Param("uuid")
+
+	existingTag, err := h.service.Get(ctx, uuid)
+	if err != nil {
+		if errors.Is(err, utils.ErrTagNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Tag not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve tag")
+	}
+
+	var req dtos.TagUpdateRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid request data: %v", err))
+	}
+	if err := dtos.Validate.Struct(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	// The tag stays where it is unless an action moves it
+	var opts *repositories.TagUpdateOptions
+	if req.Action != nil {
+		action := parseTagHierarchyAction(*req.Action)
+
+		targetID, err := h.resolveHierarchyTarget(ctx, action, req.TargetID)
+		if err != nil {
+			return err
+		}
+		if targetID != nil && *targetID == existingTag.ID {
+			return echo.NewHTTPError(http.StatusBadRequest, "A tag cannot be moved relative to itself")
+		}
+
+		opts = &repositories.TagUpdateOptions{
+			Action:   action,
+			TargetID: targetID,
+		}
+	}
+
+	if req.Name != nil {
+		existingTag.Name = *req.Name
+	}
+	if req.Description != nil {
+		existingTag.Description = req.Description
+	}
+
+	if err := h.service.Update(ctx, existingTag, opts); err != nil {
+		var conflictErr *utils.ConflictError
+		if errors.As(err, &conflictErr) {
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"error":       "A tag with this name already exists",
+				"conflict_id": conflictErr.ConflictUUID,
+			})
+		}
+		if errors.Is(err, utils.ErrInvalidInput) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		log.Error().Err(err).Msgf("Error updating tag %s", uuid)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update tag")
+	}
+
+	return c.JSON(http.StatusOK, existingTag)
+}
+
+func (h *TagHandler) DeleteTag(c echo.Context) error {
+	ctx := c.Request().Context()
+	uuid := c.Param("uuid")
+
+	tag, err := h.service.Get(ctx, uuid)
+	if err != nil {
+		if errors.Is(err, utils.ErrTagNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Tag not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve tag")
+	}
+
+	if err := h.service.Delete(ctx, tag); err != nil {
+		log.Error().Err(err).Msgf("Error deleting tag %s", uuid)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete tag")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *TagHandler) SearchTags(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req dtos.TagSearchRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+	}
+	if err := dtos.Validate.Struct(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	includes, err := parseIncludes(c, includePreviewImages)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	options := &search.TagSearchOptions{}
+	if err := applyTagsPaginationAndSorting(options, req.Limit, req.StartingAfter, req.SortBy, req.SortDirection, h.container.Config.EncryptionKey, h.container.Config.ParamMode); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if req.Name != nil {
+		options.Name = *req.Name
+	}
+	if req.Description != nil {
+		options.Description = *req.Description
+	}
+	if req.SinceDate != nil {
+		sinceTime, err := utils.ParseSearchDate(*req.SinceDate, h.container.Config.SearchLocation)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid since_date format, expected RFC3339 or YYYY-MM-DD")
+		}
+		options.SinceDate = &sinceTime
+	}
+	if req.BeforeDate != nil {
+		beforeTime, err := utils.ParseSearchDate(*req.BeforeDate, h.container.Config.SearchLocation)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid before_date format, expected RFC3339 or YYYY-MM-DD")
+		}
+		options.BeforeDate = &beforeTime
+	}
+
+	tags, err := h.service.Search(ctx, options)
+	if err != nil {
+		log.Error().Err(err).Msg("Error searching tags")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to search tags")
+	}
+
+	if includes[includePreviewImages] {
+		if err := h.populatePreviewImages(ctx, tags.Data...); err != nil {
+			log.Error().Err(err).Msg("Error fetching preview images for tags")
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch preview images")
+		}
+	}
+
+	response, err := formatPaginatedTagResponse(tags, tagCursorSignature(options), h.container.Config.EncryptionKey)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetTagTree returns the tag tree below a tag, or from the root tags when no tag is given
+func (h *TagHandler) GetTagTree(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req dtos.TagTreeRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request parameters")
+	}
+	if err := dtos.Validate.Struct(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	var start *models.Tag
+	if uuid := c.Param("uuid"); uuid != "" {
+		tag, err := h.service.Get(ctx, uuid)
+		if err != nil {
+			if errors.Is(err, utils.ErrTagNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "Tag not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve tag")
+		}
+		start = tag
+	}
+
+	opts := models.TagChildrenOptions{AfterPosition: req.AfterPosition}
+	if req.Limit != nil {
+		opts.Limit = *req.Limit
+	}
+
+	tree, err := h.service.Tree(ctx, start, req.Depth, opts)
+	if err != nil {
+		log.Error().Err(err).Msg("Error building tag tree")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve tag tree")
+	}
+
+	return c.JSON(http.StatusOK, tree)
+}
+
+// ExportTags streams every tag as NDJSON, with parents preceding their children
+func (h *TagHandler) ExportTags(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	stream := newNDJSONStream(c, "tags.ndjson")
+
+	err := h.service.ExportAll(ctx, func(record *models.TagExportRecord) error {
+		return stream.Write(record)
+	})
+
+	// The response has already started, so failures can only be logged
+	if err != nil {
+		log.Error().Err(err).Msg("Error exporting tags")
+	}
+
+	return nil
+}
+
+// GetTagStats reports image counts for a tag, directly and including its descendants
+func (h *TagHandler) GetTagStats(c echo.Context) error {
+	ctx := c.Request().Context()
+	uuid := c.Param("uuid")
+
+	includes, err := parseIncludes(c, includePreviewImages)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	tag, err := h.service.Get(ctx, uuid)
+	if err != nil {
+		if errors.Is(err, utils.ErrTagNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Tag not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve tag")
+	}
+
+	counts, err := h.service.GetImageCounts(ctx, tag)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error counting images for tag %s", uuid)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve tag statistics")
+	}
+
+	response := map[string]any{
+		"id":          tag.UUID,
+		"image_count": counts,
+	}
+
+	if includes[includePreviewImages] {
+		if err := h.populatePreviewImages(ctx, tag); err != nil {
+			log.Error().Err(err).Msgf("Error fetching preview images for tag %s", uuid)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch preview images")
+		}
+		response["preview_images"] = tag.PreviewImages
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// EnsureTagPath creates any missing tags along a path of names, returning the leaf tag
+func (h *TagHandler) EnsureTagPath(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req dtos.TagEnsurePathRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid request data: %v", err))
+	}
+	if err := dtos.Validate.Struct(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	tag, created, err := h.service.EnsurePath(ctx, req.Path)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidInput) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		log.Error().Err(err).Msg("Error ensuring tag path")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to ensure tag path")
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	return c.JSON(status, tag)
+}
+
+// BulkMoveTags moves several tags inside a new parent in one operation
+func (h *TagHandler) BulkMoveTags(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req dtos.TagBulkMoveRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid request data: %v", err))
+	}
+	if err := dtos.Validate.Struct(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	tags, err := h.service.BulkMove(ctx, req.TagIDs, req.ParentID)
+	if err != nil {
+		if errors.Is(err, utils.ErrTagNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		if errors.Is(err, utils.ErrInvalidInput) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		log.Error().Err(err).Msg("Error moving tags")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to move tags")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"data": tags,
+	})
+}
+
+// populatePreviewImages attaches the most recently tagged images to each tag, along with
+// their URLs
+func (h *TagHandler) populatePreviewImages(ctx context.Context, tags ...*models.Tag) error {
+	if err := h.service.PopulatePreviewImages(ctx, tags...); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if err := populatePreviewImageURLs(h.container, tag.PreviewImages); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveHierarchyTarget resolves the internal ID of the tag a hierarchy action places a
+// tag relative to. Moving a tag to the root needs no target, every other action does.
+func (h *TagHandler) resolveHierarchyTarget(ctx context.Context, action repositories.TagHierarchyAction, targetUUID *string) (*int64, error) {
+	if action == repositories.TagHierarchyRoot {
+		return nil, nil
+	}
+
+	if targetUUID == nil || *targetUUID == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "A target_id is required for this action")
+	}
+
+	target, err := h.service.Get(ctx, *targetUUID)
+	if err != nil {
+		if errors.Is(err, utils.ErrTagNotFound) {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Target tag not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve target tag")
+	}
+
+	return &target.ID, nil
+}
+
+// parseTagHierarchyAction converts an already validated action name into a hierarchy action
+func parseTagHierarchyAction(action string) repositories.TagHierarchyAction {
+	switch action {
+	case "inside":
+		return repositories.TagHierarchyInside
+	case "before":
+		return repositories.TagHierarchyBefore
+	case "after":
+		return repositories.TagHierarchyAfter
+	default:
+		return repositories.TagHierarchyRoot
+	}
+}
+
+func applyTagsPaginationAndSorting(options *search.TagSearchOptions, limit *int, startingAfter *string, sortBy *string, sortDirection *string, encryptionKey string, paramMode string) error {
+	if limit != nil {
+		options.Limit = *limit
+	}
+
+	if sortBy != nil {
+		switch *sortBy {
+		case "relevance":
+			options.SortBy = search.TagSortByRelevance
+		case "created_at":
+			options.SortBy = search.TagSortByCreatedAt
+		case "name":
+			options.SortBy = search.TagSortByName
+		default:
+			if paramMode != utils.ParamModeLenient {
+				return fmt.Errorf("invalid sort_by option: %s", *sortBy)
+			}
+			log.Debug().Str("sort_by", *sortBy).Msg("Ignoring unrecognised sort option")
+		}
+	}
+
+	if sortDirection != nil {
+		switch *sortDirection {
+		case "asc":
+			options.SortDirection = utils.SortDirectionAsc
+		case "desc":
+			options.SortDirection = utils.SortDirectionDesc
+		default:
+			if paramMode != utils.ParamModeLenient {
+				return fmt.Errorf("invalid sort_direction option: %s", *sortDirection)
+			}
+			log.Debug().Str("sort_direction", *sortDirection).Msg("Ignoring unrecognised sort direction")
+		}
+	}
+
+	// Apply cursor once the sort is known, so it can be checked against the cursor's signature
+	if startingAfter != nil {
+		cursor, err := utils.DecryptCursor(*startingAfter, tagCursorSignature(options), encryptionKey)
+		if err != nil {
+			return fmt.Errorf("invalid cursor: %w", err)
+		}
+		options.StartingAfter = cursor
+	}
+
+	return nil
+}
+
+func tagCursorSignature(options *search.TagSearchOptions) string {
+	return utils.SortSignature(string(options.SortBy), options.SortDirection)
+}
+
+func formatPaginatedTagResponse(result *utils.PaginatedResult[*models.Tag], signature string, encryptionKey string) (map[string]interface{}, error) {
+	response := map[string]any{
+		"data":        result.Data,
+		"has_more":    result.HasMore,
+		"total_count": result.TotalCount,
+	}
+
+	if result.Partial {
+		response["partial"] = true
+	}
+
+	if result.NextCursor != nil {
+		cursor, err := utils.EncryptCursor(result.NextCursor, signature, encryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt cursor: %w", err)
+		}
+		response["next_cursor"] = cursor
+	}
+
+	return response, nil
+}
@@ -2,9 +2,8 @@ package v1
 
 import (
 	"bytes"
-	"crypto/md5"
-	"crypto/sha1"
-	"encoding/hex"
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,13 +12,25 @@ import (
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/foresturquhart/curator/server/api/v1/dtos"
+	"github.com/foresturquhart/curator/server/cache"
+	"github.com/foresturquhart/curator/server/clip"
 	"github.com/foresturquhart/curator/server/container"
+	"github.com/foresturquhart/curator/server/fetch"
+	"github.com/foresturquhart/curator/server/imaging"
 	"github.com/foresturquhart/curator/server/models"
 	"github.com/foresturquhart/curator/server/repositories"
+	"github.com/foresturquhart/curator/server/services"
+	"github.com/foresturquhart/curator/server/storage"
 	"github.com/foresturquhart/curator/server/utils"
 	"github.com/labstack/echo/v4"
 	"github.com/pgvector/pgvector-go"
@@ -29,12 +40,20 @@ import (
 type ImageHandler struct {
 	container  *container.Container
 	repository *repositories.ImageRepository
+	searchLog  *repositories.SearchLogRepository
+	tags       *services.TagService
+	views      *cache.ImageViewCounter
+	fetcher    *fetch.Client
 }
 
 func NewImageHandler(c *container.Container, repo *repositories.ImageRepository) *ImageHandler {
 	return &ImageHandler{
 		container:  c,
 		repository: repo,
+		searchLog:  repositories.NewSearchLogRepository(c),
+		tags:       services.NewTagService(c),
+		views:      cache.NewImageViewCounter(c),
+		fetcher:    fetch.NewClient(c.Config.ImageFetchTimeout, c.Config.ImageFetchMaxBytes),
 	}
 }
 
@@ -55,6 +74,79 @@ type ImageSourceRequest struct {
 	URL         string  `json:"url"`         // Source URL
 	Title       *string `json:"title"`       // Optional source title
 	Description *string `json:"description"` // Optional source description
+	IsPrimary   bool    `json:"is_primary"`  // Whether this is the primary source
+}
+
+// personRole returns the role of a person association, falling back to the configured
+// default when none was given. Without a default, associations lacking a role are skipped.
+func (h *ImageHandler) personRole(role models.PersonRole) models.PersonRole {
+	if role == "" {
+		return h.container.Config.DefaultPersonRole
+	}
+	return role
+}
+
+// ImageMetadataRequest holds the descriptive metadata supplied with a new image
+type ImageMetadataRequest struct {
+	Title       *string              `json:"title"`
+	Description *string              `json:"description"`
+	Language    *string              `json:"language"`
+	Tags        []ImageTagRequest    `json:"tags"`
+	People      []ImagePersonRequest `json:"people"`
+	Sources     []ImageSourceRequest `json:"sources"`
+
+	// Lenient skips tags and people that do not exist rather than rejecting the image,
+	// reporting them in the response
+	Lenient bool `json:"lenient"`
+}
+
+// deriveTitle fills in a missing title from the filename of a new image, when enabled
+func (h *ImageHandler) deriveTitle(metadata *ImageMetadataRequest, filename string) {
+	if !h.container.Config.DeriveTitleFromFilename {
+		return
+	}
+	if metadata.Title != nil && strings.TrimSpace(*metadata.Title) != "" {
+		return
+	}
+
+	if title := utils.TitleFromFilename(filename); title != "" {
+		metadata.Title = &title
+	}
+}
+
+// checkIngestionPolicy enforces the configured metadata requirements for new images
+func (h *ImageHandler) checkIngestionPolicy(metadata ImageMetadataRequest) error {
+	if h.container.Config.RequireImageTitle {
+		if metadata.Title == nil || strings.TrimSpace(*metadata.Title) == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Images must be created with a title")
+		}
+	}
+
+	if h.container.Config.RequireImageTag {
+		hasTag := false
+		for _, tag := range metadata.Tags {
+			if tag.UUID != "" || tag.Name != "" {
+				hasTag = true
+				break
+			}
+		}
+		if !hasTag {
+			return echo.NewHTTPError(http.StatusBadRequest, "Images must be created with at least one tag")
+		}
+	}
+
+	return h.checkLanguage(metadata.Language)
+}
+
+// checkLanguage rejects a language that is not one of the configured text languages
+func (h *ImageHandler) checkLanguage(language *string) error {
+	if language == nil {
+		return nil
+	}
+	if _, ok := h.container.Config.TextLanguageAnalyzers[*language]; !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Unsupported language: %s", *language))
+	}
+	return nil
 }
 
 func (h *ImageHandler) CreateImage(c echo.Context) error {
@@ -82,43 +174,203 @@ func (h *ImageHandler) CreateImage(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Error reading file content: "+err.Error())
 	}
 
+	// Parse metadata from form
+	var metadata ImageMetadataRequest
+	if metadataJSON := c.FormValue("metadata"); metadataJSON != "" {
+		if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid metadata JSON: "+err.Error())
+		}
+	}
+
+	h.deriveTitle(&metadata, fileHeader.Filename)
+
+	if err := h.checkIngestionPolicy(metadata); err != nil {
+		return err
+	}
+
+	imageModel, err := h.storeImage(ctx, fileBytes, fileHeader.Filename, metadata)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, dtos.ImageFromModel(imageModel))
+}
+
+// CreateImageFromURLRequest represents a request to create an image from a remote URL
+type CreateImageFromURLRequest struct {
+	URL string `json:"url" validate:"required,url"`
+	ImageMetadataRequest
+}
+
+// CreateImageFromURL fetches an image from a remote URL and ingests it as if it had
+// been uploaded. The URL is recorded as a source of the image.
+func (h *ImageHandler) CreateImageFromURL(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req CreateImageFromURLRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := dtos.Validate.Struct(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	h.deriveTitle(&req.ImageMetadataRequest, filenameFromURL(req.URL))
+
+	// Enforce the ingestion policy before spending a fetch on the image
+	if err := h.checkIngestionPolicy(req.ImageMetadataRequest); err != nil {
+		return err
+	}
+
+	fileBytes, contentType, err := h.fetcher.Get(ctx, req.URL)
+	if err != nil {
+		switch {
+		case errors.Is(err, fetch.ErrBlockedAddress), errors.Is(err, fetch.ErrInvalidURL):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, fetch.ErrTooLarge):
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, err.Error())
+		default:
+			log.Warn().Err(err).Str("url", req.URL).Msg("Failed to fetch image")
+			return echo.NewHTTPError(http.StatusBadGateway, "Failed to fetch image")
+		}
+	}
+
+	// Servers that omit a content type are tolerated, the bytes are sniffed during ingestion
+	if contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || !strings.HasPrefix(mediaType, "image/") {
+			return echo.NewHTTPError(http.StatusUnsupportedMediaType, fmt.Sprintf("URL did not return an image (content type %s)", contentType))
+		}
+	}
+
+	// Record the fetched URL as a source, unless it was supplied explicitly
+	hasPrimary := false
+	hasSource := false
+	for _, source := range req.Sources {
+		hasPrimary = hasPrimary || source.IsPrimary
+		hasSource = hasSource || source.URL == req.URL
+	}
+	if !hasSource {
+		req.Sources = append(req.Sources, ImageSourceRequest{
+			URL:       req.URL,
+			IsPrimary: !hasPrimary,
+		})
+	}
+
+	imageModel, err := h.storeImage(ctx, fileBytes, filenameFromURL(req.URL), req.ImageMetadataRequest)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, dtos.ImageFromModel(imageModel))
+}
+
+// filenameFromURL derives a filename for a fetched image from the last segment of its URL path
+func filenameFromURL(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "image"
+	}
+
+	name := path.Base(parsedURL.Path)
+	if name == "" || name == "." || name == "/" {
+		return "image"
+	}
+
+	return name
+}
+
+// storeImage runs an uploaded image through the ingestion pipeline: format detection,
+// dimension checks, orientation handling, hashing, duplicate detection, embedding,
+// storage in the database and upload of the original. Every cheap check runs before
+// the embedding is requested, so rejected uploads never reach the CLIP service.
+// Failures are returned as HTTP errors.
+func (h *ImageHandler) storeImage(ctx context.Context, fileBytes []byte, filename string, metadata ImageMetadataRequest) (*models.Image, error) {
 	fileReader := bytes.NewReader(fileBytes)
 	fileSize := int64(len(fileBytes))
 	if fileSize < 512 {
-		return echo.NewHTTPError(http.StatusBadRequest, "File too small to reliably determine content type")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "File too small to reliably determine content type")
 	}
-	buffer := fileBytes[:512]
 
-	// Detect content type from file contents, not extension
-	contentType := http.DetectContentType(buffer)
+	// Detect the format from file contents, not extension
+	format, err := detectImageFormat(fileBytes)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
 
-	// Map MIME types to our internal format types
-	var format models.ImageFormat
-	switch {
-	case strings.HasPrefix(contentType, "image/jpeg"):
-		format = models.FormatJPEG
-	case strings.HasPrefix(contentType, "image/png"):
-		format = models.FormatPNG
-	case strings.HasPrefix(contentType, "image/gif"):
-		format = models.FormatGIF
-	default:
-		return echo.NewHTTPError(http.StatusBadRequest, "Unsupported image format: "+contentType)
+	// Get image dimensions from the header, without decoding the pixels
+	imgConfig, _, err := image.DecodeConfig(fileReader)
+	if err != nil {
+		log.Error().Err(err).Msg("Error decoding image config")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Error reading image dimensions: "+err.Error())
+	}
+
+	// Correct EXIF orientation, either in the stored original or only in derived images
+	orientation := imaging.OrientationNormal
+	var cameraMake, cameraModel string
+	if format == models.FormatJPEG {
+		orientation = imaging.ReadOrientation(fileBytes)
+		// Read the capture device before metadata is stripped from the original
+		cameraMake, cameraModel = imaging.ReadCamera(fileBytes)
+	}
+
+	// Record dimensions as displayed, after orientation correction
+	width, height := imaging.OrientedSize(imgConfig.Width, imgConfig.Height, orientation)
+
+	if maxWidth := h.container.Config.ImageMaxWidth; maxWidth > 0 && width > maxWidth {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Image width %d exceeds the maximum of %d", width, maxWidth))
+	}
+	if maxHeight := h.container.Config.ImageMaxHeight; maxHeight > 0 && height > maxHeight {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Image height %d exceeds the maximum of %d", height, maxHeight))
+	}
+
+	uprightBytes := fileBytes
+	if orientation != imaging.OrientationNormal {
+		uprightBytes, err = imaging.Reorient(fileBytes, orientation)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Error correcting image orientation: "+err.Error())
+		}
+
+		if h.container.Config.ImageOrientationMode == imaging.OrientationModeOriginal {
+			fileBytes = uprightBytes
+			fileReader = bytes.NewReader(fileBytes)
+			fileSize = int64(len(fileBytes))
+			orientation = imaging.OrientationNormal
+		}
+	}
+
+	// Strip embedded metadata from the stored original once everything needed has been read
+	// from it. Hashes are calculated afterwards, so they describe the sanitised object.
+	if h.container.Config.StripImageMetadata {
+		// The orientation tag is stripped along with everything else, so store the original upright
+		if orientation != imaging.OrientationNormal {
+			fileBytes = uprightBytes
+			orientation = imaging.OrientationNormal
+		}
+
+		fileBytes, err = stripImageMetadata(fileBytes, format)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Error stripping image metadata: "+err.Error())
+		}
+		fileReader = bytes.NewReader(fileBytes)
+		fileSize = int64(len(fileBytes))
 	}
 
 	_, err = fileReader.Seek(0, io.SeekStart)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Error processing file: "+err.Error())
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Error processing file: "+err.Error())
 	}
 
 	// Calculate file hashes
-	md5Hash, sha1Hash, err := calculateFileHashes(fileReader)
+	md5Hash, sha1Hash, err := utils.CalculateFileHashes(fileReader)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Error calculating file hashes: "+err.Error())
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Error calculating file hashes: "+err.Error())
 	}
 
 	_, err = fileReader.Seek(0, io.SeekStart)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Error processing file: "+err.Error())
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Error processing file: "+err.Error())
 	}
 
 	// TODO: stop checking for existing image here and instead do it in the Upsert function
@@ -129,49 +381,52 @@ func (h *ImageHandler) CreateImage(c echo.Context) error {
 
 	existingImages, err := h.repository.Search(ctx, existingFilter)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Error checking for duplicates: "+err.Error())
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Error checking for duplicates: "+err.Error())
 	}
 
 	if existingImages.TotalCount > 0 {
-		return echo.NewHTTPError(http.StatusConflict, "Duplicate image detected with MD5: "+md5Hash)
+		return nil, echo.NewHTTPError(http.StatusConflict, "Duplicate image detected with MD5: "+md5Hash)
 	}
 
-	_, err = fileReader.Seek(0, io.SeekStart)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Error processing file: "+err.Error())
+	// Compute the perceptual hash from the upright image, so that it matches copies that
+	// were stored upright, and reject near-duplicates of existing images. Formats whose
+	// pixels cannot be decoded here are stored without one.
+	var phash *int64
+	hash, err := imaging.PerceptualHash(uprightBytes)
+	if err == nil {
+		phash = utils.NewPointer(int64(hash))
+	} else if !errors.Is(err, imaging.ErrDecodeUnsupported) {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Error computing perceptual hash: "+err.Error())
 	}
 
-	// Get image dimensions
-	imgConfig, _, err := image.DecodeConfig(fileReader)
-	if err != nil {
-		log.Error().Err(err).Msg("Error decoding image config")
-		return echo.NewHTTPError(http.StatusBadRequest, "Error reading image dimensions: "+err.Error())
+	if maxDistance := h.container.Config.NearDuplicateMaxDistance; phash != nil && maxDistance >= 0 {
+		existing, distance, err := h.repository.GetByPhashDistance(ctx, *phash, maxDistance)
+		if err != nil && !errors.Is(err, utils.ErrImageNotFound) {
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "Error checking for near-duplicates: "+err.Error())
+		}
+		if existing != nil {
+			return nil, echo.NewHTTPError(http.StatusConflict, map[string]any{
+				"error":       "Near-duplicate image detected",
+				"conflict_id": existing.UUID,
+				"distance":    distance,
+			})
+		}
 	}
 
-	_, err = fileReader.Seek(0, io.SeekStart)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Error processing file: "+err.Error())
+	// Get embedding from CLIP service, using the upright image
+	embedding, err := h.container.Clip.GetEmbeddingFromReader(ctx, bytes.NewReader(uprightBytes))
+	if errors.Is(err, clip.ErrBusy) {
+		return nil, echo.NewHTTPError(http.StatusTooManyRequests, "Embedding service is busy, try again later")
 	}
-
-	// Get embedding from CLIP service
-	embedding, err := h.container.Clip.GetEmbeddingFromReader(ctx, fileReader)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Error getting image embedding: "+err.Error())
-	}
-
-	// Parse metadata from form
-	var metadata struct {
-		Title       *string              `json:"title"`
-		Description *string              `json:"description"`
-		Tags        []ImageTagRequest    `json:"tags"`
-		People      []ImagePersonRequest `json:"people"`
-		Sources     []ImageSourceRequest `json:"sources"`
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Error getting image embedding: "+err.Error())
 	}
-
-	if metadataJSON := c.FormValue("metadata"); metadataJSON != "" {
-		if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, "Invalid metadata JSON: "+err.Error())
+	if err := utils.ValidateEmbedding(embedding); err != nil {
+		if h.container.Config.DegenerateEmbeddingAction != utils.DegenerateEmbeddingWarn {
+			log.Warn().Err(err).Str("filename", filename).Msg("Rejecting image with degenerate embedding")
+			return nil, echo.NewHTTPError(http.StatusUnprocessableEntity, "Unable to compute a valid embedding for the image")
 		}
+		log.Warn().Err(err).Str("filename", filename).Msg("Storing image with degenerate embedding")
 	}
 
 	// Convert API request tags to model tags
@@ -188,10 +443,11 @@ func (h *ImageHandler) CreateImage(c echo.Context) error {
 	// Convert API request people to model people
 	var people []*models.ImagePerson
 	for _, personReq := range metadata.People {
-		if personReq.ID != "" && personReq.Role != "" {
+		role := h.personRole(personReq.Role)
+		if personReq.ID != "" && role != "" {
 			people = append(people, &models.ImagePerson{
 				UUID: personReq.ID,
-				Role: personReq.Role,
+				Role: role,
 			})
 		}
 	}
@@ -204,6 +460,7 @@ func (h *ImageHandler) CreateImage(c echo.Context) error {
 				URL:         sourceReq.URL,
 				Title:       sourceReq.Title,
 				Description: sourceReq.Description,
+				IsPrimary:   sourceReq.IsPrimary,
 			})
 		}
 	}
@@ -213,187 +470,971 @@ func (h *ImageHandler) CreateImage(c echo.Context) error {
 
 	// Create image model
 	imageModel := &models.Image{
-		Filename:    fileHeader.Filename,
+		Filename:    filename,
 		MD5:         md5Hash,
 		SHA1:        sha1Hash,
-		Width:       imgConfig.Width,
-		Height:      imgConfig.Height,
+		Width:       width,
+		Height:      height,
 		Format:      format,
 		Size:        fileSize,
 		Embedding:   &imageEmbedding,
 		Title:       metadata.Title,
 		Description: metadata.Description,
+		Language:    metadata.Language,
 		Tags:        tags,
 		People:      people,
 		Sources:     sources,
+		PHash:       phash,
+	}
+
+	if cameraMake != "" {
+		imageModel.CameraMake = &cameraMake
+	}
+	if cameraModel != "" {
+		imageModel.CameraModel = &cameraModel
+	}
+
+	// Generate the thumbnail up front, so that the image is recorded and indexed as having
+	// one in a single write. A missing thumbnail is generated on first request, so a
+	// failure here is not fatal.
+	thumbnail, err := imaging.Thumbnail(uprightBytes, h.container.Config.ThumbnailMaxEdge)
+	if err == nil {
+		imageModel.HasThumbnail = true
+	} else if !errors.Is(err, imaging.ErrDecodeUnsupported) {
+		log.Warn().Err(err).Str("filename", filename).Msg("Failed to generate thumbnail of image")
+	}
+
+	// Store in database
+	if err := h.repository.Upsert(ctx, imageModel, repositories.UpsertOptions{Lenient: metadata.Lenient}); err != nil {
+		if errors.Is(err, utils.ErrInvalidInput) {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Error storing image: "+err.Error())
+	}
+
+	storageKey := imageModel.GetStoredName()
+
+	_, err = fileReader.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Error processing file: "+err.Error())
+	}
+
+	err = h.container.S3.Upload(ctx, storageKey, fileReader, imageModel.Size, imageModel.Format.ContentType())
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Error uploading image file: "+err.Error())
+	}
+
+	// A thumbnail whose object is missing is regenerated on request, so a failure here is
+	// not fatal either
+	if imageModel.HasThumbnail {
+		if err := h.repository.UploadThumbnail(ctx, imageModel, thumbnail); err != nil {
+			log.Warn().Err(err).Msgf("Failed to store thumbnail of image %s", imageModel.UUID)
+		}
+	}
+
+	return imageModel, nil
+}
+
+// applyPaginationAndSorting applies common pagination and sorting parameters to an image filter
+func applyImagesPaginationAndSorting(filter *models.ImageFilter, limit *int, startingAfter *string, endingBefore *string, sortBy *string, sortDirection *string, randomSeed *string, encryptionKey string, paramMode string) error {
+	if startingAfter != nil && endingBefore != nil {
+		return fmt.Errorf("starting_after and ending_before cannot be combined")
+	}
+
+	// Apply limit
+	if limit != nil {
+		filter.Limit = *limit
+	}
+
+	// Apply sort field
+	if sortBy != nil {
+		switch *sortBy {
+		case "relevance":
+			filter.SortBy = models.SortByRelevance
+		case "created_at":
+			filter.SortBy = models.SortByCreatedAt
+		case "title":
+			filter.SortBy = models.SortByTitle
+		case "tag_count":
+			filter.SortBy = models.SortByTagCount
+		case "dimensions":
+			filter.SortBy = models.SortByDimensions
+		case "rating":
+			filter.SortBy = models.SortByRating
+		case "view_count":
+			filter.SortBy = models.SortByViewCount
+		case "random":
+			filter.SortBy = models.SortByRandom
+			if randomSeed != nil {
+				filter.RandomSeed = randomSeed
+			} else {
+				return fmt.Errorf("seed required for random sort")
+			}
+		default:
+			if paramMode != utils.ParamModeLenient {
+				return fmt.Errorf("invalid sort_by option: %s", *sortBy)
+			}
+			log.Debug().Str("sort_by", *sortBy).Msg("Ignoring unrecognised sort option")
+		}
+	}
+
+	// Apply sort direction
+	if sortDirection != nil {
+		switch *sortDirection {
+		case "asc":
+			filter.SortDirection = utils.SortDirectionAsc
+		case "desc":
+			filter.SortDirection = utils.SortDirectionDesc
+		default:
+			if paramMode != utils.ParamModeLenient {
+				return fmt.Errorf("invalid sort_direction option: %s", *sortDirection)
+			}
+			log.Debug().Str("sort_direction", *sortDirection).Msg("Ignoring unrecognised sort direction")
+		}
+	}
+
+	// Apply cursor once the sort is known, so it can be checked against the cursor's signature
+	if startingAfter != nil {
+		cursor, err := utils.DecryptCursor(*startingAfter, imageCursorSignature(filter), encryptionKey)
+		if err != nil {
+			return fmt.Errorf("invalid cursor: %w", err)
+		}
+		filter.StartingAfter = cursor
+	}
+	if endingBefore != nil {
+		cursor, err := utils.DecryptCursor(*endingBefore, imageCursorSignature(filter), encryptionKey)
+		if err != nil {
+			return fmt.Errorf("invalid cursor: %w", err)
+		}
+		filter.EndingBefore = cursor
+	}
+
+	return nil
+}
+
+// referenceSearchSort returns the sort of a search given the number of uploaded reference
+// images. A search by uploaded reference is always sorted by similarity, and this must be
+// settled before any cursor is decrypted or signed, so that the cursors of later pages
+// are checked against the same sort they were signed with.
+func referenceSearchSort(sortBy *string, sortDirection *string, references int) (*string, *string) {
+	if references == 0 {
+		return sortBy, sortDirection
+	}
+	return utils.NewPointer("relevance"), utils.NewPointer(string(utils.SortDirectionDesc))
+}
+
+// imageCursorSignature derives the cursor signature for the sort of an image filter
+func imageCursorSignature(filter *models.ImageFilter) string {
+	return utils.SortSignature(string(filter.SortBy), filter.SortDirection)
+}
+
+// detectImageFormat identifies the format of an uploaded image. The decoder's format
+// name is authoritative, and is cross-checked against a MIME sniff of the leading
+// bytes so that files whose magic bytes disagree with their content are rejected.
+func detectImageFormat(fileBytes []byte) (models.ImageFormat, error) {
+	contentType := http.DetectContentType(fileBytes[:min(len(fileBytes), 512)])
+
+	// The MIME sniff does not recognise AVIF, so fall back to the ISO-BMFF brands
+	if contentType == "application/octet-stream" && imaging.IsAVIF(fileBytes) {
+		contentType = models.FormatAVIF.ContentType()
+	}
+
+	_, decoded, err := image.DecodeConfig(bytes.NewReader(fileBytes))
+	if err != nil {
+		return "", fmt.Errorf("unsupported or unreadable image (detected %s): %w", contentType, err)
+	}
+
+	var format models.ImageFormat
+	switch decoded {
+	case "jpeg":
+		format = models.FormatJPEG
+	case "png":
+		format = models.FormatPNG
+	case "gif":
+		format = models.FormatGIF
+	case "webp":
+		format = models.FormatWEBP
+	case "avif":
+		format = models.FormatAVIF
+	default:
+		return "", fmt.Errorf("unsupported image format: %s", decoded)
+	}
+
+	if contentType != format.ContentType() {
+		return "", fmt.Errorf("image content mismatch: sniffed as %s but decodes as %s", contentType, decoded)
+	}
+
+	return format, nil
+}
+
+// stripImageMetadata removes embedded metadata from image data without re-encoding it.
+// Formats that carry no metadata worth removing are returned unchanged. AVIF stores its
+// metadata as items referenced by offset from elsewhere in the file, so it cannot be
+// removed in place and the upload is rejected instead.
+func stripImageMetadata(data []byte, format models.ImageFormat) ([]byte, error) {
+	switch format {
+	case models.FormatJPEG:
+		return imaging.StripJPEGMetadata(data)
+	case models.FormatPNG:
+		return imaging.StripPNGMetadata(data)
+	case models.FormatWEBP:
+		return imaging.StripWebPMetadata(data)
+	case models.FormatAVIF:
+		return nil, fmt.Errorf("metadata cannot be stripped from AVIF images, which are not accepted while metadata stripping is enabled")
+	default:
+		return data, nil
+	}
+}
+
+// formatPaginatedResponse creates a standardized response with pagination info
+func formatPaginatedResponse(result *models.PaginatedImageResult, signature string, encryptionKey string) (map[string]interface{}, error) {
+	response := map[string]interface{}{
+		"data":        dtos.ImagesFromModels(result.Data),
+		"has_more":    result.HasMore,
+		"total_count": result.TotalCount,
+	}
+
+	if result.Partial {
+		response["partial"] = true
+	}
+
+	if result.MaxResults > 0 {
+		response["max_results"] = result.MaxResults
+	}
+
+	if result.Facets != nil {
+		response["facets"] = result.Facets
+	}
+
+	if result.NextCursor != nil {
+		cursor, err := utils.EncryptCursor(result.NextCursor, signature, encryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt cursor: %w", err)
+		}
+		response["next_cursor"] = cursor
+	}
+
+	if result.PrevCursor != nil {
+		cursor, err := utils.EncryptCursor(result.PrevCursor, signature, encryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt cursor: %w", err)
+		}
+		response["prev_cursor"] = cursor
+	}
+
+	return response, nil
+}
+
+// Image listing modes
+const (
+	ListModeNewest  = "newest"  // Chronological listing, honouring the requested sort
+	ListModeExplore = "explore" // Visually diverse sample
+)
+
+type ListImagesRequest struct {
+	Mode          *string `query:"mode"`
+	Limit         *int    `query:"limit"`
+	StartingAfter *string `query:"starting_after"`
+	EndingBefore  *string `query:"ending_before"`
+	SortBy        *string `query:"sort_by"`
+	SortDirection *string `query:"sort_direction"`
+	RandomSeed    *string `query:"random_seed"`
+}
+
+func (h *ImageHandler) ListImages(c echo.Context) error {
+	var req ListImagesRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+	}
+
+	ctx := c.Request().Context()
+	filter := models.ImageFilter{}
+
+	// An explicit sort or cursor overrides the configured default mode
+	mode := h.container.Config.ImageDefaultListMode
+	if req.Mode != nil {
+		mode = *req.Mode
+	} else if req.SortBy != nil || req.StartingAfter != nil || req.EndingBefore != nil {
+		mode = ListModeNewest
+	}
+
+	if mode == ListModeExplore {
+		if req.SortBy != nil || req.SortDirection != nil || req.StartingAfter != nil || req.EndingBefore != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Explore mode does not support sorting or pagination")
+		}
+		if req.Limit != nil {
+			filter.Limit = *req.Limit
+		}
+
+		images, err := h.repository.Explore(ctx, filter)
+		if err != nil {
+			log.Error().Err(err).Msg("Error exploring images")
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to explore images")
+		}
+
+		response, err := formatPaginatedResponse(images, imageCursorSignature(&filter), h.container.Config.EncryptionKey)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return c.JSON(http.StatusOK, response)
+	} else if mode != ListModeNewest {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid mode option: "+mode)
+	}
+
+	// Apply pagination and sorting
+	err := applyImagesPaginationAndSorting(&filter, req.Limit, req.StartingAfter, req.EndingBefore,
+		req.SortBy, req.SortDirection, req.RandomSeed, h.container.Config.EncryptionKey, h.container.Config.ParamMode)
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// Execute search
+	images, err := h.repository.Search(ctx, filter)
+	if err != nil {
+		log.Error().Err(err).Msg("Error listing images")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list images")
+	}
+
+	// Format response
+	response, err := formatPaginatedResponse(images, imageCursorSignature(&filter), h.container.Config.EncryptionKey)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *ImageHandler) GetImage(c echo.Context) error {
+	id := c.Param("id")
+	ctx := c.Request().Context()
+
+	imageModel, err := h.repository.GetByUUID(ctx, id)
+	if err != nil {
+		if errors.Is(err, utils.ErrImageNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Image not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve image")
+	}
+
+	// Optionally populate transient URL fields
+	for _, include := range strings.Split(c.QueryParam("include"), ",") {
+		switch strings.TrimSpace(include) {
+		case "":
+			continue
+		case "urls":
+			if err := h.populateImageURLs(imageModel); err != nil {
+				log.Error().Err(err).Msgf("Error building URLs for image %s", imageModel.UUID)
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to build image URLs")
+			}
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid include option: "+include)
+		}
+	}
+
+	return c.JSON(http.StatusOK, dtos.ImageFromModel(imageModel))
+}
+
+// ImageURLResponse holds a presigned URL for downloading an image object
+type ImageURLResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// GetImageURL returns a presigned URL for the stored object of an image, which can be
+// fetched without credentials until it expires
+func (h *ImageHandler) GetImageURL(c echo.Context) error {
+	id := c.Param("id")
+	ctx := c.Request().Context()
+
+	imageModel, err := h.repository.GetByUUID(ctx, id)
+	if err != nil {
+		if errors.Is(err, utils.ErrImageNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Image not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve image")
+	}
+
+	expiry := h.container.Config.S3PresignExpiry
+	expiresAt := time.Now().Add(expiry)
+
+	url, err := h.container.S3.GetPresignedURL(ctx, imageModel.GetStoredName(), expiry)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error presigning URL for image %s", imageModel.UUID)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to build image URL")
+	}
+
+	return c.JSON(http.StatusOK, ImageURLResponse{
+		URL:       url,
+		ExpiresAt: expiresAt.UTC(),
+	})
+}
+
+// GetImageTags lists the tags of an image, grouped into those assigned to it directly
+// and those inherited from the hierarchy above them
+func (h *ImageHandler) GetImageTags(c echo.Context) error {
+	id := c.Param("id")
+	ctx := c.Request().Context()
+
+	direct, inherited, err := h.repository.GetEffectiveTags(ctx, id)
+	if err != nil {
+		if errors.Is(err, utils.ErrImageNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Image not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve image tags")
+	}
+
+	return c.JSON(http.StatusOK, dtos.ImageTagsFromModels(direct, inherited))
+}
+
+// BatchGetImagesRequest lists the images to fetch in one call
+type BatchGetImagesRequest struct {
+	IDs []string `json:"ids" validate:"required,min=1,max=100,dive,uuid"` // UUIDs of the images, in the order they should be returned
+}
+
+// BatchGetImages fetches several images by UUID, reporting any that do not exist
+func (h *ImageHandler) BatchGetImages(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req BatchGetImagesRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data: "+err.Error())
+	}
+
+	if err := dtos.Validate.Struct(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	images, missing, err := h.repository.GetManyByUUIDs(ctx, req.IDs)
+	if err != nil {
+		log.Error().Err(err).Msg("Error batch fetching images")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve images")
+	}
+
+	if missing == nil {
+		missing = []string{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"data":    dtos.ImagesFromModels(images),
+		"missing": missing,
+	})
+}
+
+// ListImagesBySource lists the images that have exactly the given source URL, ignoring
+// case, any fragment and trailing slashes
+func (h *ImageHandler) ListImagesBySource(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	sourceURL := c.QueryParam("url")
+	if strings.TrimSpace(sourceURL) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "url is required")
+	}
+
+	images, err := h.repository.FindBySourceURL(ctx, sourceURL)
+	if err != nil {
+		log.Error().Err(err).Msg("Error finding images by source")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve images")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"data": dtos.ImagesFromModels(images),
+	})
+}
+
+// populateImageURLs fills in the transient URL fields of an image from its storage key
+func (h *ImageHandler) populateImageURLs(imageModel *models.Image) error {
+	url, err := h.container.S3.GetPublicURL(imageModel.GetStoredName())
+	if err != nil {
+		return err
+	}
+	imageModel.URL = &url
+
+	return nil
+}
+
+// VerifyImage compares the stored object for an image against its recorded hashes
+func (h *ImageHandler) VerifyImage(c echo.Context) error {
+	id := c.Param("id")
+	ctx := c.Request().Context()
+
+	imageModel, err := h.repository.GetByUUID(ctx, id)
+	if err != nil {
+		if errors.Is(err, utils.ErrImageNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Image not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve image")
+	}
+
+	verification, err := h.repository.Verify(ctx, imageModel)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error verifying image %s", imageModel.UUID)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify image")
+	}
+
+	if !verification.Valid {
+		log.Warn().Str("uuid", imageModel.UUID).Msg("Stored image object does not match recorded hashes")
+	}
+
+	return c.JSON(http.StatusOK, verification)
+}
+
+// GetImageRaw serves the stored original of an image, honouring a single byte range so
+// that clients can seek without downloading the whole object. Stored objects never
+// change, so responses may be cached privately and are tagged with the MD5 hash, which
+// answers a matching If-None-Match without fetching the object. When verification is
+// enabled in the configuration or requested with verify=true, the object is hashed
+// before it is sent and refused if it no longer matches the recorded hashes.
+func (h *ImageHandler) GetImageRaw(c echo.Context) error {
+	id := c.Param("id")
+	ctx := c.Request().Context()
+
+	verify := h.container.Config.VerifyImagesOnRead
+	if raw := c.QueryParam("verify"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "verify must be true or false")
+		}
+		verify = verify || parsed
+	}
+
+	imageModel, err := h.repository.GetByUUID(ctx, id)
+	if err != nil {
+		if errors.Is(err, utils.ErrImageNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Image not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve image")
+	}
+
+	res := c.Response()
+	res.Header().Set("Accept-Ranges", "bytes")
+
+	// Only successful responses are cacheable, so errors never outlive their cause
+	etag := fmt.Sprintf(`"%s"`, imageModel.MD5)
+	res.Header().Set("ETag", etag)
+	cacheable := func() {
+		res.Header().Set(echo.HeaderCacheControl, h.imageCacheControl(true))
+	}
+
+	if match := c.Request().Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+		cacheable()
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	requested, partial, err := parseByteRange(c.Request().Header.Get("Range"), imageModel.Size)
+	if err != nil {
+		res.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", imageModel.Size))
+		return echo.NewHTTPError(http.StatusRequestedRangeNotSatisfiable, "Requested range not satisfiable")
+	}
+
+	contentType := imageModel.Format.ContentType()
+
+	if verify {
+		data, err := h.readVerifiedObject(ctx, imageModel)
+		if err != nil {
+			return err
+		}
+
+		cacheable()
+		if partial {
+			res.Header().Set("Content-Range", requested.contentRange(imageModel.Size))
+			return c.Blob(http.StatusPartialContent, contentType, data[requested.start:requested.end+1])
+		}
+		return c.Blob(http.StatusOK, contentType, data)
+	}
+
+	if partial {
+		reader, err := h.container.S3.DownloadRange(ctx, imageModel.GetStoredName(), requested.start, requested.end)
+		if err != nil {
+			log.Error().Err(err).Msgf("Error downloading image %s", imageModel.UUID)
+			return echo.NewHTTPError(http.StatusBadGateway, "Failed to retrieve image object")
+		}
+		defer reader.Close()
+
+		cacheable()
+		res.Header().Set("Content-Range", requested.contentRange(imageModel.Size))
+		res.Header().Set(echo.HeaderContentLength, strconv.FormatInt(requested.length(), 10))
+		return c.Stream(http.StatusPartialContent, contentType, reader)
+	}
+
+	reader, size, _, err := h.container.S3.Download(ctx, imageModel.GetStoredName())
+	if err != nil {
+		log.Error().Err(err).Msgf("Error downloading image %s", imageModel.UUID)
+		return echo.NewHTTPError(http.StatusBadGateway, "Failed to retrieve image object")
+	}
+	defer reader.Close()
+
+	cacheable()
+	res.Header().Set(echo.HeaderContentLength, strconv.FormatInt(size, 10))
+	return c.Stream(http.StatusOK, contentType, reader)
+}
+
+// GetImageThumbnail serves the JPEG thumbnail of an image, generating and storing it
+// first when it is missing
+func (h *ImageHandler) GetImageThumbnail(c echo.Context) error {
+	id := c.Param("id")
+	ctx := c.Request().Context()
+
+	imageModel, err := h.repository.GetByUUID(ctx, id)
+	if err != nil {
+		if errors.Is(err, utils.ErrImageNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Image not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve image")
+	}
+
+	var reader io.ReadCloser
+	var size int64
+	if imageModel.HasThumbnail {
+		reader, size, _, err = h.container.S3.Download(ctx, imageModel.GetThumbnailName())
+		if err != nil && !errors.Is(err, storage.ErrObjectNotFound) {
+			log.Error().Err(err).Msgf("Error downloading thumbnail of image %s", imageModel.UUID)
+			return echo.NewHTTPError(http.StatusBadGateway, "Failed to retrieve image thumbnail")
+		}
+	}
+
+	if reader == nil {
+		if err := h.repository.GenerateThumbnail(ctx, imageModel); err != nil {
+			if errors.Is(err, imaging.ErrDecodeUnsupported) {
+				return echo.NewHTTPError(http.StatusNotFound, "Thumbnails are not available for this image format")
+			}
+			log.Error().Err(err).Msgf("Error generating thumbnail of image %s", imageModel.UUID)
+			return echo.NewHTTPError(http.StatusBadGateway, "Failed to generate image thumbnail")
+		}
+
+		reader, size, _, err = h.container.S3.Download(ctx, imageModel.GetThumbnailName())
+		if err != nil {
+			log.Error().Err(err).Msgf("Error downloading thumbnail of image %s", imageModel.UUID)
+			return echo.NewHTTPError(http.StatusBadGateway, "Failed to retrieve image thumbnail")
+		}
+	}
+	defer reader.Close()
+
+	// Thumbnails are regenerated under the same key, so caches must not treat them as
+	// immutable
+	res := c.Response()
+	res.Header().Set(echo.HeaderCacheControl, h.imageCacheControl(false))
+	res.Header().Set(echo.HeaderContentLength, strconv.FormatInt(size, 10))
+	return c.Stream(http.StatusOK, "image/jpeg", reader)
+}
+
+// imageCacheControl builds the Cache-Control header of image bytes served by the API.
+// The server does not authenticate these endpoints itself, but deployments that keep
+// their bucket private usually put access control in front of it, which a shared cache
+// would bypass by serving stored bytes to anyone. Only private caches may keep them, and
+// only bytes that never change under the same URL are marked immutable.
+func (h *ImageHandler) imageCacheControl(immutable bool) string {
+	maxAge := h.container.Config.ImageCacheMaxAge
+	if maxAge <= 0 {
+		return "no-store"
+	}
+
+	header := fmt.Sprintf("private, max-age=%d", int64(maxAge/time.Second))
+	if immutable {
+		header += ", immutable"
 	}
+	return header
+}
 
-	// Store in database
-	if err := h.repository.Upsert(ctx, imageModel); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Error storing image: "+err.Error())
+// etagMatches reports whether an If-None-Match header names the given entity tag,
+// comparing weakly as RFC 9110 requires
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
 	}
+	return false
+}
 
-	storageKey := imageModel.GetStoredName()
+// readVerifiedObject reads the whole stored object of an image, checking it against the
+// recorded hashes before anything is sent, so that a mismatch can still be reported
+func (h *ImageHandler) readVerifiedObject(ctx context.Context, imageModel *models.Image) ([]byte, error) {
+	reader, _, _, err := h.container.S3.Download(ctx, imageModel.GetStoredName())
+	if err != nil {
+		log.Error().Err(err).Msgf("Error downloading image %s", imageModel.UUID)
+		return nil, echo.NewHTTPError(http.StatusBadGateway, "Failed to retrieve image object")
+	}
+	defer reader.Close()
 
-	_, err = fileReader.Seek(0, io.SeekStart)
+	data, err := io.ReadAll(reader)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Error processing file: "+err.Error())
+		log.Error().Err(err).Msgf("Error reading image %s", imageModel.UUID)
+		return nil, echo.NewHTTPError(http.StatusBadGateway, "Failed to retrieve image object")
 	}
 
-	err = h.container.S3.Upload(ctx, storageKey, fileReader, imageModel.Size, contentType)
+	md5Hash, sha1Hash, err := utils.CalculateFileHashes(bytes.NewReader(data))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Error uploading image file: "+err.Error())
+		log.Error().Err(err).Msgf("Error hashing image %s", imageModel.UUID)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify image")
 	}
 
-	return c.JSON(http.StatusCreated, imageModel)
-}
+	if md5Hash != imageModel.MD5 || sha1Hash != imageModel.SHA1 {
+		log.Error().
+			Str("uuid", imageModel.UUID).
+			Str("expected_md5", imageModel.MD5).
+			Str("actual_md5", md5Hash).
+			Str("expected_sha1", imageModel.SHA1).
+			Str("actual_sha1", sha1Hash).
+			Msg("Stored image object does not match recorded hashes")
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Image integrity check failed")
+	}
 
-// calculateFileHashes calculates MD5 and SHA1 hashes of a file
-func calculateFileHashes(reader io.Reader) (string, string, error) {
-	md5Hasher := md5.New()
-	sha1Hasher := sha1.New()
+	return data, nil
+}
 
-	teeReader := io.TeeReader(reader, io.MultiWriter(md5Hasher, sha1Hasher))
+// VerifyAllImages queues a background scan verifying every stored object
+func (h *ImageHandler) VerifyAllImages(c echo.Context) error {
+	ctx := c.Request().Context()
 
-	if _, err := io.Copy(io.Discard, teeReader); err != nil {
-		return "", "", err
+	if err := h.container.Worker.EnqueueVerifyImages(ctx); err != nil {
+		log.Error().Err(err).Msg("Error queueing image verification")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to queue image verification")
 	}
 
-	md5Hash := hex.EncodeToString(md5Hasher.Sum(nil))
-	sha1Hash := hex.EncodeToString(sha1Hasher.Sum(nil))
+	return c.NoContent(http.StatusAccepted)
+}
 
-	return md5Hash, sha1Hash, nil
+// ReplaceImageTagsRequest swaps one tag for another on a set of images
+type ReplaceImageTagsRequest struct {
+	ImageIDs  []string `json:"image_ids"`   // UUIDs of the images to edit
+	FromTagID string   `json:"from_tag_id"` // UUID of the tag to remove
+	ToTagID   string   `json:"to_tag_id"`   // UUID of the tag to add in its place
 }
 
-// applyPaginationAndSorting applies common pagination and sorting parameters to an image filter
-func applyImagesPaginationAndSorting(filter *models.ImageFilter, limit *int, startingAfter *string, sortBy *string, sortDirection *string, randomSeed *string, encryptionKey string) error {
-	// Apply limit
-	if limit != nil {
-		filter.Limit = *limit
-	}
+// ReplaceImageTags removes one tag and adds another across the given images
+func (h *ImageHandler) ReplaceImageTags(c echo.Context) error {
+	ctx := c.Request().Context()
 
-	// Apply cursor
-	if startingAfter != nil {
-		cursor, err := utils.DecryptCursor(*startingAfter, encryptionKey)
-		if err != nil {
-			return fmt.Errorf("invalid cursor: %w", err)
-		}
-		filter.StartingAfter = cursor
+	var req ReplaceImageTagsRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data: "+err.Error())
 	}
 
-	// Apply sort field
-	if sortBy != nil {
-		switch *sortBy {
-		case "relevance":
-			filter.SortBy = models.SortByRelevance
-		case "created_at":
-			filter.SortBy = models.SortByCreatedAt
-		case "title":
-			filter.SortBy = models.SortByTitle
-		case "tag_count":
-			filter.SortBy = models.SortByTagCount
-		case "dimensions":
-			filter.SortBy = models.SortByDimensions
-		case "random":
-			filter.SortBy = models.SortByRandom
-			if randomSeed != nil {
-				filter.RandomSeed = randomSeed
-			} else {
-				return fmt.Errorf("seed required for random sort")
-			}
-		default:
-			return fmt.Errorf("invalid sort_by option: %s", *sortBy)
-		}
+	if len(req.ImageIDs) == 0 || req.FromTagID == "" || req.ToTagID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "image_ids, from_tag_id and to_tag_id are required")
 	}
 
-	// Apply sort direction
-	if sortDirection != nil {
-		switch *sortDirection {
-		case "asc":
-			filter.SortDirection = utils.SortDirectionAsc
-		case "desc":
-			filter.SortDirection = utils.SortDirectionDesc
-		default:
-			return fmt.Errorf("invalid sort_direction option: %s", *sortDirection)
+	replaced, err := h.repository.ReplaceTag(ctx, req.ImageIDs, req.FromTagID, req.ToTagID)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrInvalidInput):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, utils.ErrImageNotFound), errors.Is(err, utils.ErrTagNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
 		}
+		log.Error().Err(err).Msg("Error replacing image tags")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to replace image tags")
 	}
 
-	return nil
+	return c.JSON(http.StatusOK, map[string]any{
+		"replaced": replaced,
+	})
 }
 
-// formatPaginatedResponse creates a standardized response with pagination info
-func formatPaginatedResponse(result *models.PaginatedImageResult, encryptionKey string) (map[string]interface{}, error) {
-	response := map[string]interface{}{
-		"data":        result.Data,
-		"has_more":    result.HasMore,
-		"total_count": result.TotalCount,
+// CopyImageMetadataRequest selects the optional metadata copied from the source image
+type CopyImageMetadataRequest struct {
+	Sources     bool `json:"sources"`     // Add the sources of the source image
+	Title       bool `json:"title"`       // Replace the title with that of the source image
+	Description bool `json:"description"` // Replace the description with that of the source image
+}
+
+// CopyImageMetadata adds the tags and people of another image to this one, and
+// optionally its sources, title and description
+func (h *ImageHandler) CopyImageMetadata(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req CopyImageMetadataRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data: "+err.Error())
 	}
 
-	if result.NextCursor != nil {
-		cursor, err := utils.EncryptCursor(result.NextCursor, encryptionKey)
-		if err != nil {
-			return nil, fmt.Errorf("failed to encrypt cursor: %w", err)
+	imageModel, err := h.repository.CopyMetadata(ctx, c.Param("id"), c.Param("sourceId"), repositories.MetadataCopyOptions{
+		Sources:     req.Sources,
+		Title:       req.Title,
+		Description: req.Description,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrInvalidInput):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, utils.ErrImageNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Image not found")
 		}
-		response["next_cursor"] = cursor
+		log.Error().Err(err).Msg("Error copying image metadata")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to copy image metadata")
 	}
 
-	return response, nil
+	return c.JSON(http.StatusOK, dtos.ImageFromModel(imageModel))
 }
 
-type ListImagesRequest struct {
-	Limit         *int    `query:"limit"`
-	StartingAfter *string `query:"starting_after"`
-	SortBy        *string `query:"sort_by"`
-	SortDirection *string `query:"sort_direction"`
-	RandomSeed    *string `query:"random_seed"`
-}
+// Tag import settings
+const (
+	tagImportBatchSize     = 200 // Rows applied per transaction
+	tagImportPathSeparator = ">" // Separates the tags of a path, such as "Animals > Cats"
+)
 
-func (h *ImageHandler) ListImages(c echo.Context) error {
-	var req ListImagesRequest
-	if err := c.Bind(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
-	}
+// TagImportRowResult reports the outcome of a single row of a tag import
+type TagImportRowResult struct {
+	Row       int    `json:"row"`                // Line of the CSV on which the row starts
+	Image     string `json:"image"`              // Image reference given in the row
+	ImageID   string `json:"image_id,omitempty"` // UUID of the resolved image
+	TagsAdded int    `json:"tags_added"`         // Number of tags newly added to the image
+	Error     string `json:"error,omitempty"`    // Reason the row failed, if it did
+}
 
+// ImportImageTags adds tags to images from a CSV, given as the request body or as a
+// "file" form field. Each row names an image by UUID, MD5 or SHA1 hash, followed by tag
+// names, which may be split across columns or comma-separated within one. Missing tags
+// are created. Rows are applied in batches and reported individually, so a bad row does
+// not fail the import. Pass header=true to skip a header row.
+func (h *ImageHandler) ImportImageTags(c echo.Context) error {
 	ctx := c.Request().Context()
-	filter := models.ImageFilter{}
 
-	// Apply pagination and sorting
-	err := applyImagesPaginationAndSorting(&filter, req.Limit, req.StartingAfter,
-		req.SortBy, req.SortDirection, req.RandomSeed, h.container.Config.EncryptionKey)
+	var body io.Reader = c.Request().Body
+	if strings.Contains(c.Request().Header.Get("Content-Type"), "multipart/form-data") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Error getting CSV file: "+err.Error())
+		}
 
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-	}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Unable to open uploaded file")
+		}
+		defer file.Close()
 
-	// Execute search
-	images, err := h.repository.Search(ctx, filter)
-	if err != nil {
-		log.Error().Err(err).Msg("Error listing images")
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list images")
+		body = file
 	}
 
-	// Format response
-	response, err := formatPaginatedResponse(images, h.container.Config.EncryptionKey)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	skipHeader := c.QueryParam("header") == "true"
+
+	var results []TagImportRowResult
+	var batch []repositories.ImageTagAssignment
+	var batchRows []int
+
+	// Tags are resolved once per import, keyed by their path
+	tagIDs := make(map[string]int64)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		assigned, err := h.repository.AssignTags(ctx, batch)
+		if err != nil {
+			return err
+		}
+
+		for i, result := range assigned {
+			row := &results[batchRows[i]]
+			row.ImageID = result.ImageUUID
+			row.TagsAdded = result.Added
+			if result.Err != nil {
+				row.Error = result.Err.Error()
+			}
+		}
+
+		batch = batch[:0]
+		batchRows = batchRows[:0]
+		return nil
 	}
 
-	return c.JSON(http.StatusOK, response)
-}
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// Parse errors already name the line they occurred on
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid CSV: %v", err))
+		}
 
-func (h *ImageHandler) GetImage(c echo.Context) error {
-	id := c.Param("id")
-	ctx := c.Request().Context()
+		if first && skipHeader {
+			continue
+		}
 
-	imageModel, err := h.repository.GetByUUID(ctx, id)
-	if err != nil {
-		if errors.Is(err, utils.ErrImageNotFound) {
-			return echo.NewHTTPError(http.StatusNotFound, "Image not found")
+		// Quoted fields may span lines and blank lines are skipped, so rows are reported
+		// by the line they start on rather than counted
+		line, _ := reader.FieldPos(0)
+
+		row := TagImportRowResult{Row: line, Image: strings.TrimSpace(record[0])}
+		if row.Image == "" {
+			row.Error = "missing image reference"
+			results = append(results, row)
+			continue
+		}
+
+		assignment := repositories.ImageTagAssignment{ImageRef: row.Image}
+		for _, column := range record[1:] {
+			for _, name := range strings.Split(column, ",") {
+				name = strings.TrimSpace(name)
+				if name == "" {
+					continue
+				}
+
+				tagID, ok := tagIDs[name]
+				if !ok {
+					tag, err := h.tags.ResolvePath(ctx, strings.Split(name, tagImportPathSeparator))
+					if err != nil {
+						row.Error = fmt.Sprintf("tag %q: %v", name, err)
+						break
+					}
+					tagID = tag.ID
+					tagIDs[name] = tagID
+				}
+
+				assignment.TagIDs = append(assignment.TagIDs, tagID)
+			}
+
+			if row.Error != "" {
+				break
+			}
+		}
+
+		if row.Error == "" && len(assignment.TagIDs) == 0 {
+			row.Error = "no tags given"
+		}
+
+		results = append(results, row)
+		if row.Error != "" {
+			continue
+		}
+
+		batch = append(batch, assignment)
+		batchRows = append(batchRows, len(results)-1)
+
+		if len(batch) >= tagImportBatchSize {
+			if err := flush(); err != nil {
+				log.Error().Err(err).Msg("Error importing image tags")
+				return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to import image tags at line %d", line))
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		log.Error().Err(err).Msg("Error importing image tags")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to import image tags")
+	}
+
+	failed := 0
+	for _, row := range results {
+		if row.Error != "" {
+			failed++
 		}
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve image")
 	}
 
-	return c.JSON(http.StatusCreated, imageModel)
+	return c.JSON(http.StatusOK, map[string]any{
+		"rows":      results,
+		"succeeded": len(results) - failed,
+		"failed":    failed,
+	})
 }
 
 func (h *ImageHandler) UpdateImage(c echo.Context) error {
@@ -413,9 +1454,12 @@ func (h *ImageHandler) UpdateImage(c echo.Context) error {
 	var updateData struct {
 		Title       *string              `json:"title"`
 		Description *string              `json:"description"`
+		Language    *string              `json:"language"`
+		Rating      *int                 `json:"rating"`
 		Tags        []ImageTagRequest    `json:"tags"`
 		People      []ImagePersonRequest `json:"people"`
 		Sources     []ImageSourceRequest `json:"sources"`
+		Lenient     bool                 `json:"lenient"`
 	}
 
 	if err := c.Bind(&updateData); err != nil {
@@ -431,6 +1475,21 @@ func (h *ImageHandler) UpdateImage(c echo.Context) error {
 		existingImage.Description = updateData.Description
 	}
 
+	if updateData.Rating != nil {
+		existingImage.Rating = updateData.Rating
+	}
+
+	// An empty language clears it
+	if updateData.Language != nil {
+		if *updateData.Language == "" {
+			existingImage.Language = nil
+		} else if err := h.checkLanguage(updateData.Language); err != nil {
+			return err
+		} else {
+			existingImage.Language = updateData.Language
+		}
+	}
+
 	// Convert API request tags to model tags
 	if updateData.Tags != nil {
 		var tags []*models.ImageTag
@@ -449,10 +1508,11 @@ func (h *ImageHandler) UpdateImage(c echo.Context) error {
 	if updateData.People != nil {
 		var people []*models.ImagePerson
 		for _, personReq := range updateData.People {
-			if personReq.ID != "" && personReq.Role != "" {
+			role := h.personRole(personReq.Role)
+			if personReq.ID != "" && role != "" {
 				people = append(people, &models.ImagePerson{
 					UUID: personReq.ID,
-					Role: personReq.Role,
+					Role: role,
 				})
 			}
 		}
@@ -468,6 +1528,7 @@ func (h *ImageHandler) UpdateImage(c echo.Context) error {
 					URL:         sourceReq.URL,
 					Title:       sourceReq.Title,
 					Description: sourceReq.Description,
+					IsPrimary:   sourceReq.IsPrimary,
 				})
 			}
 		}
@@ -475,11 +1536,68 @@ func (h *ImageHandler) UpdateImage(c echo.Context) error {
 	}
 
 	// Save updates
-	if err := h.repository.Upsert(ctx, existingImage); err != nil {
+	if err := h.repository.Upsert(ctx, existingImage, repositories.UpsertOptions{Lenient: updateData.Lenient}); err != nil {
+		if errors.Is(err, utils.ErrInvalidInput) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		var immutableErr *utils.ImmutableFieldError
+		if errors.As(err, &immutableErr) {
+			return echo.NewHTTPError(http.StatusConflict, immutableErr.Error())
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update image: "+err.Error())
 	}
 
-	return c.JSON(http.StatusOK, existingImage)
+	return c.JSON(http.StatusOK, dtos.ImageFromModel(existingImage))
+}
+
+type SetImageRatingRequest struct {
+	Rating *int `json:"rating"` // New rating, or null to clear it
+}
+
+// SetImageRating sets or clears the rating of an image
+func (h *ImageHandler) SetImageRating(c echo.Context) error {
+	id := c.Param("id")
+	ctx := c.Request().Context()
+
+	if err := dtos.Validate.Var(id, "uuid"); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid image ID")
+	}
+
+	var req SetImageRatingRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data: "+err.Error())
+	}
+
+	imageModel, err := h.repository.SetRating(ctx, id, req.Rating)
+	if err != nil {
+		if errors.Is(err, utils.ErrImageNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Image not found")
+		}
+		if errors.Is(err, utils.ErrInvalidInput) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update image rating: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, dtos.ImageFromModel(imageModel))
+}
+
+// RecordImageView counts a view of an image. Views are buffered and added to the stored
+// count periodically, so the count returned with an image lags behind recent views.
+func (h *ImageHandler) RecordImageView(c echo.Context) error {
+	id := strings.ToLower(c.Param("id"))
+	ctx := c.Request().Context()
+
+	if err := dtos.Validate.Var(id, "uuid"); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid image ID")
+	}
+
+	if err := h.views.Increment(ctx, id); err != nil {
+		log.Error().Err(err).Msgf("Error recording view of image %s", id)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to record image view")
+	}
+
+	return c.NoContent(http.StatusAccepted)
 }
 
 func (h *ImageHandler) DeleteImage(c echo.Context) error {
@@ -511,18 +1629,37 @@ func (h *ImageHandler) DeleteImage(c echo.Context) error {
 		log.Error().Err(err).Str("key", storageKey).Msg("Failed to delete image object from storage")
 	}
 
+	// A thumbnail may have been uploaded without being recorded, so delete it regardless
+	if err := h.container.S3.Delete(ctx, imageModel.GetThumbnailName()); err != nil {
+		log.Error().Err(err).Str("key", imageModel.GetThumbnailName()).Msg("Failed to delete image thumbnail from storage")
+	}
+
 	return c.NoContent(http.StatusNoContent)
 }
 
+// maxSimilarityReferences bounds how many reference images a similarity search can combine
+const maxSimilarityReferences = 20
+
 type SearchImagesRequest struct {
 	// Full text search
 	Title       *string `query:"title"`
 	Description *string `query:"description"`
 	Source      *string `query:"source"`
+	Filename    *string `query:"filename"`
+
+	// Language whose subfields the title and description are matched against
+	Language *string `query:"language"`
 
 	// Basic filtering
 	Hash *string `query:"hash"`
 
+	// Capture device filtering
+	CameraMake  *string `query:"camera_make"`
+	CameraModel *string `query:"camera_model"`
+
+	// Facets to aggregate over the matching images
+	Facets []string `query:"facets" validate:"omitempty,dive,oneof=camera_make camera_model"`
+
 	// Dimension filtering
 	MinWidth  *int `query:"min_width"`
 	MaxWidth  *int `query:"max_width"`
@@ -533,9 +1670,26 @@ type SearchImagesRequest struct {
 	SinceDate  *string `query:"since_date"`
 	BeforeDate *string `query:"before_date"`
 
+	// Metadata presence filtering
+	HasTitle       *bool `query:"has_title"`
+	HasDescription *bool `query:"has_description"`
+
+	// Rating filtering
+	MinRating *int `query:"min_rating"`
+	MaxRating *int `query:"max_rating"`
+
 	// Vector similarity
-	SimilarToID         *string  `query:"similar_to_id"`
-	SimilarityThreshold *float64 `query:"similarity_threshold"`
+	SimilarToID           *string  `query:"similar_to_id"`
+	SimilarToIDs          []string `query:"similar_to_ids" validate:"omitempty,max=20,dive,uuid"`
+	SimilarToText         *string  `query:"similar_to_text" validate:"omitempty,max=1000"`
+	IncludeReference      *bool    `query:"include_reference"`
+	SimilarityCombination *string  `query:"similarity_combination" validate:"omitempty,oneof=mean max"`
+	SimilarityThreshold   *float64 `query:"similarity_threshold"`
+	DissimilarToID        *string  `query:"dissimilar_to_id" validate:"omitempty,uuid"`
+
+	// SimilarityMetric states the metric the caller expects scores in. The metric is fixed
+	// by the vector collection, so this only guards against misreading scores.
+	SimilarityMetric *string `query:"similarity_metric" validate:"omitempty,oneof=cosine dot euclid manhattan"`
 
 	// Tag filtering
 	TagFilters []models.ImageTagFilter `query:"tag_filters"`
@@ -544,8 +1698,9 @@ type SearchImagesRequest struct {
 	PersonFilters []models.ImagePersonFilter `query:"person_filters"`
 
 	// Sorting & pagination
-	Limit         *int    `query:"limit"`
+	Limit         *int    `query:"limit" validate:"omitempty,min=1,max=100"`
 	StartingAfter *string `query:"starting_after"`
+	EndingBefore  *string `query:"ending_before"`
 	SortBy        *string `query:"sort_by"`
 	SortDirection *string `query:"sort_direction"`
 
@@ -575,14 +1730,31 @@ func (h *ImageHandler) SearchImages(c echo.Context) error {
 		}
 	}
 
+	if err := dtos.Validate.Struct(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
 	ctx := c.Request().Context()
 
+	// Read the uploaded reference images up front, as they decide the sort
+	var form *multipart.Form
+	var referenceFiles []*multipart.FileHeader
+	if isMultipart {
+		var err error
+		form, err = c.MultipartForm()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Unable to read uploaded files")
+		}
+		referenceFiles = form.File["image"]
+	}
+
 	// Build filter from request
 	filter := models.ImageFilter{}
 
 	// Apply pagination and sorting
-	err := applyImagesPaginationAndSorting(&filter, req.Limit, req.StartingAfter,
-		req.SortBy, req.SortDirection, req.RandomSeed, h.container.Config.EncryptionKey)
+	sortBy, sortDirection := referenceSearchSort(req.SortBy, req.SortDirection, len(referenceFiles))
+	err := applyImagesPaginationAndSorting(&filter, req.Limit, req.StartingAfter, req.EndingBefore,
+		sortBy, sortDirection, req.RandomSeed, h.container.Config.EncryptionKey, h.container.Config.ParamMode)
 
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
@@ -597,14 +1769,35 @@ func (h *ImageHandler) SearchImages(c echo.Context) error {
 		filter.Description = *req.Description
 	}
 
+	if req.Language != nil {
+		if err := h.checkLanguage(req.Language); err != nil {
+			return err
+		}
+		filter.Language = *req.Language
+	}
+
 	if req.Source != nil {
 		filter.Source = *req.Source
 	}
 
+	if req.Filename != nil {
+		filter.Filename = *req.Filename
+	}
+
 	if req.Hash != nil {
 		filter.Hash = *req.Hash
 	}
 
+	if req.CameraMake != nil {
+		filter.CameraMake = *req.CameraMake
+	}
+
+	if req.CameraModel != nil {
+		filter.CameraModel = *req.CameraModel
+	}
+
+	filter.Facets = req.Facets
+
 	// Apply dimension filtering
 	if req.MinWidth != nil {
 		filter.MinWidth = *req.MinWidth
@@ -622,21 +1815,27 @@ func (h *ImageHandler) SearchImages(c echo.Context) error {
 		filter.MaxHeight = *req.MaxHeight
 	}
 
+	// Apply metadata presence filtering
+	filter.HasTitle = req.HasTitle
+	filter.HasDescription = req.HasDescription
+
+	// Apply rating filtering
+	filter.MinRating = req.MinRating
+	filter.MaxRating = req.MaxRating
+
 	// Apply date filtering
 	if req.SinceDate != nil {
-		// Parse time from string
-		sinceTime, err := time.Parse(time.RFC3339, *req.SinceDate)
+		sinceTime, err := utils.ParseSearchDate(*req.SinceDate, h.container.Config.SearchLocation)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, "Invalid since_date format, expected RFC3339")
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid since_date format, expected RFC3339 or YYYY-MM-DD")
 		}
 		filter.SinceDate = &sinceTime
 	}
 
 	if req.BeforeDate != nil {
-		// Parse time from string
-		beforeTime, err := time.Parse(time.RFC3339, *req.BeforeDate)
+		beforeTime, err := utils.ParseSearchDate(*req.BeforeDate, h.container.Config.SearchLocation)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, "Invalid before_date format, expected RFC3339")
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid before_date format, expected RFC3339 or YYYY-MM-DD")
 		}
 		filter.BeforeDate = &beforeTime
 	}
@@ -645,13 +1844,46 @@ func (h *ImageHandler) SearchImages(c echo.Context) error {
 	if req.SimilarToID != nil {
 		filter.SimilarToID = *req.SimilarToID
 	}
+	filter.SimilarToIDs = req.SimilarToIDs
+
+	if req.SimilarToText != nil {
+		filter.SimilarToText = strings.TrimSpace(*req.SimilarToText)
+	}
+
+	if req.IncludeReference != nil {
+		filter.IncludeReference = *req.IncludeReference
+	}
+
+	if req.SimilarityCombination != nil {
+		filter.SimilarityCombination = utils.EmbeddingCombination(*req.SimilarityCombination)
+	}
+
+	if req.DissimilarToID != nil {
+		filter.DissimilarToID = *req.DissimilarToID
+	}
+
+	if req.SimilarityMetric != nil {
+		if metric := h.container.Qdrant.Metric(); *req.SimilarityMetric != metric {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("similarity_metric %s does not match the %s metric of the vector collection", *req.SimilarityMetric, metric))
+		}
+	}
 
 	// Apply tag filters
+	for _, tagFilter := range req.TagFilters {
+		if err := tagFilter.Validate(); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
 	if len(req.TagFilters) > 0 {
 		filter.TagFilters = req.TagFilters
 	}
 
 	// Apply person filters
+	for _, personFilter := range req.PersonFilters {
+		if err := personFilter.Validate(); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
 	if len(req.PersonFilters) > 0 {
 		filter.PersonFilters = req.PersonFilters
 	}
@@ -661,39 +1893,84 @@ func (h *ImageHandler) SearchImages(c echo.Context) error {
 		filter.SimilarityThreshold = *req.SimilarityThreshold
 	}
 
-	// Process file upload if present
+	// Process file uploads if present, each one a reference image
 	if isMultipart {
-		file, err := c.FormFile("image")
-		if err == nil { // Only process if there's an image file
+		if len(referenceFiles)+len(filter.SimilarToIDs) > maxSimilarityReferences {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("At most %d reference images can be given", maxSimilarityReferences))
+		}
+
+		for _, file := range referenceFiles {
 			src, err := file.Open()
 			if err != nil {
 				return echo.NewHTTPError(http.StatusBadRequest, "Unable to open uploaded file")
 			}
-			defer src.Close()
 
 			// Get embedding from the image file
 			embedding, err := h.container.Clip.GetEmbeddingFromReader(ctx, src)
+			src.Close()
+			if errors.Is(err, clip.ErrBusy) {
+				return echo.NewHTTPError(http.StatusTooManyRequests, "Embedding service is busy, try again later")
+			}
 			if err != nil {
 				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get image embedding")
 			}
+			if err := utils.ValidateEmbedding(embedding); err != nil {
+				return echo.NewHTTPError(http.StatusUnprocessableEntity, "Unable to compute a valid embedding for the uploaded image")
+			}
 			vecEmbedding := pgvector.NewVector(embedding)
-			filter.SimilarToEmbedding = &vecEmbedding
 
-			// Force sort by similarity
-			filter.SortBy = models.SortByRelevance
-			filter.SortDirection = utils.SortDirectionDesc
+			if filter.SimilarToEmbedding == nil {
+				filter.SimilarToEmbedding = &vecEmbedding
+			} else {
+				filter.SimilarToEmbeddings = append(filter.SimilarToEmbeddings, vecEmbedding)
+			}
+		}
+
+		// An optional upload of an image to steer results away from
+		if dissimilarFiles := form.File["dissimilar_image"]; len(dissimilarFiles) > 0 {
+			src, err := dissimilarFiles[0].Open()
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Unable to open uploaded file")
+			}
+
+			embedding, err := h.container.Clip.GetEmbeddingFromReader(ctx, src)
+			src.Close()
+			if errors.Is(err, clip.ErrBusy) {
+				return echo.NewHTTPError(http.StatusTooManyRequests, "Embedding service is busy, try again later")
+			}
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get image embedding")
+			}
+			if err := utils.ValidateEmbedding(embedding); err != nil {
+				return echo.NewHTTPError(http.StatusUnprocessableEntity, "Unable to compute a valid embedding for the uploaded image")
+			}
+			vecEmbedding := pgvector.NewVector(embedding)
+			filter.DissimilarToEmbedding = &vecEmbedding
 		}
 	}
 
+	// Negative examples only refine a similarity search
+	if (filter.DissimilarToID != "" || filter.DissimilarToEmbedding != nil) && !filter.HasSimilarity() {
+		return echo.NewHTTPError(http.StatusBadRequest, "Dissimilar references require a similarity reference")
+	}
+
 	// Execute search
+	started := time.Now()
 	images, err := h.repository.Search(ctx, filter)
 	if err != nil {
+		if errors.Is(err, clip.ErrBusy) {
+			return echo.NewHTTPError(http.StatusTooManyRequests, "Embedding service is busy, try again later")
+		}
 		log.Error().Err(err).Msg("Error searching images")
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to search images")
 	}
 
+	if h.container.Config.SearchLoggingEnabled {
+		h.recordSearch(ctx, &filter, images, time.Since(started))
+	}
+
 	// Format response
-	response, err := formatPaginatedResponse(images, h.container.Config.EncryptionKey)
+	response, err := formatPaginatedResponse(images, imageCursorSignature(&filter), h.container.Config.EncryptionKey)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
@@ -0,0 +1,83 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/foresturquhart/curator/server/models"
+	"github.com/foresturquhart/curator/server/utils"
+	"github.com/labstack/echo/v4"
+)
+
+func TestReferenceSearchCursor(t *testing.T) {
+	const key = "secret"
+
+	// First page of a search by one uploaded reference, with no sort requested
+	sortBy, sortDirection := referenceSearchSort(nil, nil, 1)
+
+	var first models.ImageFilter
+	if err := applyImagesPaginationAndSorting(&first, nil, nil, nil, sortBy, sortDirection, nil, key, utils.ParamModeStrict); err != nil {
+		t.Fatalf("first page: %v", err)
+	}
+	if first.SortBy != models.SortByRelevance || first.SortDirection != utils.SortDirectionDesc {
+		t.Fatalf("first page sorted by %s %s, want relevance desc", first.SortBy, first.SortDirection)
+	}
+
+	next, err := utils.EncryptCursor(utils.KeysetCursor(0.9, int64(42)), imageCursorSignature(&first), key)
+	if err != nil {
+		t.Fatalf("encrypting cursor: %v", err)
+	}
+
+	// Second page of the same search, continuing from the cursor of the first
+	sortBy, sortDirection = referenceSearchSort(nil, nil, 1)
+
+	var second models.ImageFilter
+	if err := applyImagesPaginationAndSorting(&second, nil, &next, nil, sortBy, sortDirection, nil, key, utils.ParamModeStrict); err != nil {
+		t.Fatalf("second page: %v", err)
+	}
+	if len(second.StartingAfter) != 2 {
+		t.Fatalf("second page cursor has %d values, want 2", len(second.StartingAfter))
+	}
+}
+
+func TestReferenceSearchSortKeepsRequestedSortWithoutUploads(t *testing.T) {
+	requested, direction := "created_at", "asc"
+
+	sortBy, sortDirection := referenceSearchSort(&requested, &direction, 0)
+	if sortBy != &requested || sortDirection != &direction {
+		t.Fatalf("got %v %v, want the requested sort", *sortBy, *sortDirection)
+	}
+}
+
+func TestImportImageTagsReportsLines(t *testing.T) {
+	// A quoted field spanning lines and a blank line both put rows out of step with a count
+	body := ",a\n\n\"\",\"first\nsecond\"\n,b\n"
+
+	req := httptest.NewRequest(http.MethodPost, "/images/tags/import", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	if err := (&ImageHandler{}).ImportImageTags(c); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	var response struct {
+		Rows []TagImportRowResult `json:"rows"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	want := []int{1, 3, 5}
+	if len(response.Rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(response.Rows), len(want))
+	}
+	for i, row := range response.Rows {
+		if row.Row != want[i] {
+			t.Errorf("row %d reported on line %d, want %d", i, row.Row, want[i])
+		}
+	}
+}
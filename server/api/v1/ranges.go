@@ -0,0 +1,74 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// errRangeNotSatisfiable is returned for a range that lies entirely beyond the content
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// byteRange is an inclusive span of bytes within some content
+type byteRange struct {
+	start int64
+	end   int64
+}
+
+// parseByteRange reads a Range header against content of the given size. Only a single
+// byte range is supported, so the boolean is false, and the whole content should be
+// served, when the header is missing, malformed or asks for several ranges.
+func parseByteRange(header string, size int64) (byteRange, bool, error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return byteRange{}, false, nil
+	}
+
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return byteRange{}, false, nil
+	}
+
+	// A suffix range selects the final bytes of the content
+	if first == "" {
+		length, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || length < 0 {
+			return byteRange{}, false, nil
+		}
+		if length == 0 || size == 0 {
+			return byteRange{}, false, errRangeNotSatisfiable
+		}
+		return byteRange{start: max(size-length, 0), end: size - 1}, true, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return byteRange{}, false, nil
+	}
+
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return byteRange{}, false, nil
+		}
+		end = min(end, size-1)
+	}
+
+	if start >= size {
+		return byteRange{}, false, errRangeNotSatisfiable
+	}
+
+	return byteRange{start: start, end: end}, true, nil
+}
+
+// contentRange formats the Content-Range header value for a range of content of the given size
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.end, size)
+}
+
+// length is the number of bytes in the range
+func (r byteRange) length() int64 {
+	return r.end - r.start + 1
+}
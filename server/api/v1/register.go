@@ -1,11 +1,14 @@
 package v1
 
 import (
+	"strings"
+
 	"github.com/foresturquhart/curator/server/api/v1/handlers"
 	"github.com/foresturquhart/curator/server/container"
 	"github.com/foresturquhart/curator/server/repositories"
 	"github.com/foresturquhart/curator/server/services"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 )
 
 func registerImageRoutes(g *echo.Group, c *container.Container, repo *repositories.ImageRepository) {
@@ -15,11 +18,27 @@ func registerImageRoutes(g *echo.Group, c *container.Container, repo *repositori
 
 	// Create
 	images.POST("", handler.CreateImage)
+	images.POST("/from-url", handler.CreateImageFromURL)
+	images.POST("/batch", handler.CreateImageBatch)
 	images.GET("", handler.ListImages)
+	images.GET("/by-source", handler.ListImagesBySource)
 	images.GET("/:id", handler.GetImage)
+	images.GET("/:id/raw", handler.GetImageRaw)
+	images.GET("/:id/tags", handler.GetImageTags)
+	images.GET("/:id/url", handler.GetImageURL)
+	images.GET("/:id/thumbnail", handler.GetImageThumbnail)
 	images.PUT("/:id", handler.UpdateImage)
 	images.DELETE("/:id", handler.DeleteImage)
 	images.POST("/search", handler.SearchImages)
+	images.GET("/searches/top", handler.TopSearches)
+	images.POST("/batch-get", handler.BatchGetImages)
+	images.POST("/verify", handler.VerifyAllImages)
+	images.POST("/tags/replace", handler.ReplaceImageTags)
+	images.POST("/tags/import", handler.ImportImageTags)
+	images.POST("/:id/verify", handler.VerifyImage)
+	images.POST("/:id/rating", handler.SetImageRating)
+	images.POST("/:id/copy-metadata-from/:sourceId", handler.CopyImageMetadata)
+	images.POST("/:id/view", handler.RecordImageView)
 }
 
 func registerPersonRoutes(g *echo.Group, c *container.Container, svc *services.PersonService) {
@@ -34,11 +53,78 @@ func registerPersonRoutes(g *echo.Group, c *container.Container, svc *services.P
 	people.PUT("/:uuid", handler.UpdatePerson)
 	people.DELETE("/:uuid", handler.DeletePerson)
 	people.POST("/search", handler.SearchPeople)
+	people.GET("/export", handler.ExportPeople)
+}
+
+func registerTagRoutes(g *echo.Group, c *container.Container, svc *services.TagService) {
+	handler := handlers.NewTagHandler(c, svc)
+
+	tags := g.Group("/tags")
+
+	tags.POST("", handler.CreateTag)
+	tags.GET("", handler.ListTags)
+	tags.GET("/tree", handler.GetTagTree)
+	tags.POST("/search", handler.SearchTags)
+	tags.GET("/:uuid", handler.GetTag)
+	tags.PUT("/:uuid", handler.UpdateTag)
+	tags.DELETE("/:uuid", handler.DeleteTag)
+	tags.GET("/:uuid/tree", handler.GetTagTree)
+	tags.GET("/export", handler.ExportTags)
+	tags.POST("/ensure-path", handler.EnsureTagPath)
+	tags.POST("/bulk-move", handler.BulkMoveTags)
+	tags.GET("/:uuid/stats", handler.GetTagStats)
 }
 
-func RegisterRoutes(e *echo.Echo, c *container.Container, repo *repositories.ImageRepository, svc *services.PersonService) {
+func registerCollectionRoutes(g *echo.Group, c *container.Container, repo *repositories.CollectionRepository) {
+	handler := handlers.NewCollectionHandler(c, repo)
+
+	collections := g.Group("/collections")
+
+	collections.PUT("/:uuid/order", handler.OrderCollection)
+}
+
+func registerAdminRoutes(g *echo.Group, c *container.Container, repo *repositories.ImageRepository) {
+	handler := handlers.NewAdminHandler(c, repo)
+
+	admin := g.Group("/admin", handlers.RequireAdminToken(c.Config.AdminToken))
+
+	admin.GET("/images/missing-embeddings", handler.ListImagesWithoutEmbedding)
+	admin.GET("/images/:id/document", handler.GetImageDocument)
+	admin.POST("/images/thumbnails", handler.GenerateThumbnails)
+	admin.POST("/vectors/reconcile", handler.ReconcileVectors)
+	admin.POST("/tags/rebalance", handler.RebalanceTags)
+	admin.GET("/tags/closure", handler.VerifyTagClosure)
+	admin.POST("/tags/closure/rebuild", handler.RebuildTagClosure)
+	admin.GET("/tasks/summary", handler.GetTaskSummary)
+	admin.POST("/indexes/:name/rebuild", handler.RebuildIndex)
+	admin.GET("/reindex/:jobId", handler.GetReindexJob)
+	admin.GET("/reindex/:jobId/stream", handler.StreamReindexJob)
+	admin.POST("/reindex/:jobId/cancel", handler.CancelReindexJob)
+}
+
+func RegisterRoutes(e *echo.Echo, c *container.Container, repo *repositories.ImageRepository, svc *services.PersonService, tagSvc *services.TagService, collectionRepo *repositories.CollectionRepository) {
+	e.GET("/health", handlers.NewHealthHandler(c).Health)
+
 	group := e.Group("/v1")
 
+	// Compress API responses, leaving small ones such as errors uncompressed
+	if c.Config.CompressionEnabled {
+		group.Use(middleware.GzipWithConfig(middleware.GzipConfig{
+			Level:     c.Config.CompressionLevel,
+			MinLength: c.Config.CompressionMinLength,
+			// Event streams must reach the client as each event is written, byte
+			// ranges of originals must refer to the stored bytes, and thumbnails are
+			// already compressed
+			Skipper: func(c echo.Context) bool {
+				return strings.HasSuffix(c.Path(), "/stream") || strings.HasSuffix(c.Path(), "/raw") ||
+					strings.HasSuffix(c.Path(), "/thumbnail")
+			},
+		}))
+	}
+
 	registerImageRoutes(group, c, repo)
 	registerPersonRoutes(group, c, svc)
+	registerTagRoutes(group, c, tagSvc)
+	registerCollectionRoutes(group, c, collectionRepo)
+	registerAdminRoutes(group, c, repo)
 }
@@ -0,0 +1,169 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/foresturquhart/curator/server/models"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	searchLogTimeout = 5 * time.Second
+
+	defaultTopSearchDays  = 7
+	defaultTopSearchLimit = 20
+	maxTopSearchLimit     = 100
+)
+
+// recordSearch logs a completed image search in the background, so that a slow or
+// failing write never holds up the response
+func (h *ImageHandler) recordSearch(ctx context.Context, filter *models.ImageFilter, result *models.PaginatedImageResult, latency time.Duration) {
+	entry := &models.SearchLogEntry{
+		Query:       normaliseSearchTerms(filter.Title, filter.Description),
+		Filters:     summariseImageFilter(filter),
+		ResultCount: result.TotalCount,
+		Latency:     latency,
+		Partial:     result.Partial,
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), searchLogTimeout)
+		defer cancel()
+
+		if err := h.searchLog.Record(ctx, entry); err != nil {
+			log.Error().Err(err).Msg("Failed to record search")
+		}
+	}()
+}
+
+// normaliseSearchTerms folds free-text terms into a single lowercase string with
+// collapsed whitespace, so that equivalent searches aggregate together
+func normaliseSearchTerms(terms ...string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.Join(terms, " ")), " "))
+}
+
+// summariseImageFilter describes the filters of an image search for the query log.
+// Hashes, source URLs, cursors, shuffle seeds and uploaded images are deliberately
+// left out, recording at most that they were used.
+func summariseImageFilter(filter *models.ImageFilter) map[string]any {
+	summary := map[string]any{}
+
+	if filter.Source != "" {
+		summary["source"] = true
+	}
+	if filter.Filename != "" {
+		summary["filename"] = true
+	}
+	if filter.Hash != "" {
+		summary["hash"] = true
+	}
+	if filter.CameraMake != "" {
+		summary["camera_make"] = filter.CameraMake
+	}
+	if filter.CameraModel != "" {
+		summary["camera_model"] = filter.CameraModel
+	}
+	if filter.MinWidth > 0 {
+		summary["min_width"] = filter.MinWidth
+	}
+	if filter.MaxWidth > 0 {
+		summary["max_width"] = filter.MaxWidth
+	}
+	if filter.MinHeight > 0 {
+		summary["min_height"] = filter.MinHeight
+	}
+	if filter.MaxHeight > 0 {
+		summary["max_height"] = filter.MaxHeight
+	}
+	if filter.SinceDate != nil {
+		summary["since_date"] = filter.SinceDate
+	}
+	if filter.BeforeDate != nil {
+		summary["before_date"] = filter.BeforeDate
+	}
+	if filter.HasTitle != nil {
+		summary["has_title"] = *filter.HasTitle
+	}
+	if filter.HasDescription != nil {
+		summary["has_description"] = *filter.HasDescription
+	}
+	if filter.SimilarToID != "" {
+		summary["similar_to_id"] = filter.SimilarToID
+	}
+	if len(filter.SimilarToIDs) > 0 {
+		summary["similar_to_ids"] = filter.SimilarToIDs
+	}
+	if filter.SimilarToText != "" {
+		summary["similar_to_text"] = filter.SimilarToText
+	}
+	if filter.SimilarToEmbedding != nil {
+		summary["similar_to_upload"] = true
+	}
+	if len(filter.SimilarToEmbeddings) > 0 {
+		summary["similar_to_uploads"] = len(filter.SimilarToEmbeddings) + 1
+	}
+	if filter.DissimilarToID != "" {
+		summary["dissimilar_to_id"] = filter.DissimilarToID
+	}
+	if filter.DissimilarToEmbedding != nil {
+		summary["dissimilar_to_upload"] = true
+	}
+	if filter.SimilarityCombination != "" {
+		summary["similarity_combination"] = filter.SimilarityCombination
+	}
+	if len(filter.TagFilters) > 0 {
+		summary["tag_filters"] = filter.TagFilters
+	}
+	if len(filter.PersonFilters) > 0 {
+		summary["person_filters"] = filter.PersonFilters
+	}
+	if filter.SortBy != "" {
+		summary["sort_by"] = filter.SortBy
+	}
+	if filter.SortDirection != "" {
+		summary["sort_direction"] = filter.SortDirection
+	}
+
+	return summary
+}
+
+// TopSearches lists the most frequent search terms over a recent window
+func (h *ImageHandler) TopSearches(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	days := defaultTopSearchDays
+	if raw := c.QueryParam("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "days must be a positive integer")
+		}
+		days = parsed
+	}
+
+	limit := defaultTopSearchLimit
+	if raw := c.QueryParam("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxTopSearchLimit {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxTopSearchLimit))
+		}
+		limit = parsed
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+
+	queries, err := h.searchLog.TopQueries(ctx, since, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Error retrieving top searches")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve top searches")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"data": queries,
+		"days": days,
+	})
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/foresturquhart/curator/server/container"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	imageViewsPendingKey  = "image_views:pending"  // Views recorded since the last flush, by image UUID
+	imageViewsFlushingKey = "image_views:flushing" // Views set aside by a flush in progress
+)
+
+// ImageViewCounter buffers image views in Redis, so that recording a view never touches
+// Postgres. The buffered counts are persisted periodically by a background flush.
+type ImageViewCounter struct {
+	container *container.Container
+}
+
+func NewImageViewCounter(container *container.Container) *ImageViewCounter {
+	return &ImageViewCounter{
+		container: container,
+	}
+}
+
+// Increment records a single view of an image
+func (c *ImageViewCounter) Increment(ctx context.Context, uuid string) error {
+	if err := c.container.Redis.Client.HIncrBy(ctx, imageViewsPendingKey, uuid, 1).Err(); err != nil {
+		return fmt.Errorf("failed to increment image views in redis: %w", err)
+	}
+
+	return nil
+}
+
+// Flush passes the buffered view counts to persist, clearing them once persisted. The
+// counts are set aside first, so views recorded during the flush wait for the next one,
+// and counts left behind by a failed flush are retried before any new ones.
+func (c *ImageViewCounter) Flush(ctx context.Context, persist func(counts map[string]int64) error) error {
+	client := c.container.Redis.Client
+
+	flushing, err := client.Exists(ctx, imageViewsFlushingKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to check for unflushed image views in redis: %w", err)
+	}
+
+	if flushing == 0 {
+		pending, err := client.Exists(ctx, imageViewsPendingKey).Result()
+		if err != nil {
+			return fmt.Errorf("failed to check for pending image views in redis: %w", err)
+		}
+		if pending == 0 {
+			return nil
+		}
+
+		if err := client.Rename(ctx, imageViewsPendingKey, imageViewsFlushingKey).Err(); err != nil {
+			return fmt.Errorf("failed to set aside image views in redis: %w", err)
+		}
+	}
+
+	values, err := client.HGetAll(ctx, imageViewsFlushingKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read image views from redis: %w", err)
+	}
+
+	counts := make(map[string]int64, len(values))
+	for uuid, value := range values {
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			log.Warn().Str("uuid", uuid).Str("value", value).Msg("Skipping invalid image view count")
+			continue
+		}
+		counts[uuid] = count
+	}
+
+	if err := persist(counts); err != nil {
+		return err
+	}
+
+	if err := client.Del(ctx, imageViewsFlushingKey).Err(); err != nil {
+		return fmt.Errorf("failed to clear flushed image views in redis: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/foresturquhart/curator/server/container"
+	"github.com/redis/go-redis/v9"
+)
+
+// IndexCheckpoints records how far a bulk reindex has progressed, so that an interrupted
+// run can resume after the last batch it completed instead of starting over
+type IndexCheckpoints struct {
+	container *container.Container
+}
+
+func NewIndexCheckpoints(container *container.Container) *IndexCheckpoints {
+	return &IndexCheckpoints{
+		container: container,
+	}
+}
+
+func indexCheckpointKey(index string) string {
+	return fmt.Sprintf("index_checkpoint:%s", index)
+}
+
+// Get returns the last ID processed by a bulk reindex of an index, and whether one is recorded
+func (c *IndexCheckpoints) Get(ctx context.Context, index string) (int64, bool, error) {
+	id, err := c.container.Redis.Client.Get(ctx, indexCheckpointKey(index)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read index checkpoint from redis: %w", err)
+	}
+
+	return id, true, nil
+}
+
+// Save records the last ID processed by a bulk reindex of an index
+func (c *IndexCheckpoints) Save(ctx context.Context, index string, id int64) error {
+	if err := c.container.Redis.Client.Set(ctx, indexCheckpointKey(index), id, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save index checkpoint to redis: %w", err)
+	}
+
+	return nil
+}
+
+// Clear removes the checkpoint of an index, so that the next bulk reindex starts from the beginning
+func (c *IndexCheckpoints) Clear(ctx context.Context, index string) error {
+	if err := c.container.Redis.Client.Del(ctx, indexCheckpointKey(index)).Err(); err != nil {
+		return fmt.Errorf("failed to clear index checkpoint from redis: %w", err)
+	}
+
+	return nil
+}
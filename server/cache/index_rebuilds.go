@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/foresturquhart/curator/server/container"
+	"github.com/rs/zerolog/log"
+)
+
+// indexRebuildTTL bounds how long a rebuild stays registered, outliving the longest
+// rebuild task, so that one whose worker died does not keep receiving writes forever
+const indexRebuildTTL = 13 * time.Hour
+
+// IndexRebuilds registers the versions of search indexes being rebuilt, so that writes
+// made while a rebuild runs reach the new version as well as the live index. Deletions
+// are also recorded, as a rebuild may write back a document it read before it was
+// deleted.
+type IndexRebuilds struct {
+	container *container.Container
+}
+
+func NewIndexRebuilds(container *container.Container) *IndexRebuilds {
+	return &IndexRebuilds{
+		container: container,
+	}
+}
+
+func indexRebuildKey(index string) string {
+	return fmt.Sprintf("index_rebuild:%s", index)
+}
+
+func indexRebuildDeletionsKey(version string) string {
+	return fmt.Sprintf("index_rebuild:%s:deleted", version)
+}
+
+// Begin registers a version of an index that is being rebuilt
+func (r *IndexRebuilds) Begin(ctx context.Context, index string, version string) error {
+	pipe := r.container.Redis.Client.TxPipeline()
+	pipe.SAdd(ctx, indexRebuildKey(index), version)
+	pipe.Expire(ctx, indexRebuildKey(index), indexRebuildTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to register index rebuild in redis: %w", err)
+	}
+
+	return nil
+}
+
+// End removes a version from the rebuilds of an index, along with its recorded deletions
+func (r *IndexRebuilds) End(ctx context.Context, index string, version string) error {
+	pipe := r.container.Redis.Client.TxPipeline()
+	pipe.SRem(ctx, indexRebuildKey(index), version)
+	pipe.Del(ctx, indexRebuildDeletionsKey(version))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to unregister index rebuild from redis: %w", err)
+	}
+
+	return nil
+}
+
+// WriteTargets returns the index along with every version of it being rebuilt. When the
+// rebuilds cannot be read, only the index itself is returned.
+func (r *IndexRebuilds) WriteTargets(ctx context.Context, index string) []string {
+	versions, err := r.container.Redis.Client.SMembers(ctx, indexRebuildKey(index)).Result()
+	if err != nil {
+		log.Warn().Err(err).Str("index", index).Msg("Failed to read index rebuilds, writing to the live index only")
+		return []string{index}
+	}
+
+	return append([]string{index}, versions...)
+}
+
+// DeleteTargets returns the index along with every version of it being rebuilt, like
+// WriteTargets, and records the deletion of a document from each version
+func (r *IndexRebuilds) DeleteTargets(ctx context.Context, index string, id string) []string {
+	targets := r.WriteTargets(ctx, index)
+
+	for _, version := range targets[1:] {
+		pipe := r.container.Redis.Client.TxPipeline()
+		pipe.SAdd(ctx, indexRebuildDeletionsKey(version), id)
+		pipe.Expire(ctx, indexRebuildDeletionsKey(version), indexRebuildTTL)
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Warn().Err(err).Str("index", version).Str("id", id).Msg("Failed to record deletion during index rebuild")
+		}
+	}
+
+	return targets
+}
+
+// Deletions lists the IDs of documents deleted while a version was being rebuilt
+func (r *IndexRebuilds) Deletions(ctx context.Context, version string) ([]string, error) {
+	ids, err := r.container.Redis.Client.SMembers(ctx, indexRebuildDeletionsKey(version)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index rebuild deletions from redis: %w", err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/foresturquhart/curator/server/container"
+	"github.com/foresturquhart/curator/server/models"
+	"github.com/rs/zerolog/log"
+)
+
+// reindexJobTTL is how long the progress of a reindex job is kept after it last changed
+const reindexJobTTL = 24 * time.Hour
+
+var (
+	// ErrReindexJobNotFound is returned when a reindex job is unknown or has expired
+	ErrReindexJobNotFound = errors.New("reindex job not found")
+
+	// ErrReindexJobDone is returned when cancelling a job that has already finished
+	ErrReindexJobDone = errors.New("reindex job has already finished")
+
+	// ErrReindexJobCancelled is the cause of a job stopping because it was cancelled
+	ErrReindexJobCancelled = errors.New("reindex job was cancelled")
+)
+
+// ReindexJobTracker records the progress of background reindex jobs in Redis, so that it
+// can be polled from any API instance. Every change is also published, so that progress
+// can be followed as it happens.
+type ReindexJobTracker struct {
+	container *container.Container
+}
+
+func NewReindexJobTracker(container *container.Container) *ReindexJobTracker {
+	return &ReindexJobTracker{
+		container: container,
+	}
+}
+
+func reindexJobKey(id string) string {
+	return fmt.Sprintf("reindex_job:%s", id)
+}
+
+func reindexJobChannel(id string) string {
+	return fmt.Sprintf("reindex_job:%s:events", id)
+}
+
+// Create records a newly queued job, to be throttled to itemsPerSecond documents a
+// second unless it is zero
+func (t *ReindexJobTracker) Create(ctx context.Context, id string, index string, itemsPerSecond float64) error {
+	return t.update(ctx, id, map[string]any{
+		"index":            index,
+		"status":           string(models.ReindexJobQueued),
+		"total":            0,
+		"processed":        0,
+		"failed":           0,
+		"items_per_second": itemsPerSecond,
+		"cancel_requested": false,
+	})
+}
+
+// Start marks a job as running
+func (t *ReindexJobTracker) Start(ctx context.Context, id string) error {
+	return t.update(ctx, id, map[string]any{
+		"status": string(models.ReindexJobRunning),
+	})
+}
+
+// Progress records how far a job has got
+func (t *ReindexJobTracker) Progress(ctx context.Context, id string, total, processed, failed int) error {
+	return t.update(ctx, id, map[string]any{
+		"total":     total,
+		"processed": processed,
+		"failed":    failed,
+	})
+}
+
+// Finish marks a job as completed, as cancelled when jobErr is ErrReindexJobCancelled,
+// or otherwise as failed when jobErr is set
+func (t *ReindexJobTracker) Finish(ctx context.Context, id string, jobErr error) error {
+	fields := map[string]any{
+		"status": string(models.ReindexJobCompleted),
+	}
+	if errors.Is(jobErr, ErrReindexJobCancelled) {
+		fields["status"] = string(models.ReindexJobCancelled)
+	} else if jobErr != nil {
+		fields["status"] = string(models.ReindexJobFailed)
+		fields["error"] = jobErr.Error()
+	}
+
+	return t.update(ctx, id, fields)
+}
+
+// Cancel asks a queued or running job to stop. The worker running it notices the request
+// when it next polls the job, so the job finishes shortly afterwards.
+func (t *ReindexJobTracker) Cancel(ctx context.Context, id string) (*models.ReindexJob, error) {
+	job, err := t.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status.Done() {
+		return nil, ErrReindexJobDone
+	}
+
+	if err := t.update(ctx, id, map[string]any{"cancel_requested": true}); err != nil {
+		return nil, err
+	}
+
+	return t.Get(ctx, id)
+}
+
+// Get retrieves the current state of a job
+func (t *ReindexJobTracker) Get(ctx context.Context, id string) (*models.ReindexJob, error) {
+	fields, err := t.container.Redis.Client.HGetAll(ctx, reindexJobKey(id)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reindex job from redis: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, ErrReindexJobNotFound
+	}
+
+	job := &models.ReindexJob{
+		ID:     id,
+		Index:  fields["index"],
+		Status: models.ReindexJobStatus(fields["status"]),
+		Error:  fields["error"],
+	}
+	job.Total, _ = strconv.Atoi(fields["total"])
+	job.Processed, _ = strconv.Atoi(fields["processed"])
+	job.Failed, _ = strconv.Atoi(fields["failed"])
+	job.ItemsPerSecond, _ = strconv.ParseFloat(fields["items_per_second"], 64)
+	job.CancelRequested, _ = strconv.ParseBool(fields["cancel_requested"])
+	if updatedAt, err := strconv.ParseInt(fields["updated_at"], 10, 64); err == nil {
+		job.UpdatedAt = time.UnixMilli(updatedAt)
+	}
+
+	return job, nil
+}
+
+// Subscribe follows the changes to a job, delivering its state after each one until ctx
+// is cancelled or the returned function is called. The subscription is established
+// before Subscribe returns, so no change made afterwards is missed.
+func (t *ReindexJobTracker) Subscribe(ctx context.Context, id string) (<-chan *models.ReindexJob, func(), error) {
+	pubsub := t.container.Redis.Client.Subscribe(ctx, reindexJobChannel(id))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, nil, fmt.Errorf("failed to subscribe to reindex job in redis: %w", err)
+	}
+
+	jobs := make(chan *models.ReindexJob)
+	go func() {
+		defer close(jobs)
+		for message := range pubsub.Channel() {
+			var job models.ReindexJob
+			if err := json.Unmarshal([]byte(message.Payload), &job); err != nil {
+				log.Warn().Err(err).Str("job_id", id).Msg("Skipping invalid reindex job event")
+				continue
+			}
+
+			select {
+			case jobs <- &job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return jobs, func() { pubsub.Close() }, nil
+}
+
+// update writes fields of a job, refreshing its expiry, and publishes its new state
+func (t *ReindexJobTracker) update(ctx context.Context, id string, fields map[string]any) error {
+	key := reindexJobKey(id)
+	fields["updated_at"] = time.Now().UnixMilli()
+
+	pipe := t.container.Redis.Client.TxPipeline()
+	pipe.HSet(ctx, key, fields)
+	pipe.Expire(ctx, key, reindexJobTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to update reindex job in redis: %w", err)
+	}
+
+	job, err := t.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode reindex job: %w", err)
+	}
+
+	if err := t.container.Redis.Client.Publish(ctx, reindexJobChannel(id), payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish reindex job in redis: %w", err)
+	}
+
+	return nil
+}
@@ -68,8 +68,8 @@ func (c *TagCache) GetTag(ctx context.Context, id int64) (*models.Tag, error) {
 	return mapToTag(fields)
 }
 
-// GetChildren retrieves the direct children of a tag
-func (c *TagCache) GetChildren(ctx context.Context, parentID *int64) ([]*models.Tag, error) {
+// GetChildren retrieves a page of the direct children of a tag, paginated by position
+func (c *TagCache) GetChildren(ctx context.Context, parentID *int64, opts models.TagChildrenOptions) (*models.TagChildrenPage, error) {
 	var parentKey string
 	if parentID != nil {
 		parentKey = fmt.Sprintf("children:%d", *parentID)
@@ -77,14 +77,28 @@ func (c *TagCache) GetChildren(ctx context.Context, parentID *int64) ([]*models.
 		parentKey = "children:root"
 	}
 
-	// Get children IDs with scores (positions)
-	childIDs, err := c.container.Redis.Client.ZRange(ctx, parentKey, 0, -1).Result()
+	// Get children IDs ordered by their scores (positions), starting after the cursor
+	args := redis.ZRangeArgs{
+		Key:     parentKey,
+		Start:   "-inf",
+		Stop:    "+inf",
+		ByScore: true,
+	}
+	if opts.AfterPosition != nil {
+		args.Start = fmt.Sprintf("(%d", *opts.AfterPosition)
+	}
+	if opts.Limit > 0 {
+		// Fetch one extra member to determine whether another page follows
+		args.Count = int64(opts.Limit + 1)
+	}
+
+	childIDs, err := c.container.Redis.Client.ZRangeArgs(ctx, args).Result()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get child tag IDs from redis: %w", err)
 	}
 
 	if len(childIDs) == 0 {
-		return []*models.Tag{}, nil
+		return &models.TagChildrenPage{Tags: []*models.Tag{}}, nil
 	}
 
 	// Use pipelining to get all children in one round trip
@@ -101,10 +115,10 @@ func (c *TagCache) GetChildren(ctx context.Context, parentID *int64) ([]*models.
 		return nil, fmt.Errorf("failed to execute pipeline for tag children: %w", err)
 	}
 
-	// Convert results to Tag models
+	// Convert results to Tag models, preserving the order of the sorted set
 	children := make([]*models.Tag, 0, len(childIDs))
-	for id, cmd := range cmds {
-		fields, err := cmd.Result()
+	for _, id := range childIDs {
+		fields, err := cmds[id].Result()
 		if err != nil {
 			log.Error().Err(err).Str("id", id).Msg("Failed to get tag from pipeline")
 			continue
@@ -124,17 +138,19 @@ func (c *TagCache) GetChildren(ctx context.Context, parentID *int64) ([]*models.
 		children = append(children, tag)
 	}
 
-	return children, nil
+	return models.NewTagChildrenPage(children, opts.Limit), nil
 }
 
-// GetTagTree retrieves a complete tag tree from the specified parent ID down to a maximum depth
-func (c *TagCache) GetTagTree(ctx context.Context, parentID *int64, maxDepth int) (map[int64][]*models.Tag, error) {
+// GetTagTree retrieves a tag tree from the specified parent ID down to a maximum depth. The
+// children of every tag are limited to opts.Limit, while opts.AfterPosition only applies
+// to the children of the starting parent.
+func (c *TagCache) GetTagTree(ctx context.Context, parentID *int64, maxDepth int, opts models.TagChildrenOptions) (map[int64]*models.TagChildrenPage, error) {
 	if maxDepth < 0 {
 		return nil, fmt.Errorf("maxDepth must be non-negative")
 	}
 
-	// Initialize the result map where key = parent ID, value = children tags
-	result := make(map[int64][]*models.Tag)
+	// Initialize the result map where key = parent ID, value = page of children tags
+	result := make(map[int64]*models.TagChildrenPage)
 
 	// Define a recursive function to fetch descendants
 	var fetchDescendants func(parentID *int64, currentDepth int) error
@@ -144,13 +160,18 @@ func (c *TagCache) GetTagTree(ctx context.Context, parentID *int64, maxDepth int
 			return nil
 		}
 
-		// Get children of this parent
-		children, err := c.GetChildren(ctx, parentID)
+		// Get children of this parent, continuing after the cursor only at the top level
+		levelOpts := models.TagChildrenOptions{Limit: opts.Limit}
+		if currentDepth == 0 {
+			levelOpts.AfterPosition = opts.AfterPosition
+		}
+
+		children, err := c.GetChildren(ctx, parentID, levelOpts)
 		if err != nil {
 			return fmt.Errorf("failed to get children: %w", err)
 		}
 
-		if len(children) == 0 {
+		if len(children.Tags) == 0 {
 			return nil
 		}
 
@@ -166,7 +187,7 @@ func (c *TagCache) GetTagTree(ctx context.Context, parentID *int64, maxDepth int
 		result[parentKey] = children
 
 		// Process each child recursively if we haven't reached max depth
-		for _, child := range children {
+		for _, child := range children.Tags {
 			if err := fetchDescendants(&child.ID, currentDepth+1); err != nil {
 				return err
 			}
@@ -251,13 +272,13 @@ func (c *TagCache) Update(ctx context.Context, tag *models.Tag, oldParentID *int
 func (c *TagCache) Delete(ctx context.Context, tag *models.Tag, recursive bool) error {
 	// If recursive, first get all children
 	if recursive {
-		children, err := c.GetChildren(ctx, &tag.ID)
+		children, err := c.GetChildren(ctx, &tag.ID, models.TagChildrenOptions{})
 		if err != nil {
 			return fmt.Errorf("failed to get children for recursive delete: %w", err)
 		}
 
 		// Recursively delete all children
-		for _, child := range children {
+		for _, child := range children.Tags {
 			if err := c.Delete(ctx, child, true); err != nil {
 				log.Error().Err(err).Int64("id", child.ID).Msg("Error deleting child tag in recursive delete")
 			}
@@ -354,3 +375,48 @@ func mapToTag(fields map[string]string) (*models.Tag, error) {
 
 	return tag, nil
 }
+
+// descendantsTTL bounds how long an expanded subtree is served from the cache
+const descendantsTTL = time.Minute
+
+// GetDescendants retrieves the cached UUIDs of a tag and all of its descendants. The
+// boolean result reports whether the expansion was present in the cache.
+func (c *TagCache) GetDescendants(ctx context.Context, uuid string) ([]string, bool, error) {
+	key := fmt.Sprintf("descendants:%s", uuid)
+
+	uuids, err := c.container.Redis.Client.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get tag descendants from redis: %w", err)
+	}
+
+	if len(uuids) == 0 {
+		return nil, false, nil
+	}
+
+	return uuids, true, nil
+}
+
+// SetDescendants caches the UUIDs of a tag and all of its descendants for a short period
+func (c *TagCache) SetDescendants(ctx context.Context, uuid string, uuids []string) error {
+	if len(uuids) == 0 {
+		return nil
+	}
+
+	key := fmt.Sprintf("descendants:%s", uuid)
+
+	members := make([]any, len(uuids))
+	for i, member := range uuids {
+		members[i] = member
+	}
+
+	pipe := c.container.Redis.Client.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.SAdd(ctx, key, members...)
+	pipe.Expire(ctx, key, descendantsTTL)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to cache tag descendants in redis: %w", err)
+	}
+
+	return nil
+}
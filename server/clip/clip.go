@@ -2,39 +2,174 @@ package clip
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/foresturquhart/curator/server/telemetry"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
 )
 
+// resolverScheme is the target scheme used to spread requests across several endpoints
+const resolverScheme = "clip"
+
+// balancedServiceConfig balances requests round-robin across every ready endpoint, and
+// retries requests that fail because an endpoint became unavailable, so that they are
+// routed to another one
+const balancedServiceConfig = `{
+	"loadBalancingConfig": [{"round_robin": {}}],
+	"methodConfig": [{
+		"name": [{}],
+		"retryPolicy": {
+			"maxAttempts": 3,
+			"initialBackoff": "0.1s",
+			"maxBackoff": "1s",
+			"backoffMultiplier": 2,
+			"retryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}]
+}`
+
+// ErrBusy is returned when an embedding request could not acquire a concurrency slot in time
+var ErrBusy = errors.New("clip service is busy")
+
 type Client struct {
 	conn       *grpc.ClientConn
 	clipClient CLIPServiceClient
+
+	// slots bounds the number of in-flight embedding requests, and is nil when unlimited
+	slots        chan struct{}
+	queueTimeout time.Duration
+}
+
+// ParseAddrs splits a comma-separated list of CLIP endpoints, giving entries without a
+// port the default port
+func ParseAddrs(hosts string, defaultPort int) ([]string, error) {
+	var addrs []string
+	for _, host := range strings.Split(hosts, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, strconv.Itoa(defaultPort))
+		}
+		addrs = append(addrs, host)
+	}
+
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no clip endpoints configured")
+	}
+
+	return addrs, nil
 }
 
-func NewClient(addr string) (*Client, error) {
+// NewClient connects to one or more CLIP endpoints. With several endpoints, requests
+// are balanced across those that are reachable and fail over when one goes down.
+// A positive maxConcurrent caps the number of in-flight embedding requests; requests
+// beyond it wait up to queueTimeout for a slot before failing with ErrBusy.
+func NewClient(addrs []string, maxConcurrent int, queueTimeout time.Duration) (*Client, error) {
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no clip endpoints given")
+	}
+
+	options := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(telemetry.UnaryClientInterceptor("clip")),
+	}
+
+	target := addrs[0]
+	if len(addrs) > 1 {
+		// Resolve to the fixed list of endpoints rather than through DNS
+		endpoints := manual.NewBuilderWithScheme(resolverScheme)
+
+		state := resolver.State{}
+		for _, addr := range addrs {
+			state.Endpoints = append(state.Endpoints, resolver.Endpoint{
+				Addresses: []resolver.Address{{Addr: addr}},
+			})
+		}
+		endpoints.InitialState(state)
+
+		target = resolverScheme + ":///" + resolverScheme
+		options = append(options,
+			grpc.WithResolvers(endpoints),
+			grpc.WithDefaultServiceConfig(balancedServiceConfig),
+		)
+	}
+
 	// Connect to the gRPC server.
-	clientConn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	clientConn, err := grpc.NewClient(target, options...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create gRPC client: %w", err)
 	}
 
 	// Create the gRPC client stub.
 	client := NewCLIPServiceClient(clientConn)
+
+	var slots chan struct{}
+	if maxConcurrent > 0 {
+		slots = make(chan struct{}, maxConcurrent)
+	}
+
 	return &Client{
-		conn:       clientConn,
-		clipClient: client,
+		conn:         clientConn,
+		clipClient:   client,
+		slots:        slots,
+		queueTimeout: queueTimeout,
 	}, nil
 }
 
+// acquire waits for a concurrency slot, returning a function that releases it
+func (c *Client) acquire(ctx context.Context) (func(), error) {
+	if c.slots == nil {
+		return func() {}, nil
+	}
+
+	// Take a free slot straight away if there is one
+	select {
+	case c.slots <- struct{}{}:
+		return func() { <-c.slots }, nil
+	default:
+	}
+
+	if c.queueTimeout <= 0 {
+		return nil, ErrBusy
+	}
+
+	timer := time.NewTimer(c.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case c.slots <- struct{}{}:
+		return func() { <-c.slots }, nil
+	case <-timer.C:
+		return nil, ErrBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 // GetEmbeddingFromImageData sends image data to the CLIP service and returns the embedding
 func (c *Client) GetEmbeddingFromImageData(ctx context.Context, imageData []byte) ([]float32, error) {
 	if len(imageData) == 0 {
 		return nil, fmt.Errorf("empty image data")
 	}
 
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	req := &ImageRequest{
 		ImageData: imageData,
 	}
@@ -47,6 +182,31 @@ func (c *Client) GetEmbeddingFromImageData(ctx context.Context, imageData []byte
 	return resp.Embedding, nil
 }
 
+// GetTextEmbedding sends a text query to the CLIP service and returns its embedding, in
+// the same space as image embeddings
+func (c *Client) GetTextEmbedding(ctx context.Context, text string) ([]float32, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("empty text")
+	}
+
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	req := &TextRequest{
+		Text: text,
+	}
+
+	resp, err := c.clipClient.GetTextEmbedding(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get text embedding: %w", err)
+	}
+
+	return resp.Embedding, nil
+}
+
 // GetEmbeddingFromReader reads from a reader (like a file upload) and gets the embedding
 func (c *Client) GetEmbeddingFromReader(ctx context.Context, reader io.Reader) ([]float32, error) {
 	imageData, err := io.ReadAll(reader)
@@ -14,7 +14,9 @@ import (
 	"github.com/foresturquhart/curator/server/container"
 	"github.com/foresturquhart/curator/server/models"
 	"github.com/foresturquhart/curator/server/repositories"
+	"github.com/foresturquhart/curator/server/search"
 	"github.com/foresturquhart/curator/server/services"
+	"github.com/foresturquhart/curator/server/telemetry"
 	"github.com/foresturquhart/curator/server/utils"
 	"github.com/foresturquhart/curator/server/worker"
 	"github.com/labstack/echo/v4"
@@ -37,6 +39,17 @@ func main() {
 		zerolog.SetGlobalLevel(lvl)
 	}
 
+	// Configure tracing
+	shutdownTelemetry, err := telemetry.Setup(ctx, &telemetry.Config{
+		Endpoint:    cfg.OTLPEndpoint,
+		Insecure:    cfg.OTLPInsecure,
+		ServiceName: cfg.OTELServiceName,
+		SampleRatio: cfg.OTELTraceSampleRatio,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to configure telemetry")
+	}
+
 	// Initialize container with all dependencies
 	c, err := container.NewContainer(ctx, cfg)
 	if err != nil {
@@ -51,19 +64,19 @@ func main() {
 
 	// Initialize repositories
 	imageRepository := repositories.NewImageRepository(c)
-	// collectionRepository := repositories.NewCollectionRepository(c)
+	collectionRepository := repositories.NewCollectionRepository(c)
 
 	// Initialize services
 	personService := services.NewPersonService(c)
 	tagService := services.NewTagService(c)
 
-	if err := imageRepository.IndexAll(ctx); err != nil {
+	if err := imageRepository.IndexAll(ctx, repositories.ImageIndex, nil, cfg.ReindexRestart); err != nil {
 		log.Fatal().Err(err).Msg("Failed to reindex images")
 	}
-	if err := personService.IndexAll(ctx); err != nil {
+	if err := personService.IndexAll(ctx, search.PeopleIndex, nil); err != nil {
 		log.Fatal().Err(err).Msg("Failed to reindex people")
 	}
-	if err := tagService.IndexAll(ctx); err != nil {
+	if err := tagService.IndexAll(ctx, search.TagIndex, nil); err != nil {
 		log.Fatal().Err(err).Msg("Failed to reindex tags")
 	}
 	// if err := collectionRepository.ReindexAll(ctx); err != nil {
@@ -103,9 +116,10 @@ func main() {
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
+	e.Use(telemetry.Middleware())
 
 	// Register API routes
-	v1.RegisterRoutes(e, c, imageRepository, personService)
+	v1.RegisterRoutes(e, c, imageRepository, personService, tagService, collectionRepository)
 
 	// Start the server
 	go func() {
@@ -120,16 +134,25 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
+	// Stop accepting requests and let in-flight ones such as uploads finish first, as
+	// they may still enqueue background tasks
+	httpCtx, cancelHTTP := context.WithTimeout(ctx, cfg.HTTPShutdownTimeout)
+	defer cancelHTTP()
 
-	// Stop the worker gracefully
+	if err := e.Shutdown(httpCtx); err != nil {
+		log.Error().Err(err).Msg("Failed to gracefully shutdown server")
+	}
+
+	// Then drain the worker, which waits for running tasks up to its own timeout
 	if err := worker.Stop(); err != nil {
 		log.Error().Err(err).Msg("Failed to gracefully stop background worker")
 	}
 
-	// Stop the server gracefully
-	if err := e.Shutdown(ctx); err != nil {
-		log.Fatal().Err(err).Msg("Failed to gracefully shutdown server")
+	// Flush any buffered spans
+	telemetryCtx, cancelTelemetry := context.WithTimeout(ctx, 10*time.Second)
+	defer cancelTelemetry()
+
+	if err := shutdownTelemetry(telemetryCtx); err != nil {
+		log.Error().Err(err).Msg("Failed to flush telemetry")
 	}
 }
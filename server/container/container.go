@@ -2,15 +2,21 @@ package container
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/foresturquhart/curator/server/clip"
 	"github.com/foresturquhart/curator/server/config"
 	"github.com/foresturquhart/curator/server/storage"
+	"github.com/foresturquhart/curator/server/storage/indexes"
 	"github.com/foresturquhart/curator/server/tasks"
+	"github.com/foresturquhart/curator/server/telemetry"
 	"github.com/qdrant/go-client/qdrant"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
 )
 
 type Container struct {
@@ -31,11 +37,25 @@ func NewContainer(ctx context.Context, cfg *config.Config) (*Container, error) {
 		return nil, fmt.Errorf("failed to initialize postgres: %w", err)
 	}
 
-	// Initialize elastic client
+	// Initialize elastic client, retrying transient failures and failing fast while the cluster is unhealthy
+	elasticBreaker := storage.NewCircuitBreaker(nil, cfg.ElasticsearchBreakerThreshold, cfg.ElasticsearchBreakerCooldown)
 	elasticClient, err := storage.NewElastic(elasticsearch.Config{
-		Addresses: []string{cfg.ElasticsearchURL},
+		Addresses:       []string{cfg.ElasticsearchURL},
+		Instrumentation: elasticsearch.NewOpenTelemetryInstrumentation(otel.GetTracerProvider(), false),
+		RetryOnStatus: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		MaxRetries:   cfg.ElasticsearchMaxRetries,
+		DisableRetry: cfg.ElasticsearchMaxRetries <= 0,
+		RetryBackoff: storage.ExponentialBackoff(cfg.ElasticsearchRetryBackoff, cfg.ElasticsearchRetryMaxBackoff),
+		RetryOnError: func(_ *http.Request, err error) bool {
+			return !errors.Is(err, storage.ErrCircuitOpen)
+		},
 		// Logger:    &CustomLogger{log},
-	})
+	}, elasticBreaker)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize elasticsearch: %w", err)
 	}
@@ -44,6 +64,9 @@ func NewContainer(ctx context.Context, cfg *config.Config) (*Container, error) {
 	qdrantClient, err := storage.NewQdrant(&qdrant.Config{
 		Host: cfg.QdrantHost,
 		Port: cfg.QdrantPort,
+		GrpcOptions: []grpc.DialOption{
+			grpc.WithChainUnaryInterceptor(telemetry.UnaryClientInterceptor("qdrant")),
+		},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize qdrant: %w", err)
@@ -69,13 +92,24 @@ func NewContainer(ctx context.Context, cfg *config.Config) (*Container, error) {
 		ForcePathStyle:  cfg.S3ForcePathStyle,
 		Bucket:          cfg.S3Bucket,
 		CreateBucket:    cfg.S3CreateBucket,
+
+		ServerSideEncryption: cfg.S3ServerSideEncryption,
+		KMSKeyID:             cfg.S3KMSKeyID,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize s3: %w", err)
 	}
 
-	// Initialize clip client
-	clipClient, err := clip.NewClient(fmt.Sprintf("%s:%d", cfg.ClipHost, cfg.ClipPort))
+	// Initialize clip client, balancing across every endpoint when several are configured
+	clipHosts := cfg.ClipHosts
+	if clipHosts == "" {
+		clipHosts = cfg.ClipHost
+	}
+	clipAddrs, err := clip.ParseAddrs(clipHosts, cfg.ClipPort)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize clip: %w", err)
+	}
+	clipClient, err := clip.NewClient(clipAddrs, cfg.ClipMaxConcurrent, cfg.ClipQueueTimeout)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize clip: %w", err)
 	}
@@ -115,7 +149,18 @@ func (c *Container) Migrate(ctx context.Context) error {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	if err := c.Elastic.Migrate(ctx); err != nil {
+	// The normalisation form is configurable, so names are backfilled here rather than
+	// in a migration, and again whenever the form changes
+	if err := c.Postgres.NormalizeNames(ctx, c.Config.NameNormalization); err != nil {
+		return fmt.Errorf("failed to normalise names: %w", err)
+	}
+
+	// Likewise, names are only unique ignoring case while they are matched ignoring case
+	if err := c.Postgres.EnforceCaseInsensitiveNames(ctx, c.Config.NameCaseInsensitive); err != nil {
+		return fmt.Errorf("failed to enforce case-insensitive names: %w", err)
+	}
+
+	if err := c.Elastic.Migrate(ctx, c.Config.ElasticsearchMappingValidation, c.TextAnalysis()); err != nil {
 		return fmt.Errorf("failed to migrate elasticsearch: %w", err)
 	}
 
@@ -125,3 +170,11 @@ func (c *Container) Migrate(ctx context.Context) error {
 
 	return nil
 }
+
+// TextAnalysis describes how the configuration analyses the text fields of indexes
+func (c *Container) TextAnalysis() indexes.TextAnalysis {
+	return indexes.TextAnalysis{
+		Analyzer:  c.Config.ElasticsearchTextAnalyzer,
+		Languages: c.Config.TextLanguageAnalyzers,
+	}
+}
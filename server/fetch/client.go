@@ -0,0 +1,166 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// maxRedirects bounds how many redirects a single fetch will follow
+const maxRedirects = 5
+
+var (
+	ErrBlockedAddress = errors.New("destination address is not publicly routable")
+	ErrInvalidURL     = errors.New("only absolute http and https URLs can be fetched")
+	ErrTooLarge       = errors.New("response body exceeds the size limit")
+)
+
+// Client fetches untrusted URLs. Every connection, including those made while following
+// redirects, is checked after DNS resolution so that requests cannot reach loopback,
+// private, link-local or otherwise internal addresses.
+type Client struct {
+	http     *http.Client
+	maxBytes int64
+}
+
+func NewClient(timeout time.Duration, maxBytes int64) *Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+
+			if !IsPublicAddr(addr) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, addr)
+			}
+
+			return nil
+		},
+	}
+
+	transport := &http.Transport{
+		// Never route through an environment proxy, which would bypass the address check
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	return &Client{
+		http: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				return validateURL(req.URL)
+			},
+		},
+		maxBytes: maxBytes,
+	}
+}
+
+// Get fetches rawURL, returning its body and content type. Bodies larger than the
+// client's size limit are rejected with ErrTooLarge.
+func (c *Client) Get(ctx context.Context, rawURL string) ([]byte, string, error) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	if err := validateURL(parsedURL); err != nil {
+		return nil, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsedURL.String(), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build request: %w", err)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch '%s': %w", rawURL, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, "", fmt.Errorf("failed to fetch '%s': unexpected status %s", rawURL, res.Status)
+	}
+
+	if res.ContentLength > c.maxBytes {
+		return nil, "", ErrTooLarge
+	}
+
+	// Read one byte past the limit to detect oversized bodies without a content length
+	body, err := io.ReadAll(io.LimitReader(res.Body, c.maxBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response from '%s': %w", rawURL, err)
+	}
+	if int64(len(body)) > c.maxBytes {
+		return nil, "", ErrTooLarge
+	}
+
+	return body, res.Header.Get("Content-Type"), nil
+}
+
+// validateURL ensures a URL is absolute and uses a web scheme
+func validateURL(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+// IsPublicAddr reports whether addr is a globally routable unicast address
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if !addr.IsValid() ||
+		addr.IsUnspecified() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return false
+	}
+
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// blockedPrefixes lists special-purpose ranges not covered by the netip predicates
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "This" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // Documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // Documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // Documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // Reserved, including broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, which can embed internal IPv4 addresses
+	netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+}
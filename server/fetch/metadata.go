@@ -0,0 +1,81 @@
+package fetch
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// PageMetadata holds the descriptive metadata extracted from an HTML page
+type PageMetadata struct {
+	Title       string
+	Description string
+}
+
+// ExtractPageMetadata reads the title and description of an HTML document, preferring
+// OpenGraph properties over the <title> element and the description meta tag
+func ExtractPageMetadata(body []byte) PageMetadata {
+	var title, ogTitle, description, ogDescription string
+
+	result := func() PageMetadata {
+		return PageMetadata{
+			Title:       firstNonEmpty(ogTitle, title),
+			Description: firstNonEmpty(ogDescription, description),
+		}
+	}
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	inTitle := false
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return result()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "title":
+				inTitle = title == ""
+			case "meta":
+				var name, content string
+				for _, attr := range token.Attr {
+					switch strings.ToLower(attr.Key) {
+					case "name", "property":
+						name = strings.ToLower(attr.Val)
+					case "content":
+						content = attr.Val
+					}
+				}
+
+				switch name {
+				case "og:title":
+					ogTitle = firstNonEmpty(ogTitle, content)
+				case "og:description":
+					ogDescription = firstNonEmpty(ogDescription, content)
+				case "description", "twitter:description":
+					description = firstNonEmpty(description, content)
+				}
+			case "body":
+				// Metadata lives in the head, so there is no need to tokenize the page content
+				return result()
+			}
+		case html.TextToken:
+			if inTitle {
+				title = string(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			inTitle = false
+		}
+	}
+}
+
+// firstNonEmpty returns the first value that is not blank, trimmed of surrounding whitespace
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if trimmed := strings.Join(strings.Fields(value), " "); trimmed != "" {
+			return trimmed
+		}
+	}
+	return ""
+}
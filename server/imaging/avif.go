@@ -0,0 +1,147 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"iter"
+)
+
+var errInvalidAVIF = errors.New("invalid AVIF data")
+
+// ErrDecodeUnsupported is returned when the pixels of an image are decoded in a format
+// whose dimensions can be read but whose pixels cannot
+var ErrDecodeUnsupported = errors.New("decoding pixels of this image format is not supported")
+
+// avifBrands are the ISO-BMFF brands identifying AVIF still images and sequences
+var avifBrands = []string{"avif", "avis"}
+
+func init() {
+	for _, brand := range avifBrands {
+		image.RegisterFormat("avif", "????ftyp"+brand, decodeUnsupported, DecodeAVIFConfig)
+	}
+}
+
+func decodeUnsupported(io.Reader) (image.Image, error) {
+	return nil, ErrDecodeUnsupported
+}
+
+// IsAVIF reports whether data begins with an ISO-BMFF file type box naming an AVIF
+// brand, either as the major brand or as a compatible one
+func IsAVIF(data []byte) bool {
+	if len(data) < 16 || string(data[4:8]) != "ftyp" {
+		return false
+	}
+
+	size := int(binary.BigEndian.Uint32(data))
+	if size < 16 || size > len(data) {
+		return false
+	}
+
+	// The major brand and minor version are followed by the compatible brands
+	brands := [][]byte{data[8:12]}
+	for offset := 16; offset+4 <= size; offset += 4 {
+		brands = append(brands, data[offset:offset+4])
+	}
+
+	for _, brand := range brands {
+		for _, avifBrand := range avifBrands {
+			if string(brand) == avifBrand {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// DecodeAVIFConfig reads the dimensions of an AVIF image from the image spatial extent
+// properties in its metadata. When several are present, such as for thumbnails or an
+// alpha plane, the largest is taken as that of the primary image.
+func DecodeAVIFConfig(r io.Reader) (image.Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	if !IsAVIF(data) {
+		return image.Config{}, errInvalidAVIF
+	}
+
+	meta := findBox(data, "meta")
+	if meta == nil || len(meta) < 4 {
+		return image.Config{}, errInvalidAVIF
+	}
+
+	// The meta box is a full box, with a version and flags before its children
+	iprp := findBox(meta[4:], "iprp")
+	ipco := findBox(iprp, "ipco")
+	if ipco == nil {
+		return image.Config{}, errInvalidAVIF
+	}
+
+	var width, height int
+	for payload := range boxes(ipco, "ispe") {
+		// Version and flags precede the 32 bit dimensions
+		if len(payload) < 12 {
+			continue
+		}
+		w := int(binary.BigEndian.Uint32(payload[4:]))
+		h := int(binary.BigEndian.Uint32(payload[8:]))
+		if w*h > width*height {
+			width, height = w, h
+		}
+	}
+
+	if width == 0 || height == 0 {
+		return image.Config{}, errInvalidAVIF
+	}
+
+	return image.Config{ColorModel: color.RGBAModel, Width: width, Height: height}, nil
+}
+
+// findBox returns the payload of the first ISO-BMFF box of a type in a sequence of
+// boxes, or nil when there is none
+func findBox(data []byte, boxType string) []byte {
+	for payload := range boxes(data, boxType) {
+		return payload
+	}
+	return nil
+}
+
+// boxes yields the payload of every ISO-BMFF box of a type in a sequence of boxes,
+// stopping at the first malformed box
+func boxes(data []byte, boxType string) iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		for len(data) >= 8 {
+			size := uint64(binary.BigEndian.Uint32(data))
+			headerSize := uint64(8)
+
+			switch size {
+			case 0:
+				// The box extends to the end of the data
+				size = uint64(len(data))
+			case 1:
+				// A 64 bit size follows the type
+				if len(data) < 16 {
+					return
+				}
+				size = binary.BigEndian.Uint64(data[8:])
+				headerSize = 16
+			}
+
+			if size < headerSize || size > uint64(len(data)) {
+				return
+			}
+
+			if bytes.Equal(data[4:8], []byte(boxType)) && !yield(data[headerSize:size]) {
+				return
+			}
+
+			data = data[size:]
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+)
+
+const (
+	exifMakeTag  = 0x010F
+	exifModelTag = 0x0110
+
+	exifTypeASCII = 2
+)
+
+// exifIFD is the first image file directory of an EXIF TIFF structure
+type exifIFD struct {
+	tiff   []byte
+	order  binary.ByteOrder
+	offset int
+}
+
+// readExifIFD0 locates the APP1 EXIF segment of JPEG data and returns its first IFD
+func readExifIFD0(data []byte) (exifIFD, bool) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return exifIFD{}, false
+	}
+
+	offset := 2
+	for offset+4 <= len(data) {
+		if data[offset] != 0xFF {
+			return exifIFD{}, false
+		}
+
+		marker := data[offset+1]
+		// Start of scan or end of image, no more metadata segments follow
+		if marker == 0xDA || marker == 0xD9 {
+			return exifIFD{}, false
+		}
+
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if length < 2 || offset+2+length > len(data) {
+			return exifIFD{}, false
+		}
+
+		segment := data[offset+4 : offset+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return parseTIFFHeader(segment[6:])
+		}
+
+		offset += 2 + length
+	}
+
+	return exifIFD{}, false
+}
+
+// parseTIFFHeader validates a TIFF header and returns its first IFD
+func parseTIFFHeader(tiff []byte) (exifIFD, bool) {
+	if len(tiff) < 8 {
+		return exifIFD{}, false
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return exifIFD{}, false
+	}
+
+	if order.Uint16(tiff[2:]) != 0x002A {
+		return exifIFD{}, false
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return exifIFD{}, false
+	}
+
+	return exifIFD{tiff: tiff, order: order, offset: offset}, true
+}
+
+// find returns the offset of the entry with the given tag
+func (d exifIFD) find(tag uint16) (int, bool) {
+	entries := int(d.order.Uint16(d.tiff[d.offset:]))
+	for i := 0; i < entries; i++ {
+		entry := d.offset + 2 + i*12
+		if entry+12 > len(d.tiff) {
+			break
+		}
+		if d.order.Uint16(d.tiff[entry:]) == tag {
+			return entry, true
+		}
+	}
+	return 0, false
+}
+
+// ascii reads an ASCII valued tag, returning an empty string when it is missing or malformed
+func (d exifIFD) ascii(tag uint16) string {
+	entry, ok := d.find(tag)
+	if !ok || d.order.Uint16(d.tiff[entry+2:]) != exifTypeASCII {
+		return ""
+	}
+
+	count := int(d.order.Uint32(d.tiff[entry+4:]))
+	if count < 0 || count > len(d.tiff) {
+		return ""
+	}
+
+	// Values of up to four bytes are stored inline in the entry
+	var value []byte
+	if count <= 4 {
+		value = d.tiff[entry+8 : entry+8+count]
+	} else {
+		start := int(d.order.Uint32(d.tiff[entry+8:]))
+		if start < 0 || start+count > len(d.tiff) {
+			return ""
+		}
+		value = d.tiff[start : start+count]
+	}
+
+	if i := bytes.IndexByte(value, 0); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(string(value))
+}
+
+// ReadCamera extracts the camera make and model from the EXIF data of a JPEG image.
+// Missing values are returned as empty strings.
+func ReadCamera(data []byte) (string, string) {
+	ifd, ok := readExifIFD0(data)
+	if !ok {
+		return "", ""
+	}
+	return ifd.ascii(exifMakeTag), ifd.ascii(exifModelTag)
+}
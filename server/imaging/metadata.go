@@ -0,0 +1,163 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	errTruncatedJPEG = errors.New("truncated JPEG data")
+	errTruncatedPNG  = errors.New("truncated PNG data")
+	errTruncatedWebP = errors.New("truncated WebP data")
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// StripJPEGMetadata removes EXIF, XMP, IPTC and comment segments from JPEG data
+// without re-encoding it. The JFIF header and any ICC colour profile are kept, as
+// they affect how the image is displayed.
+func StripJPEGMetadata(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("not JPEG data")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+
+	offset := 2
+	for {
+		if offset+4 > len(data) || data[offset] != 0xFF {
+			return nil, errTruncatedJPEG
+		}
+
+		marker := data[offset+1]
+		// Start of scan, the remainder is entropy coded image data
+		if marker == 0xDA {
+			out.Write(data[offset:])
+			return out.Bytes(), nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if length < 2 || offset+2+length > len(data) {
+			return nil, errTruncatedJPEG
+		}
+
+		segment := data[offset : offset+2+length]
+		if !isJPEGMetadataSegment(marker, segment[4:]) {
+			out.Write(segment)
+		}
+
+		offset += 2 + length
+	}
+}
+
+// isJPEGMetadataSegment reports whether a JPEG segment only carries metadata
+func isJPEGMetadataSegment(marker byte, payload []byte) bool {
+	switch {
+	case marker == 0xFE:
+		// Comment
+		return true
+	case marker == 0xE0:
+		// JFIF header
+		return false
+	case marker == 0xE2:
+		// Keep ICC profiles, drop anything else stored in APP2
+		return !bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00"))
+	case marker == 0xEE:
+		// Adobe segment, which records the colour transform
+		return false
+	case marker >= 0xE1 && marker <= 0xEF:
+		// Remaining application segments, including EXIF, XMP and IPTC
+		return true
+	default:
+		return false
+	}
+}
+
+// StripPNGMetadata removes textual, EXIF and timestamp chunks from PNG data without
+// re-encoding it
+func StripPNGMetadata(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("not PNG data")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+
+	offset := len(pngSignature)
+	for offset < len(data) {
+		if offset+8 > len(data) {
+			return nil, errTruncatedPNG
+		}
+
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		end := offset + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errTruncatedPNG
+		}
+
+		switch string(data[offset+4 : offset+8]) {
+		case "tEXt", "zTXt", "iTXt", "eXIf", "tIME":
+		default:
+			out.Write(data[offset:end])
+		}
+
+		offset = end
+	}
+
+	return out.Bytes(), nil
+}
+
+// StripWebPMetadata removes EXIF and XMP chunks from WebP data without re-encoding it,
+// clearing their flags in the extended header. Any ICC colour profile is kept.
+func StripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("not WebP data")
+	}
+
+	// Data beyond the size recorded in the RIFF header is not part of the image
+	size := int(binary.LittleEndian.Uint32(data[4:]))
+	if size < 4 || 8+size > len(data) {
+		return nil, errTruncatedWebP
+	}
+	data = data[:8+size]
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+
+	offset := 12
+	for offset < len(data) {
+		if offset+8 > len(data) {
+			return nil, errTruncatedWebP
+		}
+
+		// Chunks are padded to an even length
+		length := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		end := offset + 8 + length + length%2
+		if length < 0 || end > len(data) {
+			return nil, errTruncatedWebP
+		}
+
+		switch string(data[offset : offset+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			if length < 1 {
+				return nil, errTruncatedWebP
+			}
+			chunk := bytes.Clone(data[offset:end])
+			chunk[8] &^= webpFlagEXIF | webpFlagXMP
+			out.Write(chunk)
+		default:
+			out.Write(data[offset:end])
+		}
+
+		offset = end
+	}
+
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+
+	return stripped, nil
+}
@@ -0,0 +1,129 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+)
+
+// Orientation is the value of the EXIF orientation tag, describing how the stored
+// pixels must be transformed to display upright
+type Orientation int
+
+const (
+	OrientationNormal     Orientation = 1
+	OrientationFlipH      Orientation = 2
+	OrientationRotate180  Orientation = 3
+	OrientationFlipV      Orientation = 4
+	OrientationTranspose  Orientation = 5
+	OrientationRotate90   Orientation = 6
+	OrientationTransverse Orientation = 7
+	OrientationRotate270  Orientation = 8
+)
+
+// Orientation correction modes
+const (
+	// OrientationModeDerivatives stores the original untouched and only corrects derived images
+	OrientationModeDerivatives = "derivatives"
+	// OrientationModeOriginal rewrites the stored original upright
+	OrientationModeOriginal = "original"
+)
+
+const (
+	exifOrientationTag = 0x0112
+	jpegQuality        = 95
+)
+
+// SwapsDimensions reports whether correcting the orientation swaps width and height
+func (o Orientation) SwapsDimensions() bool {
+	return o >= OrientationTranspose && o <= OrientationRotate270
+}
+
+// OrientedSize returns the dimensions of an image once its orientation is corrected
+func OrientedSize(width int, height int, orientation Orientation) (int, int) {
+	if orientation.SwapsDimensions() {
+		return height, width
+	}
+	return width, height
+}
+
+// ReadOrientation extracts the EXIF orientation from JPEG data. Images without a
+// readable orientation tag are reported as OrientationNormal.
+func ReadOrientation(data []byte) Orientation {
+	ifd, ok := readExifIFD0(data)
+	if !ok {
+		return OrientationNormal
+	}
+
+	entry, ok := ifd.find(exifOrientationTag)
+	if !ok {
+		return OrientationNormal
+	}
+
+	value := Orientation(ifd.order.Uint16(ifd.tiff[entry+8:]))
+	if value < OrientationNormal || value > OrientationRotate270 {
+		return OrientationNormal
+	}
+	return value
+}
+
+// ApplyOrientation returns a copy of img transformed so that it displays upright
+func ApplyOrientation(img image.Image, orientation Orientation) image.Image {
+	if orientation == OrientationNormal {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := OrientedSize(w, h, orientation)
+
+	src := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		for dx := 0; dx < dw; dx++ {
+			var sx, sy int
+			switch orientation {
+			case OrientationFlipH:
+				sx, sy = w-1-dx, dy
+			case OrientationRotate180:
+				sx, sy = w-1-dx, h-1-dy
+			case OrientationFlipV:
+				sx, sy = dx, h-1-dy
+			case OrientationTranspose:
+				sx, sy = dy, dx
+			case OrientationRotate90:
+				sx, sy = dy, h-1-dx
+			case OrientationTransverse:
+				sx, sy = w-1-dy, h-1-dx
+			case OrientationRotate270:
+				sx, sy = w-1-dy, dx
+			default:
+				sx, sy = dx, dy
+			}
+
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+
+	return dst
+}
+
+// Reorient decodes JPEG data, corrects its orientation and re-encodes it. The
+// re-encoded image carries no EXIF metadata, so viewers will not rotate it again.
+func Reorient(data []byte, orientation Orientation) ([]byte, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, ApplyOrientation(img, orientation), &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
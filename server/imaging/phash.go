@@ -0,0 +1,95 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+)
+
+const (
+	// Size of the greyscale thumbnail a difference hash is computed from. One column more
+	// than the hash width is needed, as each bit compares neighbouring pixels.
+	dHashWidth  = 9
+	dHashHeight = 8
+
+	// Pixels sampled along each axis of a thumbnail cell, bounding the cost of hashing
+	// large images
+	dHashCellSamples = 16
+)
+
+// PerceptualHash decodes image data and computes its difference hash
+func PerceptualHash(data []byte) (uint64, error) {
+	img, err := decodeImage(data)
+	if err != nil {
+		return 0, fmt.Errorf("error decoding image: %w", err)
+	}
+
+	return DifferenceHash(img), nil
+}
+
+// DifferenceHash computes a 64-bit perceptual difference hash (dHash) of an image. The
+// image is reduced to a 9x8 greyscale thumbnail, and each bit records whether a pixel
+// is brighter than its right-hand neighbour, so that re-encoded or resized copies of
+// an image hash to the same or a nearby value.
+func DifferenceHash(img image.Image) uint64 {
+	bounds := img.Bounds()
+
+	var grey [dHashHeight][dHashWidth]float64
+	for y := 0; y < dHashHeight; y++ {
+		y0, y1 := cellRange(bounds.Min.Y, bounds.Dy(), y, dHashHeight)
+		for x := 0; x < dHashWidth; x++ {
+			x0, x1 := cellRange(bounds.Min.X, bounds.Dx(), x, dHashWidth)
+			grey[y][x] = meanLuminance(img, x0, x1, y0, y1)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < dHashHeight; y++ {
+		for x := 0; x < dHashWidth-1; x++ {
+			hash <<= 1
+			if grey[y][x] > grey[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// HammingDistance counts the bits that differ between two perceptual hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// cellRange returns the pixel range covered by cell i of n along an axis, which always
+// covers at least one pixel, even for images smaller than the thumbnail
+func cellRange(origin, length, i, n int) (int, int) {
+	start := origin + i*length/n
+	end := origin + (i+1)*length/n
+	if end <= start {
+		end = start + 1
+	}
+	if end > origin+length {
+		start, end = origin+length-1, origin+length
+	}
+	return start, end
+}
+
+// meanLuminance averages the luminance of an evenly spaced sample of the pixels in a
+// rectangle
+func meanLuminance(img image.Image, x0, x1, y0, y1 int) float64 {
+	xStep := max(1, (x1-x0)/dHashCellSamples)
+	yStep := max(1, (y1-y0)/dHashCellSamples)
+
+	var sum float64
+	var count int
+	for y := y0; y < y1; y += yStep {
+		for x := x0; x < x1; x += xStep {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			count++
+		}
+	}
+
+	return sum / float64(count)
+}
@@ -0,0 +1,52 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+
+	"golang.org/x/image/draw"
+)
+
+// thumbnailQuality is the JPEG quality of thumbnails, lower than that of re-encoded
+// originals as thumbnails are only ever viewed small
+const thumbnailQuality = 85
+
+// Thumbnail decodes image data and encodes a JPEG copy that fits within maxEdge pixels
+// on its longest side, corrected to display upright. Images that already fit are not
+// enlarged. JPEG has no transparency, so transparent areas are flattened onto white.
+func Thumbnail(data []byte, maxEdge int) ([]byte, error) {
+	img, err := decodeImage(data)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %w", err)
+	}
+	img = ApplyOrientation(img, ReadOrientation(data))
+
+	width, height := ThumbnailSize(img.Bounds().Dx(), img.Bounds().Dy(), maxEdge)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("error encoding thumbnail: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ThumbnailSize scales dimensions down to fit within maxEdge pixels on the longest side,
+// keeping the aspect ratio and at least one pixel on each side
+func ThumbnailSize(width, height, maxEdge int) (int, int) {
+	if width <= maxEdge && height <= maxEdge {
+		return width, height
+	}
+
+	if width >= height {
+		return maxEdge, max(1, height*maxEdge/width)
+	}
+	return max(1, width*maxEdge/height), maxEdge
+}
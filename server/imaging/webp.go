@@ -0,0 +1,35 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+
+	// Registers the WebP decoder with the image package
+	_ "golang.org/x/image/webp"
+)
+
+// Flags of the extended WebP header
+const (
+	webpFlagAnimation = 0x02
+	webpFlagXMP       = 0x04
+	webpFlagEXIF      = 0x08
+)
+
+// isAnimatedWebP reports whether data is an animated WebP image, whose frames the WebP
+// decoder cannot read
+func isAnimatedWebP(data []byte) bool {
+	return len(data) >= 21 &&
+		string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP" && string(data[12:16]) == "VP8X" &&
+		data[20]&webpFlagAnimation != 0
+}
+
+// decodeImage decodes image data in any registered format. Animated WebP images are
+// reported as unsupported, as are AVIF images.
+func decodeImage(data []byte) (image.Image, error) {
+	if isAnimatedWebP(data) {
+		return nil, ErrDecodeUnsupported
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
@@ -0,0 +1,11 @@
+package models
+
+// CollectionPositionGap is the spacing between the positions of neighbouring images in a
+// collection, leaving room to move an image between two others without renumbering
+const CollectionPositionGap = 1024
+
+// CollectionMove moves an image within a collection to directly after another image
+type CollectionMove struct {
+	ImageUUID string  // Image to move
+	AfterUUID *string // Image to place it after, nil to move it to the front
+}
@@ -1,10 +1,15 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
 	"github.com/foresturquhart/curator/server/utils"
+	"github.com/google/uuid"
 	"github.com/pgvector/pgvector-go"
 )
 
@@ -16,6 +21,19 @@ const (
 	FormatJPEG ImageFormat = "jpeg"
 	FormatPNG  ImageFormat = "png"
 	FormatGIF  ImageFormat = "gif"
+	FormatWEBP ImageFormat = "webp"
+	FormatAVIF ImageFormat = "avif"
+)
+
+// ContentType returns the MIME type of the image format
+func (f ImageFormat) ContentType() string {
+	return "image/" + string(f)
+}
+
+// Supported range of image ratings, wide enough for star ratings or percentage scores
+const (
+	MinImageRating = 0
+	MaxImageRating = 100
 )
 
 // SortBy specifies the field to sort by
@@ -28,6 +46,8 @@ const (
 	SortByTitle      SortBy = "title.keyword"
 	SortByTagCount   SortBy = "tag_count"
 	SortByDimensions SortBy = "pixel_count"
+	SortByRating     SortBy = "rating"
+	SortByViewCount  SortBy = "view_count"
 	SortByRandom     SortBy = "random"
 )
 
@@ -37,28 +57,75 @@ type PaginatedImageResult struct {
 	HasMore    bool               `json:"has_more"`    // Whether there are more results available
 	TotalCount int64              `json:"total_count"` // Total count of matching images
 	NextCursor []types.FieldValue `json:"next_cursor"` // Cursor for fetching the next page
+	PrevCursor []types.FieldValue `json:"prev_cursor"` // Cursor for fetching the previous page
+	Partial    bool               `json:"partial"`     // Whether the search timed out and returned partial results
+	MaxResults int                `json:"max_results"` // Deepest result reachable by paginating, zero when unbounded
+
+	// Facets holds the requested terms aggregations, keyed by facet name
+	Facets map[string][]FacetBucket `json:"facets,omitempty"`
+}
+
+// Image facets, returned as terms aggregations over the matching images
+const (
+	FacetCameraMake  = "camera_make"
+	FacetCameraModel = "camera_model"
+)
+
+// FacetBucket is a single value of a facet and the number of matching images holding it
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
 }
 
 // Image represents an image entity in the system
 type Image struct {
-	ID          int64            `json:"-"`           // Internal primary key
-	UUID        string           `json:"id"`          // Public-facing identifier
-	Filename    string           `json:"filename"`    // Original filename
-	MD5         string           `json:"md5"`         // MD5 hash
-	SHA1        string           `json:"sha1"`        // SHA1 hash
-	Width       int              `json:"width"`       // Width in pixels
-	Height      int              `json:"height"`      // Height in pixels
-	Format      ImageFormat      `json:"format"`      // File format
-	Size        int64            `json:"size"`        // File size in bytes
-	Embedding   *pgvector.Vector `json:"-"`           // Vector embedding (512 dimensions)
-	Title       *string          `json:"title"`       // Optional user-provided title
-	Description *string          `json:"description"` // Optional user-provided description
-	CreatedAt   time.Time        `json:"created_at"`  // Creation timestamp
-	UpdatedAt   time.Time        `json:"updated_at"`  // Last update timestamp
+	ID          int64            `json:"-"`            // Internal primary key
+	UUID        string           `json:"id"`           // Public-facing identifier
+	Filename    string           `json:"filename"`     // Original filename
+	MD5         string           `json:"md5"`          // MD5 hash
+	SHA1        string           `json:"sha1"`         // SHA1 hash
+	Width       int              `json:"width"`        // Width in pixels
+	Height      int              `json:"height"`       // Height in pixels
+	Format      ImageFormat      `json:"format"`       // File format
+	Size        int64            `json:"size"`         // File size in bytes
+	Embedding   *pgvector.Vector `json:"-"`            // Vector embedding (512 dimensions)
+	Title       *string          `json:"title"`        // Optional user-provided title
+	Description *string          `json:"description"`  // Optional user-provided description
+	Rating      *int             `json:"rating"`       // Optional curator rating
+	CameraMake  *string          `json:"camera_make"`  // Camera make read from EXIF
+	CameraModel *string          `json:"camera_model"` // Camera model read from EXIF
+	PHash       *int64           `json:"-"`            // Perceptual difference hash, bits stored as a signed integer
+	Language    *string          `json:"language"`     // Optional language of the title and description
+	ViewCount   int64            `json:"view_count"`   // Views flushed from the view counter
+	CreatedAt   time.Time        `json:"created_at"`   // Creation timestamp
+	UpdatedAt   time.Time        `json:"updated_at"`   // Last update timestamp
+
+	HasThumbnail bool `json:"has_thumbnail"` // Whether a thumbnail has been stored
 
 	Tags    []*ImageTag    `json:"tags"`    // Associated tags
 	People  []*ImagePerson `json:"people"`  // Associated people with roles
 	Sources []*ImageSource `json:"sources"` // Associated sources
+
+	// Transient fields populated on request, never stored
+	URL                 *string               `json:"url,omitempty"`                  // URL of the stored image object
+	SkippedAssociations []*SkippedAssociation `json:"skipped_associations,omitempty"` // References left out by a lenient upsert
+}
+
+// SkippedAssociation is a tag or person reference that a lenient upsert left out of an
+// image because it does not exist
+type SkippedAssociation struct {
+	Type       string `json:"type"`       // Either tag or person
+	Identifier string `json:"identifier"` // The UUID, ID or name the reference was given by
+}
+
+// PHashHex renders the perceptual hash as 16 hexadecimal digits, or nil when the image
+// has none
+func (i *Image) PHashHex() *string {
+	if i.PHash == nil {
+		return nil
+	}
+	hex := fmt.Sprintf("%016x", uint64(*i.PHash))
+	return &hex
 }
 
 func (i *Image) GetStoredName() string {
@@ -71,11 +138,35 @@ func (i *Image) GetStoredName() string {
 		ext = ".png"
 	case FormatGIF:
 		ext = ".gif"
+	case FormatWEBP:
+		ext = ".webp"
+	case FormatAVIF:
+		ext = ".avif"
 	}
 
 	return i.UUID + ext
 }
 
+// ImagePreview identifies a recently associated image shown alongside a person or tag
+type ImagePreview struct {
+	UUID   string      `json:"id"`
+	Format ImageFormat `json:"format"`
+
+	// Transient fields populated on request, never stored
+	URL *string `json:"url,omitempty"` // URL of the stored image object
+}
+
+// GetThumbnailName gets the storage key of the image thumbnail, which is always a JPEG
+func (i *Image) GetThumbnailName() string {
+	name := i.GetStoredName()
+	return "thumbnails/" + strings.TrimSuffix(name, path.Ext(name)) + ".jpg"
+}
+
+// GetStoredName gets the storage key of the image
+func (i *ImagePreview) GetStoredName() string {
+	return (&Image{UUID: i.UUID, Format: i.Format}).GetStoredName()
+}
+
 // GetID gets the ID of the image
 func (i *Image) GetID() int64 {
 	return i.ID
@@ -103,6 +194,35 @@ const (
 	RoleSubject PersonRole = "subject"
 )
 
+// IsValid reports whether the role is one of the known person roles
+func (r PersonRole) IsValid() bool {
+	return r == RoleCreator || r == RoleSubject
+}
+
+// PersonRoles is a set of roles to match in a filter. It accepts either a single role
+// or a list of roles when decoded from JSON.
+type PersonRoles []PersonRole
+
+func (r *PersonRoles) UnmarshalJSON(data []byte) error {
+	var single PersonRole
+	if err := json.Unmarshal(data, &single); err == nil {
+		if single == "" {
+			*r = nil
+		} else {
+			*r = PersonRoles{single}
+		}
+		return nil
+	}
+
+	var multiple []PersonRole
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("role must be a string or a list of strings: %w", err)
+	}
+	*r = multiple
+
+	return nil
+}
+
 // ImagePerson represents a person associated with an image in a specific role
 type ImagePerson struct {
 	ID      int64      `json:"-"`        // Internal primary key
@@ -117,19 +237,77 @@ type ImageSource struct {
 	URL         string  `json:"url"`         // Source URL
 	Title       *string `json:"title"`       // Optional source title
 	Description *string `json:"description"` // Optional source description
+	IsPrimary   bool    `json:"is_primary"`  // Whether this is the image's primary source
 }
 
-// ImageTagFilter represents a filter condition for a tag
+// ImageVerification reports the outcome of comparing a stored object against its recorded hashes
+type ImageVerification struct {
+	UUID         string `json:"id"`            // Public-facing identifier of the verified image
+	Valid        bool   `json:"valid"`         // Whether both hashes match
+	MD5Match     bool   `json:"md5_match"`     // Whether the MD5 hash matches
+	SHA1Match    bool   `json:"sha1_match"`    // Whether the SHA1 hash matches
+	ExpectedMD5  string `json:"expected_md5"`  // MD5 hash recorded in the database
+	ActualMD5    string `json:"actual_md5"`    // MD5 hash of the stored object
+	ExpectedSHA1 string `json:"expected_sha1"` // SHA1 hash recorded in the database
+	ActualSHA1   string `json:"actual_sha1"`   // SHA1 hash of the stored object
+}
+
+// VectorReconciliation reports the outcome of reconciling the vector index against the database
+type VectorReconciliation struct {
+	Points         int `json:"points"`          // Points found in the vector index
+	Images         int `json:"images"`          // Images found in the database
+	OrphansDeleted int `json:"orphans_deleted"` // Points deleted because no image exists for them
+	VectorsAdded   int `json:"vectors_added"`   // Images whose missing vector was upserted
+	Failed         int `json:"failed"`          // Images whose missing vector could not be upserted
+	Degenerate     int `json:"degenerate"`      // Images whose stored embedding is unusable and needs recomputing
+}
+
+// ImageTagFilter represents a filter condition for a tag. In a similarity search, an
+// include filter marked as a boost ranks matching images higher instead of requiring it.
 type ImageTagFilter struct {
-	ID      string `json:"id"`      // Tag name or UUID
-	Include bool   `json:"include"` // Whether to include (true) or exclude (false)
+	ID          string `json:"id"`          // Tag name or UUID
+	Include     bool   `json:"include"`     // Whether to include (true) or exclude (false)
+	Descendants bool   `json:"descendants"` // Whether to also match any tag in the subtree below this one
+	AsBoost     bool   `json:"as_boost"`    // Whether to prefer rather than require matches in similarity searches
 }
 
-// ImagePersonFilter represents a filter condition for a tag
+// Validate checks that a subtree filter names its tag by UUID, as the subtree is
+// expanded from the tag hierarchy in the database
+func (f *ImageTagFilter) Validate() error {
+	if f.Descendants {
+		if _, err := uuid.Parse(f.ID); err != nil {
+			return fmt.Errorf("%w: a tag filter matching descendants needs a tag UUID, got %q", utils.ErrInvalidInput, f.ID)
+		}
+	}
+
+	return nil
+}
+
+// ImagePersonFilter represents a filter condition for people. Either the person or the
+// roles may be left out, to match any person in the given roles or a person in any role.
+// In a similarity search, an include filter marked as a boost ranks matching images
+// higher instead of requiring it.
 type ImagePersonFilter struct {
-	ID      string      `json:"id"`      // Person UUID
-	Include bool        `json:"include"` // Whether to include (true) or exclude (false)
-	Role    *PersonRole `json:"role"`    // Filter by role (creator or subject, optional)
+	ID      string      `json:"id"`       // Person UUID (optional)
+	Include bool        `json:"include"`  // Whether to include (true) or exclude (false)
+	Role    PersonRoles `json:"role"`     // Roles to match, any of which satisfies the filter (optional)
+	AsBoost bool        `json:"as_boost"` // Whether to prefer rather than require matches in similarity searches
+}
+
+// Validate checks that the filter names a person or at least one role, and that every
+// role is known
+func (f *ImagePersonFilter) Validate() error {
+	if f.ID == "" && len(f.Role) == 0 {
+		return fmt.Errorf("%w: a person filter needs an id, a role, or both", utils.ErrInvalidInput)
+	}
+
+	for _, role := range f.Role {
+		if !role.IsValid() {
+			return fmt.Errorf("%w: invalid person role %q", utils.ErrInvalidInput, role)
+		}
+	}
+
+	return nil
 }
 
 // ImageFilter represents the filtering options for image queries
@@ -137,22 +315,47 @@ type ImageFilter struct {
 	// Filtering fields
 	Title              string              // Search by title
 	Description        string              // Search by description
+	Language           string              // Language whose subfields title and description are matched against
 	Source             string              // Search by source
+	Filename           string              // Search by original filename
 	Hash               string              // Search by MD5 or SHA1 hash
+	CameraMake         string              // Exact camera make
+	CameraModel        string              // Exact camera model
 	MinWidth           int                 // Minimum width in pixels
 	MaxWidth           int                 // Maximum width in pixels
 	MinHeight          int                 // Minimum height in pixels
 	MaxHeight          int                 // Maximum height in pixels
 	SinceDate          *time.Time          // Filter for images created after this date
 	BeforeDate         *time.Time          // Filter for images created before this date
+	HasTitle           *bool               // Filter for images with (true) or without (false) a title
+	HasDescription     *bool               // Filter for images with (true) or without (false) a description
+	MinRating          *int                // Minimum rating, excluding unrated images
+	MaxRating          *int                // Maximum rating, excluding unrated images
 	SimilarToID        string              // Find images similar to the image with this UUID
 	SimilarToEmbedding *pgvector.Vector    // Find images similar to this embedding vector
+	SimilarToText      string              // Find images matching this natural-language description
 	TagFilters         []ImageTagFilter    // Tags to include or exclude
 	PersonFilters      []ImagePersonFilter // People to include or exclude
 
+	// Additional reference images and embeddings, combined with the above into one query vector
+	SimilarToIDs          []string
+	SimilarToEmbeddings   []pgvector.Vector
+	SimilarityCombination utils.EmbeddingCombination
+
+	// IncludeReference keeps reference images in their own similarity results, from
+	// which they are otherwise excluded
+	IncludeReference bool
+
+	// Reference image and embedding that similarity results are steered away from
+	DissimilarToID        string
+	DissimilarToEmbedding *pgvector.Vector
+
 	// Similarity threshold field
 	SimilarityThreshold float64
 
+	// Facets to aggregate over the matching images
+	Facets []string
+
 	// Sorting fields
 	SortBy        SortBy              // Field to sort by (default: created_at)
 	SortDirection utils.SortDirection // Sort direction (default: desc)
@@ -163,4 +366,10 @@ type ImageFilter struct {
 	// Pagination fields
 	Limit         int                // Maximum number of results (default: 50, max: 100)
 	StartingAfter []types.FieldValue // Cursor to start after (forward pagination)
+	EndingBefore  []types.FieldValue // Cursor to end before (backward pagination)
+}
+
+// HasSimilarity reports whether the filter searches by visual similarity
+func (f ImageFilter) HasSimilarity() bool {
+	return f.SimilarToID != "" || f.SimilarToEmbedding != nil || f.SimilarToText != "" || len(f.SimilarToIDs) > 0 || len(f.SimilarToEmbeddings) > 0
 }
@@ -6,15 +6,41 @@ import (
 
 // Person represents a person entity in the system
 type Person struct {
-	ID          int64     `json:"id"`
-	UUID        string    `json:"uuid"`
-	Name        string    `json:"name"`
-	Description *string   `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int64      `json:"id"`
+	UUID        string     `json:"uuid"`
+	Name        string     `json:"name"`
+	Description *string    `json:"description"`
+	ActiveSince *time.Time `json:"active_since"`
+	ActiveUntil *time.Time `json:"active_until"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// PrimaryImageUUID is the image explicitly chosen to represent the person
+	PrimaryImageUUID *string `json:"primary_image_uuid"`
 
 	// Nested fields
+	Aliases []string        `json:"aliases"`
 	Sources []*PersonSource `json:"sources"`
+
+	// PrimaryImage is the image shown for the person, resolved on read
+	PrimaryImage *PersonPrimaryImage `json:"primary_image,omitempty"`
+
+	// PreviewImages are the most recently associated images, populated on request
+	PreviewImages []*ImagePreview `json:"preview_images,omitempty"`
+}
+
+// PersonPrimaryImage identifies the image used to represent a person
+type PersonPrimaryImage struct {
+	UUID   string      `json:"id"`
+	Format ImageFormat `json:"format"`
+
+	// Transient fields populated on request, never stored
+	URL *string `json:"url,omitempty"` // URL of the stored image object
+}
+
+// GetStoredName gets the storage key of the image
+func (i *PersonPrimaryImage) GetStoredName() string {
+	return (&Image{UUID: i.UUID, Format: i.Format}).GetStoredName()
 }
 
 type PersonSource struct {
@@ -30,6 +56,9 @@ func (p *Person) ToSearchRecord() *PersonSearchRecord {
 		UUID:        p.UUID,
 		Name:        p.Name,
 		Description: p.Description,
+		Aliases:     p.Aliases,
+		ActiveSince: p.ActiveSince,
+		ActiveUntil: p.ActiveUntil,
 		CreatedAt:   p.CreatedAt,
 		UpdatedAt:   p.UpdatedAt,
 	}
@@ -49,6 +78,9 @@ type PersonSearchRecord struct {
 	UUID        string                      `json:"uuid"`
 	Name        string                      `json:"name"`
 	Description *string                     `json:"description"`
+	Aliases     []string                    `json:"aliases"`
+	ActiveSince *time.Time                  `json:"active_since"`
+	ActiveUntil *time.Time                  `json:"active_until"`
 	CreatedAt   time.Time                   `json:"created_at"`
 	UpdatedAt   time.Time                   `json:"updated_at"`
 	Sources     []*PersonSearchRecordSource `json:"sources"`
@@ -67,6 +99,9 @@ func (r *PersonSearchRecord) ToModel() *Person {
 		UUID:        r.UUID,
 		Name:        r.Name,
 		Description: r.Description,
+		Aliases:     r.Aliases,
+		ActiveSince: r.ActiveSince,
+		ActiveUntil: r.ActiveUntil,
 		CreatedAt:   r.CreatedAt,
 		UpdatedAt:   r.UpdatedAt,
 		Sources:     make([]*PersonSource, len(r.Sources)),
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// ReindexJobStatus is the lifecycle state of a background reindex job
+type ReindexJobStatus string
+
+const (
+	ReindexJobQueued    ReindexJobStatus = "queued"
+	ReindexJobRunning   ReindexJobStatus = "running"
+	ReindexJobCompleted ReindexJobStatus = "completed"
+	ReindexJobFailed    ReindexJobStatus = "failed"
+	ReindexJobCancelled ReindexJobStatus = "cancelled"
+)
+
+// Done reports whether the job has finished, successfully or not
+func (s ReindexJobStatus) Done() bool {
+	return s == ReindexJobCompleted || s == ReindexJobFailed || s == ReindexJobCancelled
+}
+
+// ReindexJob tracks the progress of rebuilding a search index
+type ReindexJob struct {
+	ID        string           `json:"id"`              // Job identifier returned when the job was queued
+	Index     string           `json:"index"`           // Name of the index being rebuilt
+	Status    ReindexJobStatus `json:"status"`          // Current state of the job
+	Total     int              `json:"total"`           // Number of documents to index, once known
+	Processed int              `json:"processed"`       // Number of documents indexed so far
+	Failed    int              `json:"failed"`          // Number of documents that could not be indexed
+	Error     string           `json:"error,omitempty"` // Reason the job failed
+	UpdatedAt time.Time        `json:"updated_at"`      // When the job last reported progress
+
+	ItemsPerSecond  float64 `json:"items_per_second,omitempty"` // Rate the job is throttled to, where zero is unthrottled
+	CancelRequested bool    `json:"cancel_requested"`           // Whether the job has been asked to stop
+}
+
+// IndexProgressFunc is called as a bulk reindex works through its documents
+type IndexProgressFunc func(total, processed, failed int)
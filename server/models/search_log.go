@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// SearchLogEntry records a single search for later analysis. Only free-text terms and
+// a summary of the filters are kept, never cursors, seeds or uploaded content.
+type SearchLogEntry struct {
+	Query       string         // Normalised free-text terms
+	Filters     map[string]any // Summary of the filters applied
+	ResultCount int64          // Total number of matching results
+	Latency     time.Duration  // Time taken to serve the search
+	Partial     bool           // Whether the search timed out with partial results
+}
+
+// TopSearchQuery aggregates the searches made with the same terms
+type TopSearchQuery struct {
+	Query          string    `json:"query"`            // Normalised free-text terms
+	Count          int64     `json:"count"`            // Number of times the terms were searched for
+	AvgResults     float64   `json:"avg_results"`      // Average number of results returned
+	ZeroResults    int64     `json:"zero_results"`     // Number of searches that found nothing
+	LastSearchedAt time.Time `json:"last_searched_at"` // When the terms were last searched for
+}
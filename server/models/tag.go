@@ -13,6 +13,9 @@ type Tag struct {
 	Position    int32     `json:"position,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// PreviewImages are the most recently tagged images, populated on request
+	PreviewImages []*ImagePreview `json:"preview_images,omitempty"`
 }
 
 func (t *Tag) ToSearchRecord() *TagSearchRecord {
@@ -60,6 +63,9 @@ type TagSearchRecord struct {
 	ParentID    *int64    `json:"parent_id,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// ImageCount is the number of images tagged with the tag or any of its descendants
+	ImageCount int64 `json:"image_count"`
 }
 
 func (r *TagSearchRecord) ToModel() *Tag {
@@ -74,7 +80,72 @@ func (r *TagSearchRecord) ToModel() *Tag {
 	}
 }
 
+// TagExportRecord is the portable representation of a tag used for catalog exports. Parents
+// are referenced by UUID so the hierarchy can be reconstructed on import.
+type TagExportRecord struct {
+	UUID        string    `json:"id"`
+	Name        string    `json:"name"`
+	Description *string   `json:"description,omitempty"`
+	ParentUUID  *string   `json:"parent_id"`
+	Position    int32     `json:"position"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TagImageCounts reports how heavily a tag is used, directly and across its subtree
+type TagImageCounts struct {
+	Direct  int64 `json:"direct"`  // Images tagged with the tag itself
+	Subtree int64 `json:"subtree"` // Distinct images tagged with the tag or any of its descendants
+}
+
+// TagClosureReport describes how the tag closure table differs from the parent_id
+// relationships it is derived from
+type TagClosureReport struct {
+	Consistent bool  `json:"consistent"` // Whether the closure table matches the hierarchy
+	Missing    int64 `json:"missing"`    // Ancestor pairs absent from the closure table
+	Extraneous int64 `json:"extraneous"` // Closure rows matching no ancestor pair, or with the wrong depth
+	Tags       int64 `json:"tags"`       // Tags whose recorded ancestry is wrong
+}
+
 type TagTreeNode struct {
 	Tag      *Tag           `json:"tag"`
 	Children []*TagTreeNode `json:"children,omitempty"`
+
+	// NextChildPosition is set when the node has more children than were returned, and
+	// continues the listing of its children when passed as an after position
+	NextChildPosition *int32 `json:"next_child_position,omitempty"`
+}
+
+// TagTree is a page of a tag tree, with the top level paginated like every other level
+type TagTree struct {
+	Nodes        []*TagTreeNode `json:"nodes"`
+	NextPosition *int32         `json:"next_position,omitempty"`
+
+	// Truncated is set when the depth cap cut off deeper tags, which can be fetched level
+	// by level from the children listing
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// TagChildrenOptions selects a page of the direct children of a tag, ordered by position
+type TagChildrenOptions struct {
+	Limit         int    // Maximum number of children to return, zero for all of them
+	AfterPosition *int32 // Only return children positioned after this position
+}
+
+// TagChildrenPage is a page of the direct children of a tag
+type TagChildrenPage struct {
+	Tags         []*Tag
+	NextPosition *int32 // Position to continue after, set when more children remain
+}
+
+// NewTagChildrenPage builds a page from children fetched with one row beyond the limit
+func NewTagChildrenPage(tags []*Tag, limit int) *TagChildrenPage {
+	page := &TagChildrenPage{Tags: tags}
+
+	if limit > 0 && len(tags) > limit {
+		page.Tags = tags[:limit]
+		page.NextPosition = &tags[limit-1].Position
+	}
+
+	return page
 }
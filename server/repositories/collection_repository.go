@@ -0,0 +1,235 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/foresturquhart/curator/server/container"
+	"github.com/foresturquhart/curator/server/models"
+	"github.com/foresturquhart/curator/server/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+type CollectionRepository struct {
+	container *container.Container
+}
+
+func NewCollectionRepository(container *container.Container) *CollectionRepository {
+	return &CollectionRepository{
+		container: container,
+	}
+}
+
+// collectionMember is an image's membership of a collection
+type collectionMember struct {
+	imageID   int64
+	imageUUID string
+	position  int32
+}
+
+// lockCollectionTx resolves a collection by UUID and locks it, serialising concurrent
+// reorders of the same collection
+func (r *CollectionRepository) lockCollectionTx(ctx context.Context, tx pgx.Tx, uuid string) (int64, error) {
+	var id int64
+	err := tx.QueryRow(ctx, `SELECT id FROM collections WHERE uuid = $1 FOR UPDATE`, uuid).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, utils.ErrCollectionNotFound
+		}
+		return 0, fmt.Errorf("error fetching collection: %w", err)
+	}
+	return id, nil
+}
+
+// fetchMembersTx fetches the images of a collection in order
+func (r *CollectionRepository) fetchMembersTx(ctx context.Context, tx pgx.Tx, collectionID int64) ([]*collectionMember, error) {
+	query := `
+		SELECT ic.image_id, i.uuid, ic.position
+		FROM image_collections ic
+		JOIN images i ON i.id = ic.image_id
+		WHERE ic.collection_id = $1
+		ORDER BY ic.position
+	`
+
+	rows, err := tx.Query(ctx, query, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying collection images: %w", err)
+	}
+	defer rows.Close()
+
+	var members []*collectionMember
+	for rows.Next() {
+		var member collectionMember
+		if err := rows.Scan(&member.imageID, &member.imageUUID, &member.position); err != nil {
+			return nil, fmt.Errorf("error scanning collection image row: %w", err)
+		}
+		members = append(members, &member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating collection image rows: %w", err)
+	}
+
+	return members, nil
+}
+
+// writePositionsTx spaces the members of a collection out evenly in their slice order
+func (r *CollectionRepository) writePositionsTx(ctx context.Context, tx pgx.Tx, collectionID int64, members []*collectionMember) error {
+	// Positions are swapped between rows, so uniqueness can only hold once every row is written
+	if _, err := tx.Exec(ctx, `SET CONSTRAINTS unique_position_per_collection DEFERRED`); err != nil {
+		return fmt.Errorf("error deferring position constraint: %w", err)
+	}
+
+	imageIDs := make([]int64, len(members))
+	positions := make([]int32, len(members))
+	for i, member := range members {
+		member.position = int32((i + 1) * models.CollectionPositionGap)
+		imageIDs[i] = member.imageID
+		positions[i] = member.position
+	}
+
+	query := `
+		UPDATE image_collections ic
+		SET position = v.position
+		FROM unnest($2::int[], $3::int[]) AS v(image_id, position)
+		WHERE ic.collection_id = $1 AND ic.image_id = v.image_id
+	`
+
+	if _, err := tx.Exec(ctx, query, collectionID, imageIDs, positions); err != nil {
+		return fmt.Errorf("error writing collection positions: %w", err)
+	}
+
+	return nil
+}
+
+// Reorder rewrites the order of a collection. imageUUIDs must list every image in the
+// collection exactly once.
+func (r *CollectionRepository) Reorder(ctx context.Context, uuid string, imageUUIDs []string) error {
+	return r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		collectionID, err := r.lockCollectionTx(ctx, tx, uuid)
+		if err != nil {
+			return err
+		}
+
+		members, err := r.fetchMembersTx(ctx, tx, collectionID)
+		if err != nil {
+			return err
+		}
+
+		if len(imageUUIDs) != len(members) {
+			return fmt.Errorf("%w: expected %d images, got %d", utils.ErrInvalidInput, len(members), len(imageUUIDs))
+		}
+
+		membersByUUID := make(map[string]*collectionMember, len(members))
+		for _, member := range members {
+			membersByUUID[member.imageUUID] = member
+		}
+
+		ordered := make([]*collectionMember, 0, len(imageUUIDs))
+		for _, imageUUID := range imageUUIDs {
+			member, ok := membersByUUID[imageUUID]
+			if !ok {
+				return fmt.Errorf("%w: image %s is not in the collection or is listed twice", utils.ErrInvalidInput, imageUUID)
+			}
+			delete(membersByUUID, imageUUID)
+			ordered = append(ordered, member)
+		}
+
+		return r.writePositionsTx(ctx, tx, collectionID, ordered)
+	})
+}
+
+// Move applies a sequence of moves to a collection. Each moved image is given a position
+// between its new neighbours, so only its own row is written unless the gap between them
+// is exhausted, in which case the collection is respaced first.
+func (r *CollectionRepository) Move(ctx context.Context, uuid string, moves []models.CollectionMove) error {
+	return r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		collectionID, err := r.lockCollectionTx(ctx, tx, uuid)
+		if err != nil {
+			return err
+		}
+
+		members, err := r.fetchMembersTx(ctx, tx, collectionID)
+		if err != nil {
+			return err
+		}
+
+		for _, move := range moves {
+			// Remove the moved image from the current order
+			index := memberIndex(members, move.ImageUUID)
+			if index < 0 {
+				return fmt.Errorf("%w: image %s is not in the collection", utils.ErrInvalidInput, move.ImageUUID)
+			}
+			member := members[index]
+			members = append(members[:index], members[index+1:]...)
+
+			// Find where it is inserted
+			insertAt := 0
+			if move.AfterUUID != nil {
+				if *move.AfterUUID == move.ImageUUID {
+					return fmt.Errorf("%w: image %s cannot be moved after itself", utils.ErrInvalidInput, move.ImageUUID)
+				}
+				after := memberIndex(members, *move.AfterUUID)
+				if after < 0 {
+					return fmt.Errorf("%w: image %s is not in the collection", utils.ErrInvalidInput, *move.AfterUUID)
+				}
+				insertAt = after + 1
+			}
+
+			members = append(members[:insertAt], append([]*collectionMember{member}, members[insertAt:]...)...)
+
+			position, ok := positionBetween(members, insertAt)
+			if !ok {
+				// No room between the neighbours, so spread the whole collection out again
+				if err := r.writePositionsTx(ctx, tx, collectionID, members); err != nil {
+					return err
+				}
+				continue
+			}
+
+			query := `UPDATE image_collections SET position = $1 WHERE collection_id = $2 AND image_id = $3`
+			if _, err := tx.Exec(ctx, query, position, collectionID, member.imageID); err != nil {
+				return fmt.Errorf("error moving collection image: %w", err)
+			}
+			member.position = position
+		}
+
+		return nil
+	})
+}
+
+// memberIndex returns the index of an image within the members, or -1 if absent
+func memberIndex(members []*collectionMember, imageUUID string) int {
+	for i, member := range members {
+		if member.imageUUID == imageUUID {
+			return i
+		}
+	}
+	return -1
+}
+
+// positionBetween picks a position for the member at index that lies strictly between
+// its neighbours, reporting false when they leave no room
+func positionBetween(members []*collectionMember, index int) (int32, bool) {
+	// Positions must remain positive, so the front is bounded by zero
+	var lower int64
+	if index > 0 {
+		lower = int64(members[index-1].position)
+	}
+
+	if index == len(members)-1 {
+		upper := lower + models.CollectionPositionGap
+		if upper > int64(^uint32(0)>>1) {
+			return 0, false
+		}
+		return int32(upper), true
+	}
+
+	upper := int64(members[index+1].position)
+	if upper-lower < 2 {
+		return 0, false
+	}
+
+	return int32(lower + (upper-lower)/2), true
+}
@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/foresturquhart/curator/server/container"
+	"github.com/foresturquhart/curator/server/models"
+)
+
+// fetchImagePreviews retrieves up to limit of the most recently associated images for
+// each owner, through an association table linking images to people or tags. The
+// table and column names are fixed by the callers and never taken from input.
+func fetchImagePreviews(ctx context.Context, c *container.Container, table string, column string, ownerIDs []int64, limit int) (map[int64][]*models.ImagePreview, error) {
+	previews := make(map[int64][]*models.ImagePreview, len(ownerIDs))
+	if len(ownerIDs) == 0 || limit <= 0 {
+		return previews, nil
+	}
+
+	// An image may be linked to a person more than once under different roles, so
+	// associations are grouped per image before the most recent are picked
+	query := fmt.Sprintf(`
+		SELECT o.id, i.uuid, i.format
+		FROM unnest($1::bigint[]) AS o(id)
+		CROSS JOIN LATERAL (
+			SELECT a.image_id, MAX(a.created_at) AS associated_at
+			FROM %[1]s a
+			WHERE a.%[2]s = o.id
+			GROUP BY a.image_id
+			ORDER BY associated_at DESC NULLS LAST, a.image_id DESC
+			LIMIT $2
+		) recent
+		JOIN images i ON i.id = recent.image_id
+		ORDER BY o.id, recent.associated_at DESC NULLS LAST, recent.image_id DESC
+	`, table, column)
+
+	rows, err := c.Postgres.Pool.Query(ctx, query, ownerIDs, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching preview images: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ownerID int64
+		var preview models.ImagePreview
+		if err := rows.Scan(&ownerID, &preview.UUID, &preview.Format); err != nil {
+			return nil, fmt.Errorf("error scanning preview image: %w", err)
+		}
+		previews[ownerID] = append(previews[ownerID], &preview)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating preview images: %w", err)
+	}
+
+	return previews, nil
+}
@@ -6,37 +6,141 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v8/esapi"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/functionboostmode"
-	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/sortorder"
+	"github.com/foresturquhart/curator/server/cache"
 	"github.com/foresturquhart/curator/server/container"
+	"github.com/foresturquhart/curator/server/imaging"
 	"github.com/foresturquhart/curator/server/models"
+	"github.com/foresturquhart/curator/server/telemetry"
 	"github.com/foresturquhart/curator/server/utils"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/pgvector/pgvector-go"
 	"github.com/qdrant/go-client/qdrant"
 	"github.com/rs/zerolog/log"
 )
 
+// ImageIndex is the name of the Elasticsearch index holding images
+const ImageIndex = "images"
+
+// indexAllBatchSize is the number of images loaded at a time when reindexing everything
+const indexAllBatchSize = 100
+
+// imageFacetSize is the number of values returned for each requested facet
+const imageFacetSize = 20
+
+// vectorScrollPageSize is the number of point IDs read from Qdrant per page when reconciling
+const vectorScrollPageSize = 1000
+
 type ImageRepository struct {
-	container *container.Container
+	container   *container.Container
+	tagCache    *cache.TagCache
+	checkpoints *cache.IndexCheckpoints
+	rebuilds    *cache.IndexRebuilds
+
+	// queryVectors runs the nearest-neighbour query of a similarity search
+	queryVectors func(ctx context.Context, request *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error)
 }
 
 func NewImageRepository(container *container.Container) *ImageRepository {
 	return &ImageRepository{
-		container: container,
+		container:    container,
+		tagCache:     cache.NewTagCache(container),
+		checkpoints:  cache.NewIndexCheckpoints(container),
+		rebuilds:     cache.NewIndexRebuilds(container),
+		queryVectors: container.Qdrant.Client.Query,
 	}
 }
 
-// TODO: when we add or remove tags, we need to dispatch elastic reindexing requests for those tags so the image_count field can be updated
 // TODO: when we add or remove people, we need to dispatch elastic reindexing requests for those people so their image_count fields can be updated
 
-func (r *ImageRepository) reindexElastic(ctx context.Context, image *models.Image) error {
-	// Construct the document to index
+// enqueueTagCountReindex queues reindexing of tags whose associations with an image
+// changed, so that their image counts stay current. Counts include descendants, so the
+// ancestors of each tag are queued as well. Failures are logged, as the associations
+// have already been committed.
+func (r *ImageRepository) enqueueTagCountReindex(ctx context.Context, tagIDs []int64) {
+	if len(tagIDs) == 0 {
+		return
+	}
+
+	rows, err := r.container.Postgres.Pool.Query(ctx,
+		"SELECT DISTINCT ancestor FROM tag_closure WHERE descendant = ANY($1)", tagIDs)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to resolve tags to reindex")
+		return
+	}
+
+	ancestors, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to resolve tags to reindex")
+		return
+	}
+
+	enqueueTagReindex(ctx, r.container, ancestors)
+}
+
+// reindexElastic indexes an image into an index, only when absent if create is set
+func (r *ImageRepository) reindexElastic(ctx context.Context, index string, image *models.Image, create bool) error {
+	// Encode the document
+	payload, err := json.Marshal(imageDocument(image))
+	if err != nil {
+		return fmt.Errorf("error encoding document: %w", err)
+	}
+
+	// Create index request
+	req := esapi.IndexRequest{
+		Index:      index,
+		DocumentID: image.UUID,
+		Body:       bytes.NewReader(payload),
+		// Make the document immediately searchable
+		Refresh: "true",
+	}
+	if create {
+		req.OpType = "create"
+	}
+
+	// Execute the request
+	res, err := req.Do(ctx, r.container.Elastic.Client)
+	if err != nil {
+		return fmt.Errorf("error executing index request: %w", err)
+	}
+
+	// Handle potential close error
+	defer func() {
+		if closeErr := res.Body.Close(); closeErr != nil {
+			log.Error().Err(err).Msg("Failed to close Elasticsearch response body")
+		}
+	}()
+
+	// Check if the request was successful
+	if res.IsError() {
+		if create && res.StatusCode == 409 {
+			return nil
+		}
+		var e map[string]any
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			return fmt.Errorf("error parsing the response body: %w", err)
+		}
+		return fmt.Errorf("error indexing document [status:%s]: %v", res.Status(), e)
+	}
+
+	return nil
+}
+
+// imageDocument builds the Elasticsearch document of an image. The embedding is
+// deliberately left out, as vectors are stored in Qdrant and Postgres and would only
+// inflate the index and responses.
+func imageDocument(image *models.Image) map[string]any {
 	document := map[string]any{
 		"id":          image.ID,
 		"uuid":        image.UUID,
@@ -51,6 +155,9 @@ func (r *ImageRepository) reindexElastic(ctx context.Context, image *models.Imag
 		"updated_at":  image.UpdatedAt,
 		"tags_count":  len(image.Tags),
 		"pixel_count": int64(image.Width) * int64(image.Height),
+		"view_count":  image.ViewCount,
+
+		"has_thumbnail": image.HasThumbnail,
 	}
 
 	// Handle nullable fields
@@ -62,6 +169,26 @@ func (r *ImageRepository) reindexElastic(ctx context.Context, image *models.Imag
 		document["description"] = *image.Description
 	}
 
+	if image.Rating != nil {
+		document["rating"] = *image.Rating
+	}
+
+	if image.CameraMake != nil {
+		document["camera_make"] = *image.CameraMake
+	}
+
+	if image.CameraModel != nil {
+		document["camera_model"] = *image.CameraModel
+	}
+
+	if phash := image.PHashHex(); phash != nil {
+		document["phash"] = *phash
+	}
+
+	if image.Language != nil {
+		document["language"] = *image.Language
+	}
+
 	// Add tags
 	if len(image.Tags) > 0 {
 		tags := make([]map[string]any, len(image.Tags))
@@ -94,9 +221,10 @@ func (r *ImageRepository) reindexElastic(ctx context.Context, image *models.Imag
 	// Add sources
 	if len(image.Sources) > 0 {
 		sources := make([]map[string]any, len(image.Sources))
-		for _, source := range image.Sources {
+		for i, source := range image.Sources {
 			sourceDoc := map[string]any{
-				"url": source.URL,
+				"url":        source.URL,
+				"is_primary": source.IsPrimary,
 			}
 
 			// Handle nullable fields
@@ -108,52 +236,26 @@ func (r *ImageRepository) reindexElastic(ctx context.Context, image *models.Imag
 				sourceDoc["description"] = *source.Description
 			}
 
-			sources = append(sources, sourceDoc)
+			sources[i] = sourceDoc
 		}
 		document["sources"] = sources
 	}
 
-	// Encode the document
-	payload, err := json.Marshal(document)
-	if err != nil {
-		return fmt.Errorf("error encoding document: %w", err)
-	}
-
-	// Create index request
-	req := esapi.IndexRequest{
-		Index:      "images",
-		DocumentID: image.UUID,
-		Body:       bytes.NewReader(payload),
-		// Make the document immediately searchable
-		Refresh: "true",
-	}
+	return document
+}
 
-	// Execute the request
-	res, err := req.Do(ctx, r.container.Elastic.Client)
-	if err != nil {
-		return fmt.Errorf("error executing index request: %w", err)
+func (r *ImageRepository) reindexQdrant(ctx context.Context, image *models.Image) error {
+	// Vectors that are not kept in Postgres are only written when the image is created
+	if image.Embedding == nil {
+		return nil
 	}
 
-	// Handle potential close error
-	defer func() {
-		if closeErr := res.Body.Close(); closeErr != nil {
-			log.Error().Err(err).Msg("Failed to close Elasticsearch response body")
-		}
-	}()
-
-	// Check if the request was successful
-	if res.IsError() {
-		var e map[string]any
-		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
-			return fmt.Errorf("error parsing the response body: %w", err)
-		}
-		return fmt.Errorf("error indexing document [status:%s]: %v", res.Status(), e)
+	// A degenerate vector would match everything or nothing, so it is kept out of the index
+	if err := utils.ValidateEmbedding(image.Embedding.Slice()); err != nil {
+		log.Warn().Err(err).Str("uuid", image.UUID).Msg("Skipping vector of image with degenerate embedding")
+		return nil
 	}
 
-	return nil
-}
-
-func (r *ImageRepository) reindexQdrant(ctx context.Context, image *models.Image) error {
 	_, err := r.container.Qdrant.Client.Upsert(ctx, &qdrant.UpsertPoints{
 		CollectionName: "images",
 		Points: []*qdrant.PointStruct{
@@ -171,9 +273,15 @@ func (r *ImageRepository) reindexQdrant(ctx context.Context, image *models.Image
 	return nil
 }
 
+// Index indexes an image into the image index, any version of it being rebuilt, and Qdrant
 func (r *ImageRepository) Index(ctx context.Context, image *models.Image) error {
-	if err := r.reindexElastic(ctx, image); err != nil {
-		return fmt.Errorf("error indexing image in Elastic: %w", err)
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.Index")
+	defer span.End()
+
+	for _, index := range r.rebuilds.WriteTargets(ctx, ImageIndex) {
+		if err := r.reindexElastic(ctx, index, image, false); err != nil {
+			return fmt.Errorf("error indexing image in Elastic: %w", err)
+		}
 	}
 
 	if err := r.reindexQdrant(ctx, image); err != nil {
@@ -183,16 +291,29 @@ func (r *ImageRepository) Index(ctx context.Context, image *models.Image) error
 	return nil
 }
 
-func (r *ImageRepository) IndexAll(ctx context.Context) error {
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("error starting transaction: %w", err)
+// indexInto indexes an image into a version of the image index being rebuilt, and into
+// Qdrant. A document already written there by Index is left alone, as it is at least as
+// recent.
+func (r *ImageRepository) indexInto(ctx context.Context, index string, image *models.Image) error {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.Index")
+	defer span.End()
+
+	if err := r.reindexElastic(ctx, index, image, true); err != nil {
+		return fmt.Errorf("error indexing image in Elastic: %w", err)
 	}
 
-	// Get all image IDs
-	rows, err := tx.Query(ctx, "SELECT id FROM images ORDER BY id")
+	if err := r.reindexQdrant(ctx, image); err != nil {
+		return fmt.Errorf("error indexing image in qdrant: %w", err)
+	}
+
+	return nil
+}
+
+// GetAllIDs retrieves all image IDs from the database.
+func (r *ImageRepository) GetAllIDs(ctx context.Context) ([]int64, error) {
+	rows, err := r.container.Postgres.Pool.Query(ctx, "SELECT id FROM images ORDER BY id")
 	if err != nil {
-		return fmt.Errorf("error querying image IDs: %w", err)
+		return nil, fmt.Errorf("error querying image IDs: %w", err)
 	}
 	defer rows.Close()
 
@@ -200,739 +321,2510 @@ func (r *ImageRepository) IndexAll(ctx context.Context) error {
 	for rows.Next() {
 		var id int64
 		if err := rows.Scan(&id); err != nil {
-			return fmt.Errorf("error scanning image ID: %w", err)
+			return nil, fmt.Errorf("error scanning image ID: %w", err)
 		}
 		imageIDs = append(imageIDs, id)
 	}
 	if err := rows.Err(); err != nil {
-		return fmt.Errorf("error iterating image IDs: %w", err)
+		return nil, fmt.Errorf("error iterating image IDs: %w", err)
 	}
 
-	// Commit the transaction to release the connection
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("error committing transaction to get IDs: %w", err)
+	return imageIDs, nil
+}
+
+// IndexAll reindexes every image into the given version of the image index, reporting
+// progress after each one when progress is not nil. A checkpoint is recorded for that
+// version after every batch, and a run into it that was interrupted resumes after the last
+// completed batch unless restart is set.
+func (r *ImageRepository) IndexAll(ctx context.Context, index string, progress models.IndexProgressFunc, restart bool) error {
+	// Get all image IDs
+	imageIDs, err := r.GetAllIDs(ctx)
+	if err != nil {
+		return err
 	}
 
-	// Iterate through IDs and reindex each image
-	for _, id := range imageIDs {
-		// Get the image by ID
-		image, err := r.GetByID(ctx, id)
+	if restart {
+		if err := r.checkpoints.Clear(ctx, index); err != nil {
+			return err
+		}
+	} else {
+		checkpoint, found, err := r.checkpoints.Get(ctx, index)
 		if err != nil {
-			// Log the error and continue to the next image
-			log.Error().Err(err).Msgf("Error retrieving image for id %d", id)
-			continue
+			return err
 		}
-
-		// Reindex in a new transaction
-		if err := r.Index(ctx, image); err != nil {
-			log.Error().Err(err).Msgf("Error reindexing image %s", image.UUID)
-			continue
+		if found {
+			// IDs are listed in ascending order, so skip everything up to the checkpoint
+			resumeAt, _ := slices.BinarySearch(imageIDs, checkpoint+1)
+			log.Info().Int64("checkpoint", checkpoint).Int("remaining", len(imageIDs)-resumeAt).Msg("Resuming image reindex from checkpoint")
+			imageIDs = imageIDs[resumeAt:]
 		}
+	}
 
-		log.Info().Msgf("Reindexed image %s", image.UUID)
+	var processed, failed int
+	report := func() {
+		if progress != nil {
+			progress(len(imageIDs), processed, failed)
+		}
 	}
+	report()
 
-	return nil
-}
+	// Load and reindex the images in batches, so associations are fetched a batch at a time
+	for start := 0; start < len(imageIDs); start += indexAllBatchSize {
+		// Stop between batches when cancelled, leaving the checkpoint to resume from
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
-func (r *ImageRepository) getByIDTx(ctx context.Context, tx pgx.Tx, id int64) (*models.Image, error) {
-	query := `
-		SELECT id, uuid, filename, md5, sha1, width, height, format, size,
-			   embedding, title, description, created_at, updated_at
-		FROM images
-		WHERE id = $1
-	`
+		batch := imageIDs[start:min(start+indexAllBatchSize, len(imageIDs))]
 
-	var image models.Image
-	var titlePtr, descriptionPtr *string
+		images, err := r.GetManyByIDs(ctx, batch)
+		if err != nil {
+			// Log the error and continue to the next batch
+			log.Error().Err(err).Msgf("Error retrieving images for ids %d to %d", batch[0], batch[len(batch)-1])
+			failed += len(batch)
+			report()
+			continue
+		}
 
-	err := tx.QueryRow(ctx, query, id).Scan(
-		&image.ID, &image.UUID, &image.Filename, &image.MD5, &image.SHA1,
-		&image.Width, &image.Height, &image.Format, &image.Size, &image.Embedding,
-		&titlePtr, &descriptionPtr, &image.CreatedAt, &image.UpdatedAt,
-	)
+		// Images deleted since the IDs were listed cannot be indexed, and are counted as
+		// failures so that progress still reaches the total
+		failed += len(batch) - len(images)
 
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, utils.ErrImageNotFound
-		}
-		return nil, fmt.Errorf("error fetching image: %w", err)
-	}
+		for _, image := range images {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 
-	image.Title = titlePtr
-	image.Description = descriptionPtr
+			// Reindex in a new transaction
+			if err := r.indexInto(ctx, index, image); err != nil {
+				log.Error().Err(err).Msgf("Error reindexing image %s", image.UUID)
+				failed++
+				report()
+				continue
+			}
 
-	// Fetch all associations
-	err = r.fetchImageAssociations(ctx, tx, &image)
-	if err != nil {
-		return nil, err
+			log.Info().Msgf("Reindexed image %s", image.UUID)
+			processed++
+			report()
+		}
+
+		if err := r.checkpoints.Save(ctx, index, batch[len(batch)-1]); err != nil {
+			log.Warn().Err(err).Msg("Failed to record image reindex checkpoint")
+		}
 	}
 
-	return &image, nil
+	// The run is complete, so the next one starts from the beginning
+	return r.checkpoints.Clear(ctx, index)
 }
 
-func (r *ImageRepository) GetByID(ctx context.Context, id int64) (*models.Image, error) {
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
+// ReconcileVectors brings the Qdrant collection in line with the database, which is the
+// source of truth. Points with no matching image, typically left behind by a failed
+// best-effort delete, are removed, and images with no point are upserted again.
+func (r *ImageRepository) ReconcileVectors(ctx context.Context) (*models.VectorReconciliation, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.ReconcileVectors")
+	defer span.End()
+
+	result := &models.VectorReconciliation{}
+
+	// Collect every image UUID in the database
+	rows, err := r.container.Postgres.Pool.Query(ctx, "SELECT id, uuid FROM images")
 	if err != nil {
-		return nil, fmt.Errorf("error starting transaction: %w", err)
+		return nil, fmt.Errorf("error querying images: %w", err)
 	}
 
-	// Ensure we handle rollback errors
-	defer func() {
-		if tx != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				// Just log the rollback error as there's not much we can do at this point
-				log.Error().Err(err).Msg("Failed to roll back transaction")
-			}
+	images := make(map[string]int64)
+	for rows.Next() {
+		var id int64
+		var uuid string
+		if err := rows.Scan(&id, &uuid); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning image row: %w", err)
 		}
-	}()
-
-	image, err := r.getByIDTx(ctx, tx, id)
-	if err != nil {
-		return nil, err
+		images[uuid] = id
 	}
+	rows.Close()
 
-	err = tx.Commit(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("error committing transaction: %w", err)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating image rows: %w", err)
 	}
+	result.Images = len(images)
 
-	return image, nil
-}
+	// Walk every point in the collection, deleting orphans a page at a time
+	indexed := make(map[string]bool, len(images))
+	limit := uint32(vectorScrollPageSize)
+	var offset *qdrant.PointId
 
-func (r *ImageRepository) getByUUIDTx(ctx context.Context, tx pgx.Tx, uuid string) (*models.Image, error) {
-	query := `
-		SELECT id, uuid, filename, md5, sha1, width, height, format, size,
-			   embedding, title, description, created_at, updated_at
-		FROM images
-		WHERE uuid = $1
-	`
+	for {
+		page, err := r.container.Qdrant.Client.GetPointsClient().Scroll(ctx, &qdrant.ScrollPoints{
+			CollectionName: "images",
+			Offset:         offset,
+			Limit:          &limit,
+			WithPayload:    qdrant.NewWithPayloadEnable(false),
+			WithVectors:    qdrant.NewWithVectorsEnable(false),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error scrolling vectors: %w", err)
+		}
 
-	var image models.Image
-	var titlePtr, descriptionPtr *string
+		var candidates []*qdrant.PointId
+		for _, point := range page.GetResult() {
+			uuid := point.GetId().GetUuid()
+			if _, ok := images[uuid]; ok {
+				indexed[uuid] = true
+				continue
+			}
+			candidates = append(candidates, point.GetId())
+		}
+		result.Points += len(page.GetResult())
 
-	err := tx.QueryRow(ctx, query, uuid).Scan(
-		&image.ID, &image.UUID, &image.Filename, &image.MD5, &image.SHA1,
-		&image.Width, &image.Height, &image.Format, &image.Size, &image.Embedding,
-		&titlePtr, &descriptionPtr, &image.CreatedAt, &image.UpdatedAt,
-	)
+		// Images created since the snapshot was taken have vectors too, so candidates
+		// are checked against the database again before they are deleted
+		orphans, err := r.filterOrphanedPoints(ctx, candidates)
+		if err != nil {
+			return nil, err
+		}
 
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, utils.ErrImageNotFound
+		if len(orphans) > 0 {
+			_, err := r.container.Qdrant.Client.Delete(ctx, &qdrant.DeletePoints{
+				CollectionName: "images",
+				Points:         qdrant.NewPointsSelectorIDs(orphans),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error deleting orphaned vectors: %w", err)
+			}
+			result.OrphansDeleted += len(orphans)
+		}
+
+		offset = page.GetNextPageOffset()
+		if offset == nil {
+			break
 		}
-		return nil, fmt.Errorf("error fetching image: %w", err)
 	}
 
-	image.Title = titlePtr
-	image.Description = descriptionPtr
+	// Upsert the vectors of images missing from the collection
+	var missing []int64
+	for uuid, id := range images {
+		if !indexed[uuid] {
+			missing = append(missing, id)
+		}
+	}
 
-	// Fetch all associations
-	err = r.fetchImageAssociations(ctx, tx, &image)
-	if err != nil {
-		return nil, err
+	for start := 0; start < len(missing); start += indexAllBatchSize {
+		batch := missing[start:min(start+indexAllBatchSize, len(missing))]
+
+		batchImages, err := r.GetManyByIDs(ctx, batch)
+		if err != nil {
+			log.Error().Err(err).Msg("Error retrieving images with missing vectors")
+			result.Failed += len(batch)
+			continue
+		}
+
+		for _, image := range batchImages {
+			if image.Embedding == nil {
+				result.Failed++
+				continue
+			}
+
+			if err := utils.ValidateEmbedding(image.Embedding.Slice()); err != nil {
+				log.Warn().Err(err).Str("uuid", image.UUID).Msg("Image has a degenerate embedding and needs recomputing")
+				result.Degenerate++
+				continue
+			}
+
+			if err := r.reindexQdrant(ctx, image); err != nil {
+				log.Error().Err(err).Msgf("Error upserting vector for image %s", image.UUID)
+				result.Failed++
+				continue
+			}
+			result.VectorsAdded++
+		}
 	}
 
-	return &image, nil
+	return result, nil
 }
 
-func (r *ImageRepository) GetByUUID(ctx context.Context, uuid string) (*models.Image, error) {
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("error starting transaction: %w", err)
+// filterOrphanedPoints returns the points whose image no longer exists in the database
+func (r *ImageRepository) filterOrphanedPoints(ctx context.Context, points []*qdrant.PointId) ([]*qdrant.PointId, error) {
+	if len(points) == 0 {
+		return nil, nil
 	}
 
-	// Ensure we handle rollback errors
-	defer func() {
-		if tx != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				// Just log the rollback error as there's not much we can do at this point
-				log.Error().Err(err).Msg("Failed to roll back transaction")
-			}
+	// Points with numeric IDs can never belong to an image
+	uuids := make([]string, 0, len(points))
+	for _, point := range points {
+		if uuid := point.GetUuid(); uuid != "" {
+			uuids = append(uuids, uuid)
 		}
-	}()
+	}
+	if len(uuids) == 0 {
+		return points, nil
+	}
 
-	image, err := r.getByUUIDTx(ctx, tx, uuid)
+	rows, err := r.container.Postgres.Pool.Query(ctx, "SELECT uuid::text FROM images WHERE uuid = ANY($1::uuid[])", uuids)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error checking orphaned vectors: %w", err)
 	}
 
-	err = tx.Commit(ctx)
+	existing, err := pgx.CollectRows(rows, pgx.RowTo[string])
 	if err != nil {
-		return nil, fmt.Errorf("error committing transaction: %w", err)
+		return nil, fmt.Errorf("error checking orphaned vectors: %w", err)
 	}
 
-	return image, nil
-}
+	if len(existing) == 0 {
+		return points, nil
+	}
 
-// TODO: When we add a child tag, all parent tags (up the tree) should be automatically assigned to the image.
-func (r *ImageRepository) Upsert(ctx context.Context, image *models.Image) error {
-	// Start a transaction
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("error starting transaction: %w", err)
+	exists := make(map[string]bool, len(existing))
+	for _, uuid := range existing {
+		exists[uuid] = true
 	}
 
-	// Ensure we handle rollback errors
-	defer func() {
-		if tx != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				// Just log the rollback error as there's not much we can do at this point
-				log.Error().Err(err).Msg("Failed to roll back transaction")
-			}
+	orphans := make([]*qdrant.PointId, 0, len(points))
+	for _, point := range points {
+		if !exists[point.GetUuid()] {
+			orphans = append(orphans, point)
 		}
-	}()
-
-	// Get the existing image to compare associations
-	var existingImage *models.Image
+	}
+	return orphans, nil
+}
 
-	// Determine if this is an insert or update
-	isUpdate := image.ID > 0 || image.UUID != ""
+// FindWithoutEmbedding lists images that have no stored embedding, in ascending ID
+// order starting after afterID. When embeddings are kept in Qdrant only, images without
+// a vector in the collection are listed instead. Only the image columns are loaded;
+// associations are left empty, as callers only need to identify the images to backfill.
+func (r *ImageRepository) FindWithoutEmbedding(ctx context.Context, limit int, afterID int64) ([]*models.Image, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.FindWithoutEmbedding")
+	defer span.End()
 
-	if isUpdate {
-		if image.ID > 0 {
-			existingImage, err = r.getByIDTx(ctx, tx, image.ID)
-		} else {
-			existingImage, err = r.getByUUIDTx(ctx, tx, image.UUID)
-		}
+	if r.container.Config.StoreEmbeddingsInPostgres {
+		return r.queryImageColumns(ctx, "embedding IS NULL", afterID, limit)
+	}
 
+	var images []*models.Image
+	for len(images) < limit {
+		batch, err := r.queryImageColumns(ctx, "TRUE", afterID, vectorScrollPageSize)
 		if err != nil {
-			return fmt.Errorf("error retrieving existing image: %w", err)
-		}
-
-		if existingImage.Filename != image.Filename {
-			return fmt.Errorf("image filename is immutable")
-		}
-
-		if existingImage.MD5 != image.MD5 || existingImage.SHA1 != image.SHA1 {
-			return fmt.Errorf("image hashes are immutable")
+			return nil, err
 		}
-
-		if existingImage.Width != image.Width || existingImage.Height != image.Height {
-			return fmt.Errorf("image dimensions are immutable")
+		if len(batch) == 0 {
+			break
 		}
 
-		if existingImage.Format != image.Format {
-			return fmt.Errorf("image format is immutable")
+		missing, err := r.filterWithoutVector(ctx, batch)
+		if err != nil {
+			return nil, err
 		}
+		images = append(images, missing...)
 
-		if existingImage.Size != image.Size {
-			return fmt.Errorf("image size is immutable")
+		if len(batch) < vectorScrollPageSize {
+			break
 		}
+		afterID = batch[len(batch)-1].ID
+	}
 
-		if existingImage.Embedding != image.Embedding {
-			return fmt.Errorf("image embedding is immutable")
-		}
+	// Images past the limit are checked again from the last one returned
+	if len(images) > limit {
+		images = images[:limit]
+	}
 
-		// Perform the update
-		query := `
-			UPDATE images SET
-				title = $1,
-				description = $2
-			WHERE id = $3
-			RETURNING id, uuid, created_at, updated_at
-		`
+	return images, nil
+}
 
-		err = tx.QueryRow(
-			ctx, query, image.Title, image.Description, existingImage.ID,
-		).Scan(&image.ID, &image.UUID, &image.CreatedAt, &image.UpdatedAt)
+// queryImageColumns loads the columns of the images matching a condition, in ascending
+// ID order starting after afterID
+func (r *ImageRepository) queryImageColumns(ctx context.Context, condition string, afterID int64, limit int) ([]*models.Image, error) {
+	rows, err := r.container.Postgres.Pool.Query(ctx, `
+		SELECT id, uuid, filename, md5, sha1, width, height, format, size,
+			   title, description, rating, camera_make, camera_model, phash, language, has_thumbnail, view_count, created_at, updated_at
+		FROM images
+		WHERE `+condition+` AND id > $1
+		ORDER BY id ASC
+		LIMIT $2
+	`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying images without embedding: %w", err)
+	}
+	defer rows.Close()
 
-		if err != nil {
-			return fmt.Errorf("error updating image: %w", err)
+	var images []*models.Image
+	for rows.Next() {
+		var image models.Image
+		if err := rows.Scan(
+			&image.ID, &image.UUID, &image.Filename, &image.MD5, &image.SHA1,
+			&image.Width, &image.Height, &image.Format, &image.Size,
+			&image.Title, &image.Description, &image.Rating, &image.CameraMake, &image.CameraModel, &image.PHash, &image.Language, &image.HasThumbnail, &image.ViewCount, &image.CreatedAt, &image.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning image row: %w", err)
 		}
-	} else {
-		// TODO: check for duplicate here and return a conflict error
+		images = append(images, &image)
+	}
 
-		// Create new image
-		query := `
-			INSERT INTO images (
-				filename, md5, sha1, width, height, format, size,
-				embedding, title, description
-			) VALUES (
-				$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
-			) RETURNING id, uuid, created_at, updated_at
-		`
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating image rows: %w", err)
+	}
 
-		err = tx.QueryRow(ctx, query,
-			image.Filename, image.MD5, image.SHA1,
-			image.Width, image.Height, image.Format, image.Size,
-			image.Embedding, image.Title, image.Description,
-		).Scan(&image.ID, &image.UUID, &image.CreatedAt, &image.UpdatedAt)
+	return images, nil
+}
 
-		if err != nil {
-			return fmt.Errorf("error inserting image: %w", err)
-		}
+// filterWithoutVector returns the images that have no point in the Qdrant collection
+func (r *ImageRepository) filterWithoutVector(ctx context.Context, images []*models.Image) ([]*models.Image, error) {
+	ids := make([]*qdrant.PointId, 0, len(images))
+	for _, image := range images {
+		ids = append(ids, qdrant.NewIDUUID(image.UUID))
 	}
 
-	// Synchronise tag associations
-	if err := r.syncTagAssociations(ctx, tx, image, existingImage); err != nil {
-		return fmt.Errorf("error handling tag associations: %w", err)
+	points, err := r.container.Qdrant.Client.Get(ctx, &qdrant.GetPoints{
+		CollectionName: "images",
+		Ids:            ids,
+		WithPayload:    qdrant.NewWithPayloadEnable(false),
+		WithVectors:    qdrant.NewWithVectorsEnable(false),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching image vectors: %w", err)
 	}
 
-	// Synchronise people associations
-	if err := r.syncPeopleAssociations(ctx, tx, image, existingImage); err != nil {
-		return fmt.Errorf("error handling people associations: %w", err)
+	indexed := make(map[string]bool, len(points))
+	for _, point := range points {
+		indexed[point.GetId().GetUuid()] = true
 	}
 
-	// Synchronise source associations
-	if err := r.syncSourceAssociations(ctx, tx, image, existingImage); err != nil {
-		return fmt.Errorf("error handling source associations: %w", err)
+	var missing []*models.Image
+	for _, image := range images {
+		if !indexed[image.UUID] {
+			missing = append(missing, image)
+		}
 	}
+	return missing, nil
+}
+
+// CountWithoutEmbedding returns the number of images that have no stored embedding.
+// When embeddings are kept in Qdrant only, it counts images without a vector in the
+// collection instead, which means checking every image against it.
+func (r *ImageRepository) CountWithoutEmbedding(ctx context.Context) (int64, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.CountWithoutEmbedding")
+	defer span.End()
+
+	if !r.container.Config.StoreEmbeddingsInPostgres {
+		var count int64
+		var afterID int64
+		for {
+			batch, err := r.queryImageColumns(ctx, "TRUE", afterID, vectorScrollPageSize)
+			if err != nil {
+				return 0, err
+			}
+			if len(batch) == 0 {
+				return count, nil
+			}
 
-	// Commit the transaction
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("error committing transaction: %w", err)
+			missing, err := r.filterWithoutVector(ctx, batch)
+			if err != nil {
+				return 0, err
+			}
+			count += int64(len(missing))
+
+			afterID = batch[len(batch)-1].ID
+		}
 	}
 
-	// Enqueue reindex after successful storage commit
-	if err := r.container.Worker.EnqueueReindexImage(ctx, image.ID); err != nil {
-		log.Error().Err(err).Msgf("Failed to queue reindex of image %s", image.UUID)
+	var count int64
+	err := r.container.Postgres.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM images WHERE embedding IS NULL`).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting images without embedding: %w", err)
 	}
 
-	return nil
+	return count, nil
 }
 
-// syncTagAssociations synchronises tag associations for an image
-func (r *ImageRepository) syncTagAssociations(ctx context.Context, tx pgx.Tx, image *models.Image, existingImage *models.Image) error {
-	// Create maps to track existing and new tags
-	existingTags := make(map[string]*models.ImageTag)
-	if existingImage != nil {
-		for _, tag := range existingImage.Tags {
-			existingTags[tag.UUID] = tag
-		}
-	}
+func (r *ImageRepository) getByIDTx(ctx context.Context, tx pgx.Tx, id int64) (*models.Image, error) {
+	query := `
+		SELECT id, uuid, filename, md5, sha1, width, height, format, size,
+			   embedding, title, description, rating, camera_make, camera_model, phash, language, has_thumbnail, view_count, created_at, updated_at
+		FROM images
+		WHERE id = $1
+	`
 
-	// Map to track tags we need to retain
-	tagsToKeep := make(map[string]bool)
+	var image models.Image
+	var titlePtr, descriptionPtr *string
 
-	// Process each tag in the input model
-	updatedTags := make([]*models.ImageTag, 0, len(image.Tags))
+	err := tx.QueryRow(ctx, query, id).Scan(
+		&image.ID, &image.UUID, &image.Filename, &image.MD5, &image.SHA1,
+		&image.Width, &image.Height, &image.Format, &image.Size, &image.Embedding,
+		&titlePtr, &descriptionPtr, &image.Rating, &image.CameraMake, &image.CameraModel, &image.PHash, &image.Language, &image.HasThumbnail, &image.ViewCount, &image.CreatedAt, &image.UpdatedAt,
+	)
 
-	for _, tag := range image.Tags {
-		if tag == nil {
-			continue
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, utils.ErrImageNotFound
 		}
+		return nil, fmt.Errorf("error fetching image: %w", err)
+	}
 
-		// Determine if we need to look up by UUID or name
-		var findQuery string
-		var findParam any
+	image.Title = titlePtr
+	image.Description = descriptionPtr
 
-		if tag.UUID != "" {
-			findQuery = `SELECT id, uuid, name FROM tags WHERE uuid = $1`
-			findParam = tag.UUID
-		} else if tag.ID > 0 {
-			findQuery = `SELECT id, uuid, name FROM tags WHERE id = $1`
-			findParam = tag.ID
-		} else if tag.Name != "" {
-			findQuery = `SELECT id, uuid, name FROM tags WHERE LOWER(name) = LOWER($1)`
-			findParam = tag.Name
-		} else {
-			// If not ID nor UUID nor name are provided, skip this tag
-			continue
-		}
+	// Fetch all associations
+	err = r.fetchImageAssociations(ctx, tx, &image)
+	if err != nil {
+		return nil, err
+	}
 
-		// Try to find the existing tag
-		var tagID int64
-		var tagUUID, tagName string
-		err := tx.QueryRow(ctx, findQuery, findParam).Scan(&tagID, &tagUUID, &tagName)
+	return &image, nil
+}
 
-		if err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				// Tag doesn't exist - return an error
-				return fmt.Errorf("tag with identifier %v does not exist", findParam)
-			}
-			return fmt.Errorf("error finding tag: %w", err)
-		}
+func (r *ImageRepository) GetByID(ctx context.Context, id int64) (*models.Image, error) {
+	var image *models.Image
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		image, err = r.getByIDTx(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		// Create an updated tag object with complete information
-		updatedTag := &models.ImageTag{
-			ID:   tagID,
-			UUID: tagUUID,
-			Name: tagName,
-		}
+	return image, nil
+}
 
-		// Mark this tag as one to keep
-		tagsToKeep[tagUUID] = true
+// GetByPhashDistance finds the image whose perceptual hash is nearest to phash, differing
+// in at most maxDistance bits, and returns it along with that distance. ErrImageNotFound
+// is returned when no image is close enough. No index serves the distance, so every
+// image with a perceptual hash is scanned.
+func (r *ImageRepository) GetByPhashDistance(ctx context.Context, phash int64, maxDistance int) (*models.Image, int, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.GetByPhashDistance")
+	defer span.End()
 
-		// Check if this tag is already associated
-		if existingTag, exists := existingTags[tagUUID]; exists {
-			// Tag already exists - keep the original added_at time
-			updatedTag.AddedAt = existingTag.AddedAt
-		} else {
-			// New association - create it
-			query := `
-				INSERT INTO image_tags (image_id, tag_id)
-				VALUES ($1, $2)
-				RETURNING created_at
-			`
+	query := `
+		SELECT id, bit_count((phash # $1)::bit(64)) AS distance
+		FROM images
+		WHERE phash IS NOT NULL AND bit_count((phash # $1)::bit(64)) <= $2
+		ORDER BY distance, id
+		LIMIT 1
+	`
 
-			err = tx.QueryRow(ctx, query, image.ID, tagID).Scan(&updatedTag.AddedAt)
-			if err != nil {
-				return fmt.Errorf("error associating tag: %w", err)
-			}
+	var id int64
+	var distance int
+	if err := r.container.Postgres.Pool.QueryRow(ctx, query, phash, maxDistance).Scan(&id, &distance); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, 0, utils.ErrImageNotFound
 		}
+		return nil, 0, fmt.Errorf("error finding images by perceptual hash: %w", err)
+	}
 
-		updatedTags = append(updatedTags, updatedTag)
+	image, err := r.GetByID(ctx, id)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	// Remove associations for tags no longer present
-	if existingImage != nil {
-		for _, tag := range existingImage.Tags {
-			if !tagsToKeep[tag.UUID] {
-				// Tag is no longer associated - remove it
-				query := `DELETE FROM image_tags WHERE image_id = $1 AND tag_id = $2`
-				_, err := tx.Exec(ctx, query, image.ID, tag.ID)
-				if err != nil {
-					return fmt.Errorf("error removing tag association: %w", err)
-				}
-			}
+	return image, distance, nil
+}
+
+func (r *ImageRepository) getByUUIDTx(ctx context.Context, tx pgx.Tx, uuid string) (*models.Image, error) {
+	query := `
+		SELECT id, uuid, filename, md5, sha1, width, height, format, size,
+			   embedding, title, description, rating, camera_make, camera_model, phash, language, has_thumbnail, view_count, created_at, updated_at
+		FROM images
+		WHERE uuid = $1
+	`
+
+	var image models.Image
+	var titlePtr, descriptionPtr *string
+
+	err := tx.QueryRow(ctx, query, uuid).Scan(
+		&image.ID, &image.UUID, &image.Filename, &image.MD5, &image.SHA1,
+		&image.Width, &image.Height, &image.Format, &image.Size, &image.Embedding,
+		&titlePtr, &descriptionPtr, &image.Rating, &image.CameraMake, &image.CameraModel, &image.PHash, &image.Language, &image.HasThumbnail, &image.ViewCount, &image.CreatedAt, &image.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, utils.ErrImageNotFound
 		}
+		return nil, fmt.Errorf("error fetching image: %w", err)
 	}
 
-	// Update the image's tags collection
-	image.Tags = updatedTags
+	image.Title = titlePtr
+	image.Description = descriptionPtr
 
-	return nil
+	// Fetch all associations
+	err = r.fetchImageAssociations(ctx, tx, &image)
+	if err != nil {
+		return nil, err
+	}
+
+	return &image, nil
 }
 
-// syncPeopleAssociations synchronises people associations for an image
-func (r *ImageRepository) syncPeopleAssociations(ctx context.Context, tx pgx.Tx, image *models.Image, existingImage *models.Image) error {
-	// Create maps to track existing people
-	existingPeople := make(map[string]*models.ImagePerson)
-	if existingImage != nil && existingImage.People != nil {
-		for _, person := range existingImage.People {
-			// Use combination of UUID and role as key
-			key := fmt.Sprintf("%s:%s", person.UUID, person.Role)
-			existingPeople[key] = person
-		}
+func (r *ImageRepository) GetByUUID(ctx context.Context, uuid string) (*models.Image, error) {
+	var image *models.Image
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		image, err = r.getByUUIDTx(ctx, tx, uuid)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Map to track people we need to retain
-	peopleToKeep := make(map[string]bool)
+	return image, nil
+}
 
-	// Process each person in the input model
-	updatedPeople := make([]*models.ImagePerson, 0, len(image.People))
+// GetManyByUUIDs fetches several images in a single query, returning them in the order
+// requested along with the UUIDs that did not match an image. Duplicate UUIDs are
+// returned once, at their first position.
+func (r *ImageRepository) GetManyByUUIDs(ctx context.Context, uuids []string) ([]*models.Image, []string, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.GetManyByUUIDs")
+	defer span.End()
 
-	for _, person := range image.People {
-		if person == nil {
-			continue
+	var images []*models.Image
+	var missing []string
+
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		found, err := r.queryImagesTx(ctx, tx, "uuid = ANY($1::uuid[])", uuids)
+		if err != nil {
+			return err
 		}
 
-		// Determine if we need to look up by UUID or ID
-		var findQuery string
-		var findParam any
+		byUUID := make(map[string]*models.Image, len(found))
+		for _, image := range found {
+			byUUID[image.UUID] = image
+		}
 
-		if person.UUID != "" {
-			findQuery = `SELECT id, uuid, name FROM people WHERE uuid = $1`
-			findParam = person.UUID
-		} else if person.ID > 0 {
-			findQuery = `SELECT id, uuid, name FROM people WHERE id = $1`
-			findParam = person.ID
-		} else {
-			// Neither UUID nor ID is provided
-			continue
+		images = make([]*models.Image, 0, len(byUUID))
+		seen := make(map[string]bool, len(uuids))
+		for _, uuid := range uuids {
+			if seen[uuid] {
+				continue
+			}
+			seen[uuid] = true
+
+			image, ok := byUUID[strings.ToLower(uuid)]
+			if !ok {
+				missing = append(missing, uuid)
+				continue
+			}
+			images = append(images, image)
 		}
 
-		// Try to find the existing person
-		var personID int64
-		var personUUID, personName string
-		err := tx.QueryRow(ctx, findQuery, findParam).Scan(&personID, &personUUID, &personName)
+		return r.fetchAssociationsForImages(ctx, tx, images)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return images, missing, nil
+}
 
+// FindBySourceURL returns the images that have a source with the given URL, ordered by
+// ID. URLs are compared ignoring case, any fragment and trailing slashes.
+func (r *ImageRepository) FindBySourceURL(ctx context.Context, url string) ([]*models.Image, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.FindBySourceURL")
+	defer span.End()
+
+	var images []*models.Image
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		// The expression matches idx_image_sources_normalized_url
+		rows, err := tx.Query(ctx, `
+			SELECT DISTINCT image_id
+			FROM image_sources
+			WHERE lower(rtrim(split_part(url, '#', 1), '/')) = lower(rtrim(split_part($1, '#', 1), '/'))
+		`, strings.TrimSpace(url))
 		if err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				// Person doesn't exist - return an error
-				return fmt.Errorf("person with identifier %v does not exist", findParam)
-			}
-			return fmt.Errorf("error finding person: %w", err)
+			return fmt.Errorf("error querying image sources: %w", err)
 		}
 
-		// Ensure we have a valid role
-		if person.Role == "" || (person.Role != models.RoleCreator && person.Role != models.RoleSubject) {
-			return fmt.Errorf("person %s must have a valid role specified", personName)
+		imageIDs, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			return fmt.Errorf("error collecting image IDs: %w", err)
 		}
 
-		// Create an updated person object with complete information
-		updatedPerson := &models.ImagePerson{
-			ID:   personID,
-			UUID: personUUID,
-			Name: personName,
-			Role: person.Role,
+		images, err = r.queryImagesTx(ctx, tx, "id = ANY($1)", imageIDs)
+		if err != nil {
+			return err
 		}
 
-		// Generate a unique key that includes the role
-		uniqueKey := fmt.Sprintf("%s:%s", personUUID, person.Role)
-		peopleToKeep[uniqueKey] = true
+		return r.fetchAssociationsForImages(ctx, tx, images)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		// Use the ON CONFLICT trick to either insert a new association or get the existing one's timestamp
-		query := `
-            INSERT INTO image_people (image_id, person_id, role)
-            VALUES ($1, $2, $3)
-            ON CONFLICT (image_id, person_id, role) DO UPDATE
-            SET role = EXCLUDED.role
-            RETURNING created_at
-        `
+	return images, nil
+}
 
-		err = tx.QueryRow(ctx, query, image.ID, personID, person.Role).Scan(&updatedPerson.AddedAt)
+// GetManyByIDs fetches several images by internal ID in a single query, ordered by ID.
+// IDs that do not match an image are skipped.
+func (r *ImageRepository) GetManyByIDs(ctx context.Context, ids []int64) ([]*models.Image, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.GetManyByIDs")
+	defer span.End()
+
+	var images []*models.Image
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		images, err = r.queryImagesTx(ctx, tx, "id = ANY($1)", ids)
 		if err != nil {
-			return fmt.Errorf("error upserting person association: %w", err)
+			return err
 		}
 
-		updatedPeople = append(updatedPeople, updatedPerson)
+		return r.fetchAssociationsForImages(ctx, tx, images)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Remove associations for people no longer present
-	if existingImage != nil && existingImage.People != nil {
-		for _, person := range existingImage.People {
-			uniqueKey := fmt.Sprintf("%s:%s", person.UUID, person.Role)
-			if !peopleToKeep[uniqueKey] {
-				// Person+role is no longer associated - remove it
-				query := `
-                    DELETE FROM image_people 
-                    WHERE image_id = $1 AND person_id = $2 AND role = $3
-                `
-				_, err := tx.Exec(ctx, query, image.ID, person.ID, person.Role)
-				if err != nil {
-					return fmt.Errorf("error removing person association: %w", err)
-				}
-			}
+	return images, nil
+}
+
+// queryImagesTx loads the image rows matching a condition on a single argument, ordered
+// by ID, without their associations
+func (r *ImageRepository) queryImagesTx(ctx context.Context, tx pgx.Tx, condition string, arg any) ([]*models.Image, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT id, uuid, filename, md5, sha1, width, height, format, size,
+			   embedding, title, description, rating, camera_make, camera_model, phash, language, has_thumbnail, view_count, created_at, updated_at
+		FROM images
+		WHERE `+condition+`
+		ORDER BY id
+	`, arg)
+	if err != nil {
+		return nil, fmt.Errorf("error querying images: %w", err)
+	}
+	defer rows.Close()
+
+	var images []*models.Image
+	for rows.Next() {
+		var image models.Image
+		if err := rows.Scan(
+			&image.ID, &image.UUID, &image.Filename, &image.MD5, &image.SHA1,
+			&image.Width, &image.Height, &image.Format, &image.Size, &image.Embedding,
+			&image.Title, &image.Description, &image.Rating, &image.CameraMake, &image.CameraModel, &image.PHash, &image.Language, &image.HasThumbnail, &image.ViewCount, &image.CreatedAt, &image.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning image row: %w", err)
 		}
+		images = append(images, &image)
 	}
 
-	// Update the image's people collection
-	image.People = updatedPeople
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating image rows: %w", err)
+	}
 
-	return nil
+	return images, nil
 }
 
-// syncSourceAssociations synchronises source associations for an image
-func (r *ImageRepository) syncSourceAssociations(ctx context.Context, tx pgx.Tx, image *models.Image, existingImage *models.Image) error {
-	// Create map to track existing sources
-	existingSourcesByURL := make(map[string]*models.ImageSource)
+// UpsertOptions control how an upsert treats the associations of an image
+type UpsertOptions struct {
+	// Lenient skips tags and people that do not exist rather than failing the upsert,
+	// recording them in the SkippedAssociations of the image
+	Lenient bool
+}
 
-	if existingImage != nil && existingImage.Sources != nil {
-		for _, source := range existingImage.Sources {
-			existingSourcesByURL[source.URL] = source
-		}
+// TODO: When we add a child tag, all parent tags (up the tree) should be automatically assigned to the image.
+func (r *ImageRepository) Upsert(ctx context.Context, image *models.Image, opts UpsertOptions) error {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.Upsert")
+	defer span.End()
+
+	if err := validateRating(image.Rating); err != nil {
+		return err
 	}
 
-	// Map to track sources we need to retain
-	sourcesToKeep := make(map[string]bool)
+	// Get the existing image to compare associations
+	var existingImage *models.Image
 
-	// Process each source in the input model
-	updatedSources := make([]*models.ImageSource, 0, len(image.Sources))
+	// Tags whose image counts change with this upsert
+	var changedTags []int64
 
-	for _, source := range image.Sources {
-		if source == nil || source.URL == "" {
-			continue
+	// Whether the vector of a new image is held by Qdrant alone, and so must be written
+	// to it once the image is committed
+	var storeVector bool
+
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+
+		// Missing references are collected rather than failing the upsert when lenient
+		var skipped *[]*models.SkippedAssociation
+		image.SkippedAssociations = nil
+		if opts.Lenient {
+			skipped = &image.SkippedAssociations
 		}
 
-		// Mark this source URL as one to keep
-		sourcesToKeep[source.URL] = true
+		// Determine if this is an insert or update
+		isUpdate := image.ID > 0 || image.UUID != ""
 
-		// Check if this source URL is already associated with this image
-		if _, exists := existingSourcesByURL[source.URL]; exists {
-			// Source already exists - update its information
+		if isUpdate {
+			if image.ID > 0 {
+				existingImage, err = r.getByIDTx(ctx, tx, image.ID)
+			} else {
+				existingImage, err = r.getByUUIDTx(ctx, tx, image.UUID)
+			}
+
+			if err != nil {
+				return fmt.Errorf("error retrieving existing image: %w", err)
+			}
+
+			if existingImage.Filename != image.Filename {
+				return &utils.ImmutableFieldError{Entity: "image", Field: "filename"}
+			}
+
+			if existingImage.MD5 != image.MD5 || existingImage.SHA1 != image.SHA1 {
+				return &utils.ImmutableFieldError{Entity: "image", Field: "hashes"}
+			}
+
+			if existingImage.Width != image.Width || existingImage.Height != image.Height {
+				return &utils.ImmutableFieldError{Entity: "image", Field: "dimensions"}
+			}
+
+			if existingImage.Format != image.Format {
+				return &utils.ImmutableFieldError{Entity: "image", Field: "format"}
+			}
+
+			if existingImage.Size != image.Size {
+				return &utils.ImmutableFieldError{Entity: "image", Field: "size"}
+			}
+
+			if embeddingChanged(existingImage.Embedding, image.Embedding) {
+				return &utils.ImmutableFieldError{Entity: "image", Field: "embedding"}
+			}
+
+			// Perform the update
 			query := `
-                UPDATE image_sources 
-                SET title = $1, description = $2
-                WHERE image_id = $3 AND url = $4
+				UPDATE images SET
+					title = $1,
+					description = $2,
+					rating = $3,
+					language = $4
+				WHERE id = $5
+				RETURNING id, uuid, camera_make, camera_model, phash, language, has_thumbnail, view_count, created_at, updated_at
 			`
 
-			_, err := tx.Exec(ctx, query, source.Title, source.Description, image.ID, source.URL)
+			err = tx.QueryRow(
+				ctx, query, image.Title, image.Description, image.Rating, image.Language, existingImage.ID,
+			).Scan(
+				&image.ID, &image.UUID, &image.CameraMake, &image.CameraModel, &image.PHash,
+				&image.Language, &image.HasThumbnail, &image.ViewCount, &image.CreatedAt, &image.UpdatedAt,
+			)
+
 			if err != nil {
-				return fmt.Errorf("error updating source: %w", err)
+				return fmt.Errorf("error updating image: %w", err)
 			}
+		} else {
+			// TODO: check for duplicate here and return a conflict error
 
-			// Create updated source with the original timestamp
-			updatedSource := &models.ImageSource{
-				URL:         source.URL,
-				Title:       source.Title,
-				Description: source.Description,
+			// Leave the embedding to Qdrant alone when Postgres does not keep a copy
+			storedEmbedding := image.Embedding
+			if !r.container.Config.StoreEmbeddingsInPostgres {
+				storedEmbedding = nil
 			}
 
-			updatedSources = append(updatedSources, updatedSource)
-		} else {
-			// New source - insert it
+			// Create new image
 			query := `
-                INSERT INTO image_sources (image_id, url, title, description)
-                VALUES ($1, $2, $3, $4)
-            `
+				INSERT INTO images (
+					filename, md5, sha1, width, height, format, size,
+					embedding, title, description, rating, camera_make, camera_model, phash, language, has_thumbnail
+				) VALUES (
+					$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16
+				) RETURNING id, uuid, created_at, updated_at
+			`
+
+			err = tx.QueryRow(ctx, query,
+				image.Filename, image.MD5, image.SHA1,
+				image.Width, image.Height, image.Format, image.Size,
+				storedEmbedding, image.Title, image.Description, image.Rating,
+				image.CameraMake, image.CameraModel, image.PHash, image.Language, image.HasThumbnail,
+			).Scan(&image.ID, &image.UUID, &image.CreatedAt, &image.UpdatedAt)
 
-			_, err := tx.Exec(ctx, query, image.ID, source.URL, source.Title, source.Description)
 			if err != nil {
-				return fmt.Errorf("error creating source: %w", err)
+				return fmt.Errorf("error inserting image: %w", err)
 			}
 
-			// Create updated source with the new timestamp
-			updatedSource := &models.ImageSource{
-				URL:         source.URL,
-				Title:       source.Title,
-				Description: source.Description,
+			storeVector = storedEmbedding == nil && image.Embedding != nil
+		}
+
+		// Synchronise tag associations
+		changedTags, err = r.syncTagAssociations(ctx, tx, image, existingImage, skipped)
+		if err != nil {
+			return fmt.Errorf("error handling tag associations: %w", err)
+		}
+
+		// Synchronise people associations
+		if err := r.syncPeopleAssociations(ctx, tx, image, existingImage, skipped); err != nil {
+			return fmt.Errorf("error handling people associations: %w", err)
+		}
+
+		// Synchronise source associations
+		if err := r.syncSourceAssociations(ctx, tx, image, existingImage); err != nil {
+			return fmt.Errorf("error handling source associations: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Reindexing reads the image back from Postgres, so a vector it does not hold must
+	// reach Qdrant here. It is written only after the commit, so that a rolled back
+	// insert leaves no point behind, and the image is removed again if the write fails,
+	// as the vector would otherwise be lost.
+	if storeVector {
+		if err := r.reindexQdrant(ctx, image); err != nil {
+			if _, deleteErr := r.container.Postgres.Pool.Exec(ctx, "DELETE FROM images WHERE id = $1", image.ID); deleteErr != nil {
+				log.Error().Err(deleteErr).Msgf("Failed to remove image %s after its vector could not be stored", image.UUID)
 			}
+			return fmt.Errorf("error storing image vector: %w", err)
+		}
+	}
 
-			updatedSources = append(updatedSources, updatedSource)
+	// Enqueue reindex after successful storage commit
+	if err := r.container.Worker.EnqueueReindexImage(ctx, image.ID); err != nil {
+		log.Error().Err(err).Msgf("Failed to queue reindex of image %s", image.UUID)
+	}
+	r.enqueueTagCountReindex(ctx, changedTags)
+
+	// Fetch metadata for newly added sources that were left untitled or undescribed
+	if r.container.Config.SourceEnrichmentEnabled {
+		existingURLs := make(map[string]bool)
+		if existingImage != nil {
+			for _, source := range existingImage.Sources {
+				existingURLs[source.URL] = true
+			}
+		}
+
+		for _, source := range image.Sources {
+			if existingURLs[source.URL] || (source.Title != nil && source.Description != nil) {
+				continue
+			}
+			if err := r.container.Worker.EnqueueEnrichSource(ctx, image.ID, source.URL); err != nil {
+				log.Error().Err(err).Msgf("Failed to queue enrichment of source %s for image %s", source.URL, image.UUID)
+			}
 		}
 	}
 
-	// Remove sources no longer present
-	if existingImage != nil && existingImage.Sources != nil {
-		for _, source := range existingImage.Sources {
-			if !sourcesToKeep[source.URL] {
-				// Source is no longer associated - remove it
-				query := `DELETE FROM image_sources WHERE image_id = $1 AND url = $2`
-				_, err := tx.Exec(ctx, query, image.ID, source.URL)
-				if err != nil {
-					return fmt.Errorf("error removing source: %w", err)
+	return nil
+}
+
+// embeddingChanged reports whether an update would replace the stored embedding of an
+// image. Embeddings are compared by value, as every loaded image holds its own copy.
+// Embeddings held only in Qdrant are never loaded, so cannot be compared, and an update
+// without an embedding leaves the stored one untouched.
+func embeddingChanged(existing *pgvector.Vector, updated *pgvector.Vector) bool {
+	if existing == nil || updated == nil {
+		return false
+	}
+	return !slices.Equal(existing.Slice(), updated.Slice())
+}
+
+// GetEffectiveTags returns the tags assigned directly to an image, and separately those
+// it inherits as ancestors of its direct tags, each ordered by name. An inherited tag
+// takes the earliest time one of its descendants was added to the image.
+func (r *ImageRepository) GetEffectiveTags(ctx context.Context, uuid string) ([]*models.ImageTag, []*models.ImageTag, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.GetEffectiveTags")
+	defer span.End()
+
+	var imageID int64
+	err := r.container.Postgres.Pool.QueryRow(ctx, "SELECT id FROM images WHERE uuid = $1", uuid).Scan(&imageID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, utils.ErrImageNotFound
+		}
+		return nil, nil, fmt.Errorf("error fetching image: %w", err)
+	}
+
+	query := `
+		SELECT t.id, t.uuid, t.name, MIN(it.created_at) AS added_at, bool_or(tc.depth = 0) AS direct
+		FROM image_tags it
+		JOIN tag_closure tc ON tc.descendant = it.tag_id
+		JOIN tags t ON t.id = tc.ancestor
+		WHERE it.image_id = $1
+		GROUP BY t.id, t.uuid, t.name
+		ORDER BY t.name
+	`
+
+	rows, err := r.container.Postgres.Pool.Query(ctx, query, imageID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error fetching image tags: %w", err)
+	}
+	defer rows.Close()
+
+	direct := []*models.ImageTag{}
+	inherited := []*models.ImageTag{}
+	for rows.Next() {
+		var tag models.ImageTag
+		var isDirect bool
+		if err := rows.Scan(&tag.ID, &tag.UUID, &tag.Name, &tag.AddedAt, &isDirect); err != nil {
+			return nil, nil, fmt.Errorf("error scanning image tag: %w", err)
+		}
+
+		if isDirect {
+			direct = append(direct, &tag)
+		} else {
+			inherited = append(inherited, &tag)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error fetching image tags: %w", err)
+	}
+
+	return direct, inherited, nil
+}
+
+// SetRating sets or, when rating is nil, clears the rating of an image
+func (r *ImageRepository) SetRating(ctx context.Context, uuid string, rating *int) (*models.Image, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.SetRating")
+	defer span.End()
+
+	if err := validateRating(rating); err != nil {
+		return nil, err
+	}
+
+	var imageID int64
+	err := r.container.Postgres.Pool.QueryRow(ctx,
+		"UPDATE images SET rating = $1 WHERE uuid = $2 RETURNING id",
+		rating, uuid,
+	).Scan(&imageID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, utils.ErrImageNotFound
+		}
+		return nil, fmt.Errorf("error updating image rating: %w", err)
+	}
+
+	if err := r.container.Worker.EnqueueReindexImage(ctx, imageID); err != nil {
+		log.Error().Err(err).Msgf("Failed to queue reindex of image %s", uuid)
+	}
+
+	return r.GetByID(ctx, imageID)
+}
+
+// AddViewCounts adds buffered view counts, keyed by image UUID, to the stored counts and
+// queues the affected images for reindexing. Unknown UUIDs are ignored.
+func (r *ImageRepository) AddViewCounts(ctx context.Context, counts map[string]int64) error {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.AddViewCounts")
+	defer span.End()
+
+	if len(counts) == 0 {
+		return nil
+	}
+
+	uuids := make([]string, 0, len(counts))
+	increments := make([]int64, 0, len(counts))
+	for uuid, count := range counts {
+		uuids = append(uuids, uuid)
+		increments = append(increments, count)
+	}
+
+	var affectedImages []int64
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `
+			UPDATE images i
+			SET view_count = i.view_count + v.increment
+			FROM unnest($1::uuid[], $2::bigint[]) AS v(uuid, increment)
+			WHERE i.uuid = v.uuid
+			RETURNING i.id
+		`, uuids, increments)
+		if err != nil {
+			return fmt.Errorf("error updating view counts: %w", err)
+		}
+
+		affectedImages, err = pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			return fmt.Errorf("error collecting affected images: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, imageID := range affectedImages {
+		if err := r.container.Worker.EnqueueReindexImage(ctx, imageID); err != nil {
+			log.Error().Err(err).Int64("id", imageID).Msg("Failed to queue reindex of image after view count update")
+		}
+	}
+
+	return nil
+}
+
+// validateRating ensures an image rating, if set, lies within the supported range
+func validateRating(rating *int) error {
+	if rating != nil && (*rating < models.MinImageRating || *rating > models.MaxImageRating) {
+		return fmt.Errorf("%w: rating must be between %d and %d", utils.ErrInvalidInput, models.MinImageRating, models.MaxImageRating)
+	}
+	return nil
+}
+
+// EnrichSource fills in the title and description of an image source, leaving any
+// value the client already provided untouched. It reports whether the source changed.
+func (r *ImageRepository) EnrichSource(ctx context.Context, imageID int64, url string, title string, description string) (bool, error) {
+	query := `
+		UPDATE image_sources
+		SET
+			title = COALESCE(NULLIF(title, ''), NULLIF($3, '')),
+			description = COALESCE(NULLIF(description, ''), NULLIF($4, ''))
+		WHERE image_id = $1 AND url = $2
+			AND (
+				(NULLIF(title, '') IS NULL AND NULLIF($3, '') IS NOT NULL)
+				OR (NULLIF(description, '') IS NULL AND NULLIF($4, '') IS NOT NULL)
+			)
+	`
+
+	tag, err := r.container.Postgres.Pool.Exec(ctx, query, imageID, url, title, description)
+	if err != nil {
+		return false, fmt.Errorf("error enriching source: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// ReplaceTag swaps one tag for another on the given images, in a single transaction.
+// Images that do not carry the tag being replaced are left untouched. It returns the
+// number of images changed.
+func (r *ImageRepository) ReplaceTag(ctx context.Context, imageUUIDs []string, fromTagUUID string, toTagUUID string) (int, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.ReplaceTag")
+	defer span.End()
+
+	if fromTagUUID == toTagUUID {
+		return 0, fmt.Errorf("%w: a tag cannot be replaced with itself", utils.ErrInvalidInput)
+	}
+
+	var affectedImages []int64
+	var fromTagID, toTagID int64
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		// Resolve both tags
+		for _, lookup := range []struct {
+			uuid string
+			id   *int64
+		}{
+			{fromTagUUID, &fromTagID},
+			{toTagUUID, &toTagID},
+		} {
+			err := tx.QueryRow(ctx, `SELECT id FROM tags WHERE uuid = $1`, lookup.uuid).Scan(lookup.id)
+			if err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return fmt.Errorf("%w: %s", utils.ErrTagNotFound, lookup.uuid)
 				}
+				return fmt.Errorf("error finding tag: %w", err)
 			}
 		}
-	}
 
-	// Update the image's sources collection
-	image.Sources = updatedSources
+		// Resolve the images, rejecting the whole operation if any is unknown
+		rows, err := tx.Query(ctx, `SELECT id, uuid FROM images WHERE uuid = ANY($1::uuid[])`, imageUUIDs)
+		if err != nil {
+			return fmt.Errorf("error querying images: %w", err)
+		}
+
+		found := make(map[string]bool, len(imageUUIDs))
+		imageIDs := make([]int64, 0, len(imageUUIDs))
+		for rows.Next() {
+			var id int64
+			var uuid string
+			if err := rows.Scan(&id, &uuid); err != nil {
+				rows.Close()
+				return fmt.Errorf("error scanning image row: %w", err)
+			}
+			found[uuid] = true
+			imageIDs = append(imageIDs, id)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating image rows: %w", err)
+		}
+
+		for _, uuid := range imageUUIDs {
+			if !found[uuid] {
+				return fmt.Errorf("%w: %s", utils.ErrImageNotFound, uuid)
+			}
+		}
+
+		// Remove the old tag, collecting the images that carried it
+		rows, err = tx.Query(ctx, `
+			DELETE FROM image_tags
+			WHERE tag_id = $1 AND image_id = ANY($2)
+			RETURNING image_id
+		`, fromTagID, imageIDs)
+		if err != nil {
+			return fmt.Errorf("error removing tag associations: %w", err)
+		}
+
+		affectedImages, err = pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			return fmt.Errorf("error collecting affected images: %w", err)
+		}
+
+		// Add the new tag to those images, keeping any existing association
+		_, err = tx.Exec(ctx, `
+			INSERT INTO image_tags (image_id, tag_id)
+			SELECT image_id, $2 FROM unnest($1::int[]) AS image_id
+			ON CONFLICT (image_id, tag_id) DO NOTHING
+		`, affectedImages, toTagID)
+		if err != nil {
+			return fmt.Errorf("error adding tag associations: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, imageID := range affectedImages {
+		if err := r.container.Worker.EnqueueReindexImage(ctx, imageID); err != nil {
+			log.Error().Err(err).Int64("id", imageID).Msg("Failed to queue reindex of image after tag replacement")
+		}
+	}
+
+	if len(affectedImages) > 0 {
+		r.enqueueTagCountReindex(ctx, []int64{fromTagID, toTagID})
+	}
+
+	return len(affectedImages), nil
+}
+
+// MetadataCopyOptions selects the metadata copied between images beyond tags and
+// people, which are always copied
+type MetadataCopyOptions struct {
+	Sources     bool // Add the sources of the source image
+	Title       bool // Replace the title with that of the source image, when it has one
+	Description bool // Replace the description with that of the source image, when it has one
+}
+
+// CopyMetadata adds the tags and people of one image to another, and optionally its
+// sources, title and description, in a single transaction. Associations the target
+// already has are kept. It returns the updated target image.
+func (r *ImageRepository) CopyMetadata(ctx context.Context, targetUUID string, sourceUUID string, opts MetadataCopyOptions) (*models.Image, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.CopyMetadata")
+	defer span.End()
+
+	if targetUUID == sourceUUID {
+		return nil, fmt.Errorf("%w: an image cannot copy metadata from itself", utils.ErrInvalidInput)
+	}
+
+	var targetID int64
+	var changedTags []int64
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		target, err := r.getByUUIDTx(ctx, tx, targetUUID)
+		if err != nil {
+			return err
+		}
+		targetID = target.ID
+
+		source, err := r.getByUUIDTx(ctx, tx, sourceUUID)
+		if err != nil {
+			return err
+		}
+
+		updated := *target
+
+		// Copy only the tags assigned to the source directly. Its tag list also holds the
+		// ancestors it inherits, which must stay inherited on the target as well.
+		rows, err := tx.Query(ctx, `
+			INSERT INTO image_tags (image_id, tag_id)
+			SELECT $1, tag_id FROM image_tags WHERE image_id = $2
+			ON CONFLICT DO NOTHING
+			RETURNING tag_id
+		`, target.ID, source.ID)
+		if err != nil {
+			return fmt.Errorf("error copying tag associations: %w", err)
+		}
+		changedTags, err = pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			return fmt.Errorf("error copying tag associations: %w", err)
+		}
+
+		// Merge people, matching on both the person and their role
+		updated.People = append([]*models.ImagePerson{}, target.People...)
+		hasPerson := make(map[string]bool, len(target.People))
+		for _, person := range target.People {
+			hasPerson[fmt.Sprintf("%s:%s", person.UUID, person.Role)] = true
+		}
+		for _, person := range source.People {
+			if !hasPerson[fmt.Sprintf("%s:%s", person.UUID, person.Role)] {
+				updated.People = append(updated.People, &models.ImagePerson{UUID: person.UUID, Role: person.Role})
+			}
+		}
+
+		// Merge sources, leaving the target's primary source as it is
+		if opts.Sources {
+			updated.Sources = append([]*models.ImageSource{}, target.Sources...)
+			hasSource := make(map[string]bool, len(target.Sources))
+			for _, imageSource := range target.Sources {
+				hasSource[imageSource.URL] = true
+			}
+			for _, imageSource := range source.Sources {
+				if !hasSource[imageSource.URL] {
+					updated.Sources = append(updated.Sources, &models.ImageSource{
+						URL:         imageSource.URL,
+						Title:       imageSource.Title,
+						Description: imageSource.Description,
+					})
+				}
+			}
+		}
+
+		if opts.Title && source.Title != nil {
+			updated.Title = source.Title
+		}
+		if opts.Description && source.Description != nil {
+			updated.Description = source.Description
+		}
+
+		if _, err := tx.Exec(ctx,
+			"UPDATE images SET title = $1, description = $2 WHERE id = $3",
+			updated.Title, updated.Description, target.ID,
+		); err != nil {
+			return fmt.Errorf("error updating image: %w", err)
+		}
+
+		if err := r.syncPeopleAssociations(ctx, tx, &updated, target, nil); err != nil {
+			return fmt.Errorf("error handling people associations: %w", err)
+		}
+
+		if opts.Sources {
+			if err := r.syncSourceAssociations(ctx, tx, &updated, target); err != nil {
+				return fmt.Errorf("error handling source associations: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.container.Worker.EnqueueReindexImage(ctx, targetID); err != nil {
+		log.Error().Err(err).Msgf("Failed to queue reindex of image %s", targetUUID)
+	}
+	r.enqueueTagCountReindex(ctx, changedTags)
+
+	return r.GetByID(ctx, targetID)
+}
+
+// ImageTagAssignment adds tags to the image identified by its UUID, MD5 or SHA1 hash
+type ImageTagAssignment struct {
+	ImageRef string
+	TagIDs   []int64
+}
+
+// ImageTagAssignmentResult describes the outcome of a single tag assignment
+type ImageTagAssignmentResult struct {
+	ImageUUID string // UUID of the resolved image, empty when it was not found
+	Added     int    // Number of tags newly added to the image
+	Err       error  // Reason the assignment failed, if it did
+}
+
+// AssignTags adds tags to several images in a single transaction, keeping any existing
+// associations. Images that cannot be found fail their own assignment rather than the
+// whole batch. Results are returned in the order of the assignments.
+func (r *ImageRepository) AssignTags(ctx context.Context, assignments []ImageTagAssignment) ([]ImageTagAssignmentResult, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.AssignTags")
+	defer span.End()
+
+	results := make([]ImageTagAssignmentResult, len(assignments))
+	var affectedImages []int64
+	var changedTags []int64
+
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		for i, assignment := range assignments {
+			// UUIDs are recognised by their length, anything else is treated as a hash. A
+			// reference of that length that is not a UUID would fail the cast and with it
+			// the batch, so it fails only its own assignment.
+			query := `SELECT id, uuid FROM images WHERE md5 = $1 OR sha1 = $1 LIMIT 1`
+			if len(assignment.ImageRef) == 36 {
+				if _, err := uuid.Parse(assignment.ImageRef); err != nil {
+					results[i].Err = fmt.Errorf("%w: %s is not a UUID, MD5 or SHA1 hash", utils.ErrInvalidInput, assignment.ImageRef)
+					continue
+				}
+				query = `SELECT id, uuid FROM images WHERE uuid = $1::uuid`
+			}
+
+			var imageID int64
+			err := tx.QueryRow(ctx, query, strings.ToLower(assignment.ImageRef)).Scan(&imageID, &results[i].ImageUUID)
+			if err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					results[i].Err = fmt.Errorf("%w: %s", utils.ErrImageNotFound, assignment.ImageRef)
+					continue
+				}
+				return fmt.Errorf("error finding image: %w", err)
+			}
+
+			tag, err := tx.Exec(ctx, `
+				INSERT INTO image_tags (image_id, tag_id)
+				SELECT $1, tag_id FROM unnest($2::int[]) AS tag_id
+				ON CONFLICT (image_id, tag_id) DO NOTHING
+			`, imageID, assignment.TagIDs)
+			if err != nil {
+				return fmt.Errorf("error adding tag associations: %w", err)
+			}
+
+			results[i].Added = int(tag.RowsAffected())
+			if results[i].Added > 0 {
+				affectedImages = append(affectedImages, imageID)
+				changedTags = append(changedTags, assignment.TagIDs...)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, imageID := range affectedImages {
+		if err := r.container.Worker.EnqueueReindexImage(ctx, imageID); err != nil {
+			log.Error().Err(err).Int64("id", imageID).Msg("Failed to queue reindex of image after tag assignment")
+		}
+	}
+
+	r.enqueueTagCountReindex(ctx, changedTags)
+
+	return results, nil
+}
+
+// syncTagAssociations synchronises tag associations for an image and returns the IDs of
+// tags that were added or removed. Tags that do not exist fail the sync, unless skipped
+// is given, in which case they are appended to it instead.
+func (r *ImageRepository) syncTagAssociations(ctx context.Context, tx pgx.Tx, image *models.Image, existingImage *models.Image, skipped *[]*models.SkippedAssociation) ([]int64, error) {
+	// Create maps to track existing and new tags
+	existingTags := make(map[string]*models.ImageTag)
+	if existingImage != nil {
+		for _, tag := range existingImage.Tags {
+			existingTags[tag.UUID] = tag
+		}
+	}
+
+	// Map to track tags we need to retain
+	tagsToKeep := make(map[string]bool)
+
+	// Tags that gained or lost this image
+	var changed []int64
+
+	// Process each tag in the input model
+	updatedTags := make([]*models.ImageTag, 0, len(image.Tags))
+
+	for _, tag := range image.Tags {
+		if tag == nil {
+			continue
+		}
+
+		// Determine if we need to look up by UUID or name
+		var findQuery string
+		var findParam any
+
+		if tag.UUID != "" {
+			findQuery = `SELECT id, uuid, name FROM tags WHERE uuid = $1`
+			findParam = tag.UUID
+		} else if tag.ID > 0 {
+			findQuery = `SELECT id, uuid, name FROM tags WHERE id = $1`
+			findParam = tag.ID
+		} else if tag.Name != "" {
+			findQuery = `SELECT id, uuid, name FROM tags WHERE ` + nameCondition(r.container, "name")
+			findParam = normalizeName(r.container, tag.Name)
+		} else {
+			// If not ID nor UUID nor name are provided, skip this tag
+			continue
+		}
+
+		// Try to find the existing tag
+		var tagID int64
+		var tagUUID, tagName string
+		err := tx.QueryRow(ctx, findQuery, findParam).Scan(&tagID, &tagUUID, &tagName)
+
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				if skipped != nil {
+					*skipped = append(*skipped, &models.SkippedAssociation{Type: "tag", Identifier: fmt.Sprint(findParam)})
+					continue
+				}
+				// Tag doesn't exist - return an error
+				return nil, fmt.Errorf("tag with identifier %v does not exist", findParam)
+			}
+			return nil, fmt.Errorf("error finding tag: %w", err)
+		}
+
+		// Create an updated tag object with complete information
+		updatedTag := &models.ImageTag{
+			ID:   tagID,
+			UUID: tagUUID,
+			Name: tagName,
+		}
+
+		// Mark this tag as one to keep
+		tagsToKeep[tagUUID] = true
+
+		// Check if this tag is already associated
+		if existingTag, exists := existingTags[tagUUID]; exists {
+			// Tag already exists - keep the original added_at time
+			updatedTag.AddedAt = existingTag.AddedAt
+		} else {
+			// New association - create it
+			query := `
+				INSERT INTO image_tags (image_id, tag_id)
+				VALUES ($1, $2)
+				RETURNING created_at
+			`
+
+			err = tx.QueryRow(ctx, query, image.ID, tagID).Scan(&updatedTag.AddedAt)
+			if err != nil {
+				return nil, fmt.Errorf("error associating tag: %w", err)
+			}
+			changed = append(changed, tagID)
+		}
+
+		updatedTags = append(updatedTags, updatedTag)
+	}
+
+	// Remove associations for tags no longer present
+	if existingImage != nil {
+		for _, tag := range existingImage.Tags {
+			if !tagsToKeep[tag.UUID] {
+				// Tag is no longer associated - remove it
+				query := `DELETE FROM image_tags WHERE image_id = $1 AND tag_id = $2`
+				_, err := tx.Exec(ctx, query, image.ID, tag.ID)
+				if err != nil {
+					return nil, fmt.Errorf("error removing tag association: %w", err)
+				}
+				changed = append(changed, tag.ID)
+			}
+		}
+	}
+
+	// Update the image's tags collection
+	image.Tags = updatedTags
+
+	return changed, nil
+}
+
+// syncPeopleAssociations synchronises people associations for an image. People that do
+// not exist fail the sync, unless skipped is given, in which case they are appended to it
+// instead.
+func (r *ImageRepository) syncPeopleAssociations(ctx context.Context, tx pgx.Tx, image *models.Image, existingImage *models.Image, skipped *[]*models.SkippedAssociation) error {
+	// Create maps to track existing people
+	existingPeople := make(map[string]*models.ImagePerson)
+	if existingImage != nil && existingImage.People != nil {
+		for _, person := range existingImage.People {
+			// Use combination of UUID and role as key
+			key := fmt.Sprintf("%s:%s", person.UUID, person.Role)
+			existingPeople[key] = person
+		}
+	}
+
+	// Map to track people we need to retain
+	peopleToKeep := make(map[string]bool)
+
+	// Process each person in the input model
+	updatedPeople := make([]*models.ImagePerson, 0, len(image.People))
+
+	for _, person := range image.People {
+		if person == nil {
+			continue
+		}
+
+		// Determine if we need to look up by UUID or ID
+		var findQuery string
+		var findParam any
+
+		if person.UUID != "" {
+			findQuery = `SELECT id, uuid, name FROM people WHERE uuid = $1`
+			findParam = person.UUID
+		} else if person.ID > 0 {
+			findQuery = `SELECT id, uuid, name FROM people WHERE id = $1`
+			findParam = person.ID
+		} else {
+			// Neither UUID nor ID is provided
+			continue
+		}
+
+		// Try to find the existing person
+		var personID int64
+		var personUUID, personName string
+		err := tx.QueryRow(ctx, findQuery, findParam).Scan(&personID, &personUUID, &personName)
+
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				if skipped != nil {
+					*skipped = append(*skipped, &models.SkippedAssociation{Type: "person", Identifier: fmt.Sprint(findParam)})
+					continue
+				}
+				// Person doesn't exist - return an error
+				return fmt.Errorf("person with identifier %v does not exist", findParam)
+			}
+			return fmt.Errorf("error finding person: %w", err)
+		}
+
+		// Ensure we have a valid role
+		if person.Role == "" || (person.Role != models.RoleCreator && person.Role != models.RoleSubject) {
+			return fmt.Errorf("person %s must have a valid role specified", personName)
+		}
+
+		// Create an updated person object with complete information
+		updatedPerson := &models.ImagePerson{
+			ID:   personID,
+			UUID: personUUID,
+			Name: personName,
+			Role: person.Role,
+		}
+
+		// Generate a unique key that includes the role
+		uniqueKey := fmt.Sprintf("%s:%s", personUUID, person.Role)
+		peopleToKeep[uniqueKey] = true
+
+		// Use the ON CONFLICT trick to either insert a new association or get the existing one's timestamp
+		query := `
+            INSERT INTO image_people (image_id, person_id, role)
+            VALUES ($1, $2, $3)
+            ON CONFLICT (image_id, person_id, role) DO UPDATE
+            SET role = EXCLUDED.role
+            RETURNING created_at
+        `
+
+		err = tx.QueryRow(ctx, query, image.ID, personID, person.Role).Scan(&updatedPerson.AddedAt)
+		if err != nil {
+			return fmt.Errorf("error upserting person association: %w", err)
+		}
+
+		updatedPeople = append(updatedPeople, updatedPerson)
+	}
+
+	// Remove associations for people no longer present
+	if existingImage != nil && existingImage.People != nil {
+		for _, person := range existingImage.People {
+			uniqueKey := fmt.Sprintf("%s:%s", person.UUID, person.Role)
+			if !peopleToKeep[uniqueKey] {
+				// Person+role is no longer associated - remove it
+				query := `
+                    DELETE FROM image_people 
+                    WHERE image_id = $1 AND person_id = $2 AND role = $3
+                `
+				_, err := tx.Exec(ctx, query, image.ID, person.ID, person.Role)
+				if err != nil {
+					return fmt.Errorf("error removing person association: %w", err)
+				}
+			}
+		}
+	}
+
+	// Update the image's people collection
+	image.People = updatedPeople
+
+	return nil
+}
+
+// syncSourceAssociations synchronises source associations for an image
+func (r *ImageRepository) syncSourceAssociations(ctx context.Context, tx pgx.Tx, image *models.Image, existingImage *models.Image) error {
+	// Create map to track existing sources
+	existingSourcesByURL := make(map[string]*models.ImageSource)
+
+	if existingImage != nil && existingImage.Sources != nil {
+		for _, source := range existingImage.Sources {
+			existingSourcesByURL[source.URL] = source
+		}
+	}
+
+	// Determine which source, if any, is flagged as primary
+	var primaryURL *string
+	for _, source := range image.Sources {
+		if source == nil || source.URL == "" || !source.IsPrimary {
+			continue
+		}
+
+		if primaryURL != nil && *primaryURL != source.URL {
+			return fmt.Errorf("%w: only one source may be marked as primary", utils.ErrInvalidInput)
+		}
+
+		primaryURL = &source.URL
+	}
+
+	// Demote any existing primary source that is no longer primary, so that the
+	// partial unique index is not violated when the new primary is written
+	if existingImage != nil {
+		query := `
+			UPDATE image_sources
+			SET is_primary = FALSE
+			WHERE image_id = $1 AND is_primary AND url IS DISTINCT FROM $2
+		`
+
+		if _, err := tx.Exec(ctx, query, image.ID, primaryURL); err != nil {
+			return fmt.Errorf("error demoting primary source: %w", err)
+		}
+	}
+
+	// Map to track sources we need to retain
+	sourcesToKeep := make(map[string]bool)
+
+	// Process each source in the input model
+	updatedSources := make([]*models.ImageSource, 0, len(image.Sources))
+
+	for _, source := range image.Sources {
+		if source == nil || source.URL == "" {
+			continue
+		}
+
+		// Mark this source URL as one to keep
+		sourcesToKeep[source.URL] = true
+
+		// Check if this source URL is already associated with this image
+		if _, exists := existingSourcesByURL[source.URL]; exists {
+			// Source already exists - update its information
+			query := `
+                UPDATE image_sources 
+                SET title = $1, description = $2, is_primary = $3
+                WHERE image_id = $4 AND url = $5
+			`
+
+			_, err := tx.Exec(ctx, query, source.Title, source.Description, source.IsPrimary, image.ID, source.URL)
+			if err != nil {
+				return fmt.Errorf("error updating source: %w", err)
+			}
+
+			// Create updated source with the original timestamp
+			updatedSource := &models.ImageSource{
+				URL:         source.URL,
+				Title:       source.Title,
+				Description: source.Description,
+				IsPrimary:   source.IsPrimary,
+			}
+
+			updatedSources = append(updatedSources, updatedSource)
+		} else {
+			// New source - insert it
+			query := `
+                INSERT INTO image_sources (image_id, url, title, description, is_primary)
+                VALUES ($1, $2, $3, $4, $5)
+            `
+
+			_, err := tx.Exec(ctx, query, image.ID, source.URL, source.Title, source.Description, source.IsPrimary)
+			if err != nil {
+				return fmt.Errorf("error creating source: %w", err)
+			}
+
+			// Create updated source with the new timestamp
+			updatedSource := &models.ImageSource{
+				URL:         source.URL,
+				Title:       source.Title,
+				Description: source.Description,
+				IsPrimary:   source.IsPrimary,
+			}
+
+			updatedSources = append(updatedSources, updatedSource)
+		}
+	}
+
+	// Remove sources no longer present
+	if existingImage != nil && existingImage.Sources != nil {
+		for _, source := range existingImage.Sources {
+			if !sourcesToKeep[source.URL] {
+				// Source is no longer associated - remove it
+				query := `DELETE FROM image_sources WHERE image_id = $1 AND url = $2`
+				_, err := tx.Exec(ctx, query, image.ID, source.URL)
+				if err != nil {
+					return fmt.Errorf("error removing source: %w", err)
+				}
+			}
+		}
+	}
+
+	// Update the image's sources collection
+	image.Sources = updatedSources
+
+	return nil
+}
+
+func (r *ImageRepository) Delete(ctx context.Context, uuid string) error {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.Delete")
+	defer span.End()
+
+	var removedTags []int64
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		// Collect the tags of the image, whose counts drop once its associations cascade
+		rows, err := tx.Query(ctx, `
+			SELECT it.tag_id FROM image_tags it
+			INNER JOIN images i ON i.id = it.image_id
+			WHERE i.uuid = $1
+		`, uuid)
+		if err != nil {
+			return fmt.Errorf("error collecting image tags: %w", err)
+		}
+
+		removedTags, err = pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			return fmt.Errorf("error collecting image tags: %w", err)
+		}
+
+		// Delete the image record
+		result, err := tx.Exec(ctx, "DELETE FROM images WHERE uuid = $1", uuid)
+		if err != nil {
+			return fmt.Errorf("error deleting image: %w", err)
+		}
+
+		// Check if any rows were affected
+		rowsAffected := result.RowsAffected()
+		if rowsAffected == 0 {
+			return utils.ErrImageNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.enqueueTagCountReindex(ctx, removedTags)
+
+	// Delete from Elasticsearch after successful deletion, including any version of the
+	// index being rebuilt
+	for _, index := range r.rebuilds.DeleteTargets(ctx, ImageIndex, uuid) {
+		r.deleteElastic(ctx, index, uuid)
+	}
+
+	// Delete from Qdrant after successful deletion
+	_, err = r.container.Qdrant.Client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: "images",
+		Points:         qdrant.NewPointsSelector(qdrant.NewIDUUID(uuid)),
+	})
+
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to delete image %s from Qdrant", uuid)
+		return nil
+	}
+
+	return nil
+}
+
+// Verify downloads the stored object for an image and compares its hashes against
+// the recorded md5 and sha1 values
+func (r *ImageRepository) Verify(ctx context.Context, image *models.Image) (*models.ImageVerification, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.Verify")
+	defer span.End()
+
+	reader, _, _, err := r.container.S3.Download(ctx, image.GetStoredName())
+	if err != nil {
+		return nil, fmt.Errorf("error downloading image object: %w", err)
+	}
+	defer reader.Close()
+
+	md5Hash, sha1Hash, err := utils.CalculateFileHashes(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error calculating object hashes: %w", err)
+	}
+
+	verification := &models.ImageVerification{
+		UUID:         image.UUID,
+		MD5Match:     md5Hash == image.MD5,
+		SHA1Match:    sha1Hash == image.SHA1,
+		ExpectedMD5:  image.MD5,
+		ActualMD5:    md5Hash,
+		ExpectedSHA1: image.SHA1,
+		ActualSHA1:   sha1Hash,
+	}
+	verification.Valid = verification.MD5Match && verification.SHA1Match
+
+	return verification, nil
+}
+
+// StoreThumbnail generates a thumbnail from the data of an image, uploads it and records
+// that the image has one. Formats whose pixels cannot be decoded fail with
+// imaging.ErrDecodeUnsupported.
+func (r *ImageRepository) StoreThumbnail(ctx context.Context, image *models.Image, data []byte) error {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.StoreThumbnail")
+	defer span.End()
+
+	thumbnail, err := imaging.Thumbnail(data, r.container.Config.ThumbnailMaxEdge)
+	if err != nil {
+		return fmt.Errorf("error generating thumbnail: %w", err)
+	}
+
+	if err := r.UploadThumbnail(ctx, image, thumbnail); err != nil {
+		return err
+	}
+
+	if image.HasThumbnail {
+		return nil
+	}
+
+	if _, err := r.container.Postgres.Pool.Exec(ctx, "UPDATE images SET has_thumbnail = TRUE WHERE id = $1", image.ID); err != nil {
+		return fmt.Errorf("error recording thumbnail: %w", err)
+	}
+	image.HasThumbnail = true
+
+	if err := r.container.Worker.EnqueueReindexImage(ctx, image.ID); err != nil {
+		log.Error().Err(err).Msgf("Failed to queue reindex of image %s", image.UUID)
+	}
+
+	return nil
+}
+
+// UploadThumbnail uploads a thumbnail already generated for an image, without recording
+// that the image has one
+func (r *ImageRepository) UploadThumbnail(ctx context.Context, image *models.Image, thumbnail []byte) error {
+	if err := r.container.S3.Upload(ctx, image.GetThumbnailName(), bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/jpeg"); err != nil {
+		return fmt.Errorf("error uploading thumbnail: %w", err)
+	}
+
+	return nil
+}
+
+// GenerateThumbnail downloads the stored object of an image and stores a thumbnail of it
+func (r *ImageRepository) GenerateThumbnail(ctx context.Context, image *models.Image) error {
+	reader, _, _, err := r.container.S3.Download(ctx, image.GetStoredName())
+	if err != nil {
+		return fmt.Errorf("error downloading image object: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("error reading image object: %w", err)
+	}
+
+	return r.StoreThumbnail(ctx, image, data)
+}
+
+// deleteElastic deletes an image document from an index. Failures are logged rather than
+// returned, as the image itself has already been deleted.
+func (r *ImageRepository) deleteElastic(ctx context.Context, index string, uuid string) {
+	req := esapi.DeleteRequest{
+		Index:      index,
+		DocumentID: uuid,
+		Refresh:    "true",
+	}
+
+	res, err := req.Do(ctx, r.container.Elastic.Client)
+	if err != nil {
+		log.Error().Err(err).Str("index", index).Msgf("Failed to delete image %s from Elasticsearch", uuid)
+		return
+	}
+
+	// Handle potential close error
+	defer func() {
+		if closeErr := res.Body.Close(); closeErr != nil {
+			log.Error().Err(closeErr).Msg("Failed to close Elasticsearch response body")
+		}
+	}()
+
+	// Only log the error if it's not a 404 (document not found)
+	if res.IsError() && res.StatusCode != 404 {
+		var e map[string]any
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			log.Error().Err(err).Msg("Failed to parse Elasticsearch error response")
+		} else {
+			log.Error().Str("index", index).Str("status", res.Status()).Interface("error", e).Msg("Failed to delete document from Elasticsearch index")
+		}
+	}
+}
+
+// GetIDsForThumbnails lists the IDs of images without a thumbnail in ascending order, or
+// of every image when all is set
+func (r *ImageRepository) GetIDsForThumbnails(ctx context.Context, all bool) ([]int64, error) {
+	rows, err := r.container.Postgres.Pool.Query(ctx, "SELECT id FROM images WHERE $1 OR NOT has_thumbnail ORDER BY id", all)
+	if err != nil {
+		return nil, fmt.Errorf("error querying image IDs: %w", err)
+	}
+
+	imageIDs, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("error scanning image IDs: %w", err)
+	}
+
+	return imageIDs, nil
+}
+
+func (r *ImageRepository) Search(ctx context.Context, filter models.ImageFilter) (*models.PaginatedImageResult, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.Search")
+	defer span.End()
+
+	// Normalize the limit value
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50 // default
+	} else if limit > 100 {
+		limit = 100 // max
+	}
+
+	// Build the Elasticsearch query
+	query, err := r.prepareSearchQuery(ctx, filter, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error building search query: %w", err)
+	}
+
+	// Execute the search, bounded by the configured timeout
+	searchCtx, cancel, timeout := utils.WithSearchTimeout(ctx, r.container.Config.ElasticsearchSearchTimeout)
+	defer cancel()
+
+	request := r.container.Elastic.Client.Search().Index(ImageIndex).Request(query).TrackTotalHits(true)
+	if len(filter.Facets) > 0 {
+		// Aggregations are only decoded when their types are included in the response
+		request = request.TypedKeys(true)
+	}
+	if timeout != "" {
+		request = request.Timeout(timeout)
+	}
+
+	res, err := request.Do(searchCtx)
+	if err != nil {
+		// Keep simple listings available while Elasticsearch is unreachable
+		if canListFromDatabase(filter) {
+			log.Warn().Err(err).Msg("Image search failed, falling back to Postgres listing")
+			return r.listFromDatabase(ctx, filter, limit)
+		}
+		return nil, fmt.Errorf("error executing search: %w", err)
+	}
+
+	if res.TimedOut {
+		log.Warn().Msg("Image search timed out, returning partial results")
+	}
+
+	// Extract total count
+	totalHits := res.Hits.Total.Value
+	hits := res.Hits.Hits
+
+	// Determine if we have more results by checking if we have one extra hit
+	hasMore := len(hits) > limit
+	if hasMore {
+		hits = hits[:limit] // Remove the extra hit from the data set
+	}
+
+	// A backward page is fetched in reverse, so restore the requested order
+	backward := filter.EndingBefore != nil
+	if backward {
+		slices.Reverse(hits)
+	}
+
+	// Convert hits to models
+	images := make([]*models.Image, 0, len(hits))
+	for _, hit := range hits {
+		image, err := r.hitToImage(hit)
+		if err != nil {
+			return nil, fmt.Errorf("error converting hit to image: %w", err)
+		}
+		images = append(images, image)
+	}
+
+	// Use the "sort" fields of the first and last hits as the cursors
+	var nextCursor, prevCursor []types.FieldValue
+	if len(hits) > 0 {
+		nextCursor, prevCursor = utils.PageCursors(hits[0].Sort, hits[len(hits)-1].Sort, hasMore, filter.StartingAfter != nil, backward)
+	}
+
+	result := &models.PaginatedImageResult{
+		Data:       images,
+		HasMore:    hasMore,
+		TotalCount: totalHits,
+		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
+		Partial:    res.TimedOut,
+	}
+
+	// Let clients know how deep a similarity search can be paginated
+	if filter.HasSimilarity() {
+		result.MaxResults = r.similarityMaxResults(limit)
+	}
+
+	if len(filter.Facets) > 0 {
+		result.Facets = facetBuckets(res.Aggregations, filter.Facets)
+	}
+
+	return result, nil
+}
+
+// facetBuckets converts the terms aggregations of a search response into facet buckets
+func facetBuckets(aggregations map[string]types.Aggregate, facets []string) map[string][]models.FacetBucket {
+	result := make(map[string][]models.FacetBucket, len(facets))
+	for _, facet := range facets {
+		buckets := []models.FacetBucket{}
+
+		if terms, ok := aggregations[facet].(*types.StringTermsAggregate); ok {
+			if termBuckets, ok := terms.Buckets.([]types.StringTermsBucket); ok {
+				for _, bucket := range termBuckets {
+					buckets = append(buckets, models.FacetBucket{
+						Value: fmt.Sprint(bucket.Key),
+						Count: bucket.DocCount,
+					})
+				}
+			}
+		}
+
+		result[facet] = buckets
+	}
+	return result
+}
+
+// GetDocument returns the raw Elasticsearch source indexed for an image, exactly as
+// hitToImage would receive it
+func (r *ImageRepository) GetDocument(ctx context.Context, uuid string) (json.RawMessage, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.GetDocument")
+	defer span.End()
+
+	res, err := r.container.Elastic.Client.Get(ImageIndex, uuid).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching image document: %w", err)
+	}
+
+	if !res.Found {
+		return nil, utils.ErrImageNotFound
+	}
+
+	return res.Source_, nil
+}
+
+// similarityMaxResults returns the size of the window of nearest vectors a similarity
+// search draws from. Enough candidates are over-fetched for restrictive metadata
+// filters to still fill a page, up to the configured cap.
+func (r *ImageRepository) similarityMaxResults(limit int) int {
+	return similarityCandidateLimit(limit, r.container.Config.SimilarityCandidateMultiplier, r.container.Config.SimilarityMaxResults)
+}
+
+// similarityCandidateLimit returns the number of nearest vectors to fetch for a page of
+// limit results, one more than the page so that further pages can be detected, scaled
+// by the multiplier and then capped at maxResults. Multipliers below one are treated
+// as one, and a cap of zero or less leaves the count uncapped.
+func similarityCandidateLimit(limit int, multiplier int, maxResults int) int {
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	candidates := (limit + 1) * multiplier
+	if maxResults > 0 {
+		candidates = min(candidates, maxResults)
+	}
+	return candidates
+}
+
+// similarityVector resolves the query vector of a similarity search, combining the
+// embeddings of every reference image, uploaded embedding and text query in the filter
+func (r *ImageRepository) similarityVector(ctx context.Context, filter models.ImageFilter) ([]float32, error) {
+	var embeddings [][]float32
+
+	if filter.SimilarToText != "" {
+		embedding, err := r.container.Clip.GetTextEmbedding(ctx, filter.SimilarToText)
+		if err != nil {
+			return nil, fmt.Errorf("error embedding search text: %w", err)
+		}
+		if err := utils.ValidateEmbedding(embedding); err != nil {
+			return nil, fmt.Errorf("error embedding search text: %w", err)
+		}
+		embeddings = append(embeddings, embedding)
+	}
+
+	if filter.SimilarToEmbedding != nil {
+		embeddings = append(embeddings, filter.SimilarToEmbedding.Slice())
+	}
+	for _, embedding := range filter.SimilarToEmbeddings {
+		embeddings = append(embeddings, embedding.Slice())
+	}
+
+	referenceIDs := filter.SimilarToIDs
+	if filter.SimilarToID != "" {
+		referenceIDs = append([]string{filter.SimilarToID}, referenceIDs...)
+	}
+	for _, id := range referenceIDs {
+		// Fetch the image to get its embedding
+		image, err := r.GetByUUID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving reference image: %w", err)
+		}
+		embedding, err := r.imageEmbedding(ctx, image)
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, embedding)
+	}
+
+	if len(embeddings) == 1 {
+		return embeddings[0], nil
+	}
+
+	vector, err := utils.CombineEmbeddings(embeddings, filter.SimilarityCombination)
+	if err != nil {
+		return nil, fmt.Errorf("error combining reference embeddings: %w", err)
+	}
+
+	return vector, nil
+}
+
+// dissimilarityVectors resolves the embeddings a similarity search is steered away from
+func (r *ImageRepository) dissimilarityVectors(ctx context.Context, filter models.ImageFilter) ([][]float32, error) {
+	var vectors [][]float32
+
+	if filter.DissimilarToEmbedding != nil {
+		vectors = append(vectors, filter.DissimilarToEmbedding.Slice())
+	}
+
+	if filter.DissimilarToID != "" {
+		image, err := r.GetByUUID(ctx, filter.DissimilarToID)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving dissimilar reference image: %w", err)
+		}
+		embedding, err := r.imageEmbedding(ctx, image)
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, embedding)
+	}
+
+	return vectors, nil
+}
+
+// imageEmbedding returns the embedding of an image, reading it from Qdrant when Postgres
+// does not hold a copy
+func (r *ImageRepository) imageEmbedding(ctx context.Context, image *models.Image) ([]float32, error) {
+	if image.Embedding != nil {
+		return image.Embedding.Slice(), nil
+	}
+
+	points, err := r.container.Qdrant.Client.Get(ctx, &qdrant.GetPoints{
+		CollectionName: "images",
+		Ids:            []*qdrant.PointId{qdrant.NewIDUUID(image.UUID)},
+		WithVectors:    qdrant.NewWithVectorsEnable(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching vector of image %s: %w", image.UUID, err)
+	}
+
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no vector stored for image %s", image.UUID)
+	}
+
+	return points[0].GetVectors().GetVector().GetData(), nil
+}
+
+// Explore returns a visually diverse sample of images. A random pool of candidates
+// is sampled from Qdrant, then images are picked greedily so that each one is as far
+// as possible from those already chosen. Every call draws a fresh sample, so explore
+// results are not paginated.
+func (r *ImageRepository) Explore(ctx context.Context, filter models.ImageFilter) (*models.PaginatedImageResult, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ImageRepository.Explore")
+	defer span.End()
+
+	// Normalize the limit value
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50 // default
+	} else if limit > 100 {
+		limit = 100 // max
+	}
+
+	multiplier := r.container.Config.SimilarityCandidateMultiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	poolSize := uint64(limit * multiplier)
+
+	// Sample a random pool of candidates along with their vectors
+	pool, err := r.container.Qdrant.Client.Query(ctx, &qdrant.QueryPoints{
+		CollectionName: "images",
+		Query:          qdrant.NewQuerySample(qdrant.Sample_Random),
+		Limit:          &poolSize,
+		WithPayload:    qdrant.NewWithPayloadEnable(false),
+		WithVectors:    qdrant.NewWithVectorsEnable(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error sampling vectors: %w", err)
+	}
+
+	candidates := make([]string, 0, len(pool))
+	vectors := make([][]float32, 0, len(pool))
+	for _, point := range pool {
+		vector := point.GetVectors().GetVector().GetData()
+		if len(vector) == 0 {
+			continue
+		}
+		candidates = append(candidates, point.Id.GetUuid())
+		vectors = append(vectors, vector)
+	}
+
+	images := make([]*models.Image, 0, limit)
+	for _, index := range selectDiverse(vectors, limit) {
+		image, err := r.GetByUUID(ctx, candidates[index])
+		if err != nil {
+			// Skip points whose image was removed since the vector was written
+			if errors.Is(err, utils.ErrImageNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("error retrieving image %s: %w", candidates[index], err)
+		}
+		images = append(images, image)
+	}
+
+	return &models.PaginatedImageResult{
+		Data:       images,
+		HasMore:    false,
+		TotalCount: int64(len(images)),
+	}, nil
+}
+
+// selectDiverse greedily picks up to n vectors, each time choosing the vector whose
+// cosine distance to its nearest already-selected vector is largest. The first pick
+// is the first vector, which is already random as the pool is sampled randomly.
+func selectDiverse(vectors [][]float32, n int) []int {
+	if n > len(vectors) {
+		n = len(vectors)
+	}
+	if n == 0 {
+		return nil
+	}
+
+	// nearest tracks the distance from each vector to its closest selected vector
+	nearest := make([]float64, len(vectors))
+	for i := range nearest {
+		nearest[i] = math.Inf(1)
+	}
+	selected := make([]bool, len(vectors))
+
+	indexes := make([]int, 0, n)
+	next := 0
+	for len(indexes) < n {
+		indexes = append(indexes, next)
+		selected[next] = true
+
+		best, bestDistance := -1, -1.0
+		for i, vector := range vectors {
+			if selected[i] {
+				continue
+			}
+			if distance := cosineDistance(vector, vectors[next]); distance < nearest[i] {
+				nearest[i] = distance
+			}
+			if nearest[i] > bestDistance {
+				best, bestDistance = i, nearest[i]
+			}
+		}
+
+		if best < 0 {
+			break
+		}
+		next = best
+	}
+
+	return indexes
+}
+
+// cosineDistance returns 1 minus the cosine similarity of two vectors
+func cosineDistance(a []float32, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := 0; i < len(a) && i < len(b); i++ {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 1
+	}
+	return 1 - dot/(math.Sqrt(normA)*math.Sqrt(normB))
+}
+
+// canListFromDatabase reports whether the filter describes a simple listing that
+// Postgres can serve without Elasticsearch, i.e. no full-text, similarity,
+// association filters or non-chronological sorting
+func canListFromDatabase(filter models.ImageFilter) bool {
+	if filter.Title != "" || filter.Description != "" || filter.Source != "" || filter.Filename != "" {
+		return false
+	}
+
+	if filter.CameraMake != "" || filter.CameraModel != "" || len(filter.Facets) > 0 {
+		return false
+	}
+
+	if filter.HasSimilarity() {
+		return false
+	}
 
-	return nil
+	if len(filter.TagFilters) > 0 || len(filter.PersonFilters) > 0 {
+		return false
+	}
+
+	return filter.SortBy == "" || filter.SortBy == models.SortByCreatedAt
 }
 
-func (r *ImageRepository) Delete(ctx context.Context, uuid string) error {
-	// Start a transaction
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("error starting transaction: %w", err)
+// presenceCondition builds a condition matching rows where a nullable column is set, or
+// where it is null, mirroring an Elasticsearch exists query
+func presenceCondition(column string, present bool) string {
+	if present {
+		return column + " IS NOT NULL"
 	}
+	return column + " IS NULL"
+}
 
-	// Ensure we handle rollback errors
-	defer func() {
-		if tx != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				// Just log the rollback error as there's not much we can do at this point
-				log.Error().Err(err).Msg("Failed to roll back transaction")
-			}
-		}
-	}()
+// listFromDatabase serves a simple chronological listing directly from Postgres.
+// Cursors mirror the Elasticsearch sort values (created_at in epoch milliseconds
+// followed by id), so pagination continues seamlessly across both paths.
+func (r *ImageRepository) listFromDatabase(ctx context.Context, filter models.ImageFilter, limit int) (*models.PaginatedImageResult, error) {
+	var conditions []string
+	var args []any
 
-	// Delete the image record
-	result, err := tx.Exec(ctx, "DELETE FROM images WHERE uuid = $1", uuid)
-	if err != nil {
-		return fmt.Errorf("error deleting image: %w", err)
+	addCondition := func(condition string, values ...any) {
+		for _, value := range values {
+			args = append(args, value)
+			condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(args)), 1)
+		}
+		conditions = append(conditions, condition)
 	}
 
-	// Check if any rows were affected
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
-		return utils.ErrImageNotFound
+	if filter.Hash != "" {
+		addCondition("(md5 = ? OR sha1 = ?)", filter.Hash, filter.Hash)
+	}
+	if filter.MinWidth > 0 {
+		addCondition("width >= ?", filter.MinWidth)
+	}
+	if filter.MaxWidth > 0 {
+		addCondition("width <= ?", filter.MaxWidth)
+	}
+	if filter.MinHeight > 0 {
+		addCondition("height >= ?", filter.MinHeight)
 	}
+	if filter.MaxHeight > 0 {
+		addCondition("height <= ?", filter.MaxHeight)
+	}
+	if filter.SinceDate != nil {
+		addCondition("created_at >= ?", *filter.SinceDate)
+	}
+	if filter.BeforeDate != nil {
+		addCondition("created_at <= ?", *filter.BeforeDate)
+	}
+	if filter.HasTitle != nil {
+		addCondition(presenceCondition("title", *filter.HasTitle))
+	}
+	if filter.HasDescription != nil {
+		addCondition(presenceCondition("description", *filter.HasDescription))
+	}
+	if filter.MinRating != nil {
+		addCondition("rating >= ?", *filter.MinRating)
+	}
+	if filter.MaxRating != nil {
+		addCondition("rating <= ?", *filter.MaxRating)
+	}
+
+	// The total count ignores the cursor, matching Elasticsearch semantics
+	countConditions := append([]string(nil), conditions...)
+	countArgs := append([]any(nil), args...)
 
-	// Commit the transaction
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("error committing transaction: %w", err)
+	// A page before an EndingBefore cursor is fetched by walking the ordering backwards,
+	// flipping both the sort column and the id tiebreaker
+	cursor := filter.StartingAfter
+	backward := filter.EndingBefore != nil
+	if backward {
+		cursor = filter.EndingBefore
 	}
 
-	// Delete from Elasticsearch after successful deletion
-	req := esapi.DeleteRequest{
-		Index:      "images",
-		DocumentID: uuid,
-		Refresh:    "true",
+	ascending := (filter.SortDirection == utils.SortDirectionAsc) != backward
+
+	comparison, direction := "<", "DESC"
+	if ascending {
+		comparison, direction = ">", "ASC"
 	}
 
-	res, err := req.Do(ctx, r.container.Elastic.Client)
-	if err != nil {
-		log.Error().Err(err).Msgf("Failed to delete image %s from Elasticsearch", uuid)
-		return nil
+	idComparison, idDirection := ">", "ASC"
+	if backward {
+		idComparison, idDirection = "<", "DESC"
 	}
 
-	// Handle potential close error
-	defer func() {
-		if closeErr := res.Body.Close(); closeErr != nil {
-			log.Error().Err(err).Msg("Failed to close Elasticsearch response body")
+	// Apply the keyset cursor, compared at millisecond precision to match Elasticsearch
+	if len(cursor) == 2 {
+		createdAt, err := utils.CursorTime(cursor[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid cursor: %v", utils.ErrInvalidInput, err)
 		}
-	}()
 
-	// Check if the Elasticsearch delete request was successful
-	if res.IsError() {
-		// Only log the error if it's not a 404 (document not found)
-		if res.StatusCode != 404 {
-			var e map[string]any
-			if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
-				log.Error().Err(err).Msg("Failed to parse Elasticsearch error response")
-			} else {
-				log.Error().Err(err).Str("status", res.Status()).Msg("Failed to delete document from Elasticsearch index")
-			}
+		id, err := utils.CursorInt64(cursor[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid cursor: %v", utils.ErrInvalidInput, err)
 		}
-		// Don't return an error since the storage deletion was successful
-	}
-
-	// Delete from Qdrant after successful deletion
-	_, err = r.container.Qdrant.Client.Delete(ctx, &qdrant.DeletePoints{
-		CollectionName: "images",
-		Points:         qdrant.NewPointsSelector(qdrant.NewIDUUID(uuid)),
-	})
 
-	if err != nil {
-		log.Error().Err(err).Msgf("Failed to delete image %s from Qdrant", uuid)
-		return nil
+		addCondition(
+			"(date_trunc('milliseconds', created_at) "+comparison+" ? OR (date_trunc('milliseconds', created_at) = ? AND id "+idComparison+" ?))",
+			createdAt, createdAt, id,
+		)
+	} else if len(cursor) > 0 {
+		return nil, fmt.Errorf("%w: invalid cursor", utils.ErrInvalidInput)
 	}
 
-	return nil
-}
-
-func (r *ImageRepository) Search(ctx context.Context, filter models.ImageFilter) (*models.PaginatedImageResult, error) {
-	// Normalize the limit value
-	limit := filter.Limit
-	if limit <= 0 {
-		limit = 50 // default
-	} else if limit > 100 {
-		limit = 100 // max
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	// Build the Elasticsearch query
-	query, err := r.prepareSearchQuery(ctx, filter, limit)
-	if err != nil {
-		return nil, fmt.Errorf("error building search query: %w", err)
+	countWhere := ""
+	if len(countConditions) > 0 {
+		countWhere = "WHERE " + strings.Join(countConditions, " AND ")
 	}
 
-	// Execute the search
-	res, err := r.container.Elastic.Client.Search().Index("images").Request(query).TrackTotalHits(true).Do(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("error executing search: %w", err)
-	}
+	var totalCount int64
+	var hasMore bool
+	var images []*models.Image
 
-	// Extract total count
-	totalHits := res.Hits.Total.Value
-	hits := res.Hits.Hits
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM images "+countWhere, countArgs...).Scan(&totalCount); err != nil {
+			return fmt.Errorf("error counting images: %w", err)
+		}
 
-	// Determine if we have more results by checking if we have one extra hit
-	hasMore := len(hits) > limit
-	if hasMore {
-		hits = hits[:limit] // Remove the extra hit from the data set
-	}
+		query := fmt.Sprintf(`
+			SELECT id
+			FROM images
+			%s
+			ORDER BY date_trunc('milliseconds', created_at) %s, id %s
+			LIMIT %d
+		`, where, direction, idDirection, limit+1)
 
-	// Convert hits to models
-	images := make([]*models.Image, 0, len(hits))
-	var nextCursor []types.FieldValue
-	for i, hit := range hits {
-		image, err := r.hitToImage(hit)
+		rows, err := tx.Query(ctx, query, args...)
 		if err != nil {
-			return nil, fmt.Errorf("error converting hit to image: %w", err)
+			return fmt.Errorf("error listing images: %w", err)
+		}
+
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("error scanning image ID: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating image IDs: %w", err)
 		}
-		images = append(images, image)
 
-		// If this is the last hit and there are more results, use its "sort" field as the cursor.
-		if i == len(hits)-1 && hasMore {
-			nextCursor = append(nextCursor, hit.Sort...)
+		// Determine if we have more results by checking if we have one extra row
+		hasMore = len(ids) > limit
+		if hasMore {
+			ids = ids[:limit]
 		}
+
+		if backward {
+			slices.Reverse(ids)
+		}
+
+		images = make([]*models.Image, 0, len(ids))
+		for _, id := range ids {
+			image, err := r.getByIDTx(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			images = append(images, image)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var nextCursor, prevCursor []types.FieldValue
+	if len(images) > 0 {
+		first, last := images[0], images[len(images)-1]
+		nextCursor, prevCursor = utils.PageCursors(
+			utils.KeysetCursor(first.CreatedAt, first.ID),
+			utils.KeysetCursor(last.CreatedAt, last.ID),
+			hasMore, filter.StartingAfter != nil, backward,
+		)
 	}
 
-	// Use the pagination helper to format the response
 	return &models.PaginatedImageResult{
 		Data:       images,
 		HasMore:    hasMore,
-		TotalCount: totalHits,
+		TotalCount: totalCount,
 		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
 	}, nil
 }
 
@@ -941,31 +2833,59 @@ func (r *ImageRepository) prepareSearchQuery(ctx context.Context, filter models.
 	var filters, notFilters []types.Query
 	var shoulds []types.Query
 
+	// Tag and person filters preferred rather than required in a similarity search
+	var boosts []types.Query
+	boostFilter := func(query types.Query) {
+		boosts = append(boosts, types.Query{
+			ConstantScore: &types.ConstantScoreQuery{
+				Filter: &query,
+				Boost:  utils.NewPointer(r.container.Config.Relevance.ImageFilterBoost),
+			},
+		})
+	}
+
 	// Functions to use for scoring
 	var scoreFunctions []types.FunctionScore
 
 	// Flag to track if we should return zero results due to no vector matches
 	returnEmptyResults := false
 
-	if filter.SimilarToEmbedding != nil || filter.SimilarToID != "" {
-		var vectorToSearch []float32
+	if filter.HasSimilarity() {
+		vectorToSearch, err := r.similarityVector(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		// Similarity results are drawn from a fixed window of the nearest vectors, so
+		// that every page of a paginated search filters the same candidates. Results
+		// beyond the window are never returned, rather than pages running dry.
+		candidateLimit := uint64(r.similarityMaxResults(limit))
 
-		// Get the vector either directly or by ID
-		if filter.SimilarToEmbedding != nil {
-			vectorToSearch = filter.SimilarToEmbedding.Slice()
-		} else if filter.SimilarToID != "" {
-			// Fetch the image to get its embedding
-			image, err := r.GetByUUID(ctx, filter.SimilarToID)
-			if err != nil {
-				return nil, fmt.Errorf("error retrieving reference image: %w", err)
+		negativeVectors, err := r.dissimilarityVectors(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		// Steer away from negative examples with a recommendation query, which moves the
+		// query vector away from them, otherwise search directly by the query vector
+		query := qdrant.NewQuery(vectorToSearch...)
+		if len(negativeVectors) > 0 {
+			negatives := make([]*qdrant.VectorInput, 0, len(negativeVectors))
+			for _, vector := range negativeVectors {
+				negatives = append(negatives, qdrant.NewVectorInput(vector...))
 			}
-			vectorToSearch = image.Embedding.Slice()
+
+			query = qdrant.NewQueryRecommend(&qdrant.RecommendInput{
+				Positive: []*qdrant.VectorInput{qdrant.NewVectorInput(vectorToSearch...)},
+				Negative: negatives,
+			})
 		}
 
 		// Query Qdrant for similar vectors
-		searchResults, err := r.container.Qdrant.Client.Query(context.Background(), &qdrant.QueryPoints{
+		searchResults, err := r.queryVectors(ctx, &qdrant.QueryPoints{
 			CollectionName: "images",
-			Query:          qdrant.NewQuery(vectorToSearch...),
+			Query:          query,
+			Limit:          &candidateLimit,
 			WithPayload:    qdrant.NewWithPayloadEnable(false),
 		})
 
@@ -1009,6 +2929,23 @@ func (r *ImageRepository) prepareSearchQuery(ctx context.Context, filter models.
 			}
 		}
 
+		// A reference image is its own nearest match, so leave it out unless asked for
+		if !filter.IncludeReference {
+			referenceIDs := filter.SimilarToIDs
+			if filter.SimilarToID != "" {
+				referenceIDs = append([]string{filter.SimilarToID}, referenceIDs...)
+			}
+			if len(referenceIDs) > 0 {
+				notFilters = append(notFilters, types.Query{
+					Terms: &types.TermsQuery{
+						TermsQuery: map[string]types.TermsQueryField{
+							"uuid": referenceIDs,
+						},
+					},
+				})
+			}
+		}
+
 		// Set sort by _score by default when doing similarity search
 		if filter.SortBy == "" {
 			filter.SortBy = models.SortByRelevance
@@ -1028,13 +2965,23 @@ func (r *ImageRepository) prepareSearchQuery(ctx context.Context, filter models.
 		})
 	}
 
+	relevance := r.container.Config.Relevance
+
+	// Text is matched against the subfields analysed for the requested language, when
+	// one is given
+	titleField, descriptionField := "title", "description"
+	if filter.Language != "" {
+		titleField += "." + filter.Language
+		descriptionField += "." + filter.Language
+	}
+
 	// Apply title filter
 	if filter.Title != "" {
 		shoulds = append(shoulds, types.Query{
 			Match: map[string]types.MatchQuery{
-				"title": {
+				titleField: {
 					Query: filter.Title,
-					Boost: utils.NewPointer(float32(2.0)),
+					Boost: utils.NewPointer(relevance.ImageTitleBoost),
 				},
 			},
 		})
@@ -1044,8 +2991,9 @@ func (r *ImageRepository) prepareSearchQuery(ctx context.Context, filter models.
 	if filter.Description != "" {
 		shoulds = append(shoulds, types.Query{
 			Match: map[string]types.MatchQuery{
-				"description": {
+				descriptionField: {
 					Query: filter.Description,
+					Boost: utils.NewPointer(relevance.ImageDescriptionBoost),
 				},
 			},
 		})
@@ -1063,7 +3011,7 @@ func (r *ImageRepository) prepareSearchQuery(ctx context.Context, filter models.
 								Term: map[string]types.TermQuery{
 									"sources.url.keyword": {
 										Value: filter.Source,
-										Boost: utils.NewPointer(float32(2.0)), // Higher boost for exact matches
+										Boost: utils.NewPointer(relevance.ImageSourceExactBoost), // Higher boost for exact matches
 									},
 								},
 							},
@@ -1071,7 +3019,7 @@ func (r *ImageRepository) prepareSearchQuery(ctx context.Context, filter models.
 								Match: map[string]types.MatchQuery{
 									"sources.url": {
 										Query: filter.Source,
-										Boost: utils.NewPointer(float32(1.5)), // Lower boost for partial matches
+										Boost: utils.NewPointer(relevance.ImageSourcePartialBoost), // Lower boost for partial matches
 									},
 								},
 							},
@@ -1082,6 +3030,31 @@ func (r *ImageRepository) prepareSearchQuery(ctx context.Context, filter models.
 		})
 	}
 
+	// Apply filename filter, requiring a match while ranking exact filenames first
+	if filter.Filename != "" {
+		filters = append(filters, types.Query{Bool: &types.BoolQuery{
+			Should: []types.Query{
+				{
+					Term: map[string]types.TermQuery{
+						"filename.keyword": {
+							Value: filter.Filename,
+							Boost: utils.NewPointer(relevance.ImageFilenameExactBoost),
+						},
+					},
+				},
+				{
+					Match: map[string]types.MatchQuery{
+						"filename": {
+							Query: filter.Filename,
+							Boost: utils.NewPointer(relevance.ImageFilenamePartialBoost),
+						},
+					},
+				},
+			},
+			MinimumShouldMatch: 1,
+		}})
+	}
+
 	// Apply hash filter
 	if filter.Hash != "" {
 		filters = append(filters, types.Query{Bool: &types.BoolQuery{
@@ -1093,6 +3066,18 @@ func (r *ImageRepository) prepareSearchQuery(ctx context.Context, filter models.
 		}})
 	}
 
+	// Apply capture device filters
+	if filter.CameraMake != "" {
+		filters = append(filters, types.Query{
+			Term: map[string]types.TermQuery{"camera_make": {Value: filter.CameraMake}},
+		})
+	}
+	if filter.CameraModel != "" {
+		filters = append(filters, types.Query{
+			Term: map[string]types.TermQuery{"camera_model": {Value: filter.CameraModel}},
+		})
+	}
+
 	// Apply width filters
 	if filter.MinWidth > 0 || filter.MaxWidth > 0 {
 		widthRange := types.NumberRangeQuery{}
@@ -1129,6 +3114,24 @@ func (r *ImageRepository) prepareSearchQuery(ctx context.Context, filter models.
 		})
 	}
 
+	// Apply rating filters, which exclude unrated images
+	if filter.MinRating != nil || filter.MaxRating != nil {
+		ratingRange := types.NumberRangeQuery{}
+
+		if filter.MinRating != nil {
+			ratingRange.Gte = utils.NewPointer(types.Float64(*filter.MinRating))
+		}
+		if filter.MaxRating != nil {
+			ratingRange.Lte = utils.NewPointer(types.Float64(*filter.MaxRating))
+		}
+
+		filters = append(filters, types.Query{
+			Range: map[string]types.RangeQuery{
+				"rating": ratingRange,
+			},
+		})
+	}
+
 	// Apply date filters
 	if filter.SinceDate != nil || filter.BeforeDate != nil {
 		dateRange := types.DateRangeQuery{}
@@ -1147,19 +3150,61 @@ func (r *ImageRepository) prepareSearchQuery(ctx context.Context, filter models.
 		})
 	}
 
+	// Apply presence filters for free-text metadata
+	for _, presence := range []struct {
+		field   string
+		present *bool
+	}{
+		{"title", filter.HasTitle},
+		{"description", filter.HasDescription},
+	} {
+		if presence.present == nil {
+			continue
+		}
+
+		exists := types.Query{Exists: &types.ExistsQuery{Field: presence.field}}
+		if *presence.present {
+			filters = append(filters, exists)
+		} else {
+			notFilters = append(notFilters, exists)
+		}
+	}
+
 	// Apply tag filters
 	if len(filter.TagFilters) > 0 {
 		for _, tagFilter := range filter.TagFilters {
-			nestedQuery := &types.NestedQuery{
-				Path: "tags",
-				Query: &types.Query{
-					Term: map[string]types.TermQuery{
-						"tags.uuid": {Value: tagFilter.ID},
-					},
+			tagQuery := types.Query{
+				Term: map[string]types.TermQuery{
+					"tags.uuid": {Value: tagFilter.ID},
 				},
 			}
 
-			if tagFilter.Include {
+			// Match any tag within the subtree rooted at the filter tag
+			if tagFilter.Descendants {
+				uuids, err := r.expandTagDescendants(ctx, tagFilter.ID)
+				if err != nil {
+					return nil, fmt.Errorf("error expanding tag subtree: %w", err)
+				}
+
+				tagQuery = types.Query{
+					Terms: &types.TermsQuery{
+						TermsQuery: map[string]types.TermsQueryField{
+							"tags.uuid": uuids,
+						},
+					},
+				}
+			}
+
+			nestedQuery := &types.NestedQuery{
+				Path:  "tags",
+				Query: &tagQuery,
+			}
+
+			if tagFilter.Include && tagFilter.AsBoost && filter.HasSimilarity() {
+				boostFilter(types.Query{
+					Nested: nestedQuery,
+				})
+			} else if tagFilter.Include {
 				filters = append(filters, types.Query{
 					Nested: nestedQuery,
 				})
@@ -1174,19 +3219,41 @@ func (r *ImageRepository) prepareSearchQuery(ctx context.Context, filter models.
 	// Apply person filters
 	if len(filter.PersonFilters) > 0 {
 		for _, personFilter := range filter.PersonFilters {
+			if err := personFilter.Validate(); err != nil {
+				return nil, err
+			}
+
+			// Both conditions must hold for the same person entry
+			var conditions []types.Query
+			if personFilter.ID != "" {
+				conditions = append(conditions, types.Query{
+					Term: map[string]types.TermQuery{"people.uuid": {Value: personFilter.ID}},
+				})
+			}
+			if len(personFilter.Role) > 0 {
+				conditions = append(conditions, types.Query{
+					Terms: &types.TermsQuery{
+						TermsQuery: map[string]types.TermsQueryField{
+							"people.role": personFilter.Role,
+						},
+					},
+				})
+			}
+
 			nestedQuery := &types.NestedQuery{
 				Path: "people",
 				Query: &types.Query{
 					Bool: &types.BoolQuery{
-						Must: []types.Query{
-							{Term: map[string]types.TermQuery{"people.uuid": {Value: personFilter.ID}}},
-							{Term: map[string]types.TermQuery{"people.role": {Value: personFilter.Role}}},
-						},
+						Must: conditions,
 					},
 				},
 			}
 
-			if personFilter.Include {
+			if personFilter.Include && personFilter.AsBoost && filter.HasSimilarity() {
+				boostFilter(types.Query{
+					Nested: nestedQuery,
+				})
+			} else if personFilter.Include {
 				filters = append(filters, types.Query{
 					Nested: nestedQuery,
 				})
@@ -1204,16 +3271,34 @@ func (r *ImageRepository) prepareSearchQuery(ctx context.Context, filter models.
 		minScore = filter.SimilarityThreshold
 	}
 
+	// Boosts are kept apart from the text clauses, so that they never count towards the
+	// minimum_should_match, and added to a match_all so that no image needs to match them
+	if len(boosts) > 0 {
+		filters = append(filters, types.Query{
+			Bool: &types.BoolQuery{
+				Must:   []types.Query{{MatchAll: &types.MatchAllQuery{}}},
+				Should: boosts,
+			},
+		})
+	}
+
 	finalBoolQuery := &types.BoolQuery{
 		Must:    filters,
 		MustNot: notFilters,
 		Should:  shoulds,
 	}
+	if len(shoulds) > 0 && relevance.ImageMinimumShouldMatch != "" {
+		finalBoolQuery.MinimumShouldMatch = relevance.ImageMinimumShouldMatch
+	}
 
 	// Build the base query
 	searchRequest := &search.Request{
 		Size:     utils.NewPointer(limit + 1), // Extra document to detect more pages
 		MinScore: utils.NewPointer(types.Float64(minScore)),
+		// Documents indexed before embeddings were dropped from the index may still carry one
+		Source_: &types.SourceFilter{
+			Excludes: []string{"embedding"},
+		},
 		Query: &types.Query{
 			FunctionScore: &types.FunctionScoreQuery{
 				Query: &types.Query{
@@ -1225,22 +3310,14 @@ func (r *ImageRepository) prepareSearchQuery(ctx context.Context, filter models.
 		},
 	}
 
-	// Determine sort field & direction with defaults
+	// Determine sort field with defaults
 	sortField := models.SortByCreatedAt
 	if filter.SortBy != "" {
 		sortField = filter.SortBy
-	} else if filter.SimilarToID != "" || filter.SimilarToEmbedding != nil || filter.Title != "" || filter.Description != "" {
+	} else if filter.HasSimilarity() || filter.Title != "" || filter.Description != "" || filter.Filename != "" {
 		sortField = models.SortByRelevance
 	}
 
-	var sortDirection sortorder.SortOrder
-	switch filter.SortDirection {
-	case utils.SortDirectionAsc:
-		sortDirection = sortorder.Asc
-	default:
-		sortDirection = sortorder.Desc
-	}
-
 	if sortField == models.SortByRandom {
 		if filter.RandomSeed != nil {
 			searchRequest.Query = &types.Query{
@@ -1261,28 +3338,83 @@ func (r *ImageRepository) prepareSearchQuery(ctx context.Context, filter models.
 			return nil, fmt.Errorf("invalid random sorting seed provided")
 		}
 	} else {
-		searchRequest.Sort = []types.SortCombinations{
-			types.SortOptions{
-				SortOptions: map[string]types.FieldSort{
-					string(sortField): {
-						Order: &sortDirection,
-					},
-					"id": {
-						Order: &sortorder.Asc,
-					},
-				},
-			},
-		}
+		// A page before an EndingBefore cursor is fetched by walking the sort backwards
+		searchRequest.Sort = utils.SortWithTiebreaker(string(sortField), filter.SortDirection, filter.EndingBefore != nil)
 	}
 
-	// If a StartingAfter cursor is provided, attach it
+	// If a StartingAfter or EndingBefore cursor is provided, attach it
 	if filter.StartingAfter != nil {
 		searchRequest.SearchAfter = filter.StartingAfter
+	} else if filter.EndingBefore != nil {
+		searchRequest.SearchAfter = filter.EndingBefore
+	}
+
+	// Aggregate the requested facets over every matching image
+	if len(filter.Facets) > 0 {
+		searchRequest.Aggregations = make(map[string]types.Aggregations, len(filter.Facets))
+		for _, facet := range filter.Facets {
+			searchRequest.Aggregations[facet] = types.Aggregations{
+				Terms: &types.TermsAggregation{
+					Field: utils.NewPointer(facet),
+					Size:  utils.NewPointer(imageFacetSize),
+				},
+			}
+		}
 	}
 
 	return searchRequest, nil
 }
 
+// expandTagDescendants resolves the UUIDs of a tag and every tag beneath it. Expansions
+// are cached briefly so that repeated searches do not re-walk the hierarchy.
+func (r *ImageRepository) expandTagDescendants(ctx context.Context, uuid string) ([]string, error) {
+	uuids, found, err := r.tagCache.GetDescendants(ctx, uuid)
+	if err != nil {
+		log.Warn().Err(err).Str("uuid", uuid).Msg("Failed to read tag descendants from cache")
+	} else if found {
+		return uuids, nil
+	}
+
+	query := `
+		WITH RECURSIVE descendants AS (
+			SELECT id, uuid FROM tags WHERE uuid = $1
+			UNION ALL
+			SELECT t.id, t.uuid FROM tags t
+			INNER JOIN descendants d ON t.parent_id = d.id
+		)
+		SELECT uuid FROM descendants
+	`
+
+	rows, err := r.container.Postgres.Pool.Query(ctx, query, uuid)
+	if err != nil {
+		return nil, fmt.Errorf("error querying tag descendants: %w", err)
+	}
+	defer rows.Close()
+
+	uuids = nil
+	for rows.Next() {
+		var descendant string
+		if err := rows.Scan(&descendant); err != nil {
+			return nil, fmt.Errorf("error scanning tag UUID: %w", err)
+		}
+		uuids = append(uuids, descendant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tag descendants: %w", err)
+	}
+
+	// An unknown tag still filters on its own UUID, matching nothing
+	if len(uuids) == 0 {
+		return []string{uuid}, nil
+	}
+
+	if err := r.tagCache.SetDescendants(ctx, uuid, uuids); err != nil {
+		log.Warn().Err(err).Str("uuid", uuid).Msg("Failed to cache tag descendants")
+	}
+
+	return uuids, nil
+}
+
 // hitToImage converts an Elasticsearch hit to an Image model
 func (r *ImageRepository) hitToImage(hit types.Hit) (*models.Image, error) {
 	log.Debug().Interface("score", hit.Score_).Interface("uuid", hit.Id_).Msg("Parsing Elasticsearch hit")
@@ -1405,23 +3537,28 @@ func (r *ImageRepository) hitToImage(hit types.Hit) (*models.Image, error) {
 	if desc, err := getString("description"); err == nil {
 		image.Description = &desc
 	}
-
-	// Process embedding if available.
-	if embRaw, exists := source["embedding"]; exists && embRaw != nil {
-		embArr, ok := embRaw.([]any)
-		if !ok {
-			return nil, fmt.Errorf("embedding is not an array")
-		}
-		embeddingData := make([]float32, len(embArr))
-		for i, v := range embArr {
-			num, ok := v.(float64)
-			if !ok {
-				return nil, fmt.Errorf("embedding element is not a float64")
-			}
-			embeddingData[i] = float32(num)
+	if rating, err := getFloat64("rating"); err == nil {
+		image.Rating = utils.NewPointer(int(rating))
+	}
+	if viewCount, err := getFloat64("view_count"); err == nil {
+		image.ViewCount = int64(viewCount)
+	}
+	if cameraMake, err := getString("camera_make"); err == nil {
+		image.CameraMake = &cameraMake
+	}
+	if cameraModel, err := getString("camera_model"); err == nil {
+		image.CameraModel = &cameraModel
+	}
+	if phash, err := getString("phash"); err == nil {
+		if value, err := strconv.ParseUint(phash, 16, 64); err == nil {
+			image.PHash = utils.NewPointer(int64(value))
 		}
-		vec := pgvector.NewVector(embeddingData)
-		image.Embedding = &vec
+	}
+	if language, err := getString("language"); err == nil {
+		image.Language = &language
+	}
+	if hasThumbnail, ok := source["has_thumbnail"].(bool); ok {
+		image.HasThumbnail = hasThumbnail
 	}
 
 	// Process tags.
@@ -1535,6 +3672,9 @@ func (r *ImageRepository) hitToImage(hit types.Hit) (*models.Image, error) {
 				if d, ok := srcMap["description"].(string); ok {
 					imageSource.Description = &d
 				}
+				if p, ok := srcMap["is_primary"].(bool); ok {
+					imageSource.IsPrimary = p
+				}
 				sources = append(sources, imageSource)
 			}
 			image.Sources = sources
@@ -1546,43 +3686,65 @@ func (r *ImageRepository) hitToImage(hit types.Hit) (*models.Image, error) {
 
 // fetchImageAssociations populates an image with its associated tags, people, and sources
 func (r *ImageRepository) fetchImageAssociations(ctx context.Context, tx pgx.Tx, image *models.Image) error {
-	var err error
+	return r.fetchAssociationsForImages(ctx, tx, []*models.Image{image})
+}
+
+// fetchAssociationsForImages populates several images with their associated tags,
+// people, and sources, using one query per association type regardless of how many
+// images are given
+func (r *ImageRepository) fetchAssociationsForImages(ctx context.Context, tx pgx.Tx, images []*models.Image) error {
+	if len(images) == 0 {
+		return nil
+	}
 
-	// Fetch tags for the image
-	image.Tags, err = r.fetchImageTags(ctx, tx, image.ID)
+	imageIDs := make([]int64, len(images))
+	for i, image := range images {
+		imageIDs[i] = image.ID
+	}
+
+	// Fetch tags for the images
+	tags, err := r.fetchTagsForImages(ctx, tx, imageIDs)
 	if err != nil {
 		return fmt.Errorf("error fetching image tags: %w", err)
 	}
 
-	// Fetch people for the image
-	image.People, err = r.fetchImagePeople(ctx, tx, image.ID)
+	// Fetch people for the images
+	people, err := r.fetchPeopleForImages(ctx, tx, imageIDs)
 	if err != nil {
 		return fmt.Errorf("error fetching image people: %w", err)
 	}
 
-	// Fetch sources for the image
-	image.Sources, err = r.fetchImageSources(ctx, tx, image.ID)
+	// Fetch sources for the images
+	sources, err := r.fetchSourcesForImages(ctx, tx, imageIDs)
 	if err != nil {
 		return fmt.Errorf("error fetching image sources: %w", err)
 	}
 
+	for _, image := range images {
+		image.Tags = tags[image.ID]
+		image.People = people[image.ID]
+		image.Sources = sources[image.ID]
+	}
+
 	return nil
 }
 
-// fetchImageTags retrieves all tags associated with an image
-func (r *ImageRepository) fetchImageTags(ctx context.Context, tx pgx.Tx, imageID int64) ([]*models.ImageTag, error) {
+// fetchTagsForImages retrieves all tags associated with the given images, keyed by image ID
+func (r *ImageRepository) fetchTagsForImages(ctx context.Context, tx pgx.Tx, imageIDs []int64) (map[int64][]*models.ImageTag, error) {
 	query := `
 		WITH RECURSIVE tag_tree AS (
 			SELECT 
+				it.image_id,
 				t.id, 
 				t.uuid, 
 				t.name, 
 				it.created_at AS added_at
 			FROM image_tags it
 			JOIN tags t ON t.id = it.tag_id
-			WHERE it.image_id = $1
+			WHERE it.image_id = ANY($1)
 			UNION
 			SELECT 
+				tag_tree.image_id,
 				parent_t.id, 
 				parent_t.uuid, 
 				parent_t.name, 
@@ -1591,25 +3753,26 @@ func (r *ImageRepository) fetchImageTags(ctx context.Context, tx pgx.Tx, imageID
 			JOIN tag_closure tc ON tc.descendant = tag_tree.id
 			JOIN tags parent_t ON parent_t.id = tc.ancestor
 		)
-		SELECT DISTINCT id, uuid, name, added_at
+		SELECT DISTINCT image_id, id, uuid, name, added_at
 		FROM tag_tree;
 	`
 
-	rows, err := tx.Query(ctx, query, imageID)
+	rows, err := tx.Query(ctx, query, imageIDs)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var tags []*models.ImageTag
+	tags := make(map[int64][]*models.ImageTag, len(imageIDs))
 	for rows.Next() {
+		var imageID int64
 		var tag models.ImageTag
-		err := rows.Scan(&tag.ID, &tag.UUID, &tag.Name, &tag.AddedAt)
+		err := rows.Scan(&imageID, &tag.ID, &tag.UUID, &tag.Name, &tag.AddedAt)
 		if err != nil {
 			return nil, err
 		}
 
-		tags = append(tags, &tag)
+		tags[imageID] = append(tags[imageID], &tag)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -1619,10 +3782,11 @@ func (r *ImageRepository) fetchImageTags(ctx context.Context, tx pgx.Tx, imageID
 	return tags, nil
 }
 
-// fetchImagePeople retrieves all people associated with an image
-func (r *ImageRepository) fetchImagePeople(ctx context.Context, tx pgx.Tx, imageID int64) ([]*models.ImagePerson, error) {
+// fetchPeopleForImages retrieves all people associated with the given images, keyed by image ID
+func (r *ImageRepository) fetchPeopleForImages(ctx context.Context, tx pgx.Tx, imageIDs []int64) (map[int64][]*models.ImagePerson, error) {
 	query := `
 		SELECT 
+			ip.image_id,
 			p.id,
 			p.uuid,
 			p.name,
@@ -1630,25 +3794,26 @@ func (r *ImageRepository) fetchImagePeople(ctx context.Context, tx pgx.Tx, image
 			ip.created_at AS added_at
 		FROM image_people ip
 		JOIN people p ON ip.person_id = p.id
-		WHERE ip.image_id = $1
-		ORDER BY p.name, ip.role;
+		WHERE ip.image_id = ANY($1)
+		ORDER BY ip.image_id, p.name, ip.role;
 	`
 
-	rows, err := tx.Query(ctx, query, imageID)
+	rows, err := tx.Query(ctx, query, imageIDs)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var people []*models.ImagePerson
+	people := make(map[int64][]*models.ImagePerson, len(imageIDs))
 	for rows.Next() {
+		var imageID int64
 		var person models.ImagePerson
-		err := rows.Scan(&person.ID, &person.UUID, &person.Name, &person.Role, &person.AddedAt)
+		err := rows.Scan(&imageID, &person.ID, &person.UUID, &person.Name, &person.Role, &person.AddedAt)
 		if err != nil {
 			return nil, err
 		}
 
-		people = append(people, &person)
+		people[imageID] = append(people[imageID], &person)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -1658,33 +3823,36 @@ func (r *ImageRepository) fetchImagePeople(ctx context.Context, tx pgx.Tx, image
 	return people, nil
 }
 
-// fetchImageSources retrieves all sources associated with an image
-func (r *ImageRepository) fetchImageSources(ctx context.Context, tx pgx.Tx, imageID int64) ([]*models.ImageSource, error) {
+// fetchSourcesForImages retrieves all sources associated with the given images, keyed by image ID
+func (r *ImageRepository) fetchSourcesForImages(ctx context.Context, tx pgx.Tx, imageIDs []int64) (map[int64][]*models.ImageSource, error) {
 	query := `
 		SELECT 
+			s.image_id,
 			s.url,
 			s.title,
-			s.description
+			s.description,
+			s.is_primary
 		FROM image_sources s
-		WHERE s.image_id = $1
-		ORDER BY s.title, s.url;
+		WHERE s.image_id = ANY($1)
+		ORDER BY s.image_id, s.is_primary DESC, s.title, s.url;
 	`
 
-	rows, err := tx.Query(ctx, query, imageID)
+	rows, err := tx.Query(ctx, query, imageIDs)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var sources []*models.ImageSource
+	sources := make(map[int64][]*models.ImageSource, len(imageIDs))
 	for rows.Next() {
+		var imageID int64
 		var source models.ImageSource
-		err := rows.Scan(&source.URL, &source.Title, &source.Description)
+		err := rows.Scan(&imageID, &source.URL, &source.Title, &source.Description, &source.IsPrimary)
 		if err != nil {
 			return nil, err
 		}
 
-		sources = append(sources, &source)
+		sources[imageID] = append(sources[imageID], &source)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -0,0 +1,301 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/foresturquhart/curator/server/config"
+	"github.com/foresturquhart/curator/server/container"
+	"github.com/foresturquhart/curator/server/models"
+	"github.com/foresturquhart/curator/server/storage"
+	"github.com/foresturquhart/curator/server/tasks"
+	"github.com/foresturquhart/curator/server/utils"
+	"github.com/pgvector/pgvector-go"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+func TestSimilarityCandidateLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int
+		multiplier int
+		maxResults int
+		want       int
+	}{
+		{name: "no over-fetch", limit: 50, multiplier: 1, want: 51},
+		{name: "default multiplier", limit: 50, multiplier: 4, want: 204},
+		{name: "zero multiplier", limit: 20, multiplier: 0, want: 21},
+		{name: "negative multiplier", limit: 20, multiplier: -3, want: 21},
+		{name: "single result", limit: 1, multiplier: 10, want: 20},
+		{name: "below the cap", limit: 50, multiplier: 4, maxResults: 1000, want: 204},
+		{name: "capped after the multiplier", limit: 100, multiplier: 20, maxResults: 1000, want: 1000},
+		{name: "negative cap", limit: 100, multiplier: 20, maxResults: -1, want: 2020},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := similarityCandidateLimit(tt.limit, tt.multiplier, tt.maxResults); got != tt.want {
+				t.Errorf("similarityCandidateLimit(%d, %d, %d) = %d, want %d", tt.limit, tt.multiplier, tt.maxResults, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilaritySearchWithTagFilter(t *testing.T) {
+	const limit = 50
+
+	var requested uint64
+	repository := &ImageRepository{
+		container: &container.Container{Config: &config.Config{
+			SimilarityCandidateMultiplier: 4,
+			SimilarityMaxResults:          1000,
+		}},
+		queryVectors: func(ctx context.Context, request *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error) {
+			requested = request.GetLimit()
+			points := make([]*qdrant.ScoredPoint, requested)
+			for i := range points {
+				points[i] = &qdrant.ScoredPoint{
+					Id:    qdrant.NewIDUUID(fmt.Sprintf("00000000-0000-0000-0000-%012d", i)),
+					Score: 0.9,
+				}
+			}
+			return points, nil
+		},
+	}
+
+	embedding := pgvector.NewVector([]float32{0.1, 0.2, 0.3})
+	filter := models.ImageFilter{
+		SimilarToEmbedding: &embedding,
+		TagFilters:         []models.ImageTagFilter{{ID: "cats", Include: true}},
+	}
+
+	request, err := repository.prepareSearchQuery(context.Background(), filter, limit)
+	if err != nil {
+		t.Fatalf("prepareSearchQuery() error = %v", err)
+	}
+
+	// The nearest vectors are over-fetched so that the tag filter can still fill a page
+	if want := uint64(similarityCandidateLimit(limit, 4, 1000)); requested != want {
+		t.Errorf("requested %d nearest vectors, want %d", requested, want)
+	}
+	if request.Size == nil || *request.Size != limit+1 {
+		t.Errorf("page size = %v, want %d", request.Size, limit+1)
+	}
+
+	var candidates int
+	var tagFiltered bool
+	for _, query := range request.Query.FunctionScore.Query.Bool.Must {
+		if query.Terms != nil {
+			if uuids, ok := query.Terms.TermsQuery["uuid"].([]string); ok {
+				candidates = len(uuids)
+			}
+		}
+		if query.Nested != nil && query.Nested.Path == "tags" {
+			tagFiltered = true
+		}
+	}
+
+	if uint64(candidates) != requested {
+		t.Errorf("filtered to %d candidates, want all %d nearest vectors", candidates, requested)
+	}
+	if candidates <= limit {
+		t.Errorf("filtered to %d candidates, too few to fill a page of %d after tag filtering", candidates, limit)
+	}
+	if !tagFiltered {
+		t.Error("tag filter missing from the query")
+	}
+	if functions := len(request.Query.FunctionScore.Functions); uint64(functions) != requested {
+		t.Errorf("scored %d candidates, want %d", functions, requested)
+	}
+}
+
+func TestEmbeddingChanged(t *testing.T) {
+	vector := func(values ...float32) *pgvector.Vector {
+		v := pgvector.NewVector(values)
+		return &v
+	}
+
+	tests := []struct {
+		name     string
+		existing *pgvector.Vector
+		updated  *pgvector.Vector
+		want     bool
+	}{
+		{name: "neither set", existing: nil, updated: nil, want: false},
+		{name: "only existing set", existing: vector(0.1, 0.2), updated: nil, want: false},
+		{name: "only updated set", existing: nil, updated: vector(0.1, 0.2), want: false},
+		{name: "equal values in separate vectors", existing: vector(0.1, 0.2, 0.3), updated: vector(0.1, 0.2, 0.3), want: false},
+		{name: "different values", existing: vector(0.1, 0.2, 0.3), updated: vector(0.1, 0.2, 0.4), want: true},
+		{name: "different lengths", existing: vector(0.1, 0.2), updated: vector(0.1, 0.2, 0.3), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := embeddingChanged(tt.existing, tt.updated); got != tt.want {
+				t.Errorf("embeddingChanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// discardedTasks accepts the reindex tasks queued by an upsert without running them
+type discardedTasks struct {
+	tasks.Client
+}
+
+func (discardedTasks) EnqueueReindexImage(ctx context.Context, id int64) error { return nil }
+func (discardedTasks) EnqueueReindexTag(ctx context.Context, id int64) error   { return nil }
+
+// TestUpsertUpdatesExistingImage updates an image the way the image handler does, by
+// reading it back and upserting it with a changed title. It needs a Postgres database
+// with pgvector, named by TEST_POSTGRES_URL, and is skipped without one.
+func TestUpsertUpdatesExistingImage(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_URL")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+
+	postgres, err := storage.NewPostgres(dsn)
+	if err != nil {
+		t.Fatalf("connecting to postgres: %v", err)
+	}
+	t.Cleanup(postgres.Close)
+
+	if err := postgres.Migrate(); err != nil {
+		t.Fatalf("migrating database: %v", err)
+	}
+
+	repository := &ImageRepository{container: &container.Container{
+		Config:   &config.Config{StoreEmbeddingsInPostgres: true},
+		Postgres: postgres,
+		Worker:   discardedTasks{},
+	}}
+
+	ctx := context.Background()
+
+	values := make([]float32, 512)
+	for i := range values {
+		values[i] = float32(i+1) / float32(len(values))
+	}
+	embedding := pgvector.NewVector(values)
+
+	nonce := time.Now().UnixNano()
+	image := &models.Image{
+		Filename:  "upsert-test.png",
+		MD5:       fmt.Sprintf("%032x", nonce),
+		SHA1:      fmt.Sprintf("%040x", nonce),
+		Width:     10,
+		Height:    10,
+		Format:    models.FormatPNG,
+		Size:      1024,
+		Embedding: &embedding,
+	}
+	if err := repository.Upsert(ctx, image, UpsertOptions{}); err != nil {
+		t.Fatalf("inserting image: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := postgres.Pool.Exec(context.Background(), "DELETE FROM images WHERE id = $1", image.ID); err != nil {
+			t.Errorf("removing image: %v", err)
+		}
+	})
+
+	existing, err := repository.GetByUUID(ctx, image.UUID)
+	if err != nil {
+		t.Fatalf("reading image back: %v", err)
+	}
+
+	title := "Updated title"
+	existing.Title = &title
+	if err := repository.Upsert(ctx, existing, UpsertOptions{}); err != nil {
+		t.Fatalf("updating image: %v", err)
+	}
+
+	updated, err := repository.GetByUUID(ctx, image.UUID)
+	if err != nil {
+		t.Fatalf("reading updated image: %v", err)
+	}
+	if updated.Title == nil || *updated.Title != title {
+		t.Errorf("title = %v, want %q", updated.Title, title)
+	}
+
+	// Replacing the embedding itself is still rejected
+	changed := pgvector.NewVector(append([]float32{2}, values[1:]...))
+	updated.Embedding = &changed
+
+	var immutable *utils.ImmutableFieldError
+	if err := repository.Upsert(ctx, updated, UpsertOptions{}); !errors.As(err, &immutable) || immutable.Field != "embedding" {
+		t.Errorf("updating embedding: error = %v, want an immutable embedding error", err)
+	}
+}
+
+func TestImageDocumentSources(t *testing.T) {
+	title := "Original upload"
+	description := "Posted by the artist"
+
+	tests := []struct {
+		name    string
+		sources []*models.ImageSource
+		want    []map[string]any
+	}{
+		{name: "no sources"},
+		{
+			name:    "single source",
+			sources: []*models.ImageSource{{URL: "https://example.com/a", IsPrimary: true}},
+			want:    []map[string]any{{"url": "https://example.com/a", "is_primary": true}},
+		},
+		{
+			name: "several sources keep their order and optional fields",
+			sources: []*models.ImageSource{
+				{URL: "https://example.com/a", Title: &title, IsPrimary: true},
+				{URL: "https://example.com/b", Description: &description},
+				{URL: "https://example.com/c"},
+			},
+			want: []map[string]any{
+				{"url": "https://example.com/a", "is_primary": true, "title": title},
+				{"url": "https://example.com/b", "is_primary": false, "description": description},
+				{"url": "https://example.com/c", "is_primary": false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document := imageDocument(&models.Image{Sources: tt.sources})
+
+			value, ok := document["sources"]
+			if len(tt.want) == 0 {
+				if ok {
+					t.Fatalf("sources = %v, want none", value)
+				}
+				return
+			}
+
+			sources, ok := value.([]map[string]any)
+			if !ok {
+				t.Fatalf("sources has type %T, want []map[string]any", value)
+			}
+			if len(sources) != len(tt.want) {
+				t.Fatalf("got %d sources, want %d", len(sources), len(tt.want))
+			}
+
+			for i, source := range sources {
+				if source == nil {
+					t.Fatalf("source %d is nil", i)
+				}
+				if len(source) != len(tt.want[i]) {
+					t.Errorf("source %d = %v, want %v", i, source, tt.want[i])
+					continue
+				}
+				for key, want := range tt.want[i] {
+					if source[key] != want {
+						t.Errorf("source %d %s = %v, want %v", i, key, source[key], want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
 	"github.com/foresturquhart/curator/server/container"
 	"github.com/foresturquhart/curator/server/models"
 	"github.com/foresturquhart/curator/server/utils"
 	"github.com/jackc/pgx/v5"
-	"github.com/rs/zerolog/log"
 )
 
 type PersonRepository struct {
@@ -24,16 +27,19 @@ func NewPersonRepository(container *container.Container) *PersonRepository {
 
 func (r *PersonRepository) getByInternalIDTx(ctx context.Context, tx pgx.Tx, id int64) (*models.Person, error) {
 	query := `
-		SELECT id, uuid, name, description, created_at, updated_at
-		FROM people
-		WHERE id = $1
+		SELECT p.id, p.uuid, p.name, p.description, p.active_since, p.active_until, p.created_at, p.updated_at, pi.uuid
+		FROM people p
+		LEFT JOIN images pi ON pi.id = p.primary_image_id
+		WHERE p.id = $1
 	`
 
 	var person models.Person
 	var descriptionPtr *string
 
 	err := tx.QueryRow(ctx, query, id).Scan(
-		&person.ID, &person.UUID, &person.Name, &descriptionPtr, &person.CreatedAt, &person.UpdatedAt,
+		&person.ID, &person.UUID, &person.Name, &descriptionPtr,
+		&person.ActiveSince, &person.ActiveUntil, &person.CreatedAt, &person.UpdatedAt,
+		&person.PrimaryImageUUID,
 	)
 
 	if err != nil {
@@ -51,34 +57,28 @@ func (r *PersonRepository) getByInternalIDTx(ctx context.Context, tx pgx.Tx, id
 		return nil, err
 	}
 
-	return &person, nil
-}
-
-func (r *PersonRepository) GetByInternalID(ctx context.Context, id int64) (*models.Person, error) {
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
+	err = r.fetchPersonAliases(ctx, tx, &person)
 	if err != nil {
-		return nil, fmt.Errorf("error starting transaction: %w", err)
+		return nil, err
 	}
 
-	// Ensure we handle rollback errors
-	defer func() {
-		if tx != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				// Just log the rollback error as there's not much we can do at this point
-				log.Error().Err(err).Msg("Failed to roll back transaction")
-			}
-		}
-	}()
-
-	person, err := r.getByInternalIDTx(ctx, tx, id)
+	err = r.fetchPersonPrimaryImage(ctx, tx, &person)
 	if err != nil {
 		return nil, err
 	}
 
-	err = tx.Commit(ctx)
+	return &person, nil
+}
+
+func (r *PersonRepository) GetByInternalID(ctx context.Context, id int64) (*models.Person, error) {
+	var person *models.Person
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		person, err = r.getByInternalIDTx(ctx, tx, id)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error committing transaction: %w", err)
+		return nil, err
 	}
 
 	return person, nil
@@ -86,16 +86,19 @@ func (r *PersonRepository) GetByInternalID(ctx context.Context, id int64) (*mode
 
 func (r *PersonRepository) getByUUIDTx(ctx context.Context, tx pgx.Tx, uuid string) (*models.Person, error) {
 	query := `
-		SELECT id, uuid, name, description, created_at, updated_at
-		FROM people
-		WHERE uuid = $1
+		SELECT p.id, p.uuid, p.name, p.description, p.active_since, p.active_until, p.created_at, p.updated_at, pi.uuid
+		FROM people p
+		LEFT JOIN images pi ON pi.id = p.primary_image_id
+		WHERE p.uuid = $1
 	`
 
 	var person models.Person
 	var descriptionPtr *string
 
 	err := tx.QueryRow(ctx, query, uuid).Scan(
-		&person.ID, &person.UUID, &person.Name, &descriptionPtr, &person.CreatedAt, &person.UpdatedAt,
+		&person.ID, &person.UUID, &person.Name, &descriptionPtr,
+		&person.ActiveSince, &person.ActiveUntil, &person.CreatedAt, &person.UpdatedAt,
+		&person.PrimaryImageUUID,
 	)
 
 	if err != nil {
@@ -113,34 +116,28 @@ func (r *PersonRepository) getByUUIDTx(ctx context.Context, tx pgx.Tx, uuid stri
 		return nil, err
 	}
 
-	return &person, nil
-}
-
-func (r *PersonRepository) GetByUUID(ctx context.Context, uuid string) (*models.Person, error) {
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
+	err = r.fetchPersonAliases(ctx, tx, &person)
 	if err != nil {
-		return nil, fmt.Errorf("error starting transaction: %w", err)
+		return nil, err
 	}
 
-	// Ensure we handle rollback errors
-	defer func() {
-		if tx != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				// Just log the rollback error as there's not much we can do at this point
-				log.Error().Err(err).Msg("Failed to roll back transaction")
-			}
-		}
-	}()
-
-	person, err := r.getByUUIDTx(ctx, tx, uuid)
+	err = r.fetchPersonPrimaryImage(ctx, tx, &person)
 	if err != nil {
 		return nil, err
 	}
 
-	err = tx.Commit(ctx)
+	return &person, nil
+}
+
+func (r *PersonRepository) GetByUUID(ctx context.Context, uuid string) (*models.Person, error) {
+	var person *models.Person
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		person, err = r.getByUUIDTx(ctx, tx, uuid)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error committing transaction: %w", err)
+		return nil, err
 	}
 
 	return person, nil
@@ -149,16 +146,17 @@ func (r *PersonRepository) GetByUUID(ctx context.Context, uuid string) (*models.
 // GetByName finds a person by their exact name
 func (r *PersonRepository) getByNameTx(ctx context.Context, tx pgx.Tx, name string) (*models.Person, error) {
 	query := `
-        SELECT id, uuid, name, description, created_at, updated_at
+        SELECT id, uuid, name, description, active_since, active_until, created_at, updated_at
         FROM people
-        WHERE name = $1
+        WHERE ` + nameCondition(r.container, "name") + `
     `
 
 	var person models.Person
 	var descriptionPtr *string
 
-	err := tx.QueryRow(ctx, query, name).Scan(
-		&person.ID, &person.UUID, &person.Name, &descriptionPtr, &person.CreatedAt, &person.UpdatedAt,
+	err := tx.QueryRow(ctx, query, normalizeName(r.container, name)).Scan(
+		&person.ID, &person.UUID, &person.Name, &descriptionPtr,
+		&person.ActiveSince, &person.ActiveUntil, &person.CreatedAt, &person.UpdatedAt,
 	)
 
 	if err != nil {
@@ -196,6 +194,260 @@ func (r *PersonRepository) GetAllIDs(ctx context.Context) ([]int64, error) {
 	return personIDs, nil
 }
 
+// PersonListOptions describes a simple keyset-paginated listing of people
+type PersonListOptions struct {
+	SinceDate     *time.Time          // Records created after this date
+	BeforeDate    *time.Time          // Records created before this date
+	SortByName    bool                // Sort by name rather than creation date
+	SortDirection utils.SortDirection // Sort direction (default: desc)
+
+	utils.PaginationOptions
+}
+
+// List retrieves people directly from Postgres. It is used as a fallback when the
+// search index is unavailable, so its cursors mirror the Elasticsearch sort values
+// (created_at in epoch milliseconds or the name, followed by id).
+func (r *PersonRepository) List(ctx context.Context, options PersonListOptions) (*utils.PaginatedResult[*models.Person], error) {
+	// Normalize the limit value
+	limit := options.Limit
+	if limit <= 0 {
+		limit = 50 // default
+	} else if limit > 100 {
+		limit = 100 // max
+	}
+
+	var conditions []string
+	var args []any
+
+	if options.SinceDate != nil {
+		args = append(args, *options.SinceDate)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if options.BeforeDate != nil {
+		args = append(args, *options.BeforeDate)
+		conditions = append(conditions, fmt.Sprintf("created_at <= $%d", len(args)))
+	}
+
+	// The total count ignores the cursor, matching Elasticsearch semantics
+	countWhere := ""
+	if len(conditions) > 0 {
+		countWhere = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	countArgs := append([]any(nil), args...)
+
+	// Names are compared bytewise to match Elasticsearch keyword ordering, and
+	// timestamps at millisecond precision to match Elasticsearch dates
+	sortColumn := "date_trunc('milliseconds', created_at)"
+	if options.SortByName {
+		sortColumn = `name COLLATE "C"`
+	}
+
+	// A page before an EndingBefore cursor is fetched by walking the ordering backwards,
+	// flipping both the sort column and the id tiebreaker
+	cursor := options.StartingAfter
+	backward := options.EndingBefore != nil
+	if backward {
+		cursor = options.EndingBefore
+	}
+
+	comparison, direction := "<", "DESC"
+	if (options.SortDirection == utils.SortDirectionAsc) != backward {
+		comparison, direction = ">", "ASC"
+	}
+
+	idComparison, idDirection := ">", "ASC"
+	if backward {
+		idComparison, idDirection = "<", "DESC"
+	}
+
+	// Apply the keyset cursor
+	if len(cursor) == 2 {
+		var sortValue any
+		if options.SortByName {
+			name, err := utils.CursorString(cursor[0])
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid cursor: %v", utils.ErrInvalidInput, err)
+			}
+			sortValue = name
+		} else {
+			createdAt, err := utils.CursorTime(cursor[0])
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid cursor: %v", utils.ErrInvalidInput, err)
+			}
+			sortValue = createdAt
+		}
+
+		id, err := utils.CursorInt64(cursor[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid cursor: %v", utils.ErrInvalidInput, err)
+		}
+
+		args = append(args, sortValue, id)
+		conditions = append(conditions, fmt.Sprintf(
+			"(%[1]s %[2]s $%[3]d OR (%[1]s = $%[3]d AND id %[5]s $%[4]d))",
+			sortColumn, comparison, len(args)-1, len(args), idComparison,
+		))
+	} else if len(cursor) > 0 {
+		return nil, fmt.Errorf("%w: invalid cursor", utils.ErrInvalidInput)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var totalCount int64
+	var hasMore bool
+	var people []*models.Person
+
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM people "+countWhere, countArgs...).Scan(&totalCount); err != nil {
+			return fmt.Errorf("error counting people: %w", err)
+		}
+
+		query := fmt.Sprintf(`
+			SELECT id
+			FROM people
+			%s
+			ORDER BY %s %s, id %s
+			LIMIT %d
+		`, where, sortColumn, direction, idDirection, limit+1)
+
+		rows, err := tx.Query(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("error listing people: %w", err)
+		}
+
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("error scanning person ID: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating person IDs: %w", err)
+		}
+
+		// Determine if we have more results by checking if we have one extra row
+		hasMore = len(ids) > limit
+		if hasMore {
+			ids = ids[:limit]
+		}
+
+		if backward {
+			slices.Reverse(ids)
+		}
+
+		people = make([]*models.Person, 0, len(ids))
+		for _, id := range ids {
+			person, err := r.getByInternalIDTx(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			people = append(people, person)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keyset := func(person *models.Person) []types.FieldValue {
+		if options.SortByName {
+			return utils.KeysetCursor(person.Name, person.ID)
+		}
+		return utils.KeysetCursor(person.CreatedAt, person.ID)
+	}
+
+	var nextCursor, prevCursor []types.FieldValue
+	if len(people) > 0 {
+		nextCursor, prevCursor = utils.PageCursors(keyset(people[0]), keyset(people[len(people)-1]), hasMore, options.StartingAfter != nil, backward)
+	}
+
+	return &utils.PaginatedResult[*models.Person]{
+		Data:       people,
+		HasMore:    hasMore,
+		TotalCount: totalCount,
+		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
+	}, nil
+}
+
+// exportBatchSize is the number of rows fetched from an export cursor at a time
+const exportBatchSize = 500
+
+// ExportAll streams every person, including their sources, to fn in ID order. Rows are
+// read through a server-side cursor within a single read-only snapshot, so memory use
+// stays bounded regardless of the number of people.
+func (r *PersonRepository) ExportAll(ctx context.Context, fn func(*models.Person) error) error {
+	return r.container.Postgres.WithTxOptions(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	}, func(tx pgx.Tx) error {
+		query := `
+			DECLARE person_export NO SCROLL CURSOR FOR
+			SELECT id, uuid, name, description, active_since, active_until, created_at, updated_at
+			FROM people
+			ORDER BY id
+		`
+
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return fmt.Errorf("error declaring export cursor: %w", err)
+		}
+
+		for {
+			rows, err := tx.Query(ctx, fmt.Sprintf("FETCH %d FROM person_export", exportBatchSize))
+			if err != nil {
+				return fmt.Errorf("error fetching people: %w", err)
+			}
+
+			var batch []*models.Person
+			for rows.Next() {
+				var person models.Person
+				if err := rows.Scan(
+					&person.ID, &person.UUID, &person.Name, &person.Description,
+					&person.ActiveSince, &person.ActiveUntil, &person.CreatedAt, &person.UpdatedAt,
+				); err != nil {
+					rows.Close()
+					return fmt.Errorf("error scanning person: %w", err)
+				}
+				batch = append(batch, &person)
+			}
+			rows.Close()
+
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("error iterating people: %w", err)
+			}
+
+			if len(batch) == 0 {
+				break
+			}
+
+			for _, person := range batch {
+				if err := r.fetchPersonSources(ctx, tx, person); err != nil {
+					return fmt.Errorf("error fetching sources for person %s: %w", person.UUID, err)
+				}
+
+				if err := r.fetchPersonAliases(ctx, tx, person); err != nil {
+					return fmt.Errorf("error fetching aliases for person %s: %w", person.UUID, err)
+				}
+
+				if err := fn(person); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
 // FindImagesByPersonUUID retrieves the image UUIDs associated with a person.
 func (r *PersonRepository) FindImagesByPersonUUID(ctx context.Context, personUUID string) ([]int64, error) {
 	query := `
@@ -228,131 +480,196 @@ func (r *PersonRepository) FindImagesByPersonUUID(ctx context.Context, personUUI
 
 // Create inserts a new person record.
 func (r *PersonRepository) Create(ctx context.Context, person *models.Person) error {
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("error starting transaction: %w", err)
+	if err := validateActivePeriod(person); err != nil {
+		return err
 	}
 
-	defer func() {
-		if tx != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				log.Error().Err(err).Msg("Failed to roll back transaction")
-			}
-		}
-	}()
+	person.Name = normalizeName(r.container, person.Name)
 
-	existingPerson, err := r.getByNameTx(ctx, tx, person.Name)
-	if err != nil {
-		return fmt.Errorf("error checking for duplicate names: %w", err)
-	}
+	return r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		existingPerson, err := r.getByNameTx(ctx, tx, person.Name)
+		if err != nil {
+			return fmt.Errorf("error checking for duplicate names: %w", err)
+		}
 
-	if existingPerson != nil {
-		return &utils.ConflictError{
-			Message:      "A person with this name already exists",
-			ConflictUUID: existingPerson.UUID,
+		if existingPerson != nil {
+			return &utils.ConflictError{
+				Message:      "A person with this name already exists",
+				ConflictUUID: existingPerson.UUID,
+			}
 		}
-	}
 
-	query := `
-        INSERT INTO people (name, description)
-        VALUES ($1, $2)
+		query := `
+        INSERT INTO people (name, description, active_since, active_until)
+        VALUES ($1, $2, $3, $4)
         RETURNING id, uuid, created_at, updated_at
     `
 
-	err = tx.QueryRow(
-		ctx, query,
-		person.Name, person.Description,
-	).Scan(
-		&person.ID, &person.UUID,
-		&person.CreatedAt, &person.UpdatedAt,
-	)
+		err = tx.QueryRow(
+			ctx, query,
+			person.Name, person.Description, person.ActiveSince, person.ActiveUntil,
+		).Scan(
+			&person.ID, &person.UUID,
+			&person.CreatedAt, &person.UpdatedAt,
+		)
 
-	if err != nil {
-		return fmt.Errorf("error creating person: %w", err)
-	}
+		if err != nil {
+			return fmt.Errorf("error creating person: %w", err)
+		}
 
-	if err := r.syncSourceAssociations(ctx, tx, person, existingPerson); err != nil {
-		return fmt.Errorf("error syncing associations: %w", err)
-	}
+		if err := r.syncSourceAssociations(ctx, tx, person, existingPerson); err != nil {
+			return fmt.Errorf("error syncing associations: %w", err)
+		}
 
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("error committing transaction: %w", err)
-	}
+		if err := r.syncPersonAliases(ctx, tx, person, existingPerson); err != nil {
+			return fmt.Errorf("error syncing aliases: %w", err)
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // Update updates an existing person record.
 func (r *PersonRepository) Update(ctx context.Context, person *models.Person) error {
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("error starting transaction: %w", err)
+	if err := validateActivePeriod(person); err != nil {
+		return err
 	}
 
-	defer func() {
-		if tx != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				log.Error().Err(err).Msg("Failed to roll back transaction")
-			}
+	person.Name = normalizeName(r.container, person.Name)
+
+	return r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		existingPerson, err := r.getByNameTx(ctx, tx, person.Name)
+		if err != nil && !errors.Is(err, utils.ErrPersonNotFound) {
+			return fmt.Errorf("error checking for duplicate name: %w", err)
 		}
-	}()
 
-	existingPerson, err := r.getByNameTx(ctx, tx, person.Name)
-	if err != nil && !errors.Is(err, utils.ErrPersonNotFound) {
-		return fmt.Errorf("error checking for duplicate name: %w", err)
-	}
+		if existingPerson != nil && existingPerson.UUID != person.UUID {
+			return &utils.ConflictError{
+				Message:      "A person with this name already exists",
+				ConflictUUID: existingPerson.UUID,
+			}
+		}
 
-	if existingPerson != nil && existingPerson.UUID != person.UUID {
-		return &utils.ConflictError{
-			Message:      "A person with this name already exists",
-			ConflictUUID: existingPerson.UUID,
+		if person.ID > 0 {
+			existingPerson, err = r.getByInternalIDTx(ctx, tx, person.ID)
+		} else {
+			existingPerson, err = r.getByUUIDTx(ctx, tx, person.UUID)
 		}
-	}
 
-	if person.ID > 0 {
-		existingPerson, err = r.getByInternalIDTx(ctx, tx, person.ID)
-	} else {
-		existingPerson, err = r.getByUUIDTx(ctx, tx, person.UUID)
-	}
+		if err != nil {
+			return fmt.Errorf("error retrieving person: %w", err)
+		}
 
-	if err != nil {
-		return fmt.Errorf("error retrieving person: %w", err)
-	}
+		primaryImageID, err := r.resolvePrimaryImageTx(ctx, tx, existingPerson, person.PrimaryImageUUID)
+		if err != nil {
+			return err
+		}
 
-	query := `
+		query := `
         UPDATE people SET
             name = $1,
-            description = $2
-        WHERE id = $3
+            description = $2,
+            active_since = $3,
+            active_until = $4,
+            primary_image_id = $5
+        WHERE id = $6
         RETURNING id, uuid, created_at, updated_at
     `
 
-	err = tx.QueryRow(
-		ctx, query,
-		person.Name, person.Description,
-		existingPerson.ID,
-	).Scan(
-		&person.ID, &person.UUID,
-		&person.CreatedAt, &person.UpdatedAt,
-	)
+		err = tx.QueryRow(
+			ctx, query,
+			person.Name, person.Description, person.ActiveSince, person.ActiveUntil,
+			primaryImageID, existingPerson.ID,
+		).Scan(
+			&person.ID, &person.UUID,
+			&person.CreatedAt, &person.UpdatedAt,
+		)
 
-	if err != nil {
-		return fmt.Errorf("error updating person: %w", err)
+		if err != nil {
+			return fmt.Errorf("error updating person: %w", err)
+		}
+
+		if err := r.syncSourceAssociations(ctx, tx, person, existingPerson); err != nil {
+			return fmt.Errorf("error syncing associations: %w", err)
+		}
+
+		if err := r.syncPersonAliases(ctx, tx, person, existingPerson); err != nil {
+			return fmt.Errorf("error syncing aliases: %w", err)
+		}
+
+		return r.fetchPersonPrimaryImage(ctx, tx, person)
+	})
+}
+
+// resolvePrimaryImageTx resolves the internal ID of a person's chosen primary image,
+// rejecting newly chosen images the person is not associated with
+func (r *PersonRepository) resolvePrimaryImageTx(ctx context.Context, tx pgx.Tx, existingPerson *models.Person, imageUUID *string) (*int64, error) {
+	if imageUUID == nil {
+		return nil, nil
+	}
+
+	query := `
+		SELECT i.id, EXISTS (
+			SELECT 1 FROM image_people ip
+			WHERE ip.image_id = i.id AND ip.person_id = $2
+		)
+		FROM images i
+		WHERE i.uuid = $1
+	`
+
+	var imageID int64
+	var associated bool
+	if err := tx.QueryRow(ctx, query, *imageUUID, existingPerson.ID).Scan(&imageID, &associated); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: primary image %s does not exist", utils.ErrInvalidInput, *imageUUID)
+		}
+		return nil, fmt.Errorf("error resolving primary image: %w", err)
 	}
 
-	if err := r.syncSourceAssociations(ctx, tx, person, existingPerson); err != nil {
-		return fmt.Errorf("error syncing associations: %w", err)
+	// An unchanged choice is kept even if the association has since been removed,
+	// so that unrelated updates do not fail
+	unchanged := existingPerson.PrimaryImageUUID != nil && strings.EqualFold(*existingPerson.PrimaryImageUUID, *imageUUID)
+	if !associated && !unchanged {
+		return nil, fmt.Errorf("%w: primary image %s is not associated with this person", utils.ErrInvalidInput, *imageUUID)
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("error committing transaction: %w", err)
+	return &imageID, nil
+}
+
+// fetchPersonPrimaryImage resolves the image shown for a person, preferring the chosen
+// primary image while it is still associated and otherwise falling back to the most
+// recently associated image
+func (r *PersonRepository) fetchPersonPrimaryImage(ctx context.Context, tx pgx.Tx, person *models.Person) error {
+	query := `
+		SELECT i.uuid, i.format
+		FROM image_people ip
+		JOIN people p ON p.id = ip.person_id
+		JOIN images i ON i.id = ip.image_id
+		WHERE ip.person_id = $1
+		ORDER BY ip.image_id IS NOT DISTINCT FROM p.primary_image_id DESC, ip.created_at DESC, ip.image_id DESC
+		LIMIT 1
+	`
+
+	var image models.PersonPrimaryImage
+	err := tx.QueryRow(ctx, query, person.ID).Scan(&image.UUID, &image.Format)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			person.PrimaryImage = nil
+			return nil
+		}
+		return fmt.Errorf("error fetching primary image: %w", err)
 	}
 
+	person.PrimaryImage = &image
 	return nil
 }
 
+// GetPreviewImages retrieves up to limit of the most recently associated images for each
+// of the given people, keyed by internal person ID
+func (r *PersonRepository) GetPreviewImages(ctx context.Context, personIDs []int64, limit int) (map[int64][]*models.ImagePreview, error) {
+	return fetchImagePreviews(ctx, r.container, "image_people", "person_id", personIDs, limit)
+}
+
 // fetchPersonSources retrieves all sources associated with a person
 func (r *PersonRepository) fetchPersonSources(ctx context.Context, tx pgx.Tx, person *models.Person) error {
 	query := `
@@ -390,6 +707,97 @@ func (r *PersonRepository) fetchPersonSources(ctx context.Context, tx pgx.Tx, pe
 	return nil
 }
 
+// fetchPersonAliases retrieves all aliases associated with a person
+func (r *PersonRepository) fetchPersonAliases(ctx context.Context, tx pgx.Tx, person *models.Person) error {
+	query := `
+		SELECT alias
+		FROM person_aliases
+		WHERE person_id = $1
+		ORDER BY alias;
+	`
+
+	rows, err := tx.Query(ctx, query, person.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var aliases []string
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return err
+		}
+
+		aliases = append(aliases, alias)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	person.Aliases = aliases
+	return nil
+}
+
+// syncPersonAliases synchronizes the alias rows of a person with its model
+func (r *PersonRepository) syncPersonAliases(ctx context.Context, tx pgx.Tx, person *models.Person, existingPerson *models.Person) error {
+	// Create map to track existing aliases
+	existingAliases := make(map[string]bool)
+
+	if existingPerson != nil {
+		for _, alias := range existingPerson.Aliases {
+			existingAliases[alias] = true
+		}
+	}
+
+	// Map to track aliases we need to retain
+	aliasesToKeep := make(map[string]bool)
+
+	// Process each alias in the input model
+	updatedAliases := make([]string, 0, len(person.Aliases))
+
+	for _, alias := range person.Aliases {
+		alias = normalizeName(r.container, strings.TrimSpace(alias))
+		if alias == "" || alias == person.Name || aliasesToKeep[alias] {
+			continue
+		}
+
+		aliasesToKeep[alias] = true
+		updatedAliases = append(updatedAliases, alias)
+
+		if !existingAliases[alias] {
+			query := `INSERT INTO person_aliases (person_id, alias) VALUES ($1, $2)`
+			if _, err := tx.Exec(ctx, query, person.ID, alias); err != nil {
+				return fmt.Errorf("error creating alias: %w", err)
+			}
+		}
+	}
+
+	// Remove aliases no longer present
+	for alias := range existingAliases {
+		if !aliasesToKeep[alias] {
+			query := `DELETE FROM person_aliases WHERE person_id = $1 AND alias = $2`
+			if _, err := tx.Exec(ctx, query, person.ID, alias); err != nil {
+				return fmt.Errorf("error removing alias: %w", err)
+			}
+		}
+	}
+
+	// Update the person's aliases collection
+	person.Aliases = updatedAliases
+
+	return nil
+}
+
+// validateActivePeriod ensures a person's active period does not end before it starts
+func validateActivePeriod(person *models.Person) error {
+	if person.ActiveSince != nil && person.ActiveUntil != nil && person.ActiveSince.After(*person.ActiveUntil) {
+		return fmt.Errorf("%w: active_since must not be after active_until", utils.ErrInvalidInput)
+	}
+	return nil
+}
+
 // syncSourceAssociations synchronizes source associations for a person
 func (r *PersonRepository) syncSourceAssociations(ctx context.Context, tx pgx.Tx, person *models.Person, existingPerson *models.Person) error {
 	// Create map to track existing sources
@@ -481,32 +889,16 @@ func (r *PersonRepository) syncSourceAssociations(ctx context.Context, tx pgx.Tx
 }
 
 func (r *PersonRepository) Delete(ctx context.Context, uuid string) error {
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("error starting transaction: %w", err)
-	}
-
-	defer func() {
-		if tx != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				log.Error().Err(err).Msg("Failed to roll back transaction")
-			}
+	return r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, "DELETE FROM people WHERE uuid = $1", uuid)
+		if err != nil {
+			return fmt.Errorf("error deleting person: %w", err)
 		}
-	}()
 
-	result, err := tx.Exec(ctx, "DELETE FROM people WHERE uuid = $1", uuid)
-	if err != nil {
-		return fmt.Errorf("error deleting person: %w", err)
-	}
-
-	if result.RowsAffected() == 0 {
-		return utils.ErrPersonNotFound
-	}
-
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("error committing deletion: %w", err)
-	}
+		if result.RowsAffected() == 0 {
+			return utils.ErrPersonNotFound
+		}
 
-	return nil
+		return nil
+	})
 }
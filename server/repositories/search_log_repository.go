@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/foresturquhart/curator/server/container"
+	"github.com/foresturquhart/curator/server/models"
+	"github.com/foresturquhart/curator/server/telemetry"
+)
+
+type SearchLogRepository struct {
+	container *container.Container
+}
+
+func NewSearchLogRepository(container *container.Container) *SearchLogRepository {
+	return &SearchLogRepository{
+		container: container,
+	}
+}
+
+// Record stores a search in the query log
+func (r *SearchLogRepository) Record(ctx context.Context, entry *models.SearchLogEntry) error {
+	ctx, span := telemetry.StartSpan(ctx, "SearchLogRepository.Record")
+	defer span.End()
+
+	filters := entry.Filters
+	if filters == nil {
+		filters = map[string]any{}
+	}
+
+	_, err := r.container.Postgres.Pool.Exec(ctx, `
+		INSERT INTO search_queries (query, filters, result_count, latency_ms, partial)
+		VALUES ($1, $2, $3, $4, $5)
+	`, entry.Query, filters, entry.ResultCount, entry.Latency.Milliseconds(), entry.Partial)
+	if err != nil {
+		return fmt.Errorf("error recording search: %w", err)
+	}
+
+	return nil
+}
+
+// TopQueries returns the most frequently searched terms since the given time. Searches
+// made without free-text terms are not included.
+func (r *SearchLogRepository) TopQueries(ctx context.Context, since time.Time, limit int) ([]*models.TopSearchQuery, error) {
+	ctx, span := telemetry.StartSpan(ctx, "SearchLogRepository.TopQueries")
+	defer span.End()
+
+	rows, err := r.container.Postgres.Pool.Query(ctx, `
+		SELECT
+			query,
+			COUNT(*) AS count,
+			AVG(result_count)::float8 AS avg_results,
+			COUNT(*) FILTER (WHERE result_count = 0) AS zero_results,
+			MAX(created_at) AS last_searched_at
+		FROM search_queries
+		WHERE created_at >= $1 AND query <> ''
+		GROUP BY query
+		ORDER BY count DESC, last_searched_at DESC
+		LIMIT $2
+	`, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying top searches: %w", err)
+	}
+	defer rows.Close()
+
+	queries := []*models.TopSearchQuery{}
+	for rows.Next() {
+		var query models.TopSearchQuery
+		if err := rows.Scan(&query.Query, &query.Count, &query.AvgResults, &query.ZeroResults, &query.LastSearchedAt); err != nil {
+			return nil, fmt.Errorf("error scanning top search row: %w", err)
+		}
+		queries = append(queries, &query)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating top search rows: %w", err)
+	}
+
+	return queries, nil
+}
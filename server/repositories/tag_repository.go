@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/foresturquhart/curator/server/container"
 	"github.com/foresturquhart/curator/server/models"
@@ -83,59 +84,45 @@ func (r *TagRepository) getByUUIDTx(ctx context.Context, tx pgx.Tx, uuid string)
 }
 
 func (r *TagRepository) GetByInternalID(ctx context.Context, id int64) (*models.Tag, error) {
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("error starting transaction: %w", err)
-	}
-
-	defer func() {
-		if tx != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				log.Error().Err(err).Msg("Failed to roll back transaction")
-			}
-		}
-	}()
-
-	tag, err := r.getByInternalIDTx(ctx, tx, id)
+	var tag *models.Tag
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		tag, err = r.getByInternalIDTx(ctx, tx, id)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	err = tx.Commit(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("error committing transaction: %w", err)
-	}
-
 	return tag, nil
-
 }
 
 func (r *TagRepository) GetByUUID(ctx context.Context, uuid string) (*models.Tag, error) {
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
+	var tag *models.Tag
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		tag, err = r.getByUUIDTx(ctx, tx, uuid)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error starting transaction: %w", err)
+		return nil, err
 	}
 
-	defer func() {
-		if tx != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				log.Error().Err(err).Msg("Failed to roll back transaction")
-			}
-		}
-	}()
+	return tag, nil
+}
 
-	tag, err := r.getByUUIDTx(ctx, tx, uuid)
+// GetByName retrieves a tag by its unique name
+func (r *TagRepository) GetByName(ctx context.Context, name string) (*models.Tag, error) {
+	var tag *models.Tag
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		tag, err = r.getByNameTx(ctx, tx, name)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	err = tx.Commit(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("error committing transaction: %w", err)
-	}
-
 	return tag, nil
 }
 
@@ -161,18 +148,111 @@ func (r *TagRepository) GetAllIDs(ctx context.Context) ([]int64, error) {
 	return tagIDs, nil
 }
 
+// ExportAll streams every tag to fn with parents always preceding their children, and
+// siblings in position order, so the hierarchy can be rebuilt by replaying the records
+// in sequence. Rows are read through a server-side cursor within a single read-only
+// snapshot.
+func (r *TagRepository) ExportAll(ctx context.Context, fn func(*models.TagExportRecord) error) error {
+	return r.container.Postgres.WithTxOptions(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	}, func(tx pgx.Tx) error {
+		query := `
+			DECLARE tag_export NO SCROLL CURSOR FOR
+			WITH RECURSIVE tree AS (
+				SELECT t.id, t.uuid, t.name, t.description, NULL::uuid AS parent_uuid, t.position,
+					t.created_at, t.updated_at, ARRAY[t.position] AS path
+				FROM tags t
+				WHERE t.parent_id IS NULL
+				UNION ALL
+				SELECT t.id, t.uuid, t.name, t.description, tree.uuid, t.position,
+					t.created_at, t.updated_at, tree.path || t.position
+				FROM tags t
+				INNER JOIN tree ON t.parent_id = tree.id
+			)
+			SELECT uuid, name, description, parent_uuid, position, created_at, updated_at
+			FROM tree
+			ORDER BY array_length(path, 1), path
+		`
+
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return fmt.Errorf("error declaring export cursor: %w", err)
+		}
+
+		for {
+			rows, err := tx.Query(ctx, fmt.Sprintf("FETCH %d FROM tag_export", exportBatchSize))
+			if err != nil {
+				return fmt.Errorf("error fetching tags: %w", err)
+			}
+
+			var batch []*models.TagExportRecord
+			for rows.Next() {
+				var record models.TagExportRecord
+				if err := rows.Scan(
+					&record.UUID, &record.Name, &record.Description, &record.ParentUUID,
+					&record.Position, &record.CreatedAt, &record.UpdatedAt,
+				); err != nil {
+					rows.Close()
+					return fmt.Errorf("error scanning tag: %w", err)
+				}
+				batch = append(batch, &record)
+			}
+			rows.Close()
+
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("error iterating tags: %w", err)
+			}
+
+			if len(batch) == 0 {
+				break
+			}
+
+			for _, record := range batch {
+				if err := fn(record); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// normalizeName applies the configured Unicode normalisation to a tag or person name
+func normalizeName(container *container.Container, name string) string {
+	return utils.NormalizeName(name, container.Config.NameNormalization)
+}
+
+// nameCondition builds the condition matching a name column against the first query
+// parameter, ignoring case when configured to
+func nameCondition(container *container.Container, column string) string {
+	if container.Config.NameCaseInsensitive {
+		return fmt.Sprintf("LOWER(%s) = LOWER($1)", column)
+	}
+	return column + " = $1"
+}
+
+// nameConflictTarget is the ON CONFLICT target matching the unique index on names, which
+// ignores case when names are matched ignoring case
+func nameConflictTarget(container *container.Container) string {
+	if container.Config.NameCaseInsensitive {
+		return "(LOWER(name))"
+	}
+	return "(name)"
+}
+
 func (r *TagRepository) getByNameTx(ctx context.Context, tx pgx.Tx, name string) (*models.Tag, error) {
 	query := `
 		SELECT id, uuid, name, description, parent_id, position, created_at, updated_at
         FROM tags
-        WHERE name = $1
+        WHERE ` + nameCondition(r.container, "name") + `
     `
 
 	var tag models.Tag
 	var descriptionPtr *string
 	var parentIDPtr *int64
 
-	err := tx.QueryRow(ctx, query, name).Scan(
+	err := tx.QueryRow(ctx, query, normalizeName(r.container, name)).Scan(
 		&tag.ID, &tag.UUID, &tag.Name,
 		&descriptionPtr, &parentIDPtr,
 		&tag.Position, &tag.CreatedAt, &tag.UpdatedAt,
@@ -191,16 +271,21 @@ func (r *TagRepository) getByNameTx(ctx context.Context, tx pgx.Tx, name string)
 	return &tag, nil
 }
 
+// tagDescendantsCTE expands the tag with ID $1 into itself and every tag below it, as
+// the descendants relation
+const tagDescendantsCTE = `
+	WITH RECURSIVE descendants AS (
+		SELECT id FROM tags WHERE id = $1
+		UNION ALL
+		SELECT t.id FROM tags t
+		INNER JOIN descendants d ON t.parent_id = d.id
+	)
+`
+
 func (r *TagRepository) getAffectedImagesTx(ctx context.Context, tx pgx.Tx, tagID int64) ([]int64, error) {
 	var results []int64
 
-	query := `
-		WITH RECURSIVE descendants AS (
-			SELECT id FROM tags WHERE id = $1
-			UNION ALL
-			SELECT t.id FROM tags t
-			INNER JOIN descendants d ON t.parent_id = d.id
-		)
+	query := tagDescendantsCTE + `
 		SELECT DISTINCT image_id FROM image_tags WHERE tag_id IN (SELECT id FROM descendants)
 	`
 
@@ -225,6 +310,85 @@ func (r *TagRepository) getAffectedImagesTx(ctx context.Context, tx pgx.Tx, tagI
 	return results, nil
 }
 
+// tagAncestorsTx returns the given tags together with all of their ancestors
+func tagAncestorsTx(ctx context.Context, tx pgx.Tx, tagIDs []int64) ([]int64, error) {
+	rows, err := tx.Query(ctx, "SELECT DISTINCT ancestor FROM tag_closure WHERE descendant = ANY($1)", tagIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving tag ancestors: %w", err)
+	}
+
+	ancestors, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("error resolving tag ancestors: %w", err)
+	}
+
+	return ancestors, nil
+}
+
+// enqueueTagReindex queues reindexing of tags whose image counts changed, skipping those
+// given as deleted. Failures are logged, as the change has already been committed.
+func enqueueTagReindex(ctx context.Context, c *container.Container, tagIDs []int64, deleted ...int64) {
+	seen := make(map[int64]bool, len(tagIDs)+len(deleted))
+	for _, id := range deleted {
+		seen[id] = true
+	}
+
+	for _, id := range tagIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if err := c.Worker.EnqueueReindexTag(ctx, id); err != nil {
+			log.Error().Err(err).Int64("id", id).Msg("Failed to queue reindex of tag")
+		}
+	}
+}
+
+// GetImageCounts counts the images tagged directly with a tag, and those tagged with it
+// or any of its descendants. The subtree is read from the closure table, as CountImages
+// does, so that the two always agree.
+func (r *TagRepository) GetImageCounts(ctx context.Context, tagID int64) (*models.TagImageCounts, error) {
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE it.tag_id = $1),
+			COUNT(DISTINCT it.image_id)
+		FROM tag_closure tc
+		INNER JOIN image_tags it ON it.tag_id = tc.descendant
+		WHERE tc.ancestor = $1
+	`
+
+	var counts models.TagImageCounts
+	if err := r.container.Postgres.Pool.QueryRow(ctx, query, tagID).Scan(&counts.Direct, &counts.Subtree); err != nil {
+		return nil, fmt.Errorf("error counting tag images: %w", err)
+	}
+
+	return &counts, nil
+}
+
+// CountImages counts the distinct images tagged with a tag or any of its descendants
+func (r *TagRepository) CountImages(ctx context.Context, tagID int64) (int64, error) {
+	query := `
+		SELECT COUNT(DISTINCT it.image_id)
+		FROM tag_closure tc
+		INNER JOIN image_tags it ON it.tag_id = tc.descendant
+		WHERE tc.ancestor = $1
+	`
+
+	var count int64
+	if err := r.container.Postgres.Pool.QueryRow(ctx, query, tagID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting tag images: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetPreviewImages retrieves up to limit of the most recently tagged images for each of
+// the given tags, keyed by internal tag ID. Only direct associations are considered.
+func (r *TagRepository) GetPreviewImages(ctx context.Context, tagIDs []int64, limit int) (map[int64][]*models.ImagePreview, error) {
+	return fetchImagePreviews(ctx, r.container, "image_tags", "tag_id", tagIDs, limit)
+}
+
 type TagHierarchyAction int
 
 const (
@@ -241,43 +405,34 @@ type TagUpdateOptions struct {
 }
 
 func (r *TagRepository) Update(ctx context.Context, tag *models.Tag, opts *TagUpdateOptions) ([]int64, error) {
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("error starting transaction: %w", err)
-	}
+	tag.Name = normalizeName(r.container, tag.Name)
 
-	defer func() {
-		if tx != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				log.Error().Err(rollbackErr).Msg("Failed to roll back transaction")
-			}
+	var affectedImages []int64
+	var countedTags []int64
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		existingTag, err := r.getByNameTx(ctx, tx, tag.Name)
+		if err != nil && !errors.Is(err, utils.ErrTagNotFound) {
+			return fmt.Errorf("error checking for duplicate name: %w", err)
 		}
-	}()
-
-	existingTag, err := r.getByNameTx(ctx, tx, tag.Name)
-	if err != nil && !errors.Is(err, utils.ErrTagNotFound) {
-		return nil, fmt.Errorf("error checking for duplicate name: %w", err)
-	}
 
-	if existingTag != nil && existingTag.UUID != tag.UUID {
-		return nil, &utils.ConflictError{
-			Message:      "A tag with this name already exists",
-			ConflictUUID: existingTag.UUID,
+		if existingTag != nil && existingTag.UUID != tag.UUID {
+			return &utils.ConflictError{
+				Message:      "A tag with this name already exists",
+				ConflictUUID: existingTag.UUID,
+			}
 		}
-	}
 
-	if tag.ID > 0 {
-		existingTag, err = r.getByInternalIDTx(ctx, tx, tag.ID)
-	} else {
-		existingTag, err = r.getByUUIDTx(ctx, tx, tag.UUID)
-	}
+		if tag.ID > 0 {
+			existingTag, err = r.getByInternalIDTx(ctx, tx, tag.ID)
+		} else {
+			existingTag, err = r.getByUUIDTx(ctx, tx, tag.UUID)
+		}
 
-	if err != nil {
-		return nil, fmt.Errorf("error retrieving tag: %w", err)
-	}
+		if err != nil {
+			return fmt.Errorf("error retrieving tag: %w", err)
+		}
 
-	query := `
+		query := `
         UPDATE tags SET
             name = $1,
             description = $2
@@ -285,198 +440,482 @@ func (r *TagRepository) Update(ctx context.Context, tag *models.Tag, opts *TagUp
         RETURNING id, uuid, name, description, parent_id, position, created_at, updated_at
     `
 
-	err = tx.QueryRow(
-		ctx, query,
-		tag.Name, tag.Description, existingTag.ID,
-	).Scan(
-		&tag.ID, &tag.UUID, &tag.Name, &tag.Description, &tag.ParentID, &tag.Position, &tag.CreatedAt, &tag.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, fmt.Errorf("error updating tag: %w", err)
-	}
-
-	if opts != nil && opts.Action != TagHierarchyNone {
-		if opts.Action == TagHierarchyRoot {
-			query := `
-				SELECT parent_id, position, updated_at
-				FROM move_tag_inside($1, NULL)
-			`
+		err = tx.QueryRow(
+			ctx, query,
+			tag.Name, tag.Description, existingTag.ID,
+		).Scan(
+			&tag.ID, &tag.UUID, &tag.Name, &tag.Description, &tag.ParentID, &tag.Position, &tag.CreatedAt, &tag.UpdatedAt,
+		)
 
-			err = tx.QueryRow(
-				ctx, query,
-				existingTag.ID,
-			).Scan(
-				&tag.ParentID, &tag.Position, &tag.UpdatedAt,
-			)
+		if err != nil {
+			return fmt.Errorf("error updating tag: %w", err)
+		}
 
+		moved := opts != nil && opts.Action != TagHierarchyNone
+		if moved {
+			// The image counts of the ancestors the tag leaves change with the move
+			countedTags, err = tagAncestorsTx(ctx, tx, []int64{existingTag.ID})
 			if err != nil {
-				return nil, fmt.Errorf("error moving tag to root: %w", err)
-			}
-		} else {
-			targetTag, err := r.getByInternalIDTx(ctx, tx, *opts.TargetID)
-			if err != nil && !errors.Is(err, utils.ErrTagNotFound) {
-				return nil, fmt.Errorf("error retrieving target tag: %w", err)
+				return err
 			}
 
-			if opts.Action == TagHierarchyInside && *existingTag.ParentID != targetTag.ID {
+			if opts.Action == TagHierarchyRoot {
 				query := `
 					SELECT parent_id, position, updated_at
-					FROM move_tag_inside($1, $2)
+					FROM move_tag_inside($1, NULL)
 				`
 
 				err = tx.QueryRow(
 					ctx, query,
-					existingTag.ID, targetTag.ID,
+					existingTag.ID,
 				).Scan(
 					&tag.ParentID, &tag.Position, &tag.UpdatedAt,
 				)
 
 				if err != nil {
-					return nil, fmt.Errorf("error moving tag inside target: %w", err)
+					return fmt.Errorf("error moving tag to root: %w", err)
+				}
+			} else {
+				if opts.TargetID == nil {
+					return fmt.Errorf("%w: a target tag is required for this action", utils.ErrInvalidInput)
 				}
-			} else if opts.Action == TagHierarchyBefore {
-				query := `
-					SELECT parent_id, position, updated_at
-					FROM move_tag_before($1, $2)
-				`
-
-				err = tx.QueryRow(
-					ctx, query,
-					existingTag.ID, targetTag.ID,
-				).Scan(
-					&tag.ParentID, &tag.Position, &tag.UpdatedAt,
-				)
 
+				// The target may have been deleted since the request resolved it
+				targetTag, err := r.getByInternalIDTx(ctx, tx, *opts.TargetID)
+				if errors.Is(err, utils.ErrTagNotFound) {
+					return fmt.Errorf("%w: target tag not found", utils.ErrInvalidInput)
+				}
 				if err != nil {
-					return nil, fmt.Errorf("error moving tag before target: %w", err)
+					return fmt.Errorf("error retrieving target tag: %w", err)
 				}
-			} else if opts.Action == TagHierarchyAfter {
-				query := `
-					SELECT parent_id, position, updated_at
-					FROM move_tag_after($1, $2)
-				`
-
-				err = tx.QueryRow(
-					ctx, query,
-					existingTag.ID, targetTag.ID,
-				).Scan(
-					&tag.ParentID, &tag.Position, &tag.UpdatedAt,
-				)
 
+				// Moving inside, before or after a tag within the moved subtree would detach
+				// the subtree from the hierarchy as a cycle
+				cyclic, err := r.isWithinSubtreeTx(ctx, tx, existingTag.ID, targetTag.ID)
 				if err != nil {
-					return nil, fmt.Errorf("error moving tag after target: %w", err)
+					return err
+				}
+				if cyclic {
+					return fmt.Errorf("%w: tag %s cannot be moved relative to itself or one of its descendants", utils.ErrInvalidInput, existingTag.UUID)
+				}
+
+				alreadyInside := existingTag.ParentID != nil && *existingTag.ParentID == targetTag.ID
+				if opts.Action == TagHierarchyInside && !alreadyInside {
+					query := `
+						SELECT parent_id, position, updated_at
+						FROM move_tag_inside($1, $2)
+					`
+
+					err = tx.QueryRow(
+						ctx, query,
+						existingTag.ID, targetTag.ID,
+					).Scan(
+						&tag.ParentID, &tag.Position, &tag.UpdatedAt,
+					)
+
+					if err != nil {
+						return fmt.Errorf("error moving tag inside target: %w", err)
+					}
+				} else if opts.Action == TagHierarchyBefore {
+					query := `
+						SELECT parent_id, position, updated_at
+						FROM move_tag_before($1, $2)
+					`
+
+					err = tx.QueryRow(
+						ctx, query,
+						existingTag.ID, targetTag.ID,
+					).Scan(
+						&tag.ParentID, &tag.Position, &tag.UpdatedAt,
+					)
+
+					if err != nil {
+						return fmt.Errorf("error moving tag before target: %w", err)
+					}
+				} else if opts.Action == TagHierarchyAfter {
+					query := `
+						SELECT parent_id, position, updated_at
+						FROM move_tag_after($1, $2)
+					`
+
+					err = tx.QueryRow(
+						ctx, query,
+						existingTag.ID, targetTag.ID,
+					).Scan(
+						&tag.ParentID, &tag.Position, &tag.UpdatedAt,
+					)
+
+					if err != nil {
+						return fmt.Errorf("error moving tag after target: %w", err)
+					}
 				}
 			}
 		}
-	}
 
-	affectedImages, err := r.getAffectedImagesTx(ctx, tx, existingTag.ID)
+		affectedImages, err = r.getAffectedImagesTx(ctx, tx, existingTag.ID)
+		if err != nil {
+			return fmt.Errorf("error calculating affected images: %w", err)
+		}
+
+		// As do those of the ancestors it joins, unless no image carries the subtree
+		if len(affectedImages) == 0 {
+			countedTags = nil
+		} else if moved {
+			joined, err := tagAncestorsTx(ctx, tx, []int64{existingTag.ID})
+			if err != nil {
+				return err
+			}
+			countedTags = append(countedTags, joined...)
+		}
+
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error calculating affected images: %w", err)
+		return nil, err
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return nil, fmt.Errorf("error committing transaction: %w", err)
-	}
+	enqueueTagReindex(ctx, r.container, countedTags)
 
 	return affectedImages, nil
 }
 
-type TagCreateOptions struct {
-	Action   TagHierarchyAction
-	TargetID *int64
+// TagBulkMoveResult describes the tags moved by a bulk move
+type TagBulkMoveResult struct {
+	Tags              []*models.Tag // Moved tags, with their new parent and position
+	PreviousParentIDs []*int64      // Parent of each moved tag before the move
+	AffectedImages    []int64       // Distinct images tagged anywhere within the moved subtrees
 }
 
-func (r *TagRepository) Create(ctx context.Context, tag *models.Tag, opts TagCreateOptions) error {
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("error starting transaction: %w", err)
+// BulkMove moves several tags inside a new parent, or to the root when no parent is
+// given, in a single transaction. The tags keep the order they were given in, ahead of
+// the parent's existing children. A move that would place a tag inside its own subtree
+// rejects the whole operation.
+func (r *TagRepository) BulkMove(ctx context.Context, tagUUIDs []string, parentUUID *string) (*TagBulkMoveResult, error) {
+	result := &TagBulkMoveResult{
+		Tags:              make([]*models.Tag, len(tagUUIDs)),
+		PreviousParentIDs: make([]*int64, len(tagUUIDs)),
 	}
 
-	defer func() {
-		if tx != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				log.Error().Err(rollbackErr).Msg("Failed to roll back transaction")
+	var countedTags []int64
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		var parentID *int64
+		if parentUUID != nil {
+			parent, err := r.getByUUIDTx(ctx, tx, *parentUUID)
+			if err != nil {
+				return fmt.Errorf("error retrieving target tag: %w", err)
 			}
+			parentID = &parent.ID
 		}
-	}()
 
-	if opts.Action == TagHierarchyRoot {
-		query := `
-			SELECT id, uuid, name, description, parent_id, position, created_at, updated_at
-			FROM insert_tag_inside(NULL, $1, $2)
-		`
+		// Each tag is moved to the front of the parent's children, so moving them in
+		// reverse leaves them in the order given
+		affected := make(map[int64]bool)
+		var tagIDs []int64
+		for i := len(tagUUIDs) - 1; i >= 0; i-- {
+			tag, err := r.getByUUIDTx(ctx, tx, tagUUIDs[i])
+			if err != nil {
+				return fmt.Errorf("error retrieving tag %s: %w", tagUUIDs[i], err)
+			}
 
-		err = tx.QueryRow(
-			ctx, query,
-			tag.Name, tag.Description,
-		).Scan(
-			&tag.ID, &tag.UUID,
-			&tag.Name, &tag.Description,
-			&tag.ParentID, &tag.Position,
-			&tag.CreatedAt, &tag.UpdatedAt,
-		)
+			if parentID != nil {
+				cyclic, err := r.isWithinSubtreeTx(ctx, tx, tag.ID, *parentID)
+				if err != nil {
+					return err
+				}
+				if cyclic {
+					return fmt.Errorf("%w: tag %s cannot be moved inside itself or one of its descendants", utils.ErrInvalidInput, tag.UUID)
+				}
+			}
 
-		if err != nil {
-			return fmt.Errorf("error creating root tag: %w", err)
-		}
-	} else {
-		targetTag, err := r.getByInternalIDTx(ctx, tx, *opts.TargetID)
-		if err != nil && !errors.Is(err, utils.ErrTagNotFound) {
-			return fmt.Errorf("error retrieving target tag: %w", err)
-		}
+			// Copy the previous parent, as scanning the move result may reuse the pointer
+			if tag.ParentID != nil {
+				previous := *tag.ParentID
+				result.PreviousParentIDs[i] = &previous
+			}
+
+			// The image counts of the ancestors the tag leaves change with the move
+			left, err := tagAncestorsTx(ctx, tx, []int64{tag.ID})
+			if err != nil {
+				return err
+			}
+			countedTags = append(countedTags, left...)
+			tagIDs = append(tagIDs, tag.ID)
 
-		if opts.Action == TagHierarchyInside {
 			query := `
-				SELECT id, uuid, name, description, parent_id, position, created_at, updated_at
-				FROM insert_tag_inside($1, $2, $3)
+				SELECT parent_id, position, updated_at
+				FROM move_tag_inside($1, $2)
 			`
 
-			err = tx.QueryRow(
-				ctx, query,
-				targetTag.ID,
-				tag.Name, tag.Description,
-			).Scan(
-				&tag.ID, &tag.UUID,
-				&tag.Name, &tag.Description,
-				&tag.ParentID, &tag.Position,
-				&tag.CreatedAt, &tag.UpdatedAt,
-			)
+			err = tx.QueryRow(ctx, query, tag.ID, parentID).Scan(&tag.ParentID, &tag.Position, &tag.UpdatedAt)
+			if err != nil {
+				return fmt.Errorf("error moving tag %s: %w", tag.UUID, err)
+			}
+			result.Tags[i] = tag
 
+			images, err := r.getAffectedImagesTx(ctx, tx, tag.ID)
 			if err != nil {
-				return fmt.Errorf("error creating root tag: %w", err)
+				return fmt.Errorf("error calculating affected images: %w", err)
 			}
-		} else if opts.Action == TagHierarchyBefore {
-			query := `
-				SELECT id, uuid, name, description, parent_id, position, created_at, updated_at
-				FROM insert_tag_before($1, $2, $3)
-			`
+			for _, image := range images {
+				if !affected[image] {
+					affected[image] = true
+					result.AffectedImages = append(result.AffectedImages, image)
+				}
+			}
+		}
 
-			err = tx.QueryRow(
-				ctx, query,
-				targetTag.ID,
-				tag.Name, tag.Description,
-			).Scan(
-				&tag.ID, &tag.UUID,
-				&tag.Name, &tag.Description,
-				&tag.ParentID, &tag.Position,
-				&tag.CreatedAt, &tag.UpdatedAt,
+		// As do those of the ancestors they join, unless no image carries the subtrees
+		if len(result.AffectedImages) == 0 {
+			countedTags = nil
+			return nil
+		}
+
+		joined, err := tagAncestorsTx(ctx, tx, tagIDs)
+		if err != nil {
+			return err
+		}
+		countedTags = append(countedTags, joined...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	enqueueTagReindex(ctx, r.container, countedTags)
+
+	return result, nil
+}
+
+// RebalancePositions renumbers the children of every tag, and the root tags, to
+// consecutive positions from zero, keeping their order. Only the tags whose position
+// changed are written and returned.
+func (r *TagRepository) RebalancePositions(ctx context.Context) ([]*models.Tag, error) {
+	var tags []*models.Tag
+
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		// Positions are swapped between siblings, so uniqueness is only checked at commit
+		if _, err := tx.Exec(ctx, "SET CONSTRAINTS tags_unique_parent_id_position DEFERRED"); err != nil {
+			return fmt.Errorf("error deferring position constraint: %w", err)
+		}
+
+		query := `
+			WITH ranked AS (
+				SELECT id, (ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY position, id) - 1)::int AS new_position
+				FROM tags
 			)
+			UPDATE tags t
+			SET position = ranked.new_position
+			FROM ranked
+			WHERE t.id = ranked.id AND t.position <> ranked.new_position
+			RETURNING t.id, t.uuid, t.name, t.description, t.parent_id, t.position, t.created_at, t.updated_at
+		`
 
-			if err != nil {
-				return fmt.Errorf("error creating root tag: %w", err)
+		rows, err := tx.Query(ctx, query)
+		if err != nil {
+			return fmt.Errorf("error rebalancing tag positions: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var tag models.Tag
+			if err := rows.Scan(
+				&tag.ID, &tag.UUID, &tag.Name,
+				&tag.Description, &tag.ParentID,
+				&tag.Position, &tag.CreatedAt, &tag.UpdatedAt,
+			); err != nil {
+				return fmt.Errorf("error scanning rebalanced tag: %w", err)
 			}
-		} else if opts.Action == TagHierarchyAfter {
+			tags = append(tags, &tag)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// tagClosureDiffCTE computes the expected closure of the tag hierarchy from the parent_id
+// relationships, and the rows by which the closure table differs from it
+const tagClosureDiffCTE = `
+	WITH RECURSIVE expected AS (
+		SELECT id AS ancestor, id AS descendant, 0 AS depth FROM tags
+		UNION ALL
+		SELECT e.ancestor, t.id, e.depth + 1 FROM expected e
+		INNER JOIN tags t ON t.parent_id = e.descendant
+	),
+	missing AS (
+		SELECT ancestor, descendant, depth FROM expected
+		EXCEPT
+		SELECT ancestor, descendant, depth FROM tag_closure
+	),
+	extraneous AS (
+		SELECT ancestor, descendant, depth FROM tag_closure
+		EXCEPT
+		SELECT ancestor, descendant, depth FROM expected
+	),
+	drifted AS (
+		SELECT descendant FROM missing
+		UNION
+		SELECT descendant FROM extraneous
+	)
+`
+
+func (r *TagRepository) verifyClosureTx(ctx context.Context, tx pgx.Tx) (*models.TagClosureReport, error) {
+	query := tagClosureDiffCTE + `
+		SELECT
+			(SELECT COUNT(*) FROM missing),
+			(SELECT COUNT(*) FROM extraneous),
+			(SELECT COUNT(*) FROM drifted)
+	`
+
+	var report models.TagClosureReport
+	if err := tx.QueryRow(ctx, query).Scan(&report.Missing, &report.Extraneous, &report.Tags); err != nil {
+		return nil, fmt.Errorf("error verifying tag closure: %w", err)
+	}
+	report.Consistent = report.Missing == 0 && report.Extraneous == 0
+
+	return &report, nil
+}
+
+// VerifyClosure compares the tag closure table against the parent_id relationships,
+// reporting any differences without correcting them
+func (r *TagRepository) VerifyClosure(ctx context.Context) (*models.TagClosureReport, error) {
+	var report *models.TagClosureReport
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		report, err = r.verifyClosureTx(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// RebuildClosure recomputes the tag closure table from the parent_id relationships. It
+// returns the differences found before the rebuild, and the images tagged with any tag
+// whose ancestry was wrong, as their inherited tags were derived from bad data.
+func (r *TagRepository) RebuildClosure(ctx context.Context) (*models.TagClosureReport, []int64, error) {
+	var report *models.TagClosureReport
+	var affectedImages []int64
+	var countedTags []int64
+
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		// Hold off changes to the hierarchy until the rebuilt closure is committed
+		if _, err := tx.Exec(ctx, "LOCK TABLE tags IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+			return fmt.Errorf("error locking tags: %w", err)
+		}
+
+		var err error
+		report, err = r.verifyClosureTx(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		if report.Consistent {
+			return nil
+		}
+
+		rows, err := tx.Query(ctx, tagClosureDiffCTE+`
+			SELECT DISTINCT image_id FROM image_tags WHERE tag_id IN (SELECT descendant FROM drifted)
+		`)
+		if err != nil {
+			return fmt.Errorf("error collecting affected images: %w", err)
+		}
+
+		affectedImages, err = pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			return fmt.Errorf("error collecting affected images: %w", err)
+		}
+
+		rows, err = tx.Query(ctx, tagClosureDiffCTE+`
+			SELECT descendant FROM drifted
+		`)
+		if err != nil {
+			return fmt.Errorf("error collecting drifted tags: %w", err)
+		}
+
+		drifted, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			return fmt.Errorf("error collecting drifted tags: %w", err)
+		}
+
+		// Image counts are read from the closure, so the ancestors of drifted tags were
+		// counted wrongly on either side of the rebuild
+		countedTags, err = tagAncestorsTx(ctx, tx, drifted)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, "SELECT rebuild_tag_closure()"); err != nil {
+			return fmt.Errorf("error rebuilding tag closure: %w", err)
+		}
+
+		rebuilt, err := tagAncestorsTx(ctx, tx, drifted)
+		if err != nil {
+			return err
+		}
+		countedTags = append(countedTags, rebuilt...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	enqueueTagReindex(ctx, r.container, countedTags)
+
+	return report, affectedImages, nil
+}
+
+// isWithinSubtreeTx reports whether a tag is the given root tag or one of its descendants
+func (r *TagRepository) isWithinSubtreeTx(ctx context.Context, tx pgx.Tx, rootID int64, tagID int64) (bool, error) {
+	query := tagDescendantsCTE + `
+		SELECT EXISTS (SELECT 1 FROM descendants WHERE id = $2)
+	`
+
+	var within bool
+	if err := tx.QueryRow(ctx, query, rootID, tagID).Scan(&within); err != nil {
+		return false, fmt.Errorf("error checking tag hierarchy: %w", err)
+	}
+
+	return within, nil
+}
+
+type TagCreateOptions struct {
+	Action   TagHierarchyAction
+	TargetID *int64
+}
+
+func (r *TagRepository) Create(ctx context.Context, tag *models.Tag, opts TagCreateOptions) error {
+	tag.Name = normalizeName(r.container, tag.Name)
+
+	return r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		existingTag, err := r.getByNameTx(ctx, tx, tag.Name)
+		if err != nil && !errors.Is(err, utils.ErrTagNotFound) {
+			return fmt.Errorf("error checking for duplicate name: %w", err)
+		}
+
+		if existingTag != nil {
+			return &utils.ConflictError{
+				Message:      "A tag with this name already exists",
+				ConflictUUID: existingTag.UUID,
+			}
+		}
+
+		if opts.Action == TagHierarchyRoot {
 			query := `
 				SELECT id, uuid, name, description, parent_id, position, created_at, updated_at
-				FROM insert_tag_after($1, $2, $3)
+				FROM insert_tag_inside(NULL, $1, $2)
 			`
 
 			err = tx.QueryRow(
 				ctx, query,
-				targetTag.ID,
 				tag.Name, tag.Description,
 			).Scan(
 				&tag.ID, &tag.UUID,
@@ -489,203 +928,388 @@ func (r *TagRepository) Create(ctx context.Context, tag *models.Tag, opts TagCre
 				return fmt.Errorf("error creating root tag: %w", err)
 			}
 		} else {
-			return fmt.Errorf("a hierarchy operation must be specified when creating a tag")
-		}
-	}
+			targetTag, err := r.getByInternalIDTx(ctx, tx, *opts.TargetID)
+			if err != nil && !errors.Is(err, utils.ErrTagNotFound) {
+				return fmt.Errorf("error retrieving target tag: %w", err)
+			}
 
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("error committing transaction: %w", err)
-	}
+			if opts.Action == TagHierarchyInside {
+				query := `
+					SELECT id, uuid, name, description, parent_id, position, created_at, updated_at
+					FROM insert_tag_inside($1, $2, $3)
+				`
 
-	return nil
-}
+				err = tx.QueryRow(
+					ctx, query,
+					targetTag.ID,
+					tag.Name, tag.Description,
+				).Scan(
+					&tag.ID, &tag.UUID,
+					&tag.Name, &tag.Description,
+					&tag.ParentID, &tag.Position,
+					&tag.CreatedAt, &tag.UpdatedAt,
+				)
 
-func (r *TagRepository) Merge(ctx context.Context, sourceTag *models.Tag, destinationTag *models.Tag) ([]int64, error) {
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("error starting transaction: %w", err)
-	}
+				if err != nil {
+					return fmt.Errorf("error creating root tag: %w", err)
+				}
+			} else if opts.Action == TagHierarchyBefore {
+				query := `
+					SELECT id, uuid, name, description, parent_id, position, created_at, updated_at
+					FROM insert_tag_before($1, $2, $3)
+				`
 
-	defer func() {
-		if tx != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				log.Error().Err(rollbackErr).Msg("Failed to roll back transaction")
+				err = tx.QueryRow(
+					ctx, query,
+					targetTag.ID,
+					tag.Name, tag.Description,
+				).Scan(
+					&tag.ID, &tag.UUID,
+					&tag.Name, &tag.Description,
+					&tag.ParentID, &tag.Position,
+					&tag.CreatedAt, &tag.UpdatedAt,
+				)
+
+				if err != nil {
+					return fmt.Errorf("error creating root tag: %w", err)
+				}
+			} else if opts.Action == TagHierarchyAfter {
+				query := `
+					SELECT id, uuid, name, description, parent_id, position, created_at, updated_at
+					FROM insert_tag_after($1, $2, $3)
+				`
+
+				err = tx.QueryRow(
+					ctx, query,
+					targetTag.ID,
+					tag.Name, tag.Description,
+				).Scan(
+					&tag.ID, &tag.UUID,
+					&tag.Name, &tag.Description,
+					&tag.ParentID, &tag.Position,
+					&tag.CreatedAt, &tag.UpdatedAt,
+				)
+
+				if err != nil {
+					return fmt.Errorf("error creating root tag: %w", err)
+				}
+			} else {
+				return fmt.Errorf("a hierarchy operation must be specified when creating a tag")
 			}
 		}
-	}()
 
-	if sourceTag.ID <= 0 {
-		if sourceTag.UUID != "" {
-			sourceTag, err = r.getByUUIDTx(ctx, tx, sourceTag.UUID)
-			if err != nil {
-				return nil, fmt.Errorf("error retrieving source tag: %w", err)
+		return nil
+	})
+}
+
+// EnsurePath makes sure a chain of tags exists, each nested inside the one before it,
+// creating whichever are missing. As tag names are unique, an existing tag found under
+// a different parent than the path requires is rejected rather than moved. It returns
+// the tags along the path and those of them that were created.
+func (r *TagRepository) EnsurePath(ctx context.Context, names []string) ([]*models.Tag, []*models.Tag, error) {
+	var path, created []*models.Tag
+
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		var parentID *int64
+
+		for _, name := range names {
+			name = normalizeName(r.container, name)
+
+			tag, err := r.getByNameTx(ctx, tx, name)
+			if err != nil && !errors.Is(err, utils.ErrTagNotFound) {
+				return fmt.Errorf("error retrieving tag %q: %w", name, err)
 			}
-		} else {
-			return nil, fmt.Errorf("source tag is invalid")
-		}
-	}
 
-	if destinationTag.ID <= 0 {
-		if destinationTag.UUID != "" {
-			destinationTag, err = r.getByUUIDTx(ctx, tx, destinationTag.UUID)
-			if err != nil {
-				return nil, fmt.Errorf("error retrieving destination tag: %w", err)
+			if tag == nil {
+				tag, err = r.insertPathTagTx(ctx, tx, parentID, name)
+				if err != nil {
+					return fmt.Errorf("error creating tag %q: %w", name, err)
+				}
+
+				if tag != nil {
+					created = append(created, tag)
+				} else {
+					// Another request created the tag since it was looked up
+					tag, err = r.getByNameTx(ctx, tx, name)
+					if err != nil {
+						return fmt.Errorf("error retrieving tag %q: %w", name, err)
+					}
+				}
 			}
-		} else {
-			return nil, fmt.Errorf("destination tag is invalid")
+
+			if !sameParent(tag.ParentID, parentID) {
+				return fmt.Errorf("%w: tag %q already exists elsewhere in the hierarchy", utils.ErrInvalidInput, name)
+			}
+
+			path = append(path, tag)
+			parentID = &tag.ID
 		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return path, created, nil
+}
+
+// insertPathTagTx creates a tag as the first child of a parent, like insert_tag_inside,
+// unless a tag of the same name already exists, in which case it returns nil. The
+// insert waits on any concurrent insert of the name, so that two requests ensuring the
+// same path do not both try to create it.
+func (r *TagRepository) insertPathTagTx(ctx context.Context, tx pgx.Tx, parentID *int64, name string) (*models.Tag, error) {
+	// Take the same lock on the parent's children as the insert_tag functions
+	var lockKey int64
+	if parentID != nil {
+		lockKey = *parentID
+	}
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", lockKey); err != nil {
+		return nil, fmt.Errorf("error locking tag siblings: %w", err)
 	}
 
-	if sourceTag.ID == destinationTag.ID {
-		return nil, fmt.Errorf("source tag and destination tag are the same")
+	// Siblings are only shifted up once the insert succeeds, so the new tag briefly
+	// shares position zero with the first of them
+	if _, err := tx.Exec(ctx, "SET CONSTRAINTS tags_unique_parent_id_position DEFERRED"); err != nil {
+		return nil, fmt.Errorf("error deferring position constraint: %w", err)
 	}
 
 	query := `
-		SELECT id, uuid, name, description, parent_id, position, created_at, updated_at
-		FROM merge_tags($1, $2)
+		INSERT INTO tags (name, parent_id, position)
+		VALUES ($1, $2, 0)
+		ON CONFLICT ` + nameConflictTarget(r.container) + ` DO NOTHING
+		RETURNING id, uuid, name, description, parent_id, position, created_at, updated_at
 	`
 
-	err = tx.QueryRow(
-		ctx, query,
-		destinationTag.ID, sourceTag.ID,
-	).Scan(
-		&destinationTag.ID, &destinationTag.UUID, &destinationTag.Name, &destinationTag.Description, &destinationTag.ParentID, &destinationTag.Position, &destinationTag.CreatedAt, &destinationTag.UpdatedAt,
+	tag := &models.Tag{}
+	err := tx.QueryRow(ctx, query, name, parentID).Scan(
+		&tag.ID, &tag.UUID,
+		&tag.Name, &tag.Description,
+		&tag.ParentID, &tag.Position,
+		&tag.CreatedAt, &tag.UpdatedAt,
 	)
-
 	if err != nil {
-		return nil, fmt.Errorf("error merging tags: %w", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error inserting tag: %w", err)
 	}
 
-	affectedImages, err := r.getAffectedImagesTx(ctx, tx, destinationTag.ID)
-	if err != nil {
-		return nil, fmt.Errorf("error calculating affected images: %w", err)
+	query = `
+		UPDATE tags
+		SET position = position + 1
+		WHERE parent_id IS NOT DISTINCT FROM $1 AND id <> $2
+	`
+
+	if _, err := tx.Exec(ctx, query, parentID, tag.ID); err != nil {
+		return nil, fmt.Errorf("error shifting sibling positions: %w", err)
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return nil, fmt.Errorf("error committing transaction: %w", err)
+	if _, err := tx.Exec(ctx, "SET CONSTRAINTS tags_unique_parent_id_position IMMEDIATE"); err != nil {
+		return nil, fmt.Errorf("error checking sibling positions: %w", err)
 	}
 
-	return affectedImages, nil
+	return tag, nil
 }
 
-func (r *TagRepository) Delete(ctx context.Context, tag *models.Tag) ([]int64, error) {
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("error starting transaction: %w", err)
+// sameParent reports whether two optional parent IDs refer to the same parent
+func sameParent(a *int64, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
 	}
+	return *a == *b
+}
 
-	defer func() {
-		if tx != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				log.Error().Err(rollbackErr).Msg("Failed to roll back transaction")
+func (r *TagRepository) Merge(ctx context.Context, sourceTag *models.Tag, destinationTag *models.Tag) ([]int64, error) {
+	var affectedImages []int64
+	var countedTags []int64
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		if sourceTag.ID <= 0 {
+			if sourceTag.UUID != "" {
+				sourceTag, err = r.getByUUIDTx(ctx, tx, sourceTag.UUID)
+				if err != nil {
+					return fmt.Errorf("error retrieving source tag: %w", err)
+				}
+			} else {
+				return fmt.Errorf("source tag is invalid")
 			}
 		}
-	}()
 
-	if tag.ID <= 0 {
-		if tag.UUID != "" {
-			tag, err = r.getByUUIDTx(ctx, tx, tag.UUID)
-			if err != nil {
-				return nil, fmt.Errorf("error retrieving tag: %w", err)
+		if destinationTag.ID <= 0 {
+			if destinationTag.UUID != "" {
+				destinationTag, err = r.getByUUIDTx(ctx, tx, destinationTag.UUID)
+				if err != nil {
+					return fmt.Errorf("error retrieving destination tag: %w", err)
+				}
+			} else {
+				return fmt.Errorf("destination tag is invalid")
 			}
-		} else {
-			return nil, fmt.Errorf("tag is invalid")
 		}
-	}
 
-	affectedImages, err := r.getAffectedImagesTx(ctx, tx, tag.ID)
-	if err != nil {
-		return nil, fmt.Errorf("error calculating affected images: %w", err)
-	}
+		if sourceTag.ID == destinationTag.ID {
+			return fmt.Errorf("source tag and destination tag are the same")
+		}
+
+		// The images and children of the source tag leave its ancestors
+		countedTags, err = tagAncestorsTx(ctx, tx, []int64{sourceTag.ID})
+		if err != nil {
+			return err
+		}
+
+		query := `
+			SELECT id, uuid, name, description, parent_id, position, created_at, updated_at
+			FROM merge_tags($1, $2)
+		`
+
+		err = tx.QueryRow(
+			ctx, query,
+			destinationTag.ID, sourceTag.ID,
+		).Scan(
+			&destinationTag.ID, &destinationTag.UUID, &destinationTag.Name, &destinationTag.Description, &destinationTag.ParentID, &destinationTag.Position, &destinationTag.CreatedAt, &destinationTag.UpdatedAt,
+		)
 
-	query := `SELECT delete_tag_recursive($1)`
+		if err != nil {
+			return fmt.Errorf("error merging tags: %w", err)
+		}
+
+		affectedImages, err = r.getAffectedImagesTx(ctx, tx, destinationTag.ID)
+		if err != nil {
+			return fmt.Errorf("error calculating affected images: %w", err)
+		}
 
-	_, err = tx.Exec(ctx, query, tag.ID)
+		// And join the destination tag and its ancestors
+		joined, err := tagAncestorsTx(ctx, tx, []int64{destinationTag.ID})
+		if err != nil {
+			return err
+		}
+		countedTags = append(countedTags, joined...)
 
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error deleting tag: %w", err)
+		return nil, err
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return nil, fmt.Errorf("error committing transaction: %w", err)
-	}
+	enqueueTagReindex(ctx, r.container, countedTags, sourceTag.ID)
 
 	return affectedImages, nil
 }
 
-// GetChildren fetches the direct children of a tag
-func (r *TagRepository) GetChildren(ctx context.Context, parentID *int64) ([]*models.Tag, error) {
-	tx, err := r.container.Postgres.Pool.Begin(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("error starting transaction: %w", err)
-	}
+func (r *TagRepository) Delete(ctx context.Context, tag *models.Tag) ([]int64, error) {
+	var affectedImages []int64
+	var countedTags []int64
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		if tag.ID <= 0 {
+			if tag.UUID != "" {
+				tag, err = r.getByUUIDTx(ctx, tx, tag.UUID)
+				if err != nil {
+					return fmt.Errorf("error retrieving tag: %w", err)
+				}
+			} else {
+				return fmt.Errorf("tag is invalid")
+			}
+		}
+
+		affectedImages, err = r.getAffectedImagesTx(ctx, tx, tag.ID)
+		if err != nil {
+			return fmt.Errorf("error calculating affected images: %w", err)
+		}
 
-	defer func() {
-		if tx != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
-				log.Error().Err(rollbackErr).Msg("Failed to roll back transaction")
+		// The images of the deleted subtree no longer count towards its ancestors
+		if len(affectedImages) > 0 {
+			countedTags, err = tagAncestorsTx(ctx, tx, []int64{tag.ID})
+			if err != nil {
+				return err
 			}
 		}
-	}()
 
-	var query string
-	var args []interface{}
+		query := `SELECT delete_tag_recursive($1)`
 
-	if parentID == nil {
-		query = `
-			SELECT id, uuid, name, description, parent_id, position, created_at, updated_at
-			FROM tags
-			WHERE parent_id IS NULL
-			ORDER BY position
-		`
-	} else {
-		query = `
-			SELECT id, uuid, name, description, parent_id, position, created_at, updated_at
-			FROM tags
-			WHERE parent_id = $1
-			ORDER BY position
-		`
-		args = append(args, *parentID)
-	}
+		_, err = tx.Exec(ctx, query, tag.ID)
 
-	rows, err := tx.Query(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("error deleting tag: %w", err)
+		}
+
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error querying tag children: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
 
+	enqueueTagReindex(ctx, r.container, countedTags, tag.ID)
+
+	return affectedImages, nil
+}
+
+// GetChildren fetches a page of the direct children of a tag, paginated by position
+func (r *TagRepository) GetChildren(ctx context.Context, parentID *int64, opts models.TagChildrenOptions) (*models.TagChildrenPage, error) {
 	var tags []*models.Tag
-	for rows.Next() {
-		var tag models.Tag
-		var descriptionPtr *string
-		var parentIDPtr *int64
+	err := r.container.Postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		var conditions []string
+		var args []interface{}
 
-		if err := rows.Scan(
-			&tag.ID, &tag.UUID, &tag.Name,
-			&descriptionPtr, &parentIDPtr,
-			&tag.Position, &tag.CreatedAt, &tag.UpdatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("error scanning tag row: %w", err)
+		if parentID == nil {
+			conditions = append(conditions, "parent_id IS NULL")
+		} else {
+			args = append(args, *parentID)
+			conditions = append(conditions, fmt.Sprintf("parent_id = $%d", len(args)))
 		}
 
-		tag.Description = descriptionPtr
-		tag.ParentID = parentIDPtr
+		if opts.AfterPosition != nil {
+			args = append(args, *opts.AfterPosition)
+			conditions = append(conditions, fmt.Sprintf("position > $%d", len(args)))
+		}
 
-		tags = append(tags, &tag)
-	}
+		query := fmt.Sprintf(`
+			SELECT id, uuid, name, description, parent_id, position, created_at, updated_at
+			FROM tags
+			WHERE %s
+			ORDER BY position
+		`, strings.Join(conditions, " AND "))
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating tag rows: %w", err)
-	}
+		// Fetch one extra row to determine whether another page follows
+		if opts.Limit > 0 {
+			args = append(args, opts.Limit+1)
+			query += fmt.Sprintf("LIMIT $%d", len(args))
+		}
+
+		rows, err := tx.Query(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("error querying tag children: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var tag models.Tag
+			var descriptionPtr *string
+			var parentIDPtr *int64
+
+			if err := rows.Scan(
+				&tag.ID, &tag.UUID, &tag.Name,
+				&descriptionPtr, &parentIDPtr,
+				&tag.Position, &tag.CreatedAt, &tag.UpdatedAt,
+			); err != nil {
+				return fmt.Errorf("error scanning tag row: %w", err)
+			}
 
-	err = tx.Commit(ctx)
+			tag.Description = descriptionPtr
+			tag.ParentID = parentIDPtr
+
+			tags = append(tags, &tag)
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating tag rows: %w", err)
+		}
+
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error committing transaction: %w", err)
+		return nil, err
 	}
-	tx = nil
 
-	return tags, nil
+	return models.NewTagChildrenPage(tags, opts.Limit), nil
 }
@@ -5,12 +5,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v8/esapi"
 	elastic_search "github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
-	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/sortorder"
+	"github.com/foresturquhart/curator/server/cache"
 	"github.com/foresturquhart/curator/server/container"
 	"github.com/foresturquhart/curator/server/models"
 	"github.com/foresturquhart/curator/server/utils"
@@ -21,18 +22,31 @@ const PeopleIndex = "people"
 
 type PersonSearch struct {
 	container *container.Container
+	rebuilds  *cache.IndexRebuilds
 }
 
 func NewPersonSearch(container *container.Container) *PersonSearch {
 	return &PersonSearch{
 		container: container,
+		rebuilds:  cache.NewIndexRebuilds(container),
 	}
 }
 
-// Delete removes a document from the Elasticsearch index based on the provided UUID.
+// Delete removes a document from the Elasticsearch index based on the provided UUID,
+// and from any version of the index being rebuilt.
 func (s *PersonSearch) Delete(ctx context.Context, uuid string) error {
+	for _, index := range s.rebuilds.DeleteTargets(ctx, PeopleIndex, uuid) {
+		if err := s.delete(ctx, index, uuid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *PersonSearch) delete(ctx context.Context, index string, uuid string) error {
 	req := esapi.DeleteRequest{
-		Index:      PeopleIndex,
+		Index:      index,
 		DocumentID: uuid,
 		Refresh:    "true",
 	}
@@ -62,8 +76,26 @@ func (s *PersonSearch) Delete(ctx context.Context, uuid string) error {
 	return nil
 }
 
-// Index adds or updates a PersonSearchRecord in the Elasticsearch index.
+// Index adds or updates a PersonSearchRecord in the Elasticsearch index, and in any
+// version of the index being rebuilt.
 func (s *PersonSearch) Index(ctx context.Context, record *models.PersonSearchRecord) error {
+	for _, index := range s.rebuilds.WriteTargets(ctx, PeopleIndex) {
+		if err := s.write(ctx, index, record, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IndexInto adds a PersonSearchRecord to a version of the index being rebuilt. A document
+// already written there by Index is left alone, as it is at least as recent.
+func (s *PersonSearch) IndexInto(ctx context.Context, index string, record *models.PersonSearchRecord) error {
+	return s.write(ctx, index, record, true)
+}
+
+// write indexes a PersonSearchRecord into an index, only when absent if create is set
+func (s *PersonSearch) write(ctx context.Context, index string, record *models.PersonSearchRecord, create bool) error {
 	// Construct the document to index
 	document := map[string]any{
 		"id":         record.ID,
@@ -78,6 +110,18 @@ func (s *PersonSearch) Index(ctx context.Context, record *models.PersonSearchRec
 		document["description"] = *record.Description
 	}
 
+	if len(record.Aliases) > 0 {
+		document["aliases"] = record.Aliases
+	}
+
+	if record.ActiveSince != nil {
+		document["active_since"] = record.ActiveSince.Format(time.DateOnly)
+	}
+
+	if record.ActiveUntil != nil {
+		document["active_until"] = record.ActiveUntil.Format(time.DateOnly)
+	}
+
 	// Add sources
 	if len(record.Sources) > 0 {
 		sources := make([]map[string]any, len(record.Sources))
@@ -108,12 +152,15 @@ func (s *PersonSearch) Index(ctx context.Context, record *models.PersonSearchRec
 
 	// Create index request
 	req := esapi.IndexRequest{
-		Index:      PeopleIndex,
+		Index:      index,
 		DocumentID: record.UUID,
 		Body:       bytes.NewReader(payload),
 		// Make the document immediately searchable
 		Refresh: "true",
 	}
+	if create {
+		req.OpType = "create"
+	}
 
 	// Execute the request
 	res, err := req.Do(ctx, s.container.Elastic.Client)
@@ -130,6 +177,9 @@ func (s *PersonSearch) Index(ctx context.Context, record *models.PersonSearchRec
 
 	// Check if the request was successful
 	if res.IsError() {
+		if create && res.StatusCode == 409 {
+			return nil
+		}
 		var e map[string]any
 		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
 			return fmt.Errorf("error parsing the response body: %w", err)
@@ -159,6 +209,8 @@ type PersonSearchOptions struct {
 	Source     string     // Filter by source URL
 	SinceDate  *time.Time // Records created after this date
 	BeforeDate *time.Time // Records created before this date
+	ActiveFrom *time.Time // People active at some point on or after this date
+	ActiveTo   *time.Time // People active at some point on or before this date
 
 	// Sorting
 	SortBy        PersonSortBy
@@ -173,6 +225,8 @@ type PersonSearchResult struct {
 	HasMore    bool
 	TotalCount int64
 	NextCursor []types.FieldValue
+	PrevCursor []types.FieldValue
+	Partial    bool
 }
 
 // Search executes an Elasticsearch query based on the provided filter, sort, and pagination options.
@@ -191,12 +245,24 @@ func (s *PersonSearch) Search(ctx context.Context, options *PersonSearchOptions)
 		return nil, fmt.Errorf("error building search query: %w", err)
 	}
 
-	// Execute the search
-	res, err := s.container.Elastic.Client.Search().Index(PeopleIndex).Request(query).TrackTotalHits(true).Do(ctx)
+	// Execute the search, bounded by the configured timeout
+	ctx, cancel, timeout := utils.WithSearchTimeout(ctx, s.container.Config.ElasticsearchSearchTimeout)
+	defer cancel()
+
+	request := s.container.Elastic.Client.Search().Index(PeopleIndex).Request(query).TrackTotalHits(true)
+	if timeout != "" {
+		request = request.Timeout(timeout)
+	}
+
+	res, err := request.Do(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error executing search: %w", err)
 	}
 
+	if res.TimedOut {
+		log.Warn().Str("index", PeopleIndex).Msg("Search timed out, returning partial results")
+	}
+
 	// Extract total count
 	totalHits := res.Hits.Total.Value
 	hits := res.Hits.Hits
@@ -207,20 +273,26 @@ func (s *PersonSearch) Search(ctx context.Context, options *PersonSearchOptions)
 		hits = hits[:limit] // Remove the extra hit from the data set
 	}
 
+	// A backward page is fetched in reverse, so restore the requested order
+	backward := options.EndingBefore != nil
+	if backward {
+		slices.Reverse(hits)
+	}
+
 	// Convert hits to models
 	people := make([]*models.PersonSearchRecord, 0, len(hits))
-	var nextCursor []types.FieldValue
-	for i, hit := range hits {
+	for _, hit := range hits {
 		person, err := s.hitToPerson(hit)
 		if err != nil {
 			return nil, fmt.Errorf("error converting hit to person: %w", err)
 		}
 		people = append(people, person)
+	}
 
-		// If this is the last hit and there are more results, use its "sort" field as the cursor.
-		if i == len(hits)-1 && hasMore {
-			nextCursor = append(nextCursor, hit.Sort...)
-		}
+	// Use the "sort" fields of the first and last hits as the cursors
+	var nextCursor, prevCursor []types.FieldValue
+	if len(hits) > 0 {
+		nextCursor, prevCursor = utils.PageCursors(hits[0].Sort, hits[len(hits)-1].Sort, hasMore, options.StartingAfter != nil, backward)
 	}
 
 	return &PersonSearchResult{
@@ -228,6 +300,8 @@ func (s *PersonSearch) Search(ctx context.Context, options *PersonSearchOptions)
 		HasMore:    hasMore,
 		TotalCount: totalHits,
 		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
+		Partial:    res.TimedOut,
 	}, nil
 }
 
@@ -236,16 +310,36 @@ func (s *PersonSearch) prepareSearchQuery(options *PersonSearchOptions, limit in
 	var filters []types.Query
 	var shoulds []types.Query
 
-	// Apply name filter
+	relevance := s.container.Config.Relevance
+
+	// Apply name filter, matching aliases with the same weight as the canonical name
+	// and ranking exact matches on either above partial ones
 	if options.Name != "" {
-		shoulds = append(shoulds, types.Query{
-			Match: map[string]types.MatchQuery{
-				"name": {
-					Query: options.Name,
-					Boost: utils.NewPointer(float32(2.0)),
+		shoulds = append(shoulds,
+			types.Query{
+				MultiMatch: &types.MultiMatchQuery{
+					Query:  options.Name,
+					Fields: []string{"name", "aliases"},
+					Boost:  utils.NewPointer(relevance.PersonNameBoost),
 				},
 			},
-		})
+			types.Query{
+				Term: map[string]types.TermQuery{
+					"name.keyword": {
+						Value: options.Name,
+						Boost: utils.NewPointer(relevance.PersonNameExactBoost),
+					},
+				},
+			},
+			types.Query{
+				Term: map[string]types.TermQuery{
+					"aliases.keyword": {
+						Value: options.Name,
+						Boost: utils.NewPointer(relevance.PersonAliasExactBoost),
+					},
+				},
+			},
+		)
 	}
 
 	// Apply description filter
@@ -254,6 +348,7 @@ func (s *PersonSearch) prepareSearchQuery(options *PersonSearchOptions, limit in
 			Match: map[string]types.MatchQuery{
 				"description": {
 					Query: options.Description,
+					Boost: utils.NewPointer(relevance.PersonDescriptionBoost),
 				},
 			},
 		})
@@ -271,7 +366,7 @@ func (s *PersonSearch) prepareSearchQuery(options *PersonSearchOptions, limit in
 								Term: map[string]types.TermQuery{
 									"sources.url.keyword": {
 										Value: options.Source,
-										Boost: utils.NewPointer(float32(2.0)), // Higher boost for exact matches
+										Boost: utils.NewPointer(relevance.PersonSourceExactBoost), // Higher boost for exact matches
 									},
 								},
 							},
@@ -279,7 +374,7 @@ func (s *PersonSearch) prepareSearchQuery(options *PersonSearchOptions, limit in
 								Match: map[string]types.MatchQuery{
 									"sources.url": {
 										Query: options.Source,
-										Boost: utils.NewPointer(float32(1.5)), // Lower boost for partial matches
+										Boost: utils.NewPointer(relevance.PersonSourcePartialBoost), // Lower boost for partial matches
 									},
 								},
 							},
@@ -308,13 +403,31 @@ func (s *PersonSearch) prepareSearchQuery(options *PersonSearchOptions, limit in
 		})
 	}
 
-	// Determine sort direction
-	var sortDirection sortorder.SortOrder
-	switch options.SortDirection {
-	case utils.SortDirectionAsc:
-		sortDirection = sortorder.Asc
-	default:
-		sortDirection = sortorder.Desc
+	// Apply active period filters. A person matches when their active period overlaps the
+	// requested range, with missing bounds treated as open-ended. People without any
+	// active period recorded are excluded.
+	if options.ActiveFrom != nil || options.ActiveTo != nil {
+		filters = append(filters, types.Query{
+			Bool: &types.BoolQuery{
+				Should: []types.Query{
+					{Exists: &types.ExistsQuery{Field: "active_since"}},
+					{Exists: &types.ExistsQuery{Field: "active_until"}},
+				},
+				MinimumShouldMatch: 1,
+			},
+		})
+
+		if options.ActiveFrom != nil {
+			filters = append(filters, openEndedRange("active_until", types.DateRangeQuery{
+				Gte: utils.NewPointer(options.ActiveFrom.Format(time.DateOnly)),
+			}))
+		}
+
+		if options.ActiveTo != nil {
+			filters = append(filters, openEndedRange("active_since", types.DateRangeQuery{
+				Lte: utils.NewPointer(options.ActiveTo.Format(time.DateOnly)),
+			}))
+		}
 	}
 
 	sortField := string(PersonSortByCreatedAt)
@@ -322,43 +435,66 @@ func (s *PersonSearch) prepareSearchQuery(options *PersonSearchOptions, limit in
 		sortField = string(options.SortBy)
 	}
 
+	boolQuery := &types.BoolQuery{
+		Must:   filters,
+		Should: shoulds,
+	}
+	if len(shoulds) > 0 && relevance.PersonMinimumShouldMatch != "" {
+		boolQuery.MinimumShouldMatch = relevance.PersonMinimumShouldMatch
+	}
+
 	// Build the search request based on the sort field
 	searchRequest := &elastic_search.Request{
 		Size: utils.NewPointer(limit + 1),
 		Query: &types.Query{
-			Bool: &types.BoolQuery{
-				Must:   filters,
-				Should: shoulds,
-			},
-		},
-		Sort: []types.SortCombinations{
-			types.SortOptions{
-				SortOptions: map[string]types.FieldSort{
-					sortField: {
-						Order: &sortDirection,
-					},
-					"id": {
-						Order: &sortorder.Asc,
-					},
-				},
-			},
+			Bool: boolQuery,
 		},
+		// A page before an EndingBefore cursor is fetched by walking the sort backwards
+		Sort: utils.SortWithTiebreaker(sortField, options.SortDirection, options.EndingBefore != nil),
 	}
 
-	// If a StartingAfter cursor is provided, attach it
+	// If a StartingAfter or EndingBefore cursor is provided, attach it
 	if options.StartingAfter != nil {
 		searchRequest.SearchAfter = options.StartingAfter
+	} else if options.EndingBefore != nil {
+		searchRequest.SearchAfter = options.EndingBefore
 	}
 
 	return searchRequest, nil
 }
 
+// openEndedRange matches documents where the field satisfies the range or is missing
+func openEndedRange(field string, dateRange types.DateRangeQuery) types.Query {
+	return types.Query{
+		Bool: &types.BoolQuery{
+			Should: []types.Query{
+				{
+					Range: map[string]types.RangeQuery{
+						field: dateRange,
+					},
+				},
+				{
+					Bool: &types.BoolQuery{
+						MustNot: []types.Query{
+							{Exists: &types.ExistsQuery{Field: field}},
+						},
+					},
+				},
+			},
+			MinimumShouldMatch: 1,
+		},
+	}
+}
+
 // rawPersonSearchRecord is a helper type for unmarshalling the Elasticsearch hit source.
 type rawPersonSearchRecord struct {
 	ID          float64                       `json:"id"`
 	UUID        string                        `json:"uuid"`
 	Name        string                        `json:"name"`
 	Description *string                       `json:"description"`
+	Aliases     []string                      `json:"aliases"`
+	ActiveSince *string                       `json:"active_since"`
+	ActiveUntil *string                       `json:"active_until"`
 	CreatedAt   string                        `json:"created_at"`
 	UpdatedAt   string                        `json:"updated_at"`
 	Sources     []rawPersonSearchRecordSource `json:"sources"`
@@ -387,6 +523,16 @@ func (s *PersonSearch) hitToPerson(hit types.Hit) (*models.PersonSearchRecord, e
 		return nil, fmt.Errorf("error parsing updated_at: %w", err)
 	}
 
+	activeSince, err := parseOptionalDate(raw.ActiveSince)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing active_since: %w", err)
+	}
+
+	activeUntil, err := parseOptionalDate(raw.ActiveUntil)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing active_until: %w", err)
+	}
+
 	// Convert raw sources to domain sources.
 	var sources []*models.PersonSearchRecordSource
 	for _, src := range raw.Sources {
@@ -402,8 +548,25 @@ func (s *PersonSearch) hitToPerson(hit types.Hit) (*models.PersonSearchRecord, e
 		UUID:        raw.UUID,
 		Name:        raw.Name,
 		Description: raw.Description,
+		Aliases:     raw.Aliases,
+		ActiveSince: activeSince,
+		ActiveUntil: activeUntil,
 		CreatedAt:   createdAt,
 		UpdatedAt:   updatedAt,
 		Sources:     sources,
 	}, nil
 }
+
+// parseOptionalDate parses a nullable date-only value from a search document
+func parseOptionalDate(value *string) (*time.Time, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.DateOnly, *value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parsed, nil
+}
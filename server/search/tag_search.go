@@ -11,6 +11,7 @@ import (
 	elastic_search "github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/sortorder"
+	"github.com/foresturquhart/curator/server/cache"
 	"github.com/foresturquhart/curator/server/container"
 	"github.com/foresturquhart/curator/server/models"
 	"github.com/foresturquhart/curator/server/utils"
@@ -21,15 +22,35 @@ const TagIndex = "tags"
 
 type TagSearch struct {
 	container *container.Container
+	rebuilds  *cache.IndexRebuilds
 }
 
 func NewTagSearch(container *container.Container) *TagSearch {
 	return &TagSearch{
 		container: container,
+		rebuilds:  cache.NewIndexRebuilds(container),
 	}
 }
 
+// Index adds or updates a TagSearchRecord in the index, and in any version of it being rebuilt
 func (s *TagSearch) Index(ctx context.Context, record *models.TagSearchRecord) error {
+	for _, index := range s.rebuilds.WriteTargets(ctx, TagIndex) {
+		if err := s.write(ctx, index, record, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IndexInto adds a TagSearchRecord to a version of the index being rebuilt. A document
+// already written there by Index is left alone, as it is at least as recent.
+func (s *TagSearch) IndexInto(ctx context.Context, index string, record *models.TagSearchRecord) error {
+	return s.write(ctx, index, record, true)
+}
+
+// write indexes a TagSearchRecord into an index, only when absent if create is set
+func (s *TagSearch) write(ctx context.Context, index string, record *models.TagSearchRecord, create bool) error {
 	// Marshal the document
 	payload, err := json.Marshal(record)
 	if err != nil {
@@ -38,12 +59,15 @@ func (s *TagSearch) Index(ctx context.Context, record *models.TagSearchRecord) e
 
 	// Create index request
 	req := esapi.IndexRequest{
-		Index:      TagIndex,
+		Index:      index,
 		DocumentID: record.UUID,
 		Body:       bytes.NewReader(payload),
 		// Make the document immediately searchable
 		Refresh: "true",
 	}
+	if create {
+		req.OpType = "create"
+	}
 
 	// Execute the request
 	res, err := req.Do(ctx, s.container.Elastic.Client)
@@ -60,6 +84,9 @@ func (s *TagSearch) Index(ctx context.Context, record *models.TagSearchRecord) e
 
 	// Check if the request was successful
 	if res.IsError() {
+		if create && res.StatusCode == 409 {
+			return nil
+		}
 		var e map[string]any
 		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
 			return fmt.Errorf("error parsing the response body: %w", err)
@@ -70,9 +97,20 @@ func (s *TagSearch) Index(ctx context.Context, record *models.TagSearchRecord) e
 	return nil
 }
 
+// Delete removes a document from the index, and from any version of it being rebuilt
 func (s *TagSearch) Delete(ctx context.Context, uuid string) error {
+	for _, index := range s.rebuilds.DeleteTargets(ctx, TagIndex, uuid) {
+		if err := s.delete(ctx, index, uuid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *TagSearch) delete(ctx context.Context, index string, uuid string) error {
 	req := esapi.DeleteRequest{
-		Index:      TagIndex,
+		Index:      index,
 		DocumentID: uuid,
 		Refresh:    "true",
 	}
@@ -134,6 +172,7 @@ type TagSearchResult struct {
 	HasMore    bool
 	TotalCount int64
 	NextCursor []types.FieldValue
+	Partial    bool
 }
 
 func (s *TagSearch) Search(ctx context.Context, options *TagSearchOptions) (*TagSearchResult, error) {
@@ -151,12 +190,24 @@ func (s *TagSearch) Search(ctx context.Context, options *TagSearchOptions) (*Tag
 		return nil, fmt.Errorf("error building search query: %w", err)
 	}
 
-	// Execute the search
-	res, err := s.container.Elastic.Client.Search().Index(TagIndex).Request(query).TrackTotalHits(true).Do(ctx)
+	// Execute the search, bounded by the configured timeout
+	ctx, cancel, timeout := utils.WithSearchTimeout(ctx, s.container.Config.ElasticsearchSearchTimeout)
+	defer cancel()
+
+	request := s.container.Elastic.Client.Search().Index(TagIndex).Request(query).TrackTotalHits(true)
+	if timeout != "" {
+		request = request.Timeout(timeout)
+	}
+
+	res, err := request.Do(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error executing search: %w", err)
 	}
 
+	if res.TimedOut {
+		log.Warn().Str("index", TagIndex).Msg("Search timed out, returning partial results")
+	}
+
 	// Extract total count
 	totalHits := res.Hits.Total.Value
 	hits := res.Hits.Hits
@@ -188,6 +239,7 @@ func (s *TagSearch) Search(ctx context.Context, options *TagSearchOptions) (*Tag
 		HasMore:    hasMore,
 		TotalCount: totalHits,
 		NextCursor: nextCursor,
+		Partial:    res.TimedOut,
 	}, nil
 }
 
@@ -196,13 +248,15 @@ func (s *TagSearch) prepareSearchQuery(options *TagSearchOptions, limit int) (*e
 	var filters []types.Query
 	var shoulds []types.Query
 
+	relevance := s.container.Config.Relevance
+
 	// Apply name filter
 	if options.Name != "" {
 		shoulds = append(shoulds, types.Query{
 			Match: map[string]types.MatchQuery{
 				"name": {
 					Query: options.Name,
-					Boost: utils.NewPointer(float32(2.0)),
+					Boost: utils.NewPointer(relevance.TagNameBoost),
 				},
 			},
 		})
@@ -214,6 +268,7 @@ func (s *TagSearch) prepareSearchQuery(options *TagSearchOptions, limit int) (*e
 			Match: map[string]types.MatchQuery{
 				"description": {
 					Query: options.Description,
+					Boost: utils.NewPointer(relevance.TagDescriptionBoost),
 				},
 			},
 		})
@@ -260,14 +315,19 @@ func (s *TagSearch) prepareSearchQuery(options *TagSearchOptions, limit int) (*e
 		sortField = string(options.SortBy)
 	}
 
+	boolQuery := &types.BoolQuery{
+		Must:   filters,
+		Should: shoulds,
+	}
+	if len(shoulds) > 0 && relevance.TagMinimumShouldMatch != "" {
+		boolQuery.MinimumShouldMatch = relevance.TagMinimumShouldMatch
+	}
+
 	// Build the search request based on the sort field
 	searchRequest := &elastic_search.Request{
 		Size: utils.NewPointer(limit + 1),
 		Query: &types.Query{
-			Bool: &types.BoolQuery{
-				Must:   filters,
-				Should: shoulds,
-			},
+			Bool: boolQuery,
 		},
 		Sort: []types.SortCombinations{
 			types.SortOptions{
@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/foresturquhart/curator/server/config"
 	"github.com/foresturquhart/curator/server/container"
 	"github.com/foresturquhart/curator/server/models"
 	"github.com/foresturquhart/curator/server/repositories"
 	"github.com/foresturquhart/curator/server/search"
+	"github.com/foresturquhart/curator/server/telemetry"
 	"github.com/foresturquhart/curator/server/utils"
 	"github.com/rs/zerolog/log"
 )
@@ -30,26 +32,63 @@ func (s *PersonService) Get(ctx context.Context, uuid string) (*models.Person, e
 	return s.repo.GetByUUID(ctx, uuid)
 }
 
+// PopulatePreviewImages attaches the most recently associated images to each person
+func (s *PersonService) PopulatePreviewImages(ctx context.Context, people ...*models.Person) error {
+	ctx, span := telemetry.StartSpan(ctx, "PersonService.PopulatePreviewImages")
+	defer span.End()
+
+	ids := make([]int64, len(people))
+	for i, person := range people {
+		ids[i] = person.ID
+	}
+
+	previews, err := s.repo.GetPreviewImages(ctx, ids, s.container.Config.PreviewImageLimit)
+	if err != nil {
+		return err
+	}
+
+	for _, person := range people {
+		person.PreviewImages = previews[person.ID]
+	}
+
+	return nil
+}
+
 func (s *PersonService) GetByInternalID(ctx context.Context, id int64) (*models.Person, error) {
 	return s.repo.GetByInternalID(ctx, id)
 }
 
 func (s *PersonService) Create(ctx context.Context, person *models.Person) error {
+	ctx, span := telemetry.StartSpan(ctx, "PersonService.Create")
+	defer span.End()
+
 	if err := s.repo.Create(ctx, person); err != nil {
 		return fmt.Errorf("failed to create person: %w", err)
 	}
 
-	if err := s.search.Index(ctx, person.ToSearchRecord()); err != nil {
-		log.Error().Err(err).Msgf("Failed to index person %s", person.UUID)
-	}
+	s.indexPerson(ctx, person)
 
 	return nil
 }
 
 func (s *PersonService) Search(ctx context.Context, options *search.PersonSearchOptions) (*utils.PaginatedResult[*models.Person], error) {
+	ctx, span := telemetry.StartSpan(ctx, "PersonService.Search")
+	defer span.End()
+
 	result, err := s.search.Search(ctx, options)
 
 	if err != nil {
+		// Keep simple listings available while Elasticsearch is unreachable
+		if canListPeopleFromDatabase(options) {
+			log.Warn().Err(err).Msg("Person search failed, falling back to Postgres listing")
+			return s.repo.List(ctx, repositories.PersonListOptions{
+				SinceDate:         options.SinceDate,
+				BeforeDate:        options.BeforeDate,
+				SortByName:        options.SortBy == search.PersonSortByName,
+				SortDirection:     options.SortDirection,
+				PaginationOptions: options.PaginationOptions,
+			})
+		}
 		return nil, fmt.Errorf("failed to search for people: %w", err)
 	}
 
@@ -63,17 +102,39 @@ func (s *PersonService) Search(ctx context.Context, options *search.PersonSearch
 		HasMore:    result.HasMore,
 		TotalCount: result.TotalCount,
 		NextCursor: result.NextCursor,
+		PrevCursor: result.PrevCursor,
+		Partial:    result.Partial,
 	}, nil
 }
 
+// canListPeopleFromDatabase reports whether the search options describe a simple
+// listing that Postgres can serve without Elasticsearch
+func canListPeopleFromDatabase(options *search.PersonSearchOptions) bool {
+	if options.Name != "" || options.Description != "" || options.Source != "" {
+		return false
+	}
+
+	if options.ActiveFrom != nil || options.ActiveTo != nil {
+		return false
+	}
+
+	switch options.SortBy {
+	case "", search.PersonSortByCreatedAt, search.PersonSortByName:
+		return true
+	default:
+		return false
+	}
+}
+
 func (s *PersonService) Update(ctx context.Context, person *models.Person) error {
+	ctx, span := telemetry.StartSpan(ctx, "PersonService.Update")
+	defer span.End()
+
 	if err := s.repo.Update(ctx, person); err != nil {
 		return fmt.Errorf("failed to update person: %w", err)
 	}
 
-	if err := s.search.Index(ctx, person.ToSearchRecord()); err != nil {
-		log.Error().Err(err).Msgf("Failed to index person %s", person.UUID)
-	}
+	s.indexPerson(ctx, person)
 
 	imageIDs, err := s.repo.FindImagesByPersonUUID(ctx, person.UUID)
 	if err != nil {
@@ -89,40 +150,84 @@ func (s *PersonService) Update(ctx context.Context, person *models.Person) error
 	return nil
 }
 
+// indexPerson indexes a person after they were written, either directly or by queueing
+// them on the worker so that failures are retried, depending on the configured index mode
+func (s *PersonService) indexPerson(ctx context.Context, person *models.Person) {
+	if s.container.Config.PersonIndexMode == config.IndexModeSync {
+		if err := s.search.Index(ctx, person.ToSearchRecord()); err != nil {
+			log.Error().Err(err).Msgf("Failed to index person %s", person.UUID)
+		}
+		return
+	}
+
+	if err := s.container.Worker.EnqueueReindexPerson(ctx, person.ID); err != nil {
+		log.Error().Err(err).Msgf("Failed to queue indexing of person %s", person.UUID)
+	}
+}
+
 func (s *PersonService) Index(ctx context.Context, person *models.Person) error {
 	return s.search.Index(ctx, person.ToSearchRecord())
 }
 
-func (s *PersonService) IndexAll(ctx context.Context) error {
+// IndexAll reindexes every person into the given version of the people index,
+// reporting progress after each one when progress is not nil
+func (s *PersonService) IndexAll(ctx context.Context, index string, progress models.IndexProgressFunc) error {
 	// Retrieve all person IDs from the repository.
 	personIDs, err := s.repo.GetAllIDs(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get person IDs: %w", err)
 	}
 
+	var processed, failed int
+	report := func() {
+		if progress != nil {
+			progress(len(personIDs), processed, failed)
+		}
+	}
+	report()
+
 	// Iterate through IDs and index each person
 	for _, id := range personIDs {
+		// Stop when cancelled
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		// Get the person by ID
 		person, err := s.repo.GetByInternalID(ctx, id)
 		if err != nil {
 			// Log the error and continue to the next person
 			log.Error().Err(err).Msgf("Error retrieving person for id %d", id)
+			failed++
+			report()
 			continue
 		}
 
 		// Index in a new transaction
-		if err := s.search.Index(ctx, person.ToSearchRecord()); err != nil {
+		if err := s.search.IndexInto(ctx, index, person.ToSearchRecord()); err != nil {
 			log.Error().Err(err).Msgf("Error reindexing person %s", person.UUID)
+			failed++
+			report()
 			continue
 		}
 
 		log.Info().Msgf("Reindexed person %s", person.UUID)
+		processed++
+		report()
 	}
 
 	return nil
 }
 
+// ExportAll streams every person to fn
+func (s *PersonService) ExportAll(ctx context.Context, fn func(*models.Person) error) error {
+	return s.repo.ExportAll(ctx, fn)
+}
+
 func (s *PersonService) Delete(ctx context.Context, uuid string) error {
+	ctx, span := telemetry.StartSpan(ctx, "PersonService.Delete")
+	defer span.End()
+
 	imageIDs, err := s.repo.FindImagesByPersonUUID(ctx, uuid)
 	if err != nil {
 		log.Error().Err(err).Msgf("Error retrieving associated images for person %s", uuid)
@@ -2,17 +2,26 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/foresturquhart/curator/server/cache"
+	"github.com/foresturquhart/curator/server/config"
 	"github.com/foresturquhart/curator/server/container"
 	"github.com/foresturquhart/curator/server/models"
 	"github.com/foresturquhart/curator/server/repositories"
 	"github.com/foresturquhart/curator/server/search"
+	"github.com/foresturquhart/curator/server/telemetry"
 	"github.com/foresturquhart/curator/server/utils"
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	defaultTreeBreadth = 100 // Children returned per tag when building a tree
+	maxTreeBreadth     = 500 // Upper bound on the children requested per tag
+)
+
 type TagService struct {
 	container *container.Container
 	repo      *repositories.TagRepository
@@ -37,7 +46,40 @@ func (s *TagService) GetByInternalID(ctx context.Context, id int64) (*models.Tag
 	return s.repo.GetByInternalID(ctx, id)
 }
 
+// GetImageCounts reports how many images use a tag, directly and across its subtree
+func (s *TagService) GetImageCounts(ctx context.Context, tag *models.Tag) (*models.TagImageCounts, error) {
+	ctx, span := telemetry.StartSpan(ctx, "TagService.GetImageCounts")
+	defer span.End()
+
+	return s.repo.GetImageCounts(ctx, tag.ID)
+}
+
+// PopulatePreviewImages attaches the most recently tagged images to each tag
+func (s *TagService) PopulatePreviewImages(ctx context.Context, tags ...*models.Tag) error {
+	ctx, span := telemetry.StartSpan(ctx, "TagService.PopulatePreviewImages")
+	defer span.End()
+
+	ids := make([]int64, len(tags))
+	for i, tag := range tags {
+		ids[i] = tag.ID
+	}
+
+	previews, err := s.repo.GetPreviewImages(ctx, ids, s.container.Config.PreviewImageLimit)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		tag.PreviewImages = previews[tag.ID]
+	}
+
+	return nil
+}
+
 func (s *TagService) Create(ctx context.Context, tag *models.Tag, opts repositories.TagCreateOptions) error {
+	ctx, span := telemetry.StartSpan(ctx, "TagService.Create")
+	defer span.End()
+
 	if err := s.repo.Create(ctx, tag, opts); err != nil {
 		return fmt.Errorf("failed to create tag: %w", err)
 	}
@@ -46,14 +88,147 @@ func (s *TagService) Create(ctx context.Context, tag *models.Tag, opts repositor
 		log.Error().Err(err).Msgf("Failed to cache tag %s", tag.UUID)
 	}
 
-	if err := s.search.Index(ctx, tag.ToSearchRecord()); err != nil {
-		log.Error().Err(err).Msgf("Failed to index tag %s", tag.UUID)
-	}
+	s.indexTag(ctx, tag)
 
 	return nil
 }
 
+// EnsurePath makes sure the given chain of tag names exists as a path from the root of
+// the hierarchy, creating any missing tags, and returns the leaf tag. It is safe to call
+// repeatedly with the same path.
+func (s *TagService) EnsurePath(ctx context.Context, names []string) (*models.Tag, bool, error) {
+	ctx, span := telemetry.StartSpan(ctx, "TagService.EnsurePath")
+	defer span.End()
+
+	if len(names) == 0 {
+		return nil, false, fmt.Errorf("%w: the path must contain at least one tag", utils.ErrInvalidInput)
+	}
+
+	cleaned := make([]string, len(names))
+	for i, name := range names {
+		cleaned[i] = strings.TrimSpace(name)
+		if cleaned[i] == "" {
+			return nil, false, fmt.Errorf("%w: tag names in the path cannot be empty", utils.ErrInvalidInput)
+		}
+	}
+
+	path, created, err := s.repo.EnsurePath(ctx, cleaned)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to ensure tag path: %w", err)
+	}
+
+	for _, tag := range created {
+		if err := s.cache.Insert(ctx, tag); err != nil {
+			log.Error().Err(err).Msgf("Failed to cache tag %s", tag.UUID)
+		}
+
+		s.indexTag(ctx, tag)
+	}
+
+	return path[len(path)-1], len(created) > 0, nil
+}
+
+// ResolvePath returns the tag named by a path of names. A single name refers to an
+// existing tag wherever it sits in the hierarchy, and is created at the root when
+// missing, while longer paths are ensured from the root as with EnsurePath.
+func (s *TagService) ResolvePath(ctx context.Context, names []string) (*models.Tag, error) {
+	if len(names) == 1 {
+		tag, err := s.repo.GetByName(ctx, strings.TrimSpace(names[0]))
+		if err == nil {
+			return tag, nil
+		}
+		if !errors.Is(err, utils.ErrTagNotFound) {
+			return nil, fmt.Errorf("failed to retrieve tag: %w", err)
+		}
+	}
+
+	tag, _, err := s.EnsurePath(ctx, names)
+	return tag, err
+}
+
+// BulkMove moves several tags inside a new parent, or to the root when no parent is
+// given, then reindexes every image within the moved subtrees once
+func (s *TagService) BulkMove(ctx context.Context, tagUUIDs []string, parentUUID *string) ([]*models.Tag, error) {
+	ctx, span := telemetry.StartSpan(ctx, "TagService.BulkMove")
+	defer span.End()
+
+	seen := make(map[string]bool, len(tagUUIDs))
+	for _, uuid := range tagUUIDs {
+		if seen[uuid] {
+			return nil, fmt.Errorf("%w: tag %s is listed more than once", utils.ErrInvalidInput, uuid)
+		}
+		seen[uuid] = true
+	}
+
+	result, err := s.repo.BulkMove(ctx, tagUUIDs, parentUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to move tags: %w", err)
+	}
+
+	for i, tag := range result.Tags {
+		if err := s.cache.Update(ctx, tag, result.PreviousParentIDs[i]); err != nil {
+			log.Error().Err(err).Msgf("Failed to update tag %s in cache", tag.UUID)
+		}
+
+		s.indexTag(ctx, tag)
+	}
+
+	for _, affectedImage := range result.AffectedImages {
+		if err := s.container.Worker.EnqueueReindexImage(ctx, affectedImage); err != nil {
+			log.Error().Err(err).Int64("id", affectedImage).Msg("Error reindexing image after tag move")
+		}
+	}
+
+	return result.Tags, nil
+}
+
+// RebalancePositions renumbers sibling tags to consecutive positions, bringing the cache
+// and search index in line with the tags that moved. It returns the number of tags moved.
+func (s *TagService) RebalancePositions(ctx context.Context) (int, error) {
+	ctx, span := telemetry.StartSpan(ctx, "TagService.RebalancePositions")
+	defer span.End()
+
+	tags, err := s.repo.RebalancePositions(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to rebalance tag positions: %w", err)
+	}
+
+	for _, tag := range tags {
+		if err := s.cache.Update(ctx, tag, tag.ParentID); err != nil {
+			log.Error().Err(err).Msgf("Failed to update tag %s in cache", tag.UUID)
+		}
+
+		s.indexTag(ctx, tag)
+	}
+
+	return len(tags), nil
+}
+
+// RebuildClosure recomputes the tag closure table from the hierarchy, queueing the images
+// whose inherited tags were affected for reindexing. It returns the differences that
+// were corrected.
+func (s *TagService) RebuildClosure(ctx context.Context) (*models.TagClosureReport, error) {
+	ctx, span := telemetry.StartSpan(ctx, "TagService.RebuildClosure")
+	defer span.End()
+
+	report, affectedImages, err := s.repo.RebuildClosure(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rebuild tag closure: %w", err)
+	}
+
+	for _, affectedImage := range affectedImages {
+		if err := s.container.Worker.EnqueueReindexImage(ctx, affectedImage); err != nil {
+			log.Error().Err(err).Int64("id", affectedImage).Msg("Error reindexing image after tag closure rebuild")
+		}
+	}
+
+	return report, nil
+}
+
 func (s *TagService) Update(ctx context.Context, tag *models.Tag, opts *repositories.TagUpdateOptions) error {
+	ctx, span := telemetry.StartSpan(ctx, "TagService.Update")
+	defer span.End()
+
 	var oldTag *models.Tag
 	var err error
 
@@ -77,9 +252,7 @@ func (s *TagService) Update(ctx context.Context, tag *models.Tag, opts *reposito
 		log.Error().Err(err).Msgf("Failed to update tag %s in cache", tag.UUID)
 	}
 
-	if err := s.search.Index(ctx, tag.ToSearchRecord()); err != nil {
-		log.Error().Err(err).Msgf("Failed to index tag %s", tag.UUID)
-	}
+	s.indexTag(ctx, tag)
 
 	for _, affectedImage := range affectedImages {
 		if err := s.container.Worker.EnqueueReindexImage(ctx, affectedImage); err != nil {
@@ -91,6 +264,9 @@ func (s *TagService) Update(ctx context.Context, tag *models.Tag, opts *reposito
 }
 
 func (s *TagService) Merge(ctx context.Context, source *models.Tag, destination *models.Tag) error {
+	ctx, span := telemetry.StartSpan(ctx, "TagService.Merge")
+	defer span.End()
+
 	affectedImages, err := s.repo.Merge(ctx, source, destination)
 	if err != nil {
 		return fmt.Errorf("failed to merge tags: %w", err)
@@ -105,9 +281,7 @@ func (s *TagService) Merge(ctx context.Context, source *models.Tag, destination
 		log.Error().Err(err).Msgf("Failed to delete tag %s from cache", source.UUID)
 	}
 
-	if err := s.search.Index(ctx, destination.ToSearchRecord()); err != nil {
-		log.Error().Err(err).Msgf("Failed to index tag %s", destination.UUID)
-	}
+	s.indexTag(ctx, destination)
 
 	// Update destination tag in cache
 	if err := s.cache.Update(ctx, destination, destination.ParentID); err != nil {
@@ -124,6 +298,9 @@ func (s *TagService) Merge(ctx context.Context, source *models.Tag, destination
 }
 
 func (s *TagService) Delete(ctx context.Context, tag *models.Tag) error {
+	ctx, span := telemetry.StartSpan(ctx, "TagService.Delete")
+	defer span.End()
+
 	affectedImages, err := s.repo.Delete(ctx, tag)
 	if err != nil {
 		return fmt.Errorf("failed to delet tag: %w", err)
@@ -147,7 +324,14 @@ func (s *TagService) Delete(ctx context.Context, tag *models.Tag) error {
 	return nil
 }
 
-func (s *TagService) Tree(ctx context.Context, start *models.Tag, depth *int) ([]*models.TagTreeNode, error) {
+// Tree builds the tag tree below start, or from the root tags when start is nil. Each
+// level is limited to opts.Limit children, defaulting to defaultTreeBreadth, and
+// opts.AfterPosition continues the listing of the top level. The depth is capped by the
+// configured maximum, marking the tree as truncated when deeper tags were left out.
+func (s *TagService) Tree(ctx context.Context, start *models.Tag, depth *int, opts models.TagChildrenOptions) (*models.TagTree, error) {
+	ctx, span := telemetry.StartSpan(ctx, "TagService.Tree")
+	defer span.End()
+
 	// Determine the starting parent ID
 	var parentID *int64
 	if start != nil {
@@ -160,23 +344,81 @@ func (s *TagService) Tree(ctx context.Context, start *models.Tag, depth *int) ([
 		maxDepth = *depth
 	}
 
+	// Enforce the depth cap, fetching one level beyond it to tell whether anything was cut off
+	depthCap := s.container.Config.TagTreeMaxDepth
+	capped := depthCap > 0 && (maxDepth < 0 || maxDepth > depthCap)
+	fetchDepth := maxDepth
+	if capped {
+		maxDepth = depthCap
+		fetchDepth = depthCap + 1
+	}
+
+	// Bound the number of children returned for every tag
+	if opts.Limit <= 0 {
+		opts.Limit = defaultTreeBreadth
+	} else if opts.Limit > maxTreeBreadth {
+		opts.Limit = maxTreeBreadth
+	}
+
+	// The cache counts the levels to fetch including the top one, rather than those below it
+	cacheDepth := fetchDepth
+	if cacheDepth >= 0 {
+		cacheDepth++
+	}
+
 	// Try to get the tree from cache first
-	tagTreeMap, err := s.cache.GetTagTree(ctx, parentID, maxDepth)
+	var tree *models.TagTree
+	tagTreeMap, err := s.cache.GetTagTree(ctx, parentID, cacheDepth, opts)
 	if err != nil {
 		log.Warn().Err(err).
 			Str("start_uuid", utils.ValueOrEmpty(start, func(t *models.Tag) string { return t.UUID })).
-			Int("max_depth", maxDepth).
+			Int("max_depth", fetchDepth).
 			Msg("Failed to get tag tree from cache, falling back to database")
 
 		// Fall back to database queries for the tree
-		return s.getTreeFromDatabase(ctx, parentID, maxDepth)
+		tree, err = s.getTreeFromDatabase(ctx, parentID, fetchDepth, opts)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		nodes, nextPosition := s.buildTreeFromMap(parentID, tagTreeMap)
+		tree = &models.TagTree{
+			Nodes:        nodes,
+			NextPosition: nextPosition,
+		}
+	}
+
+	if capped {
+		tree.Truncated = pruneTree(tree.Nodes, maxDepth)
 	}
 
-	return s.buildTreeFromMap(parentID, tagTreeMap), nil
+	return tree, nil
 }
 
-// buildTreeFromMap converts a map of parent IDs to children lists into a hierarchical tree
-func (s *TagService) buildTreeFromMap(parentID *int64, tagTreeMap map[int64][]*models.Tag) []*models.TagTreeNode {
+// pruneTree removes the nodes more than depth levels below nodes, reporting whether any
+// were removed
+func pruneTree(nodes []*models.TagTreeNode, depth int) bool {
+	pruned := false
+	for _, node := range nodes {
+		if depth == 0 {
+			if len(node.Children) > 0 || node.NextChildPosition != nil {
+				pruned = true
+			}
+			node.Children = nil
+			node.NextChildPosition = nil
+			continue
+		}
+
+		if pruneTree(node.Children, depth-1) {
+			pruned = true
+		}
+	}
+	return pruned
+}
+
+// buildTreeFromMap converts a map of parent IDs to pages of children into a hierarchical
+// tree, also returning the position to continue the listing of the parent's children
+func (s *TagService) buildTreeFromMap(parentID *int64, tagTreeMap map[int64]*models.TagChildrenPage) ([]*models.TagTreeNode, *int32) {
 	// Determine the key to use for looking up children
 	var key int64
 	if parentID == nil {
@@ -187,47 +429,53 @@ func (s *TagService) buildTreeFromMap(parentID *int64, tagTreeMap map[int64][]*m
 
 	// Get the children for this parent
 	children, ok := tagTreeMap[key]
-	if !ok || len(children) == 0 {
-		return []*models.TagTreeNode{}
+	if !ok || len(children.Tags) == 0 {
+		return []*models.TagTreeNode{}, nil
 	}
 
 	// Build the tree nodes for these children
-	result := make([]*models.TagTreeNode, 0, len(children))
-	for _, child := range children {
+	result := make([]*models.TagTreeNode, 0, len(children.Tags))
+	for _, child := range children.Tags {
 		node := &models.TagTreeNode{
 			Tag: child,
 		}
 
 		// Recursively build children for this node
-		node.Children = s.buildTreeFromMap(&child.ID, tagTreeMap)
+		node.Children, node.NextChildPosition = s.buildTreeFromMap(&child.ID, tagTreeMap)
 
 		result = append(result, node)
 	}
 
-	return result
+	return result, children.NextPosition
 }
 
 // getTreeFromDatabase builds the tree by making database queries
 // This is a fallback method when the cache is not available
-func (s *TagService) getTreeFromDatabase(ctx context.Context, parentID *int64, maxDepth int) ([]*models.TagTreeNode, error) {
+func (s *TagService) getTreeFromDatabase(ctx context.Context, parentID *int64, maxDepth int, opts models.TagChildrenOptions) (*models.TagTree, error) {
 	// Get children from the repository
-	children, err := s.repo.GetChildren(ctx, parentID)
+	children, err := s.repo.GetChildren(ctx, parentID, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get tag children from database: %w", err)
 	}
 
+	tree := &models.TagTree{
+		Nodes:        make([]*models.TagTreeNode, 0, len(children.Tags)),
+		NextPosition: children.NextPosition,
+	}
+
 	// If we're at max depth or there are no children, return
-	if maxDepth == 0 || len(children) == 0 {
-		nodes := make([]*models.TagTreeNode, 0, len(children))
-		for _, child := range children {
-			nodes = append(nodes, &models.TagTreeNode{Tag: child})
+	if maxDepth == 0 || len(children.Tags) == 0 {
+		for _, child := range children.Tags {
+			tree.Nodes = append(tree.Nodes, &models.TagTreeNode{Tag: child})
 		}
-		return nodes, nil
+		return tree, nil
 	}
 
+	// Only the top level continues after the cursor, deeper levels start from their first child
+	childOpts := models.TagChildrenOptions{Limit: opts.Limit}
+
 	// Build the tree recursively
-	result := make([]*models.TagTreeNode, 0, len(children))
-	for _, child := range children {
+	for _, child := range children.Tags {
 		node := &models.TagTreeNode{
 			Tag: child,
 		}
@@ -238,20 +486,24 @@ func (s *TagService) getTreeFromDatabase(ctx context.Context, parentID *int64, m
 			nextDepth--
 		}
 
-		childNodes, err := s.getTreeFromDatabase(ctx, &child.ID, nextDepth)
+		subtree, err := s.getTreeFromDatabase(ctx, &child.ID, nextDepth, childOpts)
 		if err != nil {
 			log.Error().Err(err).Int64("id", child.ID).Msg("Error getting children for tag")
 			continue
 		}
 
-		node.Children = childNodes
-		result = append(result, node)
+		node.Children = subtree.Nodes
+		node.NextChildPosition = subtree.NextPosition
+		tree.Nodes = append(tree.Nodes, node)
 	}
 
-	return result, nil
+	return tree, nil
 }
 
 func (s *TagService) Search(ctx context.Context, options *search.TagSearchOptions) (*utils.PaginatedResult[*models.Tag], error) {
+	ctx, span := telemetry.StartSpan(ctx, "TagService.Search")
+	defer span.End()
+
 	result, err := s.search.Search(ctx, options)
 
 	if err != nil {
@@ -268,36 +520,110 @@ func (s *TagService) Search(ctx context.Context, options *search.TagSearchOption
 		HasMore:    result.HasMore,
 		TotalCount: result.TotalCount,
 		NextCursor: result.NextCursor,
+		Partial:    result.Partial,
 	}, nil
 }
 
+// ExportAll streams every tag to fn, parents before children
+func (s *TagService) ExportAll(ctx context.Context, fn func(*models.TagExportRecord) error) error {
+	return s.repo.ExportAll(ctx, fn)
+}
+
+// indexTag indexes a tag after it was written, either directly or by queueing it on the
+// worker so that failures are retried, depending on the configured index mode
+func (s *TagService) indexTag(ctx context.Context, tag *models.Tag) {
+	if s.container.Config.TagIndexMode == config.IndexModeSync {
+		if err := s.Index(ctx, tag); err != nil {
+			log.Error().Err(err).Msgf("Failed to index tag %s", tag.UUID)
+		}
+		return
+	}
+
+	if err := s.container.Worker.EnqueueReindexTag(ctx, tag.ID); err != nil {
+		log.Error().Err(err).Msgf("Failed to queue indexing of tag %s", tag.UUID)
+	}
+}
+
 func (s *TagService) Index(ctx context.Context, tag *models.Tag) error {
-	if err := s.search.Index(ctx, tag.ToSearchRecord()); err != nil {
+	record, err := s.searchRecord(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	if err := s.search.Index(ctx, record); err != nil {
+		return fmt.Errorf("failed to index tag: %w", err)
+	}
+
+	return nil
+}
+
+// indexInto indexes a tag into a version of the tag index being rebuilt
+func (s *TagService) indexInto(ctx context.Context, index string, tag *models.Tag) error {
+	record, err := s.searchRecord(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	if err := s.search.IndexInto(ctx, index, record); err != nil {
 		return fmt.Errorf("failed to index tag: %w", err)
 	}
 
 	return nil
 }
 
-func (s *TagService) IndexAll(ctx context.Context) error {
+// searchRecord builds the search document for a tag, including its image count
+func (s *TagService) searchRecord(ctx context.Context, tag *models.Tag) (*models.TagSearchRecord, error) {
+	count, err := s.repo.CountImages(ctx, tag.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tag images: %w", err)
+	}
+
+	record := tag.ToSearchRecord()
+	record.ImageCount = count
+
+	return record, nil
+}
+
+// IndexAll reindexes every tag into the given version of the tag index, reporting
+// progress after each one when progress is not nil
+func (s *TagService) IndexAll(ctx context.Context, index string, progress models.IndexProgressFunc) error {
 	tagIDs, err := s.repo.GetAllIDs(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get person IDs: %w", err)
 	}
 
+	var processed, failed int
+	report := func() {
+		if progress != nil {
+			progress(len(tagIDs), processed, failed)
+		}
+	}
+	report()
+
 	for _, id := range tagIDs {
+		// Stop when cancelled
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		tag, err := s.repo.GetByInternalID(ctx, id)
 		if err != nil {
 			log.Error().Err(err).Msgf("Error retrieving tag for id %d", id)
+			failed++
+			report()
 			continue
 		}
 
-		if err := s.search.Index(ctx, tag.ToSearchRecord()); err != nil {
+		if err := s.indexInto(ctx, index, tag); err != nil {
 			log.Error().Err(err).Msgf("Error reindexing tag %s", tag.UUID)
+			failed++
+			report()
 			continue
 		}
 
 		log.Info().Msgf("Reindexed tag %s", tag.UUID)
+		processed++
+		report()
 	}
 
 	return nil
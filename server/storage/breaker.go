@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// CircuitBreaker is an http.RoundTripper that stops sending requests to a backend after
+// a run of consecutive failures. Once the cooldown elapses a single probe request is let
+// through, closing the breaker again if it succeeds.
+type CircuitBreaker struct {
+	next      http.RoundTripper
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewCircuitBreaker(next http.RoundTripper, threshold int, cooldown time.Duration) *CircuitBreaker {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &CircuitBreaker{
+		next:      next,
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+func (b *CircuitBreaker) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !b.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	res, err := b.next.RoundTrip(req)
+
+	// Requests abandoned by the caller say nothing about the health of the backend
+	if req.Context().Err() != nil {
+		b.release()
+		return res, err
+	}
+
+	b.record(err == nil && !isUnhealthyStatus(res.StatusCode))
+
+	return res, err
+}
+
+// State reports the current state of the breaker
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.threshold <= 0 || b.failures < b.threshold:
+		return CircuitClosed
+	case time.Since(b.openedAt) < b.cooldown:
+		return CircuitOpen
+	default:
+		return CircuitHalfOpen
+	}
+}
+
+// allow reports whether a request may be sent, claiming the probe slot when half-open
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+
+	if time.Since(b.openedAt) < b.cooldown || b.probing {
+		return false
+	}
+
+	b.probing = true
+	return true
+}
+
+// release frees the probe slot without recording an outcome
+func (b *CircuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// record updates the breaker with the outcome of a request
+func (b *CircuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	if success {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
+
+// isUnhealthyStatus reports whether a response status indicates an overloaded or failing backend
+func isUnhealthyStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// ExponentialBackoff returns a retry backoff doubling from base up to max, with full jitter
+// so that clients retrying at the same time spread out
+func ExponentialBackoff(base time.Duration, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		backoff := base
+		for i := 1; i < attempt && backoff < max; i++ {
+			backoff *= 2
+		}
+		if backoff > max {
+			backoff = max
+		}
+		if backoff <= 0 {
+			return 0
+		}
+		return time.Duration(rand.Int64N(int64(backoff))) + 1
+	}
+}
@@ -2,21 +2,57 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/conflicts"
 	"github.com/foresturquhart/curator/server/storage/indexes"
+	"github.com/rs/zerolog/log"
 )
 
+// Mapping validation modes, deciding how drift between a live index mapping and the
+// expected mapping is handled at startup
+const (
+	MappingValidationOff    = "off"    // Skip validation
+	MappingValidationWarn   = "warn"   // Log each difference
+	MappingValidationStrict = "strict" // Refuse to start
+)
+
+// ErrUnknownIndex is returned when an index name has no known mapping
+var ErrUnknownIndex = errors.New("unknown index")
+
+// Mapping metadata keys recording the analyzer that the text fields of an index were
+// created with, and the analyzers of their per-language subfields
+const (
+	textAnalyzerMeta  = "text_analyzer"
+	textLanguagesMeta = "text_languages"
+)
+
+// legacyTextAnalyzer is the analyzer of indexes created before it was recorded
+const legacyTextAnalyzer = "english"
+
 type Elastic struct {
-	Client *elasticsearch.TypedClient
+	Client  *elasticsearch.TypedClient
+	Breaker *CircuitBreaker
 }
 
-func NewElastic(cfg elasticsearch.Config) (*Elastic, error) {
+// NewElastic creates an Elasticsearch client. When a breaker is given, every request,
+// including each retry, is sent through it.
+func NewElastic(cfg elasticsearch.Config, breaker *CircuitBreaker) (*Elastic, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if breaker != nil {
+		cfg.Transport = breaker
+	}
+
 	client, err := elasticsearch.NewTypedClient(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create elasticsearch client: %w", err)
@@ -27,33 +63,364 @@ func NewElastic(cfg elasticsearch.Config) (*Elastic, error) {
 	}
 
 	return &Elastic{
-		Client: client,
+		Client:  client,
+		Breaker: breaker,
 	}, nil
 }
 
-func (e *Elastic) Migrate(ctx context.Context) error {
-	for name, mapping := range indexes.Indexes {
+// Migrate creates missing indexes with their text fields analysed as described, and
+// brings the mappings of existing ones up to date. The analyzer of an existing field
+// cannot be changed in place, so existing indexes keep the analyzers they were created
+// with until they are rebuilt, although newly configured languages are added.
+func (e *Elastic) Migrate(ctx context.Context, mappingValidation string, text indexes.TextAnalysis) error {
+	for name, build := range indexes.Indexes {
 		exists, err := e.Client.Indices.Exists(name).Do(ctx)
 		if err != nil {
 			return fmt.Errorf("unable to check if index %s exists: %w", name, err)
 		}
 
 		if !exists {
-			res, err := e.Client.Indices.Create(name).Mappings(mapping).Do(ctx)
+			version, err := e.CreateIndexVersion(ctx, name, text)
 			if err != nil {
-				return fmt.Errorf("failed to create index %s: %w", name, err)
-			} else if !res.Acknowledged {
-				return fmt.Errorf("failed to create index %s: not acknowledged", name)
+				return err
+			}
+
+			if err := e.SwapIndex(ctx, name, version); err != nil {
+				return err
 			}
 		} else {
-			res, err := e.Client.Indices.PutMapping(name).Properties(mapping.Properties).Do(ctx)
+			current, err := e.textAnalysis(ctx, name)
+			if err != nil {
+				return err
+			}
+
+			merged, added := mergeTextAnalysis(name, current, text)
+			mapping := buildMapping(build, merged)
+
+			if mappingValidation != MappingValidationOff {
+				if err := e.validateMapping(ctx, name, mapping, mappingValidation); err != nil {
+					return err
+				}
+			}
+
+			res, err := e.Client.Indices.PutMapping(name).Properties(mapping.Properties).Meta_(mapping.Meta_).Do(ctx)
 			if err != nil {
 				return fmt.Errorf("failed to update index %s: %w", name, err)
 			} else if !res.Acknowledged {
 				return fmt.Errorf("failed to update index %s: not acknowledged", name)
 			}
+
+			if len(added) > 0 {
+				e.reanalyze(ctx, name, added)
+			}
+		}
+	}
+
+	return nil
+}
+
+// reanalyze starts an update by query over an index in the background, so that the
+// subfields of newly added languages are populated for existing documents. A failure
+// to start it is logged rather than returned, as searches still work, just without
+// matching older documents in those languages until the index is rebuilt.
+func (e *Elastic) reanalyze(ctx context.Context, name string, languages []string) {
+	res, err := e.Client.UpdateByQuery(name).Conflicts(conflicts.Proceed).WaitForCompletion(false).Do(ctx)
+	if err != nil {
+		log.Warn().Err(err).Str("index", name).Strs("languages", languages).Msg("Failed to reanalyse existing documents for new languages, rebuild the index to apply them")
+		return
+	}
+
+	log.Info().Str("index", name).Strs("languages", languages).Interface("task", res.Task).Msg("Reanalysing existing documents for new languages")
+}
+
+// CreateIndexVersion creates an empty index from the current mapping of a known index,
+// with its text fields analysed as described, and returns its name. The new index takes
+// no traffic until SwapIndex points the alias of the known index at it.
+func (e *Elastic) CreateIndexVersion(ctx context.Context, name string, text indexes.TextAnalysis) (string, error) {
+	build, ok := indexes.Indexes[name]
+	if !ok {
+		return "", ErrUnknownIndex
+	}
+
+	version := fmt.Sprintf("%s_%d", name, time.Now().UnixMilli())
+
+	res, err := e.Client.Indices.Create(version).Mappings(buildMapping(build, text)).Do(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create index %s: %w", version, err)
+	} else if !res.Acknowledged {
+		return "", fmt.Errorf("failed to create index %s: not acknowledged", version)
+	}
+
+	return version, nil
+}
+
+// SwapIndex atomically points the alias name at the index version, deleting whatever
+// it replaces. Indexes created before aliases were used carry the name themselves, and
+// are deleted in the same step so that the alias can take it over.
+func (e *Elastic) SwapIndex(ctx context.Context, name string, version string) error {
+	var replaced []string
+
+	aliased, err := e.Client.Indices.ExistsAlias(name).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to check if alias %s exists: %w", name, err)
+	}
+
+	if aliased {
+		res, err := e.Client.Indices.GetAlias().Name(name).Do(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to get indexes of alias %s: %w", name, err)
+		}
+		for index := range res {
+			replaced = append(replaced, index)
+		}
+	} else {
+		exists, err := e.Client.Indices.Exists(name).Do(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to check if index %s exists: %w", name, err)
+		}
+		if exists {
+			replaced = append(replaced, name)
+		}
+	}
+
+	actions := []types.IndicesAction{{Add: &types.AddAction{Index: &version, Alias: &name}}}
+	for _, index := range replaced {
+		actions = append(actions, types.IndicesAction{RemoveIndex: &types.RemoveIndexAction{Index: &index}})
+	}
+
+	res, err := e.Client.Indices.UpdateAliases().Actions(actions...).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to point alias %s at index %s: %w", name, version, err)
+	} else if !res.Acknowledged {
+		return fmt.Errorf("failed to point alias %s at index %s: not acknowledged", name, version)
+	}
+
+	return nil
+}
+
+// DeleteIndex deletes an index version that never took traffic, such as one left by a
+// rebuild that was cancelled or failed
+func (e *Elastic) DeleteIndex(ctx context.Context, version string) error {
+	res, err := e.Client.Indices.Delete(version).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete index %s: %w", version, err)
+	} else if !res.Acknowledged {
+		return fmt.Errorf("failed to delete index %s: not acknowledged", version)
+	}
+
+	return nil
+}
+
+// DeleteDocuments deletes the documents with the given IDs from an index, ignoring those
+// it does not hold
+func (e *Elastic) DeleteDocuments(ctx context.Context, index string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := e.Client.DeleteByQuery(index).
+		Query(&types.Query{Ids: &types.IdsQuery{Values: ids}}).
+		Conflicts(conflicts.Proceed).
+		Refresh(true).
+		Do(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete documents from index %s: %w", index, err)
+	}
+
+	return nil
+}
+
+// indexMapping returns the mapping of an index, which may be reached through its alias
+// and is then keyed by the index version in the response
+func (e *Elastic) indexMapping(ctx context.Context, name string) (*types.TypeMapping, error) {
+	res, err := e.Client.Indices.GetMapping().Index(name).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get mapping of index %s: %w", name, err)
+	}
+
+	for _, record := range res {
+		if len(res) == 1 {
+			return &record.Mappings, nil
 		}
 	}
 
+	return nil, fmt.Errorf("unable to get mapping of index %s: expected one index, found %d", name, len(res))
+}
+
+// buildMapping builds the mapping of an index, recording its text analysis in its
+// metadata so that it can be recovered once the index exists
+func buildMapping(build indexes.MappingFunc, text indexes.TextAnalysis) *types.TypeMapping {
+	mapping := build(text)
+
+	analyzer, _ := json.Marshal(text.Analyzer)
+	languages, _ := json.Marshal(text.Languages)
+	mapping.Meta_ = types.Metadata{
+		textAnalyzerMeta:  analyzer,
+		textLanguagesMeta: languages,
+	}
+
+	return mapping
+}
+
+// textAnalysis reports how the text fields of an existing index were analysed when
+// they were created
+func (e *Elastic) textAnalysis(ctx context.Context, name string) (indexes.TextAnalysis, error) {
+	text := indexes.TextAnalysis{Analyzer: legacyTextAnalyzer}
+
+	mapping, err := e.indexMapping(ctx, name)
+	if err != nil {
+		return text, err
+	}
+
+	if value, ok := mapping.Meta_[textAnalyzerMeta]; ok {
+		if err := json.Unmarshal(value, &text.Analyzer); err != nil {
+			return text, fmt.Errorf("unable to read text analyzer of index %s: %w", name, err)
+		}
+	}
+
+	if value, ok := mapping.Meta_[textLanguagesMeta]; ok {
+		if err := json.Unmarshal(value, &text.Languages); err != nil {
+			return text, fmt.Errorf("unable to read text languages of index %s: %w", name, err)
+		}
+	}
+
+	return text, nil
+}
+
+// mergeTextAnalysis combines the text analysis of an existing index with the configured
+// analysis. Existing fields keep their analyzers, logging a warning where the
+// configuration differs, while configured languages the index lacks are added and
+// returned.
+func mergeTextAnalysis(name string, current, configured indexes.TextAnalysis) (indexes.TextAnalysis, []string) {
+	if current.Analyzer != configured.Analyzer {
+		log.Warn().Str("index", name).Msgf("Index text fields use the %s analyzer rather than the configured %s analyzer, rebuild the index to apply it", current.Analyzer, configured.Analyzer)
+	}
+
+	languages := maps.Clone(current.Languages)
+	if languages == nil {
+		languages = make(map[string]string)
+	}
+
+	var added []string
+	for code, analyzer := range configured.Languages {
+		existing, ok := languages[code]
+		if !ok {
+			languages[code] = analyzer
+			added = append(added, code)
+		} else if existing != analyzer {
+			log.Warn().Str("index", name).Msgf("Index %s text subfields use the %s analyzer rather than the configured %s analyzer, rebuild the index to apply it", code, existing, analyzer)
+		}
+	}
+
+	sort.Strings(added)
+
+	return indexes.TextAnalysis{Analyzer: current.Analyzer, Languages: languages}, added
+}
+
+// validateMapping compares the live mapping of an index against the expected mapping,
+// logging each difference, or failing in strict mode. Fields present only in the live
+// mapping are ignored, as they are harmless, and fields missing from it are only
+// logged, as updating the mapping adds them.
+func (e *Elastic) validateMapping(ctx context.Context, name string, expected *types.TypeMapping, mode string) error {
+	mapping, err := e.indexMapping(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	expectedProperties, err := propertiesToMap(expected.Properties)
+	if err != nil {
+		return fmt.Errorf("unable to read expected mapping of index %s: %w", name, err)
+	}
+
+	liveProperties, err := propertiesToMap(mapping.Properties)
+	if err != nil {
+		return fmt.Errorf("unable to read live mapping of index %s: %w", name, err)
+	}
+
+	differences, missing := mappingDifferences("", expectedProperties, liveProperties)
+	for _, path := range missing {
+		log.Info().Str("index", name).Msgf("Adding %s to index mapping", path)
+	}
+
+	if len(differences) == 0 {
+		return nil
+	}
+
+	if mode == MappingValidationStrict {
+		return fmt.Errorf("mapping of index %s has drifted: %s", name, strings.Join(differences, "; "))
+	}
+
+	for _, difference := range differences {
+		log.Warn().Str("index", name).Msg("Index mapping drift: " + difference)
+	}
+
 	return nil
 }
+
+// propertiesToMap converts typed mapping properties into their generic JSON form
+func propertiesToMap(properties map[string]types.Property) (map[string]any, error) {
+	data, err := json.Marshal(properties)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// mappingDifferences lists the expected fields whose type or analyzer differs in the
+// live mapping, and separately those missing from it, recursing into object properties
+// and multi-fields
+func mappingDifferences(prefix string, expected map[string]any, live map[string]any) ([]string, []string) {
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var differences, missing []string
+	for _, name := range names {
+		path := prefix + name
+
+		expectedField, _ := expected[name].(map[string]any)
+		liveField, ok := live[name].(map[string]any)
+		if !ok {
+			missing = append(missing, path)
+			continue
+		}
+
+		if expectedType, liveType := fieldType(expectedField), fieldType(liveField); expectedType != liveType {
+			differences = append(differences, fmt.Sprintf("%s has type %s, expected %s", path, liveType, expectedType))
+			continue
+		}
+
+		if expectedAnalyzer, ok := expectedField["analyzer"]; ok && liveField["analyzer"] != expectedAnalyzer {
+			differences = append(differences, fmt.Sprintf("%s has analyzer %v, expected %v", path, liveField["analyzer"], expectedAnalyzer))
+		}
+
+		for _, nested := range []string{"properties", "fields"} {
+			expectedNested, _ := expectedField[nested].(map[string]any)
+			liveNested, _ := liveField[nested].(map[string]any)
+			if len(expectedNested) > 0 {
+				nestedDifferences, nestedMissing := mappingDifferences(path+".", expectedNested, liveNested)
+				differences = append(differences, nestedDifferences...)
+				missing = append(missing, nestedMissing...)
+			}
+		}
+	}
+
+	return differences, missing
+}
+
+// fieldType returns the type of a mapped field, where fields with sub-properties and
+// no explicit type are objects
+func fieldType(field map[string]any) string {
+	if fieldType, ok := field["type"].(string); ok {
+		return fieldType
+	}
+	return "object"
+}
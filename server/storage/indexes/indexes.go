@@ -1,5 +1,42 @@
 package indexes
 
-import "github.com/elastic/go-elasticsearch/v8/typedapi/types"
+import (
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/foresturquhart/curator/server/utils"
+)
 
-var Indexes = make(map[string]*types.TypeMapping)
+// TextAnalysis describes how the text fields of an index are analysed
+type TextAnalysis struct {
+	// Analyzer analyses text fields
+	Analyzer string
+
+	// Languages maps language codes to the analyzers of the per-language subfields that
+	// multilingual text fields are given
+	Languages map[string]string
+}
+
+// MappingFunc builds the mapping of an index, analysing its text fields as described
+type MappingFunc func(text TextAnalysis) *types.TypeMapping
+
+var Indexes = make(map[string]MappingFunc)
+
+// multilingualText maps a text field analysed with the default analyzer, along with a
+// keyword subfield and a subfield per configured language, such as title.en
+func multilingualText(text TextAnalysis) types.TextProperty {
+	fields := map[string]types.Property{
+		"keyword": types.KeywordProperty{
+			IgnoreAbove: utils.NewPointer(256),
+		},
+	}
+
+	for code, analyzer := range text.Languages {
+		fields[code] = types.TextProperty{
+			Analyzer: utils.NewPointer(analyzer),
+		}
+	}
+
+	return types.TextProperty{
+		Analyzer: utils.NewPointer(text.Analyzer),
+		Fields:   fields,
+	}
+}
@@ -6,58 +6,70 @@ import (
 )
 
 func init() {
-	Indexes["people"] = &types.TypeMapping{
-		Properties: map[string]types.Property{
-			"id":   types.LongNumberProperty{},
-			"uuid": types.KeywordProperty{},
-			"name": types.TextProperty{
-				Analyzer: utils.NewPointer("english"),
-				Fields: map[string]types.Property{
-					"keyword": types.KeywordProperty{
-						IgnoreAbove: utils.NewPointer(256),
+	Indexes["people"] = func(text TextAnalysis) *types.TypeMapping {
+		return &types.TypeMapping{
+			Properties: map[string]types.Property{
+				"id":   types.LongNumberProperty{},
+				"uuid": types.KeywordProperty{},
+				"name": types.TextProperty{
+					Analyzer: utils.NewPointer(text.Analyzer),
+					Fields: map[string]types.Property{
+						"keyword": types.KeywordProperty{
+							IgnoreAbove: utils.NewPointer(256),
+						},
 					},
 				},
-			},
-			"description": types.TextProperty{
-				Analyzer: utils.NewPointer("english"),
-				Fields: map[string]types.Property{
-					"keyword": types.KeywordProperty{
-						IgnoreAbove: utils.NewPointer(256),
+				"description": types.TextProperty{
+					Analyzer: utils.NewPointer(text.Analyzer),
+					Fields: map[string]types.Property{
+						"keyword": types.KeywordProperty{
+							IgnoreAbove: utils.NewPointer(256),
+						},
 					},
 				},
-			},
-			"created_at": types.DateProperty{},
-			"updated_at": types.DateProperty{},
+				"aliases": types.TextProperty{
+					Analyzer: utils.NewPointer(text.Analyzer),
+					Fields: map[string]types.Property{
+						"keyword": types.KeywordProperty{
+							IgnoreAbove: utils.NewPointer(256),
+						},
+					},
+				},
+				"active_since": types.DateProperty{},
+				"active_until": types.DateProperty{},
+				"created_at":   types.DateProperty{},
+				"updated_at":   types.DateProperty{},
 
-			// Nested properties
-			"sources": types.NestedProperty{
-				Properties: map[string]types.Property{
-					"url": types.TextProperty{
-						Analyzer: utils.NewPointer("english"),
-						Fields: map[string]types.Property{
-							"keyword": types.KeywordProperty{
-								IgnoreAbove: utils.NewPointer(256),
+				// Nested properties
+				"sources": types.NestedProperty{
+					Properties: map[string]types.Property{
+						"url": types.TextProperty{
+							Analyzer: utils.NewPointer(text.Analyzer),
+							Fields: map[string]types.Property{
+								"keyword": types.KeywordProperty{
+									IgnoreAbove: utils.NewPointer(256),
+								},
 							},
 						},
-					},
-					"name": types.TextProperty{
-						Analyzer: utils.NewPointer("english"),
-						Fields: map[string]types.Property{
-							"keyword": types.KeywordProperty{
-								IgnoreAbove: utils.NewPointer(256),
+						"name": types.TextProperty{
+							Analyzer: utils.NewPointer(text.Analyzer),
+							Fields: map[string]types.Property{
+								"keyword": types.KeywordProperty{
+									IgnoreAbove: utils.NewPointer(256),
+								},
 							},
 						},
-					},
-					"description": types.TextProperty{
-						Analyzer: utils.NewPointer("english"),
-						Fields: map[string]types.Property{
-							"keyword": types.KeywordProperty{
-								IgnoreAbove: utils.NewPointer(256),
+						"description": types.TextProperty{
+							Analyzer: utils.NewPointer(text.Analyzer),
+							Fields: map[string]types.Property{
+								"keyword": types.KeywordProperty{
+									IgnoreAbove: utils.NewPointer(256),
+								},
 							},
 						},
 					},
 				},
 			},
-		},
+		}
 	}
 }
@@ -6,29 +6,32 @@ import (
 )
 
 func init() {
-	Indexes["tags"] = &types.TypeMapping{
-		Properties: map[string]types.Property{
-			"id":   types.LongNumberProperty{},
-			"uuid": types.KeywordProperty{},
-			"name": types.TextProperty{
-				Analyzer: utils.NewPointer("english"),
-				Fields: map[string]types.Property{
-					"keyword": types.KeywordProperty{
-						IgnoreAbove: utils.NewPointer(256),
+	Indexes["tags"] = func(text TextAnalysis) *types.TypeMapping {
+		return &types.TypeMapping{
+			Properties: map[string]types.Property{
+				"id":   types.LongNumberProperty{},
+				"uuid": types.KeywordProperty{},
+				"name": types.TextProperty{
+					Analyzer: utils.NewPointer(text.Analyzer),
+					Fields: map[string]types.Property{
+						"keyword": types.KeywordProperty{
+							IgnoreAbove: utils.NewPointer(256),
+						},
 					},
 				},
-			},
-			"description": types.TextProperty{
-				Analyzer: utils.NewPointer("english"),
-				Fields: map[string]types.Property{
-					"keyword": types.KeywordProperty{
-						IgnoreAbove: utils.NewPointer(256),
+				"description": types.TextProperty{
+					Analyzer: utils.NewPointer(text.Analyzer),
+					Fields: map[string]types.Property{
+						"keyword": types.KeywordProperty{
+							IgnoreAbove: utils.NewPointer(256),
+						},
 					},
 				},
+				"parent_id":   types.LongNumberProperty{},
+				"created_at":  types.DateProperty{},
+				"updated_at":  types.DateProperty{},
+				"image_count": types.LongNumberProperty{},
 			},
-			"parent_id":  types.LongNumberProperty{},
-			"created_at": types.DateProperty{},
-			"updated_at": types.DateProperty{},
-		},
+		}
 	}
 }
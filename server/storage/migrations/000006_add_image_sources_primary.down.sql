@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_image_sources_primary;
+ALTER TABLE image_sources DROP COLUMN IF EXISTS is_primary;
\ No newline at end of file
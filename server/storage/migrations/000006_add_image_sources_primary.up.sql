@@ -0,0 +1,12 @@
+-- ============================================================================
+-- Image Sources Primary Flag
+-- ============================================================================
+
+-- Flag marking the canonical source for an image
+ALTER TABLE image_sources
+    ADD COLUMN is_primary BOOLEAN NOT NULL DEFAULT FALSE; -- Whether this is the image's primary source
+
+-- Ensure at most one primary source exists per image
+CREATE UNIQUE INDEX idx_image_sources_primary
+    ON image_sources (image_id)
+    WHERE is_primary;
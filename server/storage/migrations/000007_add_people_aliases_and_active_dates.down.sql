@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS trg_update_people_from_person_aliases ON person_aliases;
+DROP INDEX IF EXISTS idx_person_aliases_alias;
+DROP TABLE IF EXISTS person_aliases;
+ALTER TABLE people DROP CONSTRAINT IF EXISTS chk_people_active_period;
+ALTER TABLE people DROP COLUMN IF EXISTS active_until;
+ALTER TABLE people DROP COLUMN IF EXISTS active_since;
\ No newline at end of file
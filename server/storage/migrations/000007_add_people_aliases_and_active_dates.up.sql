@@ -0,0 +1,32 @@
+-- ============================================================================
+-- People Active Period
+-- ============================================================================
+
+-- Optional period during which a person was active
+ALTER TABLE people
+    ADD COLUMN active_since DATE, -- Optional start of the person's active period
+    ADD COLUMN active_until DATE, -- Optional end of the person's active period
+    ADD CONSTRAINT chk_people_active_period CHECK (active_since IS NULL OR active_until IS NULL OR active_since <= active_until);
+
+-- ============================================================================
+-- Person Aliases Table
+-- ============================================================================
+
+-- Create person_aliases table to hold alternative names for people
+CREATE TABLE person_aliases (
+    id SERIAL PRIMARY KEY, -- Internal primary key for relationships
+    person_id INT NOT NULL, -- Reference to associated person
+    alias TEXT NOT NULL, -- Alternative name
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP, -- Record creation timestamp
+    FOREIGN KEY (person_id) REFERENCES people(id) ON DELETE CASCADE, -- Auto-delete when person is removed
+    CONSTRAINT person_aliases_unique_alias UNIQUE (person_id, alias) -- Prevent duplicate aliases for the same person
+);
+
+-- Index for efficient lookup of people by alias
+CREATE INDEX idx_person_aliases_alias ON person_aliases (alias);
+
+-- Trigger to update people timestamps when aliases are added/removed
+CREATE TRIGGER trg_update_people_from_person_aliases
+AFTER INSERT OR UPDATE OR DELETE ON person_aliases
+FOR EACH ROW
+EXECUTE FUNCTION update_people_from_link();
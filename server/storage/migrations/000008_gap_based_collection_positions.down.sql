@@ -0,0 +1,106 @@
+-- Restore contiguous positions before reinstating the shifting trigger
+UPDATE image_collections ic
+SET position = ranked.rank
+FROM (
+    SELECT collection_id, image_id, ROW_NUMBER() OVER (PARTITION BY collection_id ORDER BY position) AS rank
+    FROM image_collections
+) ranked
+WHERE ic.collection_id = ranked.collection_id AND ic.image_id = ranked.image_id;
+
+ALTER TABLE image_collections DROP CONSTRAINT unique_position_per_collection;
+ALTER TABLE image_collections ADD CONSTRAINT unique_position_per_collection UNIQUE (collection_id, position);
+
+CREATE OR REPLACE FUNCTION get_next_position(p_collection_id INT)
+RETURNS INT AS $$
+DECLARE
+    next_pos INT;
+BEGIN
+    SELECT COALESCE(MAX(position) + 1, 1)
+    INTO next_pos
+    FROM image_collections
+    WHERE collection_id = p_collection_id;
+
+    RETURN next_pos;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION reorder_collection_items() RETURNS TRIGGER AS $$
+DECLARE
+    old_pos INT;
+    new_pos INT;
+    affected_collection_id INT;
+BEGIN
+    IF (TG_OP = 'INSERT') THEN
+        affected_collection_id := NEW.collection_id;
+        new_pos := NEW.position;
+
+        UPDATE image_collections
+        SET position = position + 1
+        WHERE collection_id = affected_collection_id
+          AND image_id != NEW.image_id
+          AND position >= new_pos;
+
+        RETURN NEW;
+    ELSIF (TG_OP = 'UPDATE') THEN
+        IF (OLD.collection_id != NEW.collection_id) THEN
+            UPDATE image_collections
+            SET position = position - 1
+            WHERE collection_id = OLD.collection_id
+              AND position > OLD.position;
+
+            UPDATE image_collections
+            SET position = position + 1
+            WHERE collection_id = NEW.collection_id
+              AND position >= NEW.position;
+
+            RETURN NEW;
+        ELSIF (OLD.position != NEW.position) THEN
+            old_pos := OLD.position;
+            new_pos := NEW.position;
+            affected_collection_id := NEW.collection_id;
+
+            IF (old_pos < new_pos) THEN
+                UPDATE image_collections
+                SET position = position - 1
+                WHERE collection_id = affected_collection_id
+                  AND image_id != NEW.image_id
+                  AND position > old_pos
+                  AND position <= new_pos;
+            ELSIF (old_pos > new_pos) THEN
+                UPDATE image_collections
+                SET position = position + 1
+                WHERE collection_id = affected_collection_id
+                  AND image_id != NEW.image_id
+                  AND position >= new_pos
+                  AND position < old_pos;
+            END IF;
+
+            RETURN NEW;
+        END IF;
+    ELSIF (TG_OP = 'DELETE') THEN
+        UPDATE image_collections
+        SET position = position - 1
+        WHERE collection_id = OLD.collection_id
+          AND position > OLD.position;
+
+        RETURN OLD;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER reorder_collection_items_trigger
+BEFORE INSERT OR UPDATE OR DELETE ON image_collections
+FOR EACH ROW
+EXECUTE FUNCTION reorder_collection_items();
+
+CREATE OR REPLACE FUNCTION move_collection_item(
+    p_collection_id INT,
+    p_image_id INT,
+    p_new_position INT
+) RETURNS VOID AS $$
+BEGIN
+    UPDATE image_collections SET position = p_new_position WHERE collection_id = p_collection_id AND image_id = p_image_id;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,42 @@
+-- ============================================================================
+-- Gap-Based Collection Positions
+-- ============================================================================
+
+-- Positions are spaced apart so that an image can be moved between two neighbours
+-- by writing a single row, instead of shifting every following item
+DROP TRIGGER IF EXISTS reorder_collection_items_trigger ON image_collections;
+DROP FUNCTION IF EXISTS move_collection_item(INT, INT, INT);
+DROP FUNCTION IF EXISTS reorder_collection_items();
+
+-- Allow positions to be rewritten in bulk within a transaction
+ALTER TABLE image_collections DROP CONSTRAINT unique_position_per_collection;
+ALTER TABLE image_collections ADD CONSTRAINT unique_position_per_collection
+    UNIQUE (collection_id, position) DEFERRABLE INITIALLY IMMEDIATE; -- Prevent duplicate positions
+
+-- Spread existing positions out by the default gap
+UPDATE image_collections ic
+SET position = ranked.rank * 1024
+FROM (
+    SELECT collection_id, image_id, ROW_NUMBER() OVER (PARTITION BY collection_id ORDER BY position) AS rank
+    FROM image_collections
+) ranked
+WHERE ic.collection_id = ranked.collection_id AND ic.image_id = ranked.image_id;
+
+-- ============================================================================
+-- Helper Function: Get Next Position
+-- ============================================================================
+
+-- Function to get the next available position for a new item in a collection, leaving a gap after the last item
+CREATE OR REPLACE FUNCTION get_next_position(p_collection_id INT)
+RETURNS INT AS $$
+DECLARE
+    next_pos INT;
+BEGIN
+    SELECT COALESCE(MAX(position) + 1024, 1024)
+    INTO next_pos
+    FROM image_collections
+    WHERE collection_id = p_collection_id;
+
+    RETURN next_pos;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_search_queries_created_at;
+DROP TABLE IF EXISTS search_queries;
@@ -0,0 +1,16 @@
+-- ============================================================================
+-- Search Query Log
+-- ============================================================================
+
+CREATE TABLE search_queries (
+    id BIGSERIAL PRIMARY KEY, -- Internal primary key
+    query TEXT NOT NULL DEFAULT '', -- Normalised free-text terms, empty for filter-only searches
+    filters JSONB NOT NULL DEFAULT '{}', -- Non-sensitive summary of the filters applied
+    result_count BIGINT NOT NULL, -- Total number of matching results
+    latency_ms INT NOT NULL, -- Time taken to serve the search
+    partial BOOLEAN NOT NULL DEFAULT FALSE, -- Whether the search timed out with partial results
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP -- When the search was made
+);
+
+-- Index for aggregating recent queries
+CREATE INDEX idx_search_queries_created_at ON search_queries (created_at);
@@ -0,0 +1 @@
+ALTER TABLE people DROP COLUMN IF EXISTS primary_image_id;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- ============================================================================
+-- People Primary Image
+-- ============================================================================
+
+-- Optional image chosen to represent a person, cleared if the image is removed
+ALTER TABLE people
+    ADD COLUMN primary_image_id INT REFERENCES images(id) ON DELETE SET NULL; -- Optional reference to the person's representative image
@@ -0,0 +1,2 @@
+ALTER TABLE images DROP CONSTRAINT IF EXISTS chk_images_rating;
+ALTER TABLE images DROP COLUMN IF EXISTS rating;
\ No newline at end of file
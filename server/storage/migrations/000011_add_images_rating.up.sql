@@ -0,0 +1,8 @@
+-- ============================================================================
+-- Image Rating
+-- ============================================================================
+
+-- Optional curator rating, wide enough for star ratings or percentage scores
+ALTER TABLE images
+    ADD COLUMN rating SMALLINT, -- Optional rating between 0 and 100
+    ADD CONSTRAINT chk_images_rating CHECK (rating IS NULL OR rating BETWEEN 0 AND 100);
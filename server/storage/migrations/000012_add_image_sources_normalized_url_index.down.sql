@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_image_sources_normalized_url;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- ============================================================================
+-- Image Sources Normalised URL Index
+-- ============================================================================
+
+-- Index for exact source lookups, ignoring case, fragments and trailing slashes
+CREATE INDEX idx_image_sources_normalized_url
+    ON image_sources (lower(rtrim(split_part(url, '#', 1), '/')));
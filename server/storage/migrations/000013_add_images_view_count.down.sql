@@ -0,0 +1 @@
+ALTER TABLE images DROP COLUMN IF EXISTS view_count;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- ============================================================================
+-- Image View Count
+-- ============================================================================
+
+-- Number of recorded views, buffered in Redis and flushed periodically
+ALTER TABLE images
+    ADD COLUMN view_count BIGINT NOT NULL DEFAULT 0; -- Total views flushed so far
@@ -0,0 +1 @@
+ALTER TABLE images DROP COLUMN IF EXISTS camera_make, DROP COLUMN IF EXISTS camera_model;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- ============================================================================
+-- Image Capture Device
+-- ============================================================================
+
+-- Camera recorded in the EXIF data of the uploaded original
+ALTER TABLE images
+    ADD COLUMN camera_make TEXT,  -- EXIF Make, e.g. SONY
+    ADD COLUMN camera_model TEXT; -- EXIF Model, e.g. ILCE-7M3
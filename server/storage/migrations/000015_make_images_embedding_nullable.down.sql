@@ -0,0 +1 @@
+ALTER TABLE images ALTER COLUMN embedding SET NOT NULL;
\ No newline at end of file
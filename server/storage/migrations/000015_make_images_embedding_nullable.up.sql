@@ -0,0 +1,7 @@
+-- ============================================================================
+-- Optional Image Embeddings
+-- ============================================================================
+
+-- Embeddings may be kept in Qdrant alone, leaving the column empty
+ALTER TABLE images
+    ALTER COLUMN embedding DROP NOT NULL;
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS trg_move_tag_closure ON tags;
+DROP TRIGGER IF EXISTS trg_insert_tag_closure ON tags;
+DROP FUNCTION IF EXISTS move_tag_closure();
+DROP FUNCTION IF EXISTS insert_tag_closure();
+DROP FUNCTION IF EXISTS rebuild_tag_closure();
+DROP TABLE IF EXISTS tag_closure;
\ No newline at end of file
@@ -0,0 +1,92 @@
+-- ============================================================================
+-- Tag Closure Table
+-- ============================================================================
+
+-- Every ancestor/descendant pair of the tag hierarchy, including each tag paired with
+-- itself at depth 0. Derived entirely from tags.parent_id and kept in step by triggers.
+CREATE TABLE tag_closure (
+    ancestor INT NOT NULL REFERENCES tags(id) ON DELETE CASCADE, -- Ancestor tag
+    descendant INT NOT NULL REFERENCES tags(id) ON DELETE CASCADE, -- Descendant tag
+    depth INT NOT NULL, -- Number of levels between the two tags
+    PRIMARY KEY (ancestor, descendant)
+);
+
+-- Index descendants for ancestor lookups
+CREATE INDEX idx_tag_closure_descendant ON tag_closure (descendant);
+
+/**
+ * Function: rebuild_tag_closure
+ *
+ * Discards the closure table and recomputes it from the parent_id relationships.
+ *
+ * Returns:
+ *   The number of closure rows written
+ */
+CREATE OR REPLACE FUNCTION rebuild_tag_closure() RETURNS BIGINT AS $$
+DECLARE
+    v_rows BIGINT;
+BEGIN
+    DELETE FROM tag_closure;
+
+    WITH RECURSIVE closure AS (
+        SELECT id AS ancestor, id AS descendant, 0 AS depth
+        FROM tags
+        UNION ALL
+        SELECT c.ancestor, t.id, c.depth + 1
+        FROM closure c
+        JOIN tags t ON t.parent_id = c.descendant
+    )
+    INSERT INTO tag_closure (ancestor, descendant, depth)
+    SELECT ancestor, descendant, depth FROM closure;
+
+    GET DIAGNOSTICS v_rows = ROW_COUNT;
+    RETURN v_rows;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Link a new tag to itself and to every ancestor of its parent
+CREATE OR REPLACE FUNCTION insert_tag_closure() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO tag_closure (ancestor, descendant, depth)
+    VALUES (NEW.id, NEW.id, 0);
+
+    INSERT INTO tag_closure (ancestor, descendant, depth)
+    SELECT ancestor, NEW.id, depth + 1
+    FROM tag_closure
+    WHERE descendant = NEW.parent_id;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Detach a moved subtree from its former ancestors and attach it beneath the new parent
+CREATE OR REPLACE FUNCTION move_tag_closure() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM tag_closure
+    WHERE descendant IN (SELECT descendant FROM tag_closure WHERE ancestor = NEW.id)
+      AND ancestor IN (SELECT ancestor FROM tag_closure WHERE descendant = NEW.id AND ancestor <> NEW.id);
+
+    INSERT INTO tag_closure (ancestor, descendant, depth)
+    SELECT above.ancestor, below.descendant, above.depth + below.depth + 1
+    FROM tag_closure above
+    CROSS JOIN tag_closure below
+    WHERE above.descendant = NEW.parent_id
+      AND below.ancestor = NEW.id;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_insert_tag_closure
+AFTER INSERT ON tags
+FOR EACH ROW
+EXECUTE FUNCTION insert_tag_closure();
+
+CREATE TRIGGER trg_move_tag_closure
+AFTER UPDATE OF parent_id ON tags
+FOR EACH ROW
+WHEN (OLD.parent_id IS DISTINCT FROM NEW.parent_id)
+EXECUTE FUNCTION move_tag_closure();
+
+-- Populate the closure of the existing hierarchy
+SELECT rebuild_tag_closure();
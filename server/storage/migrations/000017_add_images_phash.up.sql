@@ -0,0 +1,8 @@
+-- ============================================================================
+-- Image Perceptual Hash
+-- ============================================================================
+
+-- 64-bit difference hash of the uploaded image, used to detect near-duplicates by the
+-- number of differing bits. Images uploaded before it was introduced have none.
+ALTER TABLE images
+    ADD COLUMN phash BIGINT; -- Hash bits stored as a signed integer
@@ -0,0 +1,6 @@
+-- Enum values cannot be dropped, so the type is recreated without them. Images stored
+-- in either format must be removed first.
+ALTER TYPE image_format RENAME TO image_format_old;
+CREATE TYPE image_format AS ENUM ('jpeg', 'png', 'gif', 'bmp');
+ALTER TABLE images ALTER COLUMN format TYPE image_format USING format::text::image_format;
+DROP TYPE image_format_old;
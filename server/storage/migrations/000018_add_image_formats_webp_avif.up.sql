@@ -0,0 +1,6 @@
+-- ============================================================================
+-- WebP and AVIF Image Formats
+-- ============================================================================
+
+ALTER TYPE image_format ADD VALUE IF NOT EXISTS 'webp';
+ALTER TYPE image_format ADD VALUE IF NOT EXISTS 'avif';
@@ -0,0 +1,8 @@
+-- ============================================================================
+-- Image Language
+-- ============================================================================
+
+-- Language of the title and description, naming one of the configured text languages
+-- so that they are searched through its per-language subfields
+ALTER TABLE images
+    ADD COLUMN language TEXT; -- Language code, such as en
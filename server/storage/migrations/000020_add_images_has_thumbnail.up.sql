@@ -0,0 +1,8 @@
+-- ============================================================================
+-- Image Thumbnails
+-- ============================================================================
+
+-- Whether a thumbnail has been stored for the image. Images uploaded before thumbnails
+-- were introduced, or in formats that cannot be decoded, have none.
+ALTER TABLE images
+    ADD COLUMN has_thumbnail BOOLEAN NOT NULL DEFAULT FALSE; -- Thumbnail stored under thumbnails/
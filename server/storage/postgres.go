@@ -8,13 +8,17 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/foresturquhart/curator/server/telemetry"
+	"github.com/foresturquhart/curator/server/utils"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	pgxvec "github.com/pgvector/pgvector-go/pgx"
+	"github.com/rs/zerolog/log"
 )
 
 //go:embed migrations/*.sql
@@ -34,6 +38,8 @@ func NewPostgres(dsn string) (*Postgres, error) {
 		return nil, fmt.Errorf("unable to parse postgres config: %w", err)
 	}
 
+	cfg.ConnConfig.Tracer = telemetry.PostgresTracer{}
+
 	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
 		if _, err := conn.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS vector;"); err != nil {
 			return fmt.Errorf("unable to load pgvector extension: %w", err)
@@ -57,10 +63,149 @@ func NewPostgres(dsn string) (*Postgres, error) {
 	}, nil
 }
 
+// WithTx runs fn inside a transaction, committing it if fn succeeds and rolling it back
+// otherwise. Errors returned by fn are passed through unchanged.
+func (d *Postgres) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return d.WithTxOptions(ctx, pgx.TxOptions{}, fn)
+}
+
+// WithTxOptions is like WithTx, but begins the transaction with the given options
+func (d *Postgres) WithTxOptions(ctx context.Context, options pgx.TxOptions, fn func(tx pgx.Tx) error) error {
+	tx, err := d.Pool.BeginTx(ctx, options)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	// Rolling back a committed transaction is a no-op, so this only undoes failures
+	defer func() {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
+			// Just log the rollback error as there's not much we can do at this point
+			log.Error().Err(rollbackErr).Msg("Failed to roll back transaction")
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
 func (d *Postgres) Close() {
 	d.Pool.Close()
 }
 
+// nameNormalizationForms maps the configured name normalisation forms to the forms
+// understood by the Postgres normalize function
+var nameNormalizationForms = map[string]string{
+	utils.NameNormalizationNFC:  "NFC",
+	utils.NameNormalizationNFKC: "NFKC",
+}
+
+// normalizedNameColumns lists the columns holding tag and person names, along with the
+// column scoping their uniqueness, if any
+var normalizedNameColumns = []struct {
+	table  string
+	column string
+	scope  string
+}{
+	{table: "tags", column: "name"},
+	{table: "people", column: "name"},
+	{table: "person_aliases", column: "alias", scope: "person_id"},
+}
+
+// NormalizeNames brings tag names, person names and aliases stored before a
+// normalisation form was configured into that form, so that lookups by normalised name
+// find them. It is idempotent and only rewrites names not already in the form. A name
+// whose normalised form is already taken is left as it is and reported, as the two
+// cannot be merged automatically.
+func (d *Postgres) NormalizeNames(ctx context.Context, form string) error {
+	pgForm, ok := nameNormalizationForms[form]
+	if !ok {
+		return nil
+	}
+
+	for _, target := range normalizedNameColumns {
+		normalized := fmt.Sprintf("normalize(%s, %s)", target.column, pgForm)
+
+		// Of several names normalising to the same form, only the oldest is rewritten
+		partition := normalized
+		conflict := fmt.Sprintf("o.%s = c.normalized", target.column)
+		if target.scope != "" {
+			partition = target.scope + ", " + normalized
+			conflict += fmt.Sprintf(" AND o.%[1]s = t.%[1]s", target.scope)
+		}
+
+		query := fmt.Sprintf(`
+			WITH candidates AS (
+				SELECT DISTINCT ON (%[3]s) id, %[4]s AS normalized
+				FROM %[1]s
+				WHERE %[2]s IS NOT %[5]s NORMALIZED
+				ORDER BY %[3]s, id
+			)
+			UPDATE %[1]s t
+			SET %[2]s = c.normalized
+			FROM candidates c
+			WHERE t.id = c.id AND NOT EXISTS (
+				SELECT 1 FROM %[1]s o WHERE %[6]s
+			)
+		`, target.table, target.column, partition, normalized, pgForm, conflict)
+
+		tag, err := d.Pool.Exec(ctx, query)
+		if err != nil {
+			return fmt.Errorf("error normalising %s.%s: %w", target.table, target.column, err)
+		}
+		if tag.RowsAffected() > 0 {
+			log.Info().Int64("rows", tag.RowsAffected()).Msgf("Normalised %s.%s to %s", target.table, target.column, pgForm)
+		}
+
+		var remaining int64
+		query = fmt.Sprintf("SELECT COUNT(*) FROM %[1]s WHERE %[2]s IS NOT %[3]s NORMALIZED", target.table, target.column, pgForm)
+		if err := d.Pool.QueryRow(ctx, query).Scan(&remaining); err != nil {
+			return fmt.Errorf("error counting unnormalised %s.%s: %w", target.table, target.column, err)
+		}
+		if remaining > 0 {
+			log.Warn().Int64("rows", remaining).Msgf("Left %s.%s unnormalised where the %s form is already taken; merge these duplicates by hand", target.table, target.column, pgForm)
+		}
+	}
+
+	return nil
+}
+
+// caseInsensitiveNameTables lists the tables whose name column is matched ignoring case
+// when configured to
+var caseInsensitiveNameTables = []string{"tags", "people"}
+
+// EnforceCaseInsensitiveNames adds a unique index on the lowercased names of tags and
+// people when names are matched ignoring case, so that two names differing only in case
+// cannot both be created, and drops it again otherwise. Creating the index fails while
+// such names already exist, as they cannot be merged automatically.
+func (d *Postgres) EnforceCaseInsensitiveNames(ctx context.Context, enabled bool) error {
+	for _, table := range caseInsensitiveNameTables {
+		index := table + "_name_lower_key"
+
+		query := fmt.Sprintf("DROP INDEX IF EXISTS %s", index)
+		if enabled {
+			query = fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (LOWER(name))", index, table)
+		}
+
+		if _, err := d.Pool.Exec(ctx, query); err != nil {
+			var pgErr *pgconn.PgError
+			// unique_violation, raised while building the index over duplicate names
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return fmt.Errorf("%s has names differing only in case, merge them by hand before matching names ignoring case: %w", table, err)
+			}
+			return fmt.Errorf("error updating index %s: %w", index, err)
+		}
+	}
+
+	return nil
+}
+
 func (d *Postgres) Migrate() error {
 	source, err := iofs.New(migrations, "migrations")
 	if err != nil {
@@ -3,12 +3,19 @@ package storage
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/qdrant/go-client/qdrant"
 )
 
+// imagesDistance is the distance used when creating the images collection
+const imagesDistance = qdrant.Distance_Cosine
+
 type Qdrant struct {
 	Client *qdrant.Client
+
+	// distance is the metric the images collection was created with, read on migration
+	distance qdrant.Distance
 }
 
 func NewQdrant(cfg *qdrant.Config) (*Qdrant, error) {
@@ -37,14 +44,31 @@ func (q *Qdrant) Migrate(ctx context.Context) error {
 			CollectionName: "images",
 			VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
 				Size:     512,
-				Distance: qdrant.Distance_Cosine,
+				Distance: imagesDistance,
 			}),
 		})
 
 		if err != nil {
 			return fmt.Errorf("ailed to create index images: %w", err)
 		}
+
+		q.distance = imagesDistance
+		return nil
+	}
+
+	// The metric is fixed when a collection is created, so an existing collection may
+	// use a different one than new collections would
+	info, err := q.Client.GetCollectionInfo(ctx, "images")
+	if err != nil {
+		return fmt.Errorf("unable to get info of index images: %w", err)
 	}
+	q.distance = info.GetConfig().GetParams().GetVectorsConfig().GetParams().GetDistance()
 
 	return nil
 }
+
+// Metric returns the similarity metric of the images collection in lower case, such as
+// "cosine", "dot", "euclid" or "manhattan"
+func (q *Qdrant) Metric() string {
+	return strings.ToLower(q.distance.String())
+}
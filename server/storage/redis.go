@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/foresturquhart/curator/server/telemetry"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -17,6 +18,7 @@ func NewRedis(opt *redis.Options) (*Redis, error) {
 	defer cancel()
 
 	client := redis.NewClient(opt)
+	client.AddHook(telemetry.RedisHook{})
 
 	if err := client.Ping(ctx).Err(); err != nil {
 		return nil, fmt.Errorf("unable to connect to redis: %w", err)
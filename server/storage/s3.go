@@ -2,14 +2,30 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
+	"time"
 
+	"github.com/foresturquhart/curator/server/telemetry"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Supported server-side encryption modes
+const (
+	S3EncryptionNone = ""
+	S3EncryptionS3   = "SSE-S3"
+	S3EncryptionKMS  = "SSE-KMS"
+)
+
+// ErrObjectNotFound is returned when no object is stored under a key
+var ErrObjectNotFound = errors.New("object not found")
+
 type S3Config struct {
 	Endpoint        string
 	AccessKeyID     string
@@ -19,11 +35,15 @@ type S3Config struct {
 	ForcePathStyle  bool
 	Bucket          string
 	CreateBucket    bool
+
+	ServerSideEncryption string
+	KMSKeyID             string
 }
 
 type S3 struct {
 	client *minio.Client
 	config *S3Config
+	sse    encrypt.ServerSide
 }
 
 func NewS3(ctx context.Context, config *S3Config) (*S3, error) {
@@ -43,6 +63,11 @@ func NewS3(ctx context.Context, config *S3Config) (*S3, error) {
 		config.Endpoint = parsedEndpoint.Scheme + "://" + parsedEndpoint.Host
 	}
 
+	sse, err := newServerSideEncryption(config)
+	if err != nil {
+		return nil, err
+	}
+
 	client, err := minio.New(parsedEndpoint.Host, &minio.Options{
 		Creds:  credentials.NewStaticV4(config.AccessKeyID, config.SecretAccessKey, ""),
 		Secure: config.UseSSL,
@@ -71,27 +96,134 @@ func NewS3(ctx context.Context, config *S3Config) (*S3, error) {
 	return &S3{
 		client: client,
 		config: config,
+		sse:    sse,
 	}, nil
 }
 
+// newServerSideEncryption builds the encryption settings applied to uploaded objects
+func newServerSideEncryption(config *S3Config) (encrypt.ServerSide, error) {
+	switch config.ServerSideEncryption {
+	case S3EncryptionNone:
+		return nil, nil
+	case S3EncryptionS3:
+		return encrypt.NewSSE(), nil
+	case S3EncryptionKMS:
+		if config.KMSKeyID == "" {
+			return nil, fmt.Errorf("a KMS key ID is required for %s encryption", S3EncryptionKMS)
+		}
+
+		sse, err := encrypt.NewSSEKMS(config.KMSKeyID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KMS encryption settings: %w", err)
+		}
+		return sse, nil
+	default:
+		return nil, fmt.Errorf("unsupported server-side encryption mode '%s'", config.ServerSideEncryption)
+	}
+}
+
 func (s *S3) Upload(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error {
+	ctx, span := s.startSpan(ctx, "s3.PutObject", key)
+	defer span.End()
+
 	_, err := s.client.PutObject(ctx, s.config.Bucket, key, reader, size, minio.PutObjectOptions{
-		ContentType: contentType,
+		ContentType:          contentType,
+		ServerSideEncryption: s.sse,
 	})
 	if err != nil {
+		span.RecordError(err)
 		return fmt.Errorf("failed to upload object '%s' to bucket '%s': %w", key, s.config.Bucket, err)
 	}
 	return nil
 }
 
+// Download opens the object stored under key, returning its body, size and content type.
+// The caller is responsible for closing the returned reader.
+func (s *S3) Download(ctx context.Context, key string) (io.ReadCloser, int64, string, error) {
+	ctx, span := s.startSpan(ctx, "s3.GetObject", key)
+	defer span.End()
+
+	object, err := s.client.GetObject(ctx, s.config.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		span.RecordError(err)
+		return nil, 0, "", fmt.Errorf("failed to get object '%s' from bucket '%s': %w", key, s.config.Bucket, err)
+	}
+
+	// GetObject is lazy, so stat the object to surface missing keys early
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		span.RecordError(err)
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			err = errors.Join(ErrObjectNotFound, err)
+		}
+		return nil, 0, "", fmt.Errorf("failed to stat object '%s' in bucket '%s': %w", key, s.config.Bucket, err)
+	}
+
+	return object, info.Size, info.ContentType, nil
+}
+
+// DownloadRange opens the bytes from start to end inclusive of the object stored under key.
+// The caller is responsible for closing the returned reader.
+func (s *S3) DownloadRange(ctx context.Context, key string, start, end int64) (io.ReadCloser, error) {
+	ctx, span := s.startSpan(ctx, "s3.GetObject", key)
+	defer span.End()
+
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(start, end); err != nil {
+		return nil, fmt.Errorf("invalid range for object '%s': %w", key, err)
+	}
+
+	object, err := s.client.GetObject(ctx, s.config.Bucket, key, opts)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get object '%s' from bucket '%s': %w", key, s.config.Bucket, err)
+	}
+
+	// GetObject is lazy, so stat the object to surface missing keys early
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to stat object '%s' in bucket '%s': %w", key, s.config.Bucket, err)
+	}
+
+	return object, nil
+}
+
 func (s *S3) Delete(ctx context.Context, key string) error {
+	ctx, span := s.startSpan(ctx, "s3.RemoveObject", key)
+	defer span.End()
+
 	err := s.client.RemoveObject(ctx, s.config.Bucket, key, minio.RemoveObjectOptions{})
 	if err != nil {
+		span.RecordError(err)
 		return fmt.Errorf("failed to delete object '%s' from bucket '%s': %w", key, s.config.Bucket, err)
 	}
 	return nil
 }
 
+// startSpan starts a client span for an operation on a single object
+func (s *S3) startSpan(ctx context.Context, name string, key string) (context.Context, trace.Span) {
+	return telemetry.StartClientSpan(ctx, name,
+		attribute.String("aws.s3.bucket", s.config.Bucket),
+		attribute.String("aws.s3.key", key),
+	)
+}
+
+// GetPresignedURL returns a URL granting read access to the object stored under key
+// until expiry has passed, including in private buckets
+func (s *S3) GetPresignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	ctx, span := s.startSpan(ctx, "s3.PresignedGetObject", key)
+	defer span.End()
+
+	presigned, err := s.client.PresignedGetObject(ctx, s.config.Bucket, key, expiry, nil)
+	if err != nil {
+		span.RecordError(err)
+		return "", fmt.Errorf("failed to presign object '%s' in bucket '%s': %w", key, s.config.Bucket, err)
+	}
+	return presigned.String(), nil
+}
+
 func (s *S3) GetPublicURL(key string) (string, error) {
 	parsedEndpoint, err := url.Parse(s.config.Endpoint)
 	if err != nil {
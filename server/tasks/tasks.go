@@ -1,6 +1,13 @@
 package tasks
 
-import "context"
+import (
+	"context"
+	"errors"
+)
+
+// ErrAlreadyQueued is returned when enqueueing a task of which only one may be queued or
+// running at a time, while another of its kind still is
+var ErrAlreadyQueued = errors.New("task is already queued or running")
 
 // Task types
 type TaskType string
@@ -9,10 +16,78 @@ const (
 	TypeReindexImage  TaskType = "reindex:image"
 	TypeReindexPerson TaskType = "reindex:person"
 	TypeReindexTag    TaskType = "reindex:tag"
+	TypeVerifyImages  TaskType = "verify:images"
+	TypeEnrichSource  TaskType = "enrich:source"
+
+	TypeReconcileVectors  TaskType = "reconcile:vectors"
+	TypeFlushImageViews   TaskType = "flush:image_views"
+	TypeRebuildIndex      TaskType = "rebuild:index"
+	TypeRebalanceTags     TaskType = "rebalance:tags"
+	TypeRebuildTagClosure TaskType = "rebuild:tag_closure"
+	TypeGenerateThumbnail TaskType = "generate:thumbnail"
+
+	TypeGenerateThumbnails TaskType = "generate:thumbnails"
+)
+
+// Types lists every task type handled by the worker
+var Types = []TaskType{
+	TypeReindexImage,
+	TypeReindexPerson,
+	TypeReindexTag,
+	TypeVerifyImages,
+	TypeEnrichSource,
+	TypeReconcileVectors,
+	TypeFlushImageViews,
+	TypeRebuildIndex,
+	TypeRebalanceTags,
+	TypeRebuildTagClosure,
+	TypeGenerateThumbnail,
+	TypeGenerateThumbnails,
+}
+
+// Queue names
+const (
+	QueueReindex     = "reindex"
+	QueueMaintenance = "maintenance"
+	QueueEnrichment  = "enrichment"
 )
 
-// Queue name
-const QueueReindex = "reindex"
+// EnrichSourcePayload identifies an image source whose metadata should be fetched
+type EnrichSourcePayload struct {
+	ImageID int64  `json:"image_id"`
+	URL     string `json:"url"`
+}
+
+// RebuildIndexPayload identifies a search index to rebuild and swap in, and the job
+// tracking its progress
+type RebuildIndexPayload struct {
+	JobID string `json:"job_id"`
+	Index string `json:"index"`
+}
+
+// GenerateThumbnailsPayload selects the images whose thumbnails should be generated
+type GenerateThumbnailsPayload struct {
+	// All regenerates the thumbnail of every image rather than only of those without one
+	All bool `json:"all"`
+}
+
+// StateCounts counts background tasks by state. Failed tasks are those awaiting a retry
+// along with those that exhausted their retries.
+type StateCounts struct {
+	Pending int `json:"pending"`
+	Active  int `json:"active"`
+	Failed  int `json:"failed"`
+}
+
+// Summary counts background tasks across every queue, in total and by task type
+type Summary struct {
+	Total  StateCounts             `json:"total"`
+	ByType map[string]*StateCounts `json:"by_type"`
+
+	// Truncated reports that a backlog was too large to count by type in full, so the
+	// counts by type only cover part of it while the totals remain exact
+	Truncated bool `json:"truncated,omitempty"`
+}
 
 // Client defines an interface for enqueuing tasks
 type Client interface {
@@ -24,4 +99,36 @@ type Client interface {
 
 	// EnqueueReindexTag adds a job to reindex a tag
 	EnqueueReindexTag(ctx context.Context, id int64) error
+
+	// EnqueueVerifyImages adds a job to verify the stored objects of every image
+	EnqueueVerifyImages(ctx context.Context) error
+
+	// EnqueueEnrichSource adds a job to fill in the missing title and description of an image source
+	EnqueueEnrichSource(ctx context.Context, imageID int64, url string) error
+
+	// EnqueueReconcileVectors adds a job to reconcile the vector index against the database
+	EnqueueReconcileVectors(ctx context.Context) error
+
+	// EnqueueRebuildIndex adds a job to rebuild a search index from its documents and swap it in
+	EnqueueRebuildIndex(ctx context.Context, jobID string, index string) error
+
+	// EnqueueRebalanceTags adds a job to renumber the positions of sibling tags, returning
+	// ErrAlreadyQueued while another is queued or running
+	EnqueueRebalanceTags(ctx context.Context) error
+
+	// EnqueueRebuildTagClosure adds a job to recompute the tag closure table from the
+	// hierarchy, returning ErrAlreadyQueued while another is queued or running
+	EnqueueRebuildTagClosure(ctx context.Context) error
+
+	// EnqueueGenerateThumbnail adds a job to generate and store the thumbnail of an image,
+	// returning ErrAlreadyQueued while one for the same image is queued or running
+	EnqueueGenerateThumbnail(ctx context.Context, id int64) error
+
+	// EnqueueGenerateThumbnails adds a job to queue thumbnail generation for every image
+	// without a thumbnail, or for every image when all is set, returning ErrAlreadyQueued
+	// while another is queued or running
+	EnqueueGenerateThumbnails(ctx context.Context, all bool) error
+
+	// Summarize counts the pending, active and failed tasks by task type
+	Summarize(ctx context.Context) (*Summary, error)
 }
@@ -0,0 +1,31 @@
+package telemetry
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PostgresTracer creates a client span for every query executed through pgx
+type PostgresTracer struct{}
+
+var _ pgx.QueryTracer = PostgresTracer{}
+
+func (PostgresTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = StartClientSpan(ctx, "postgres.query",
+		semconv.DBSystemPostgreSQL,
+		semconv.DBQueryText(data.SQL),
+	)
+	return ctx
+}
+
+func (PostgresTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err == nil {
+		span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	}
+	End(span, data.Err)
+}
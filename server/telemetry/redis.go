@@ -0,0 +1,59 @@
+package telemetry
+
+import (
+	"context"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// RedisHook creates a client span for every command or pipeline sent to redis
+type RedisHook struct{}
+
+var _ redis.Hook = RedisHook{}
+
+func (RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		ctx, span := StartClientSpan(ctx, "redis.dial",
+			semconv.DBSystemRedis,
+			semconv.ServerAddress(addr),
+		)
+		conn, err := next(ctx, network, addr)
+		End(span, err)
+		return conn, err
+	}
+}
+
+func (RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := StartClientSpan(ctx, "redis."+cmd.Name(),
+			semconv.DBSystemRedis,
+			semconv.DBOperationName(cmd.Name()),
+		)
+		err := next(ctx, cmd)
+		End(span, ignoreRedisNil(err))
+		return err
+	}
+}
+
+func (RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := StartClientSpan(ctx, "redis.pipeline",
+			semconv.DBSystemRedis,
+			attribute.Int("db.redis.pipeline_length", len(cmds)),
+		)
+		err := next(ctx, cmds)
+		End(span, ignoreRedisNil(err))
+		return err
+	}
+}
+
+// ignoreRedisNil treats cache misses as successful calls
+func ignoreRedisNil(err error) error {
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
@@ -1,17 +1,51 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"math"
+	"time"
 
 	"github.com/btcsuite/btcd/btcutil/base58"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/sortorder"
 	"github.com/xxtea/xxtea-go/xxtea"
 )
 
+// CursorVersion is the current version of the cursor payload format
+const CursorVersion = 1
+
+// cursorPayload is the structure serialised inside an encrypted cursor. The signature
+// records the sort the cursor was created with, so that sort values are never applied
+// to a query with a different sort. Values are either Elasticsearch sort values or a
+// database keyset built with KeysetCursor, which share the same encoding so that a
+// cursor from one backend can continue a listing served by the other.
+type cursorPayload struct {
+	Version   int                `json:"v"`
+	Signature string             `json:"s"`
+	Values    []types.FieldValue `json:"values"`
+}
+
+// SortSignature builds a cursor signature from the sort field and direction of a query
+func SortSignature(sortBy string, direction SortDirection) string {
+	if sortBy == "" {
+		sortBy = "default"
+	}
+	if direction == "" {
+		direction = "default"
+	}
+	return fmt.Sprintf("%s:%s,id:asc", sortBy, direction)
+}
+
 // EncryptCursor encrypts the cursor
-func EncryptCursor(input []types.FieldValue, key string) (string, error) {
-	// Serialize the input to JSON
-	jsonData, err := json.Marshal(input)
+func EncryptCursor(input []types.FieldValue, signature string, key string) (string, error) {
+	// Serialize the versioned payload to JSON
+	jsonData, err := json.Marshal(cursorPayload{
+		Version:   CursorVersion,
+		Signature: signature,
+		Values:    input,
+	})
 	if err != nil {
 		return "", err
 	}
@@ -24,18 +58,134 @@ func EncryptCursor(input []types.FieldValue, key string) (string, error) {
 	return encoded, nil
 }
 
-// DecryptCursor decrypts the cursor
-func DecryptCursor(input string, key string) ([]types.FieldValue, error) {
+// DecryptCursor decrypts the cursor, rejecting cursors created with a different
+// payload version or sort signature
+func DecryptCursor(input string, signature string, key string) ([]types.FieldValue, error) {
 	// Decode the base58 string to get the encrypted bytes
 	decoded := base58.Decode(input)
 
 	// Decrypt the JSON data using the XXTEA algorithm
 	decryptedBytes := xxtea.Decrypt(decoded, []byte(key))
 
-	// Unmarshal the decrypted JSON back into an array
-	var arr []types.FieldValue
-	if err := json.Unmarshal(decryptedBytes, &arr); err != nil {
+	// Unmarshal the decrypted JSON back into the versioned payload, keeping numbers
+	// exact so that large integer keys survive the round trip
+	var payload cursorPayload
+	decoder := json.NewDecoder(bytes.NewReader(decryptedBytes))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
 		return nil, err
 	}
-	return arr, nil
+
+	if payload.Version != CursorVersion {
+		return nil, fmt.Errorf("unsupported cursor version %d", payload.Version)
+	}
+
+	if payload.Signature != signature {
+		return nil, ErrCursorMismatch
+	}
+
+	return payload.Values, nil
+}
+
+// SortWithTiebreaker builds an Elasticsearch sort on a field followed by id. The fields
+// are given as separate entries so that id is always applied second. When reverse is
+// set both directions are flipped, walking the same ordering backwards, as is done to
+// fetch the page before a cursor.
+func SortWithTiebreaker(field string, direction SortDirection, reverse bool) []types.SortCombinations {
+	fieldOrder, idOrder := sortorder.Desc, sortorder.Asc
+	if direction == SortDirectionAsc {
+		fieldOrder = sortorder.Asc
+	}
+	if reverse {
+		fieldOrder, idOrder = reverseSortOrder(fieldOrder), reverseSortOrder(idOrder)
+	}
+
+	return []types.SortCombinations{
+		types.SortOptions{SortOptions: map[string]types.FieldSort{field: {Order: &fieldOrder}}},
+		types.SortOptions{SortOptions: map[string]types.FieldSort{"id": {Order: &idOrder}}},
+	}
+}
+
+func reverseSortOrder(order sortorder.SortOrder) sortorder.SortOrder {
+	if order == sortorder.Asc {
+		return sortorder.Desc
+	}
+	return sortorder.Asc
+}
+
+// PageCursors derives the cursors either side of a page from the sort values of its
+// first and last rows, with the page in display order. For a page fetched backwards
+// from an ending_before cursor, hasMore reports whether earlier rows remain; otherwise
+// it reports whether later rows remain, and paged whether the page followed a cursor.
+func PageCursors(first, last []types.FieldValue, hasMore, paged, backward bool) (next, prev []types.FieldValue) {
+	if backward {
+		next = last
+		if hasMore {
+			prev = first
+		}
+		return next, prev
+	}
+
+	if hasMore {
+		next = last
+	}
+	if paged {
+		prev = first
+	}
+	return next, prev
+}
+
+// KeysetCursor builds a cursor from the sort key of the last row of a database listing.
+// Times are encoded as epoch milliseconds, matching Elasticsearch date sort values.
+func KeysetCursor(values ...any) []types.FieldValue {
+	cursor := make([]types.FieldValue, len(values))
+	for i, value := range values {
+		if t, ok := value.(time.Time); ok {
+			value = t.UnixMilli()
+		}
+		cursor[i] = value
+	}
+	return cursor
+}
+
+// CursorInt64 converts a decoded cursor value into an int64
+func CursorInt64(value types.FieldValue) (int64, error) {
+	switch v := value.(type) {
+	case float64:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		// Elasticsearch may render whole numbers in floating point notation
+		f, err := v.Float64()
+		if err != nil || f != math.Trunc(f) {
+			return 0, fmt.Errorf("cursor value %s is not an integer", v)
+		}
+		return int64(f), nil
+	default:
+		return 0, fmt.Errorf("unexpected cursor value type %T", value)
+	}
+}
+
+// CursorTime converts a decoded cursor value holding epoch milliseconds into a time
+func CursorTime(value types.FieldValue) (time.Time, error) {
+	millis, err := CursorInt64(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(millis), nil
+}
+
+// CursorString converts a decoded cursor value into a string
+func CursorString(value types.FieldValue) (string, error) {
+	v, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected cursor value type %T", value)
+	}
+	return v, nil
 }
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// searchDateLayouts are the formats accepted for search date filters, most specific
+// first. Layouts without an offset are interpreted in the configured search time zone.
+var searchDateLayouts = []struct {
+	layout    string
+	hasOffset bool
+}{
+	{time.RFC3339Nano, true},
+	{"2006-01-02T15:04:05", false},
+	{"2006-01-02 15:04:05", false},
+	{"2006-01-02T15:04", false},
+	{"2006-01-02 15:04", false},
+	{time.DateOnly, false},
+}
+
+// ParseSearchDate parses a date filter given either as an RFC3339 timestamp, a local
+// date and time, or a date alone, which means the start of that day. Values without an
+// offset are interpreted in loc, or UTC when loc is nil.
+func ParseSearchDate(value string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	for _, format := range searchDateLayouts {
+		var parsed time.Time
+		var err error
+		if format.hasOffset {
+			parsed, err = time.Parse(format.layout, value)
+		} else {
+			parsed, err = time.ParseInLocation(format.layout, value, loc)
+		}
+		if err == nil {
+			return parsed, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognised date %q", value)
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrDegenerateEmbedding is returned for an embedding that cannot take part in similarity
+// search, such as one with a zero norm or non-finite components
+var ErrDegenerateEmbedding = errors.New("degenerate embedding")
+
+// Actions taken when the embedding of an uploaded image is degenerate
+const (
+	DegenerateEmbeddingReject = "reject" // Refuse the upload
+	DegenerateEmbeddingWarn   = "warn"   // Log the problem and store the image anyway
+)
+
+// EmbeddingCombination specifies how several embeddings are merged into one query vector
+type EmbeddingCombination string
+
+// Embedding combination constants
+const (
+	EmbeddingCombinationMean EmbeddingCombination = "mean" // Centroid of the normalised embeddings
+	EmbeddingCombinationMax  EmbeddingCombination = "max"  // Element-wise maximum of the normalised embeddings
+)
+
+// CombineEmbeddings merges several embeddings into a single vector. Each embedding is
+// normalised first so that every reference contributes equally regardless of magnitude.
+func CombineEmbeddings(embeddings [][]float32, combination EmbeddingCombination) ([]float32, error) {
+	if len(embeddings) == 0 {
+		return nil, fmt.Errorf("no embeddings to combine")
+	}
+
+	dimensions := len(embeddings[0])
+	combined := make([]float32, dimensions)
+
+	for i, embedding := range embeddings {
+		if len(embedding) != dimensions {
+			return nil, fmt.Errorf("embedding dimensions differ: %d and %d", dimensions, len(embedding))
+		}
+
+		normalised := normaliseEmbedding(embedding)
+
+		switch combination {
+		case EmbeddingCombinationMax:
+			for j, value := range normalised {
+				if i == 0 || value > combined[j] {
+					combined[j] = value
+				}
+			}
+		case EmbeddingCombinationMean, "":
+			for j, value := range normalised {
+				combined[j] += value / float32(len(embeddings))
+			}
+		default:
+			return nil, fmt.Errorf("unknown embedding combination: %s", combination)
+		}
+	}
+
+	return combined, nil
+}
+
+// ValidateEmbedding checks that an embedding is usable for similarity search. Empty
+// embeddings, NaN or infinite components and a zero norm all match ErrDegenerateEmbedding.
+func ValidateEmbedding(embedding []float32) error {
+	if len(embedding) == 0 {
+		return fmt.Errorf("%w: no components", ErrDegenerateEmbedding)
+	}
+
+	var sum float64
+	for i, value := range embedding {
+		component := float64(value)
+		if math.IsNaN(component) || math.IsInf(component, 0) {
+			return fmt.Errorf("%w: component %d is not finite", ErrDegenerateEmbedding, i)
+		}
+		sum += component * component
+	}
+
+	if sum == 0 {
+		return fmt.Errorf("%w: zero norm", ErrDegenerateEmbedding)
+	}
+
+	return nil
+}
+
+// normaliseEmbedding scales an embedding to unit length
+func normaliseEmbedding(embedding []float32) []float32 {
+	var sum float64
+	for _, value := range embedding {
+		sum += float64(value) * float64(value)
+	}
+
+	normalised := make([]float32, len(embedding))
+	if sum == 0 {
+		return normalised
+	}
+
+	norm := float32(math.Sqrt(sum))
+	for i, value := range embedding {
+		normalised[i] = value / norm
+	}
+
+	return normalised
+}
@@ -10,9 +10,30 @@ var (
 	ErrPersonNotFound = errors.New("person not found")
 	ErrTagNotFound    = errors.New("tag not found")
 
+	ErrCollectionNotFound = errors.New("collection not found")
+
 	ErrInvalidInput = errors.New("invalid input")
+
+	ErrCursorMismatch = errors.New("cursor does not match the current sort")
+
+	ErrImmutableField = errors.New("field is immutable")
 )
 
+// ImmutableFieldError reports an attempt to change a field that cannot be modified once
+// stored. It matches ErrImmutableField with errors.Is.
+type ImmutableFieldError struct {
+	Entity string
+	Field  string
+}
+
+func (e *ImmutableFieldError) Error() string {
+	return fmt.Sprintf("%s %s cannot be changed", e.Entity, e.Field)
+}
+
+func (e *ImmutableFieldError) Is(target error) bool {
+	return target == ErrImmutableField
+}
+
 // ConflictError represents a conflict with an existing resource
 type ConflictError struct {
 	Message      string
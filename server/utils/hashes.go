@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+)
+
+// CalculateFileHashes calculates MD5 and SHA1 hashes of a file
+func CalculateFileHashes(reader io.Reader) (string, string, error) {
+	md5Hasher := md5.New()
+	sha1Hasher := sha1.New()
+
+	teeReader := io.TeeReader(reader, io.MultiWriter(md5Hasher, sha1Hasher))
+
+	if _, err := io.Copy(io.Discard, teeReader); err != nil {
+		return "", "", err
+	}
+
+	md5Hash := hex.EncodeToString(md5Hasher.Sum(nil))
+	sha1Hash := hex.EncodeToString(sha1Hasher.Sum(nil))
+
+	return md5Hash, sha1Hash, nil
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"golang.org/x/text/unicode/norm"
+)
+
+// Unicode normalisation forms applied to tag and person names
+const (
+	NameNormalizationNone = "none" // Store names exactly as given
+	NameNormalizationNFC  = "nfc"  // Compose canonically equivalent sequences
+	NameNormalizationNFKC = "nfkc" // Also fold compatibility variants, such as full-width letters
+)
+
+// NormalizeName applies a Unicode normalisation form to a tag or person name, so that
+// visually identical names are stored and matched as the same string
+func NormalizeName(name string, form string) string {
+	switch form {
+	case NameNormalizationNFC:
+		return norm.NFC.String(name)
+	case NameNormalizationNFKC:
+		return norm.NFKC.String(name)
+	default:
+		return name
+	}
+}
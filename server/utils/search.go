@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// searchTimeoutGrace gives Elasticsearch time to return partial results after its own
+// timeout elapses, before the client abandons the request
+const searchTimeoutGrace = time.Second
+
+// WithSearchTimeout bounds ctx for a search executed with the given server-side timeout,
+// also returning the timeout formatted for Elasticsearch. A non-positive timeout leaves
+// ctx unbounded and returns an empty string.
+func WithSearchTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc, string) {
+	if timeout <= 0 {
+		return ctx, func() {}, ""
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout+searchTimeoutGrace)
+	return ctx, cancel, fmt.Sprintf("%dms", timeout.Milliseconds())
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TitleFromFilename derives a readable title from a filename by dropping its extension,
+// treating underscores, hyphens, dots and plus signs as spaces and capitalising each
+// word. It returns an empty string when nothing readable remains.
+func TitleFromFilename(filename string) string {
+	name := filepath.Base(filename)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '_' || r == '-' || r == '.' || r == '+'
+	})
+
+	for i, word := range words {
+		first, size := utf8.DecodeRuneInString(word)
+		words[i] = string(unicode.ToTitle(first)) + word[size:]
+	}
+
+	return strings.Join(words, " ")
+}
@@ -11,9 +11,16 @@ const (
 	SortDirectionDesc SortDirection = "desc"
 )
 
+// Parameter validation modes, deciding how unrecognised sort options are handled
+const (
+	ParamModeStrict  = "strict"  // Reject unrecognised options
+	ParamModeLenient = "lenient" // Ignore unrecognised options, falling back to defaults
+)
+
 type PaginationOptions struct {
 	Limit         int
 	StartingAfter []types.FieldValue
+	EndingBefore  []types.FieldValue
 }
 
 type PaginatedResult[T any] struct {
@@ -21,4 +28,6 @@ type PaginatedResult[T any] struct {
 	HasMore    bool               `json:"has_more"`
 	TotalCount int64              `json:"total_count"`
 	NextCursor []types.FieldValue `json:"next_cursor,omitempty"`
+	PrevCursor []types.FieldValue `json:"prev_cursor,omitempty"`
+	Partial    bool               `json:"partial,omitempty"`
 }
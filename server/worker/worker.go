@@ -4,27 +4,55 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/foresturquhart/curator/server/cache"
 	"github.com/foresturquhart/curator/server/container"
+	"github.com/foresturquhart/curator/server/fetch"
+	"github.com/foresturquhart/curator/server/imaging"
+	"github.com/foresturquhart/curator/server/models"
 	"github.com/foresturquhart/curator/server/repositories"
+	"github.com/foresturquhart/curator/server/search"
 	"github.com/foresturquhart/curator/server/services"
+	"github.com/foresturquhart/curator/server/storage"
 	"github.com/foresturquhart/curator/server/tasks"
+	"github.com/foresturquhart/curator/server/utils"
 	"github.com/hibiken/asynq"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
 )
 
 // Worker represents the background job processor
 type Worker struct {
-	server *asynq.Server
-	client *asynq.Client
+	container *container.Container
+	server    *asynq.Server
+	client    *asynq.Client
+	inspector *asynq.Inspector
+	scheduler *asynq.Scheduler
 
 	imageRepository *repositories.ImageRepository
+	imageViews      *cache.ImageViewCounter
+	reindexJobs     *cache.ReindexJobTracker
+	indexRebuilds   *cache.IndexRebuilds
+	checkpoints     *cache.IndexCheckpoints
+	fetcher         *fetch.Client
 
 	personService *services.PersonService
 	tagService    *services.TagService
+
+	// active tracks the tasks currently being processed, by task ID
+	active sync.Map
+}
+
+// activeTask records a task being processed, so that it can be reported on shutdown
+type activeTask struct {
+	taskType  string
+	startedAt time.Time
 }
 
 // Ensure Worker implements tasks.Client
@@ -42,40 +70,154 @@ func NewWorker(
 		container.Redis.Client,
 		asynq.Config{
 			Queues: map[string]int{
-				tasks.QueueReindex: 10,
+				tasks.QueueReindex:     10,
+				tasks.QueueEnrichment:  3,
+				tasks.QueueMaintenance: 1,
 			},
-			Concurrency: 16,
-			Logger:      nil,
+			Concurrency:     16,
+			ShutdownTimeout: container.Config.WorkerShutdownTimeout,
+			Logger:          nil,
 		},
 	)
 
 	// Client for enqueuing tasks
 	client := asynq.NewClientFromRedisClient(container.Redis.Client)
 
+	// Scheduler for periodic tasks, which would otherwise drop enqueue errors silently
+	scheduler := asynq.NewSchedulerFromRedisClient(container.Redis.Client, &asynq.SchedulerOpts{
+		PostEnqueueFunc: logScheduledEnqueue,
+	})
+
+	_, err := scheduler.Register(
+		fmt.Sprintf("@every %s", container.Config.ImageViewFlushInterval),
+		asynq.NewTask(string(tasks.TypeFlushImageViews), nil),
+		singletonOptions(5*time.Minute)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error scheduling image view flush: %w", err)
+	}
+
+	if container.Config.TagRebalanceInterval > 0 {
+		_, err := scheduler.Register(
+			fmt.Sprintf("@every %s", container.Config.TagRebalanceInterval),
+			asynq.NewTask(string(tasks.TypeRebalanceTags), nil),
+			singletonOptions(time.Hour)...,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scheduling tag rebalancing: %w", err)
+		}
+	}
+
 	return &Worker{
+		container:       container,
 		server:          server,
 		client:          client,
+		inspector:       asynq.NewInspectorFromRedisClient(container.Redis.Client),
+		scheduler:       scheduler,
 		imageRepository: imageRepository,
+		imageViews:      cache.NewImageViewCounter(container),
+		reindexJobs:     cache.NewReindexJobTracker(container),
+		indexRebuilds:   cache.NewIndexRebuilds(container),
+		checkpoints:     cache.NewIndexCheckpoints(container),
+		fetcher:         fetch.NewClient(container.Config.FetchTimeout, container.Config.FetchMaxBytes),
 		personService:   personService,
 		tagService:      tagService,
 	}, nil
 }
 
+// logScheduledEnqueue reports a scheduled task that could not be enqueued, including
+// one skipped because the previous run is still queued or running
+func logScheduledEnqueue(info *asynq.TaskInfo, err error) {
+	if err == nil {
+		return
+	}
+
+	if errors.Is(err, asynq.ErrDuplicateTask) {
+		log.Warn().Err(err).Msg("Skipped scheduled task as the previous run is still queued or running")
+		return
+	}
+	log.Error().Err(err).Msg("Failed to enqueue scheduled task")
+}
+
 func (w *Worker) Start() error {
 	mux := asynq.NewServeMux()
+	mux.Use(w.trackActive)
 
 	mux.HandleFunc(string(tasks.TypeReindexImage), w.handleReindexImage)
 	mux.HandleFunc(string(tasks.TypeReindexPerson), w.handleReindexPerson)
 	mux.HandleFunc(string(tasks.TypeReindexTag), w.handleReindexTag)
+	mux.HandleFunc(string(tasks.TypeVerifyImages), w.handleVerifyImages)
+	mux.HandleFunc(string(tasks.TypeEnrichSource), w.handleEnrichSource)
+	mux.HandleFunc(string(tasks.TypeReconcileVectors), w.handleReconcileVectors)
+	mux.HandleFunc(string(tasks.TypeFlushImageViews), w.handleFlushImageViews)
+	mux.HandleFunc(string(tasks.TypeRebuildIndex), w.handleRebuildIndex)
+	mux.HandleFunc(string(tasks.TypeRebalanceTags), w.handleRebalanceTags)
+	mux.HandleFunc(string(tasks.TypeRebuildTagClosure), w.handleRebuildTagClosure)
+	mux.HandleFunc(string(tasks.TypeGenerateThumbnail), w.handleGenerateThumbnail)
+	mux.HandleFunc(string(tasks.TypeGenerateThumbnails), w.handleGenerateThumbnails)
+
+	if err := w.scheduler.Start(); err != nil {
+		return fmt.Errorf("error starting scheduler: %w", err)
+	}
 
 	return w.server.Start(mux)
 }
 
+// Stop stops scheduling and fetching new tasks, then waits up to the configured shutdown
+// timeout for running tasks to finish. Tasks still running when it expires are abandoned
+// and retried once a worker is available again.
 func (w *Worker) Stop() error {
+	w.scheduler.Shutdown()
+	w.server.Stop()
+
+	if count := w.countActive(); count > 0 {
+		log.Info().Msgf("Waiting up to %s for %d background tasks to finish", w.container.Config.WorkerShutdownTimeout, count)
+	}
+
 	w.server.Shutdown()
+
+	w.active.Range(func(key, value any) bool {
+		task := value.(*activeTask)
+		log.Warn().
+			Str("task_id", key.(string)).
+			Str("task_type", task.taskType).
+			Dur("running_for", time.Since(task.startedAt)).
+			Msg("Background task did not finish before shutdown and will be retried")
+		return true
+	})
+
+	if err := w.inspector.Close(); err != nil {
+		log.Error().Err(err).Msg("Failed to close task inspector")
+	}
+
 	return w.client.Close()
 }
 
+// trackActive records each task while it is being processed
+func (w *Worker) trackActive(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		id, ok := asynq.GetTaskID(ctx)
+		if !ok {
+			return next.ProcessTask(ctx, task)
+		}
+
+		w.active.Store(id, &activeTask{taskType: task.Type(), startedAt: time.Now()})
+		defer w.active.Delete(id)
+
+		return next.ProcessTask(ctx, task)
+	})
+}
+
+// countActive counts the tasks currently being processed
+func (w *Worker) countActive() int {
+	count := 0
+	w.active.Range(func(_, _ any) bool {
+		count++
+		return true
+	})
+	return count
+}
+
 func (w *Worker) encodeIdPayload(id int64) []byte {
 	buf := new(bytes.Buffer)
 	binary.Write(buf, binary.BigEndian, id)
@@ -100,7 +242,8 @@ func (w *Worker) enqueueReindex(ctx context.Context, taskType tasks.TaskType, id
 		asynq.MaxRetry(5),
 		asynq.Timeout(3*time.Minute),
 		asynq.Queue(tasks.QueueReindex),
-		asynq.Retention(24*time.Hour),
+		// Completed tasks are not retained, as a retained task ID would swallow every
+		// reindex of the same entity until it expired
 		asynq.TaskID(fmt.Sprintf("%s:%d", string(taskType), id)),
 	)
 
@@ -141,6 +284,185 @@ func (w *Worker) EnqueueReindexTag(ctx context.Context, id int64) error {
 	return nil
 }
 
+func (w *Worker) EnqueueVerifyImages(ctx context.Context) error {
+	task := asynq.NewTask(string(tasks.TypeVerifyImages), nil)
+
+	_, err := w.client.EnqueueContext(
+		ctx,
+		task,
+		asynq.MaxRetry(0),
+		asynq.Timeout(12*time.Hour),
+		asynq.Queue(tasks.QueueMaintenance),
+		asynq.TaskID(string(tasks.TypeVerifyImages)),
+	)
+
+	if err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) || errors.Is(err, asynq.ErrDuplicateTask) {
+			log.Debug().Str("task", string(tasks.TypeVerifyImages)).Msg("Verification task already queued, skipping duplicate")
+			return nil
+		}
+		return fmt.Errorf("error enqueueing image verification: %w", err)
+	}
+
+	return nil
+}
+
+func (w *Worker) EnqueueEnrichSource(ctx context.Context, imageID int64, url string) error {
+	payload, err := json.Marshal(tasks.EnrichSourcePayload{
+		ImageID: imageID,
+		URL:     url,
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding source enrichment payload: %w", err)
+	}
+
+	task := asynq.NewTask(string(tasks.TypeEnrichSource), payload)
+
+	_, err = w.client.EnqueueContext(
+		ctx,
+		task,
+		asynq.MaxRetry(3),
+		asynq.Timeout(time.Minute),
+		asynq.Queue(tasks.QueueEnrichment),
+	)
+	if err != nil {
+		return fmt.Errorf("error enqueueing source enrichment: %w", err)
+	}
+
+	log.Debug().Int64("image_id", imageID).Str("url", url).Msg("Successfully enqueued source enrichment task")
+
+	return nil
+}
+
+// singletonOptions returns the options of a maintenance task of which only one may be
+// queued or running at a time. A fixed task ID would be held on to by a failed task
+// once archived, blocking every later run, whereas the uniqueness lock is released as
+// soon as the task succeeds and otherwise expires along with its timeout.
+func singletonOptions(timeout time.Duration) []asynq.Option {
+	return []asynq.Option{
+		asynq.MaxRetry(0),
+		asynq.Timeout(timeout),
+		asynq.Queue(tasks.QueueMaintenance),
+		asynq.Unique(timeout),
+	}
+}
+
+func (w *Worker) EnqueueReconcileVectors(ctx context.Context) error {
+	task := asynq.NewTask(string(tasks.TypeReconcileVectors), nil)
+
+	_, err := w.client.EnqueueContext(ctx, task, singletonOptions(12*time.Hour)...)
+
+	if err != nil {
+		if errors.Is(err, asynq.ErrDuplicateTask) {
+			log.Debug().Str("task", string(tasks.TypeReconcileVectors)).Msg("Reconciliation task already queued, skipping duplicate")
+			return nil
+		}
+		return fmt.Errorf("error enqueueing vector reconciliation: %w", err)
+	}
+
+	return nil
+}
+
+func (w *Worker) EnqueueRebuildIndex(ctx context.Context, jobID string, index string) error {
+	payload, err := json.Marshal(tasks.RebuildIndexPayload{
+		JobID: jobID,
+		Index: index,
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding index rebuild payload: %w", err)
+	}
+
+	task := asynq.NewTask(string(tasks.TypeRebuildIndex), payload)
+
+	_, err = w.client.EnqueueContext(
+		ctx,
+		task,
+		asynq.MaxRetry(0),
+		asynq.Timeout(12*time.Hour),
+		asynq.Queue(tasks.QueueMaintenance),
+		asynq.TaskID(jobID),
+	)
+	if err != nil {
+		return fmt.Errorf("error enqueueing index rebuild: %w", err)
+	}
+
+	log.Debug().Str("job_id", jobID).Str("index", index).Msg("Successfully enqueued index rebuild task")
+
+	return nil
+}
+
+func (w *Worker) EnqueueRebalanceTags(ctx context.Context) error {
+	task := asynq.NewTask(string(tasks.TypeRebalanceTags), nil)
+
+	_, err := w.client.EnqueueContext(ctx, task, singletonOptions(time.Hour)...)
+
+	if err != nil {
+		if errors.Is(err, asynq.ErrDuplicateTask) {
+			return fmt.Errorf("error enqueueing tag rebalancing: %w", tasks.ErrAlreadyQueued)
+		}
+		return fmt.Errorf("error enqueueing tag rebalancing: %w", err)
+	}
+
+	return nil
+}
+
+func (w *Worker) EnqueueRebuildTagClosure(ctx context.Context) error {
+	task := asynq.NewTask(string(tasks.TypeRebuildTagClosure), nil)
+
+	_, err := w.client.EnqueueContext(ctx, task, singletonOptions(time.Hour)...)
+
+	if err != nil {
+		if errors.Is(err, asynq.ErrDuplicateTask) {
+			return fmt.Errorf("error enqueueing tag closure rebuild: %w", tasks.ErrAlreadyQueued)
+		}
+		return fmt.Errorf("error enqueueing tag closure rebuild: %w", err)
+	}
+
+	return nil
+}
+
+func (w *Worker) EnqueueGenerateThumbnail(ctx context.Context, id int64) error {
+	task := asynq.NewTask(string(tasks.TypeGenerateThumbnail), w.encodeIdPayload(id))
+
+	_, err := w.client.EnqueueContext(
+		ctx,
+		task,
+		asynq.MaxRetry(3),
+		asynq.Timeout(3*time.Minute),
+		asynq.Queue(tasks.QueueMaintenance),
+		asynq.TaskID(fmt.Sprintf("%s:%d", string(tasks.TypeGenerateThumbnail), id)),
+	)
+
+	if err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) || errors.Is(err, asynq.ErrDuplicateTask) {
+			return fmt.Errorf("error enqueueing thumbnail generation: %w", tasks.ErrAlreadyQueued)
+		}
+		return fmt.Errorf("error enqueueing thumbnail generation: %w", err)
+	}
+
+	return nil
+}
+
+func (w *Worker) EnqueueGenerateThumbnails(ctx context.Context, all bool) error {
+	payload, err := json.Marshal(tasks.GenerateThumbnailsPayload{All: all})
+	if err != nil {
+		return fmt.Errorf("error encoding thumbnail generation payload: %w", err)
+	}
+
+	task := asynq.NewTask(string(tasks.TypeGenerateThumbnails), payload)
+
+	_, err = w.client.EnqueueContext(ctx, task, singletonOptions(time.Hour)...)
+
+	if err != nil {
+		if errors.Is(err, asynq.ErrDuplicateTask) {
+			return fmt.Errorf("error enqueueing thumbnail generation: %w", tasks.ErrAlreadyQueued)
+		}
+		return fmt.Errorf("error enqueueing thumbnail generation: %w", err)
+	}
+
+	return nil
+}
+
 func (w *Worker) handleReindexImage(ctx context.Context, task *asynq.Task) error {
 	id := w.decodeIdPayload(task.Payload())
 
@@ -158,6 +480,83 @@ func (w *Worker) handleReindexImage(ctx context.Context, task *asynq.Task) error
 	return nil
 }
 
+func (w *Worker) handleGenerateThumbnail(ctx context.Context, task *asynq.Task) error {
+	id := w.decodeIdPayload(task.Payload())
+
+	log.Info().Int64("id", id).Msg("Executing thumbnail generation job for image")
+
+	image, err := w.imageRepository.GetByID(ctx, id)
+	if err != nil {
+		// The image was deleted after the job was queued
+		if errors.Is(err, utils.ErrImageNotFound) {
+			return nil
+		}
+		return fmt.Errorf("error getting image: %w", err)
+	}
+
+	if err := w.imageRepository.GenerateThumbnail(ctx, image); err != nil {
+		// Retrying cannot help with a format whose pixels cannot be decoded
+		if errors.Is(err, imaging.ErrDecodeUnsupported) {
+			log.Debug().Int64("id", id).Str("format", string(image.Format)).Msg("Skipping thumbnail of image in an undecodable format")
+			return nil
+		}
+		return fmt.Errorf("error generating thumbnail: %w", err)
+	}
+
+	return nil
+}
+
+func (w *Worker) handleGenerateThumbnails(ctx context.Context, task *asynq.Task) error {
+	var payload tasks.GenerateThumbnailsPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("error decoding thumbnail generation payload: %v: %w", err, asynq.SkipRetry)
+	}
+
+	log.Info().Bool("all", payload.All).Msg("Executing thumbnail generation fan-out job")
+
+	imageIDs, err := w.imageRepository.GetIDsForThumbnails(ctx, payload.All)
+	if err != nil {
+		return fmt.Errorf("error listing images for thumbnail generation: %w", err)
+	}
+
+	var queued, failed int
+	var skipped []int64
+	for _, id := range imageIDs {
+		if err := w.EnqueueGenerateThumbnail(ctx, id); err != nil {
+			if errors.Is(err, tasks.ErrAlreadyQueued) {
+				skipped = append(skipped, id)
+				continue
+			}
+			if ctx.Err() != nil {
+				return fmt.Errorf("error queueing thumbnail generation: %w", ctx.Err())
+			}
+			log.Error().Err(err).Int64("id", id).Msg("Error queueing thumbnail generation")
+			failed++
+			continue
+		}
+		queued++
+	}
+
+	if len(skipped) > 0 {
+		log.Warn().
+			Ints64("ids", skipped).
+			Msg("Skipped images whose thumbnail generation was already queued or kept after failing")
+	}
+
+	log.Info().
+		Int("images", len(imageIDs)).
+		Int("queued", queued).
+		Int("skipped", len(skipped)).
+		Int("failed", failed).
+		Msg("Finished queueing thumbnail generation")
+
+	if failed > 0 {
+		return fmt.Errorf("error queueing thumbnail generation for %d of %d images", failed, len(imageIDs))
+	}
+
+	return nil
+}
+
 func (w *Worker) handleReindexPerson(ctx context.Context, task *asynq.Task) error {
 	id := w.decodeIdPayload(task.Payload())
 
@@ -191,3 +590,416 @@ func (w *Worker) handleReindexTag(ctx context.Context, task *asynq.Task) error {
 
 	return nil
 }
+
+func (w *Worker) handleVerifyImages(ctx context.Context, task *asynq.Task) error {
+	log.Info().Msg("Executing verification job for all images")
+
+	imageIDs, err := w.imageRepository.GetAllIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting image IDs: %w", err)
+	}
+
+	var corrupted, failed int
+	for _, id := range imageIDs {
+		image, err := w.imageRepository.GetByID(ctx, id)
+		if err != nil {
+			log.Error().Err(err).Int64("id", id).Msg("Error retrieving image for verification")
+			failed++
+			continue
+		}
+
+		verification, err := w.imageRepository.Verify(ctx, image)
+		if err != nil {
+			log.Error().Err(err).Str("uuid", image.UUID).Msg("Error verifying image")
+			failed++
+			continue
+		}
+
+		if !verification.Valid {
+			log.Warn().
+				Str("uuid", image.UUID).
+				Str("expected_md5", verification.ExpectedMD5).
+				Str("actual_md5", verification.ActualMD5).
+				Str("expected_sha1", verification.ExpectedSHA1).
+				Str("actual_sha1", verification.ActualSHA1).
+				Msg("Stored image object does not match recorded hashes")
+			corrupted++
+		}
+	}
+
+	log.Info().
+		Int("total", len(imageIDs)).
+		Int("corrupted", corrupted).
+		Int("failed", failed).
+		Msg("Finished verifying images")
+
+	return nil
+}
+
+func (w *Worker) handleEnrichSource(ctx context.Context, task *asynq.Task) error {
+	var payload tasks.EnrichSourcePayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("error decoding source enrichment payload: %v: %w", err, asynq.SkipRetry)
+	}
+
+	log.Info().Int64("image_id", payload.ImageID).Str("url", payload.URL).Msg("Executing enrichment job for image source")
+
+	body, contentType, err := w.fetcher.Get(ctx, payload.URL)
+	if err != nil {
+		// Blocked destinations and invalid or oversized pages will not succeed on retry
+		if errors.Is(err, fetch.ErrBlockedAddress) || errors.Is(err, fetch.ErrInvalidURL) || errors.Is(err, fetch.ErrTooLarge) {
+			log.Warn().Err(err).Str("url", payload.URL).Msg("Skipping enrichment of image source")
+			return nil
+		}
+		return fmt.Errorf("error fetching source: %w", err)
+	}
+
+	if !strings.Contains(contentType, "html") {
+		log.Debug().Str("url", payload.URL).Str("content_type", contentType).Msg("Image source is not an HTML page, skipping enrichment")
+		return nil
+	}
+
+	metadata := fetch.ExtractPageMetadata(body)
+
+	updated, err := w.imageRepository.EnrichSource(ctx, payload.ImageID, payload.URL, metadata.Title, metadata.Description)
+	if err != nil {
+		return fmt.Errorf("error enriching source: %w", err)
+	}
+
+	// Sources are part of the indexed document, so refresh it when anything changed
+	if updated {
+		if err := w.EnqueueReindexImage(ctx, payload.ImageID); err != nil {
+			log.Error().Err(err).Int64("id", payload.ImageID).Msg("Error reindexing image after source enrichment")
+		}
+	}
+
+	return nil
+}
+
+func (w *Worker) handleReconcileVectors(ctx context.Context, task *asynq.Task) error {
+	log.Info().Msg("Executing vector reconciliation job")
+
+	result, err := w.imageRepository.ReconcileVectors(ctx)
+	if err != nil {
+		return fmt.Errorf("error reconciling vectors: %w", err)
+	}
+
+	log.Info().
+		Int("points", result.Points).
+		Int("images", result.Images).
+		Int("orphans_deleted", result.OrphansDeleted).
+		Int("vectors_added", result.VectorsAdded).
+		Int("failed", result.Failed).
+		Int("degenerate", result.Degenerate).
+		Msg("Finished reconciling vectors")
+
+	return nil
+}
+
+func (w *Worker) handleRebalanceTags(ctx context.Context, task *asynq.Task) error {
+	log.Info().Msg("Executing tag position rebalancing job")
+
+	moved, err := w.tagService.RebalancePositions(ctx)
+	if err != nil {
+		return fmt.Errorf("error rebalancing tags: %w", err)
+	}
+
+	log.Info().Int("moved", moved).Msg("Finished rebalancing tag positions")
+
+	return nil
+}
+
+func (w *Worker) handleRebuildTagClosure(ctx context.Context, task *asynq.Task) error {
+	log.Info().Msg("Executing tag closure rebuild job")
+
+	report, err := w.tagService.RebuildClosure(ctx)
+	if err != nil {
+		return fmt.Errorf("error rebuilding tag closure: %w", err)
+	}
+
+	if report.Consistent {
+		log.Info().Msg("Tag closure was consistent, nothing to rebuild")
+		return nil
+	}
+
+	log.Warn().
+		Int64("missing", report.Missing).
+		Int64("extraneous", report.Extraneous).
+		Int64("tags", report.Tags).
+		Msg("Rebuilt inconsistent tag closure")
+
+	return nil
+}
+
+func (w *Worker) handleFlushImageViews(ctx context.Context, task *asynq.Task) error {
+	log.Debug().Msg("Executing image view flush job")
+
+	var flushed int
+	err := w.imageViews.Flush(ctx, func(counts map[string]int64) error {
+		flushed = len(counts)
+		return w.imageRepository.AddViewCounts(ctx, counts)
+	})
+	if err != nil {
+		return fmt.Errorf("error flushing image views: %w", err)
+	}
+
+	if flushed > 0 {
+		log.Info().Int("images", flushed).Msg("Flushed image view counts")
+	}
+
+	return nil
+}
+
+// rebuildProgressInterval is the number of documents between progress updates of an
+// index rebuild
+const rebuildProgressInterval = 100
+
+// rebuildCancelPollInterval is how often a running index rebuild checks whether it has
+// been cancelled
+const rebuildCancelPollInterval = time.Second
+
+func (w *Worker) handleRebuildIndex(ctx context.Context, task *asynq.Task) error {
+	var payload tasks.RebuildIndexPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("error decoding index rebuild payload: %v: %w", err, asynq.SkipRetry)
+	}
+
+	log.Info().Str("job_id", payload.JobID).Str("index", payload.Index).Msg("Executing index rebuild job")
+
+	if err := w.reindexJobs.Start(ctx, payload.JobID); err != nil {
+		log.Error().Err(err).Str("job_id", payload.JobID).Msg("Error recording start of index rebuild")
+	}
+
+	err := w.rebuildIndex(ctx, payload)
+
+	if finishErr := w.reindexJobs.Finish(ctx, payload.JobID, err); finishErr != nil {
+		log.Error().Err(finishErr).Str("job_id", payload.JobID).Msg("Error recording end of index rebuild")
+	}
+
+	if errors.Is(err, cache.ErrReindexJobCancelled) {
+		log.Info().Str("job_id", payload.JobID).Str("index", payload.Index).Msg("Cancelled index rebuild")
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("error rebuilding index %s: %w", payload.Index, err)
+	}
+
+	log.Info().Str("job_id", payload.JobID).Str("index", payload.Index).Msg("Finished rebuilding index")
+
+	return nil
+}
+
+// rebuildIndex populates a new version of an index from the database, throttled to the
+// rate recorded with the job, and swaps it in for the live index once complete. The live
+// index keeps serving until then. A cancellation request stops the rebuild between
+// documents with ErrReindexJobCancelled as the error, and the new version is discarded,
+// as it is on failure.
+//
+// The new version is registered while it is rebuilt, so that documents written or
+// deleted in the meantime reach it as well as the live index. The rebuild only adds
+// documents that are absent, leaving those newer writes in place, and documents deleted
+// during the rebuild are deleted from the new version again before it is swapped in, in
+// case the rebuild read them first.
+func (w *Worker) rebuildIndex(ctx context.Context, payload tasks.RebuildIndexPayload) (err error) {
+	var indexAll func(context.Context, string, models.IndexProgressFunc) error
+	switch payload.Index {
+	case repositories.ImageIndex:
+		// Checkpoints are kept per version and every rebuild creates a new one, so there is
+		// never an earlier run to resume
+		indexAll = func(ctx context.Context, index string, progress models.IndexProgressFunc) error {
+			return w.imageRepository.IndexAll(ctx, index, progress, true)
+		}
+	case search.PeopleIndex:
+		indexAll = w.personService.IndexAll
+	case search.TagIndex:
+		indexAll = w.tagService.IndexAll
+	default:
+		return storage.ErrUnknownIndex
+	}
+
+	// A job cancelled while queued never touches the index
+	itemsPerSecond := w.container.Config.ReindexItemsPerSecond
+	if job, err := w.reindexJobs.Get(ctx, payload.JobID); err != nil {
+		log.Warn().Err(err).Str("job_id", payload.JobID).Msg("Error retrieving index rebuild job, using the configured throttle")
+	} else if job.CancelRequested {
+		return cache.ErrReindexJobCancelled
+	} else {
+		itemsPerSecond = job.ItemsPerSecond
+	}
+
+	version, err := w.container.Elastic.CreateIndexVersion(ctx, payload.Index, w.container.TextAnalysis())
+	if err != nil {
+		return err
+	}
+
+	// Until it is swapped in, the new version is only of use to this rebuild. It stops
+	// receiving writes before it is discarded, and its checkpoint goes with it.
+	parent := ctx
+	defer func() {
+		if endErr := w.indexRebuilds.End(parent, payload.Index, version); endErr != nil {
+			log.Error().Err(endErr).Str("job_id", payload.JobID).Str("index", version).Msg("Error unregistering index rebuild")
+		}
+		if err != nil {
+			if deleteErr := w.container.Elastic.DeleteIndex(parent, version); deleteErr != nil {
+				log.Error().Err(deleteErr).Str("job_id", payload.JobID).Str("index", version).Msg("Error discarding index from unfinished rebuild")
+			}
+			if clearErr := w.checkpoints.Clear(parent, version); clearErr != nil {
+				log.Error().Err(clearErr).Str("job_id", payload.JobID).Str("index", version).Msg("Error clearing checkpoint of unfinished rebuild")
+			}
+		}
+	}()
+
+	if err := w.indexRebuilds.Begin(ctx, payload.Index, version); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go w.watchReindexCancellation(ctx, payload.JobID, cancel)
+
+	var limiter *rate.Limiter
+	if itemsPerSecond > 0 {
+		limiter = rate.NewLimiter(rate.Limit(itemsPerSecond), 1)
+	}
+
+	// Progress is reported after every document, so waiting on the limiter here paces the
+	// whole run. Progress is recorded periodically rather than after every document, with
+	// the parent context so that the last report survives a cancellation.
+	err = indexAll(ctx, version, func(total, processed, failed int) {
+		done := processed + failed
+		if done%rebuildProgressInterval == 0 || done == total {
+			if err := w.reindexJobs.Progress(parent, payload.JobID, total, processed, failed); err != nil {
+				log.Warn().Err(err).Str("job_id", payload.JobID).Msg("Error recording index rebuild progress")
+			}
+		}
+
+		// A wait cut short by cancellation is noticed by the reindex itself
+		if limiter != nil {
+			_ = limiter.Wait(ctx)
+		}
+	})
+
+	if errors.Is(context.Cause(ctx), cache.ErrReindexJobCancelled) {
+		return cache.ErrReindexJobCancelled
+	}
+	if err != nil {
+		return err
+	}
+
+	// Delete again what was deleted during the rebuild, as the rebuild may have written it
+	// back from an earlier read. Deletions from now on reach the new version directly.
+	deleted, err := w.indexRebuilds.Deletions(parent, version)
+	if err != nil {
+		return err
+	}
+	if err := w.container.Elastic.DeleteDocuments(parent, version, deleted); err != nil {
+		return err
+	}
+
+	return w.container.Elastic.SwapIndex(parent, payload.Index, version)
+}
+
+// watchReindexCancellation polls a reindex job until ctx is done, cancelling ctx with
+// ErrReindexJobCancelled once the job has been asked to stop
+func (w *Worker) watchReindexCancellation(ctx context.Context, jobID string, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(rebuildCancelPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job, err := w.reindexJobs.Get(ctx, jobID)
+			if err != nil {
+				log.Warn().Err(err).Str("job_id", jobID).Msg("Error checking index rebuild for cancellation")
+				continue
+			}
+			if job.CancelRequested {
+				cancel(cache.ErrReindexJobCancelled)
+				return
+			}
+		}
+	}
+}
+
+// summaryPageSize is the number of tasks listed at a time when counting tasks by type
+const summaryPageSize = 1000
+
+// summaryMaxTasks caps the tasks listed per queue and state when counting tasks by type,
+// so that a large backlog cannot make a summary expensive
+const summaryMaxTasks = 10000
+
+func (w *Worker) Summarize(ctx context.Context) (*tasks.Summary, error) {
+	queues, err := w.inspector.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("error listing queues: %w", err)
+	}
+
+	// Report every known type, even without tasks, so that counts do not come and go
+	summary := &tasks.Summary{
+		ByType: make(map[string]*tasks.StateCounts, len(tasks.Types)),
+	}
+	for _, taskType := range tasks.Types {
+		summary.ByType[string(taskType)] = &tasks.StateCounts{}
+	}
+
+	countsFor := func(taskType string) *tasks.StateCounts {
+		counts, ok := summary.ByType[taskType]
+		if !ok {
+			counts = &tasks.StateCounts{}
+			summary.ByType[taskType] = counts
+		}
+		return counts
+	}
+
+	for _, queue := range queues {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		info, err := w.inspector.GetQueueInfo(queue)
+		if err != nil {
+			return nil, fmt.Errorf("error inspecting queue %s: %w", queue, err)
+		}
+
+		summary.Total.Pending += info.Pending
+		summary.Total.Active += info.Active
+		summary.Total.Failed += info.Retry + info.Archived
+
+		states := []struct {
+			size  int
+			list  func(string, ...asynq.ListOption) ([]*asynq.TaskInfo, error)
+			count func(*tasks.StateCounts)
+		}{
+			{info.Pending, w.inspector.ListPendingTasks, func(c *tasks.StateCounts) { c.Pending++ }},
+			{info.Active, w.inspector.ListActiveTasks, func(c *tasks.StateCounts) { c.Active++ }},
+			{info.Retry, w.inspector.ListRetryTasks, func(c *tasks.StateCounts) { c.Failed++ }},
+			{info.Archived, w.inspector.ListArchivedTasks, func(c *tasks.StateCounts) { c.Failed++ }},
+		}
+
+		for _, state := range states {
+			if state.size > summaryMaxTasks {
+				summary.Truncated = true
+			}
+
+			for page := 1; (page-1)*summaryPageSize < min(state.size, summaryMaxTasks); page++ {
+				infos, err := state.list(queue, asynq.Page(page), asynq.PageSize(summaryPageSize))
+				if err != nil {
+					return nil, fmt.Errorf("error listing tasks in queue %s: %w", queue, err)
+				}
+
+				for _, info := range infos {
+					state.count(countsFor(info.Type))
+				}
+
+				if len(infos) < summaryPageSize {
+					break
+				}
+			}
+		}
+	}
+
+	return summary, nil
+}